@@ -0,0 +1,271 @@
+//Package batch verifies many cards at once, for example the cards a workforce sends in for a compliance check.
+//Identical cards are verified once and the report lists every input each was found in
+package batch
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//DefaultConcurrency the number of cards verified at once if not configured, signature checks can fetch keys
+//over the network so it is not tied to the number of CPUs
+const DefaultConcurrency = 8
+
+//dateLayout the layout of the dates in the report
+const dateLayout = "2006-01-02"
+
+//Input a card to verify
+type Input struct {
+
+	//Source where the card came from, for example a file name or CSV row, reported with the result
+	Source string
+
+	//Card the card as presented, a shc:/ QR code, .smart-health-card file contents, JWS or HC1: code
+	Card string
+}
+
+//Config how to verify a batch, only Verifier is required
+type Config struct {
+
+	//Verifier verifies each card, shared by all the workers
+	Verifier verification.Verifier
+
+	//Concurrency the number of cards verified at once, if zero DefaultConcurrency
+	Concurrency int
+
+	//At verify as of this time, if zero the verifier's time so every card is verified at its own now
+	At time.Time
+}
+
+//Result the verification of a unique card
+type Result struct {
+
+	//Sources where the card was found, more than one if the same card was sent more than once
+	Sources []string `json:"sources"`
+
+	//CardHash identifies the card, see verification.HashCard
+	CardHash string `json:"card_hash,omitempty"`
+
+	//Format the format of the card, empty if it could not be decoded
+	Format string `json:"format,omitempty"`
+
+	//State the card's state, empty if it could not be decoded or verified, see Error
+	State verification.CardVerificationState `json:"state,omitempty"`
+
+	//Reasons the checks that were made and failed, see verification.FailedChecks
+	Reasons []string `json:"reasons,omitempty"`
+
+	//PatientName the patient's given and family names
+	PatientName string `json:"patient_name,omitempty"`
+
+	//LastDoseDate the date of the last administered dose as recorded on the card
+	LastDoseDate string `json:"last_dose_date,omitempty"`
+
+	//ValidFrom the date the card meets the days since last dose criteria, see
+	//verification.ImmunizationVerificationResults ValidFrom
+	ValidFrom string `json:"valid_from,omitempty"`
+
+	//Error why the card could not be decoded or verified
+	Error string `json:"error,omitempty"`
+}
+
+//Summary the counts for a batch
+type Summary struct {
+
+	//Inputs the number of inputs
+	Inputs int `json:"inputs"`
+
+	//Cards the number of unique cards and inputs that could not be decoded
+	Cards int `json:"cards"`
+
+	//Duplicates the number of inputs that were the same card as an earlier input
+	Duplicates int `json:"duplicates"`
+
+	//Errors the number of inputs that could not be decoded and unique cards that could not be verified
+	Errors int `json:"errors"`
+
+	//States the number of unique cards in each state
+	States map[verification.CardVerificationState]int `json:"states"`
+}
+
+//Report the results of a batch in the order the cards were first found
+type Report struct {
+	Summary *Summary  `json:"summary"`
+	Results []*Result `json:"results"`
+}
+
+//item a unique card and where it was found
+type item struct {
+	result *Result
+	card   *verification.Card
+}
+
+//Verify decode and verify the inputs, a card that cannot be decoded or verified is reported in its Result
+//rather than failing the batch. Only cancelling the context stops the batch
+func Verify(ctx context.Context, config *Config, inputs []*Input) (*Report, error) {
+
+	if config == nil || config.Verifier == nil {
+		return nil, fmt.Errorf("error batch verify a verifier is required")
+	}
+
+	concurrency := config.Concurrency
+	if concurrency <= 0 {
+		concurrency = DefaultConcurrency
+	}
+
+	items, duplicates := decode(inputs)
+
+	work := make(chan *item)
+	var wg sync.WaitGroup
+	for i := 0; i < concurrency; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for it := range work {
+				verify(ctx, config, it)
+			}
+		}()
+	}
+
+sendLoop:
+	for _, it := range items {
+		if it.card == nil {
+			continue
+		}
+		select {
+		case work <- it:
+		case <-ctx.Done():
+			break sendLoop
+		}
+	}
+	close(work)
+	wg.Wait()
+
+	if err := ctx.Err(); err != nil {
+		return nil, fmt.Errorf("error batch verify err=%s", err)
+	}
+
+	report := &Report{
+		Summary: &Summary{
+			Inputs:     len(inputs),
+			Cards:      len(items),
+			Duplicates: duplicates,
+			States:     make(map[verification.CardVerificationState]int),
+		},
+		Results: make([]*Result, 0, len(items)),
+	}
+
+	for _, it := range items {
+		if it.result.Error != "" {
+			report.Summary.Errors++
+		} else {
+			report.Summary.States[it.result.State]++
+		}
+		report.Results = append(report.Results, it.result)
+	}
+
+	return report, nil
+}
+
+//decode the inputs into unique cards in the order first found, a card is identified by its hash so the same card
+//as a QR code and a file is verified once. Each input that cannot be decoded is reported on its own so bad rows
+//are not hidden as duplicates
+func decode(inputs []*Input) ([]*item, int) {
+
+	items := make([]*item, 0, len(inputs))
+	byKey := make(map[string]*item)
+	duplicates := 0
+
+	for _, input := range inputs {
+
+		card, format, err := pipeline.Decode(input.Card)
+		if err != nil {
+			items = append(items, &item{result: &Result{Sources: []string{input.Source}, Error: err.Error()}})
+			continue
+		}
+
+		key := verification.HashCard(card)
+		if it, ok := byKey[key]; ok {
+			it.result.Sources = append(it.result.Sources, input.Source)
+			duplicates++
+			continue
+		}
+
+		it := &item{
+			card: card,
+			result: &Result{
+				Sources:      []string{input.Source},
+				CardHash:     key,
+				Format:       string(format),
+				PatientName:  strings.TrimSpace(card.PatientGivenName + " " + card.PatientFamilyName),
+				LastDoseDate: lastDoseDate(card.Doses),
+			},
+		}
+
+		byKey[key] = it
+		items = append(items, it)
+	}
+
+	return items, duplicates
+}
+
+//verify the item's card and fill in its result
+func verify(ctx context.Context, config *Config, it *item) {
+
+	var results *verification.CardVerificationResults
+	var err error
+	if config.At.IsZero() {
+		results, err = config.Verifier.Verify(ctx, it.card)
+	} else {
+		results, err = config.Verifier.VerifyAt(ctx, it.card, config.At)
+	}
+
+	if err != nil {
+		it.result.Error = err.Error()
+		return
+	}
+
+	it.result.State = results.State
+	it.result.Reasons = verification.FailedChecks(results)
+	if results.Immunization != nil && results.Immunization.ValidFrom != nil {
+		it.result.ValidFrom = results.Immunization.ValidFrom.UTC().Format(dateLayout)
+	}
+}
+
+//lastDoseDate the date of the latest administered dose as recorded, empty if no dose has a date
+func lastDoseDate(doses []*pdm.Dose) string {
+
+	var last *pdm.Dose
+	var lastOccurrence *pdm.DateTime
+	for _, dose := range doses {
+
+		if !dose.Administered() {
+			continue
+		}
+
+		occurrence := pdm.DoseOccurrence(dose)
+		if occurrence == nil {
+			continue
+		}
+
+		if lastOccurrence == nil || occurrence.Latest().After(lastOccurrence.Latest()) {
+			last = dose
+			lastOccurrence = occurrence
+		}
+	}
+
+	switch {
+	case last == nil:
+		return ""
+	case last.OccurrenceDateTime != "":
+		return last.OccurrenceDateTime
+	}
+	return last.OccurrenceString
+}
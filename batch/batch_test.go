@@ -0,0 +1,239 @@
+package batch_test
+
+import (
+	"archive/zip"
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/batch"
+	"github.com/webshield-dev/dhc-common/dcc"
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Batch(t *testing.T) {
+
+	card, err := os.ReadFile("../testdata/card.smart-health-card")
+	require.NoError(t, err)
+	qr, err := os.ReadFile("../testdata/card.qr.txt")
+	require.NoError(t, err)
+	certificate, err := os.ReadFile("../testdata/certificate.hc1.txt")
+	require.NoError(t, err)
+
+	dir := t.TempDir()
+	writeFile(t, filepath.Join(dir, "card.smart-health-card"), card)
+	writeFile(t, filepath.Join(dir, "notes.pdf"), []byte("not a card"))
+	writeFile(t, filepath.Join(dir, "people.csv"), []byte("name,card\n"+
+		"John,"+strings.TrimSpace(string(qr))+"\n"+
+		"Erika,\""+strings.TrimSpace(string(certificate))+"\"\n"+
+		"Missing,\n"))
+
+	var zipped bytes.Buffer
+	zw := zip.NewWriter(&zipped)
+	for name, content := range map[string][]byte{
+		"cards/john.smart-health-card":            card,
+		"cards/junk.txt":                          []byte("=cmd|' /C calc'!A0"),
+		"__MACOSX/cards/._john.smart-health-card": []byte("resource fork"),
+	} {
+		w, err := zw.Create(name)
+		require.NoError(t, err)
+		_, err = w.Write(content)
+		require.NoError(t, err)
+	}
+	require.NoError(t, zw.Close())
+	writeFile(t, filepath.Join(dir, "cards.zip"), zipped.Bytes())
+
+	inputs, err := batch.ReadPaths(dir)
+	require.NoError(t, err)
+	sources := make([]string, 0, len(inputs))
+	for _, input := range inputs {
+		sources = append(sources, strings.TrimPrefix(input.Source, dir+"/"))
+	}
+	require.Equal(t, []string{
+		"card.smart-health-card",
+		"cards.zip/cards/john.smart-health-card",
+		"cards.zip/cards/junk.txt",
+		"people.csv:2",
+		"people.csv:3",
+		"people.csv:4",
+	}, sources)
+
+	verifier := makeTestVerifier(t, "eu")
+	report, err := batch.Verify(context.Background(), &batch.Config{
+		Verifier:    verifier,
+		Concurrency: 3,
+		At:          time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC),
+	}, inputs)
+	require.NoError(t, err)
+
+	t.Run("should deduplicate identical cards", func(t *testing.T) {
+		require.Equal(t, 6, report.Summary.Inputs)
+		require.Equal(t, 4, report.Summary.Cards)
+		require.Equal(t, 2, report.Summary.Duplicates)
+		require.Len(t, report.Results, 4)
+		require.Len(t, report.Results[0].Sources, 3)
+	})
+
+	t.Run("should report each card", func(t *testing.T) {
+		shcResult := report.Results[0]
+		require.Equal(t, "shc", shcResult.Format)
+		require.Equal(t, "John B. Anyperson", shcResult.PatientName)
+		require.Equal(t, "2021-01-29", shcResult.LastDoseDate)
+		require.NotEmpty(t, shcResult.State)
+		require.Empty(t, shcResult.Error)
+
+		dccResult := report.Results[2]
+		require.Equal(t, "dcc", dccResult.Format)
+		require.Equal(t, verification.CardVerificationStateValid, dccResult.State)
+		require.Empty(t, dccResult.Reasons)
+		require.Equal(t, "2021-06-01", dccResult.LastDoseDate)
+		require.Equal(t, "2021-06-16", dccResult.ValidFrom)
+	})
+
+	t.Run("should report cards that cannot be decoded", func(t *testing.T) {
+		require.Equal(t, 2, report.Summary.Errors)
+		require.NotEmpty(t, report.Results[1].Error)
+		require.Equal(t, []string{filepath.Join(dir, "people.csv") + ":4"}, report.Results[3].Sources)
+		require.NotEmpty(t, report.Results[3].Error)
+	})
+
+	t.Run("should count states", func(t *testing.T) {
+		total := 0
+		for _, count := range report.Summary.States {
+			total += count
+		}
+		require.Equal(t, 2, total)
+		require.Equal(t, 2, report.Summary.States[verification.CardVerificationStateValid])
+	})
+
+	t.Run("should write csv", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, report.WriteCSV(&out))
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		require.Len(t, lines, 5)
+		require.True(t, strings.HasPrefix(lines[0], "sources,card_hash,format,state,reasons,patient_name"))
+		require.Contains(t, lines[3], ",dcc,valid,,")
+	})
+
+	t.Run("should write json", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, report.WriteJSON(&out))
+		var decoded batch.Report
+		require.NoError(t, json.Unmarshal(out.Bytes(), &decoded))
+		require.Equal(t, report.Summary, decoded.Summary)
+	})
+
+	t.Run("should write the summary", func(t *testing.T) {
+		var out bytes.Buffer
+		require.NoError(t, report.WriteSummary(&out))
+		require.Contains(t, out.String(), "Duplicates: 2")
+		require.Contains(t, out.String(), "valid:")
+	})
+
+	t.Run("should stop when cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		_, err := batch.Verify(ctx, &batch.Config{Verifier: verifier}, inputs)
+		require.Error(t, err)
+	})
+
+	t.Run("should require a verifier", func(t *testing.T) {
+		_, err := batch.Verify(context.Background(), &batch.Config{}, inputs)
+		require.Error(t, err)
+	})
+}
+
+func Test_BatchReasons(t *testing.T) {
+
+	data, err := os.ReadFile("../testdata/dcc-signer-es256.pem")
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	signer, err := dcc.NewSigner("XA", key.(crypto.Signer), nil)
+	require.NoError(t, err)
+
+	//the first dose of a two dose series
+	payload, err := dcc.MakePayload(&dcc.Record{
+		Person: &dcc.Person{GivenName: "Jane", FamilyName: "Doe", DateOfBirth: "1980-01-01"},
+		Doses: []*pdm.Dose{{
+			Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"},
+			OccurrenceDateTime: "2021-04-06",
+		}},
+		DoseNumber: 1,
+		Country:    "XA",
+	}, nil)
+	require.NoError(t, err)
+	certificate, err := signer.Sign(payload, time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC), time.Time{})
+	require.NoError(t, err)
+
+	report, err := batch.Verify(context.Background(), &batch.Config{
+		Verifier: makeTestVerifier(t, "eu", "../testdata/dcc-test-trust.json"),
+		At:       time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+	}, []*batch.Input{{Source: "one-dose", Card: certificate.QR}})
+	require.NoError(t, err)
+	require.Len(t, report.Results, 1)
+
+	result := report.Results[0]
+	require.Equal(t, verification.CardVerificationStateSafetyCriteriaNotMet, result.State)
+	require.Equal(t, []string{verification.AuditRuleDosesRequired}, result.Reasons,
+		"checks that were not made should not be reported")
+}
+
+func Test_BatchUndecodable(t *testing.T) {
+
+	//the same bad text in two rows is two errors, not one error and a duplicate
+	csvData := "name,card\nJohn,shc:/not-a-card\n,,\nErika,shc:/not-a-card\nMissing,\n"
+	inputs, err := batch.ReadCSV(strings.NewReader(csvData), "people.csv")
+	require.NoError(t, err)
+	require.Len(t, inputs, 3, "the blank row should be skipped")
+
+	report, err := batch.Verify(context.Background(), &batch.Config{Verifier: makeTestVerifier(t, "eu")}, inputs)
+	require.NoError(t, err)
+
+	require.Equal(t, 3, report.Summary.Inputs)
+	require.Equal(t, 3, report.Summary.Cards)
+	require.Equal(t, 0, report.Summary.Duplicates)
+	require.Equal(t, 3, report.Summary.Errors)
+
+	require.Len(t, report.Results, 3)
+	for i, source := range []string{"people.csv:2", "people.csv:4", "people.csv:5"} {
+		require.Equal(t, []string{source}, report.Results[i].Sources)
+		require.NotEmpty(t, report.Results[i].Error)
+	}
+}
+
+//makeTestVerifier a verifier for the policy trusting the test issuers and any other trust files
+func makeTestVerifier(t *testing.T, policyID string, trustPaths ...string) verification.Verifier {
+
+	policies, err := pipeline.SelectPolicies(policyID)
+	require.NoError(t, err)
+	trust, err := pipeline.LoadTrustPaths(append([]string{"../testdata/trust.json"}, trustPaths...)...)
+	require.NoError(t, err)
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            policies[0],
+		PolicyVersions:    policies[1:],
+		SignatureVerifier: trust.SignatureVerifier,
+		IssuerTrustStore:  trust.IssuerTrustStore,
+	})
+	require.NoError(t, err)
+	return verifier
+}
+
+func writeFile(t *testing.T, name string, content []byte) {
+	require.NoError(t, os.WriteFile(name, content, 0600))
+}
@@ -0,0 +1,263 @@
+package batch
+
+import (
+	"archive/zip"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/dcc"
+	"github.com/webshield-dev/dhc-common/shc"
+)
+
+//
+// Cards arrive as .smart-health-card files, text files of scanned QR codes, zip files of either, or spreadsheets
+// with a card per row. Spreadsheets must be saved as CSV, any cell holding a shc:/ or HC1: code or card file
+// contents is the row's card.
+//
+
+const (
+	//MaxCardSize the largest card file read, a card is a few KB
+	MaxCardSize = 1 << 20
+
+	//MaxInputs the most cards read from the files of a batch, it bounds the work a zip file can cause
+	MaxInputs = 100000
+)
+
+const (
+	textExtension = ".txt"
+	zipExtension  = ".zip"
+	csvExtension  = ".csv"
+)
+
+//ReadPaths the cards in the files, a path can be a card file, a text file of QR codes, a zip or CSV file or a
+//directory of them. Directories are read recursively and files with other extensions are ignored
+func ReadPaths(paths ...string) ([]*Input, error) {
+
+	inputs := make([]*Input, 0)
+	for _, p := range paths {
+
+		info, err := os.Stat(p)
+		if err != nil {
+			return nil, fmt.Errorf("error read cards path=%s err=%s", p, err)
+		}
+
+		files := []string{p}
+		if info.IsDir() {
+			if files, err = cardFiles(p); err != nil {
+				return nil, err
+			}
+		}
+
+		for _, file := range files {
+			read, err := readFile(file)
+			if err != nil {
+				return nil, err
+			}
+			if inputs = append(inputs, read...); len(inputs) > MaxInputs {
+				return nil, fmt.Errorf("error read cards more than %d cards", MaxInputs)
+			}
+		}
+	}
+
+	return inputs, nil
+}
+
+//cardFiles the files under the directory that can hold cards in name order
+func cardFiles(dir string) ([]string, error) {
+
+	files := make([]string, 0)
+	err := filepath.Walk(dir, func(p string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() && p != dir && ignored(info.Name()) {
+			return filepath.SkipDir
+		}
+		if !info.IsDir() && !ignored(info.Name()) && hasCardExtension(p, true) {
+			files = append(files, p)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error read cards dir=%s err=%s", dir, err)
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+//readFile the cards in a file of any of the supported kinds
+func readFile(file string) ([]*Input, error) {
+
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("error read cards file=%s err=%s", file, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	switch strings.ToLower(filepath.Ext(file)) {
+	case zipExtension:
+		info, err := f.Stat()
+		if err != nil {
+			return nil, fmt.Errorf("error read cards file=%s err=%s", file, err)
+		}
+		return ReadZip(f, info.Size(), file)
+	case csvExtension:
+		return ReadCSV(f, file)
+	}
+
+	card, err := readCard(f, file)
+	if err != nil {
+		return nil, err
+	}
+	return []*Input{{Source: file, Card: card}}, nil
+}
+
+//ReadZip the cards in the card, text and CSV files in a zip file, the source of each is the zip file name and
+//the name of the file in the zip
+func ReadZip(r io.ReaderAt, size int64, name string) ([]*Input, error) {
+
+	zr, err := zip.NewReader(r, size)
+	if err != nil {
+		return nil, fmt.Errorf("error read cards zip=%s err=%s", name, err)
+	}
+
+	files := make([]*zip.File, 0, len(zr.File))
+	for _, file := range zr.File {
+		if !file.FileInfo().IsDir() && !ignoredZipPath(file.Name) && hasCardExtension(file.Name, false) {
+			files = append(files, file)
+		}
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].Name < files[j].Name })
+
+	inputs := make([]*Input, 0, len(files))
+	for _, file := range files {
+
+		read, err := readZipFile(file, name+"/"+file.Name)
+		if err != nil {
+			return nil, err
+		}
+
+		if inputs = append(inputs, read...); len(inputs) > MaxInputs {
+			return nil, fmt.Errorf("error read cards zip=%s has more than %d cards", name, MaxInputs)
+		}
+	}
+
+	return inputs, nil
+}
+
+func readZipFile(file *zip.File, source string) ([]*Input, error) {
+
+	f, err := file.Open()
+	if err != nil {
+		return nil, fmt.Errorf("error read cards file=%s err=%s", source, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	if strings.ToLower(path.Ext(file.Name)) == csvExtension {
+		return ReadCSV(io.LimitReader(f, MaxInputs*MaxCardSize), source)
+	}
+
+	card, err := readCard(f, source)
+	if err != nil {
+		return nil, err
+	}
+	return []*Input{{Source: source, Card: card}}, nil
+}
+
+//ReadCSV the card in each row of a spreadsheet saved as CSV, the source of each is the name and row number. A
+//first row without a card is taken to be a header and skipped, blank rows are skipped and a later row without
+//a card is reported as an input with no card so it is not missed
+func ReadCSV(r io.Reader, name string) ([]*Input, error) {
+
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.LazyQuotes = true
+
+	inputs := make([]*Input, 0)
+	for row := 1; ; row++ {
+
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("error read cards csv=%s err=%s", name, err)
+		}
+
+		card := ""
+		empty := true
+		for _, cell := range record {
+			cell = strings.TrimSpace(cell)
+			if cell != "" {
+				empty = false
+			}
+			if isCard(cell) {
+				card = cell
+				break
+			}
+		}
+
+		if empty || (card == "" && row == 1) {
+			continue
+		}
+
+		if inputs = append(inputs, &Input{Source: fmt.Sprintf("%s:%d", name, row), Card: card}); len(inputs) > MaxInputs {
+			return nil, fmt.Errorf("error read cards csv=%s has more than %d cards", name, MaxInputs)
+		}
+	}
+
+	return inputs, nil
+}
+
+//readCard a card file, an error if larger than MaxCardSize
+func readCard(r io.Reader, source string) (string, error) {
+
+	data, err := io.ReadAll(io.LimitReader(r, MaxCardSize+1))
+	if err != nil {
+		return "", fmt.Errorf("error read cards file=%s err=%s", source, err)
+	}
+	if len(data) > MaxCardSize {
+		return "", fmt.Errorf("error read cards file=%s is larger than %d bytes", source, MaxCardSize)
+	}
+
+	return string(data), nil
+}
+
+//isCard the text looks like a card, a QR code or card file contents
+func isCard(text string) bool {
+	return strings.Contains(text, shc.QRPrefix) || strings.Contains(text, dcc.Prefix) ||
+		(strings.HasPrefix(text, "{") && strings.Contains(text, "verifiableCredential"))
+}
+
+//hasCardExtension the file can hold cards, zip files cannot be nested
+func hasCardExtension(name string, allowZip bool) bool {
+	switch strings.ToLower(path.Ext(name)) {
+	case shc.FileExtension, textExtension, csvExtension:
+		return true
+	case zipExtension:
+		return allowZip
+	}
+	return false
+}
+
+//ignored hidden files and directories, for example .DS_Store
+func ignored(name string) bool {
+	return strings.HasPrefix(name, ".")
+}
+
+//ignoredZipPath hidden files and the resource forks macOS adds to zip files
+func ignoredZipPath(name string) bool {
+	for _, part := range strings.Split(name, "/") {
+		if ignored(part) || part == "__MACOSX" {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,96 @@
+package batch
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//csvHeader the columns of the CSV report, sources and reasons are separated by ;
+var csvHeader = []string{"sources", "card_hash", "format", "state", "reasons", "patient_name", "last_dose_date",
+	"valid_from", "error"}
+
+//WriteJSON write the report as indented JSON
+func (r *Report) WriteJSON(w io.Writer) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(r); err != nil {
+		return fmt.Errorf("error write report err=%s", err)
+	}
+	return nil
+}
+
+//WriteCSV write a row per unique card, see WriteSummary for the counts. Cells that a spreadsheet would run as a
+//formula are quoted with a leading ' as card contents cannot be trusted
+func (r *Report) WriteCSV(w io.Writer) error {
+
+	writer := csv.NewWriter(w)
+	if err := writer.Write(csvHeader); err != nil {
+		return fmt.Errorf("error write report err=%s", err)
+	}
+
+	for _, result := range r.Results {
+		record := []string{
+			strings.Join(result.Sources, ";"),
+			result.CardHash,
+			result.Format,
+			string(result.State),
+			strings.Join(result.Reasons, ";"),
+			result.PatientName,
+			result.LastDoseDate,
+			result.ValidFrom,
+			result.Error,
+		}
+		for i := range record {
+			record[i] = escapeFormula(record[i])
+		}
+		if err := writer.Write(record); err != nil {
+			return fmt.Errorf("error write report err=%s", err)
+		}
+	}
+
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		return fmt.Errorf("error write report err=%s", err)
+	}
+	return nil
+}
+
+//WriteSummary write the counts as text, a line each with the states in name order
+func (r *Report) WriteSummary(w io.Writer) error {
+
+	s := r.Summary
+	lines := []string{
+		fmt.Sprintf("Inputs:     %d", s.Inputs),
+		fmt.Sprintf("Cards:      %d", s.Cards),
+		fmt.Sprintf("Duplicates: %d", s.Duplicates),
+		fmt.Sprintf("Errors:     %d", s.Errors),
+	}
+
+	states := make([]string, 0, len(s.States))
+	for state := range s.States {
+		states = append(states, string(state))
+	}
+	sort.Strings(states)
+	for _, state := range states {
+		lines = append(lines, fmt.Sprintf("  %-24s %d", state+":", s.States[verification.CardVerificationState(state)]))
+	}
+
+	if _, err := fmt.Fprintln(w, strings.Join(lines, "\n")); err != nil {
+		return fmt.Errorf("error write summary err=%s", err)
+	}
+	return nil
+}
+
+//escapeFormula prefix a cell starting with a formula character with ', see OWASP CSV injection
+func escapeFormula(cell string) string {
+	if cell != "" && strings.ContainsAny(cell[:1], "=+-@\t\r") {
+		return "'" + cell
+	}
+	return cell
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/batch"
+)
+
+const (
+	reportFormatCSV  = "csv"
+	reportFormatJSON = "json"
+)
+
+//batchOptions the flags used by a batch
+type batchOptions struct {
+	paths        []string
+	policy       string
+	trustPaths   []string
+	metadataPath string
+	lotsPath     string
+	at           time.Time
+	reportFormat string
+	concurrency  int
+}
+
+//runBatch verify the cards in the paths and print the report, the summary counts are printed to stderr for a
+//CSV report so the report can be redirected to a file
+func runBatch(opts *batchOptions, stdout io.Writer, stderr io.Writer) int {
+
+	if opts.reportFormat != reportFormatCSV && opts.reportFormat != reportFormatJSON {
+		fmt.Fprintf(stderr, "error -report must be %s or %s got=%s\n", reportFormatCSV, reportFormatJSON, opts.reportFormat)
+		return exitUsage
+	}
+
+	inputs, err := batch.ReadPaths(opts.paths...)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	verifier, err := makeVerifier(opts.policy, opts.trustPaths, opts.metadataPath, opts.lotsPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	report, err := batch.Verify(context.Background(), &batch.Config{
+		Verifier:    verifier,
+		Concurrency: opts.concurrency,
+		At:          opts.at,
+	}, inputs)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if opts.reportFormat == reportFormatJSON {
+		err = report.WriteJSON(stdout)
+	} else if err = report.WriteCSV(stdout); err == nil {
+		err = report.WriteSummary(stderr)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	return 0
+}
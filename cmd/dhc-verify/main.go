@@ -0,0 +1,233 @@
+//Command dhc-verify verifies a SMART Health Card or EU Digital COVID Certificate as presented and prints the
+//verification results, so a card can be checked without building an app.
+//
+//	dhc-verify [flags] [card]
+//	dhc-verify -batch [flags] path...
+//
+//The card is a file, the text of a QR code, JWS or HC1: code, or - or nothing to read standard input. The exit
+//code is the card's state, see exitCodes. With -batch the paths are card, zip and CSV files or directories of
+//them, a report of every card is printed and the exit code is 0 if it could be written
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/batch"
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+const (
+	//exitError the card could not be read or verified
+	exitError = 1
+
+	//exitUsage the flags are invalid
+	exitUsage = 2
+
+	//maxInputSize a card file is a few KB
+	maxInputSize = 1 << 20
+)
+
+//exitCodes the exit code for each state, valid is 0 so scripts can test for success
+var exitCodes = map[verification.CardVerificationState]int{
+	verification.CardVerificationStateValid:                0,
+	verification.CardVerificationStateUnknown:              10,
+	verification.CardVerificationStateCorrupt:              11,
+	verification.CardVerificationStateSafetyCriteriaNotMet: 12,
+	verification.CardVerificationStatePaperCard:            13,
+	verification.CardVerificationStateUnVerified:           14,
+	verification.CardVerificationStateIssuerUnknown:        15,
+	verification.CardVerificationStateNotYetValid:          16,
+	verification.CardVerificationStateExpired:              17,
+}
+
+//stringList a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+//run verify the card and return the exit code
+func run(args []string, stdin io.Reader, stdout io.Writer, stderr io.Writer) int {
+
+	flags := flag.NewFlagSet("dhc-verify", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+
+	var trustPaths stringList
+	policy := flags.String("policy", "usa", fmt.Sprintf("a built in policy %v or a policy file", pipeline.BuiltInPolicyIDs()))
+	flags.Var(&trustPaths, "trust", "a trust file of trusted issuers and their keys, can be repeated")
+	metadataPath := flags.String("metadata", "", "a vaccine metadata file or directory, the built in metadata if not set")
+	lotsPath := flags.String("lots", "", "a lot registry file, lots are not checked if not set")
+	at := flags.String("at", "", "verify as of an RFC 3339 time rather than now, to reproduce a past decision")
+	jsonOutput := flags.Bool("json", false, "print the results as JSON")
+	noColor := flags.Bool("no-color", false, "do not color the summary, also set by the NO_COLOR environment variable")
+	batchMode := flags.Bool("batch", false, "verify the cards in card, zip and CSV files or directories and print a report")
+	reportFormat := flags.String("report", reportFormatCSV, "the batch report format, csv or json")
+	concurrency := flags.Int("concurrency", batch.DefaultConcurrency, "the number of batch cards verified at once")
+
+	flags.Usage = func() {
+		fmt.Fprintf(stderr, "usage: dhc-verify [flags] [card file, QR code, JWS or HC1: code, - for stdin]\n")
+		fmt.Fprintf(stderr, "       dhc-verify -batch [flags] path...\n")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return exitUsage
+	}
+
+	if (!*batchMode && flags.NArg() > 1) || (*batchMode && flags.NArg() == 0) {
+		flags.Usage()
+		return exitUsage
+	}
+
+	verificationTime := time.Now()
+	if *at != "" {
+		var err error
+		if verificationTime, err = time.Parse(time.RFC3339, *at); err != nil {
+			fmt.Fprintf(stderr, "error -at must be an RFC 3339 time err=%s\n", err)
+			return exitUsage
+		}
+	}
+
+	if *batchMode {
+		return runBatch(&batchOptions{
+			paths:        flags.Args(),
+			policy:       *policy,
+			trustPaths:   trustPaths,
+			metadataPath: *metadataPath,
+			lotsPath:     *lotsPath,
+			at:           verificationTime,
+			reportFormat: *reportFormat,
+			concurrency:  *concurrency,
+		}, stdout, stderr)
+	}
+
+	input, err := readInput(flags.Arg(0), stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	card, format, err := pipeline.Decode(input)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	verifier, err := makeVerifier(*policy, trustPaths, *metadataPath, *lotsPath)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	results, err := verifier.VerifyAt(context.Background(), card, verificationTime)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	if *jsonOutput {
+		err = writeJSON(stdout, results)
+	} else {
+		_, color := os.LookupEnv("NO_COLOR")
+		err = writeSummary(stdout, format, card, results, !*noColor && !color)
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return exitError
+	}
+
+	code, ok := exitCodes[results.State]
+	if !ok {
+		return exitError
+	}
+	return code
+}
+
+//readInput the argument is a file if one exists with its name, otherwise the card itself
+func readInput(arg string, stdin io.Reader) (string, error) {
+
+	var r io.Reader
+	switch {
+	case arg == "" || arg == "-":
+		r = stdin
+	default:
+		f, err := os.Open(filepath.Clean(arg))
+		if os.IsNotExist(err) || errors.Is(err, syscall.ENAMETOOLONG) {
+			return arg, nil
+		}
+		if err != nil {
+			return "", fmt.Errorf("error read card file=%s err=%s", arg, err)
+		}
+		defer func() { _ = f.Close() }()
+		r = f
+	}
+
+	data, err := io.ReadAll(io.LimitReader(r, maxInputSize+1))
+	if err != nil {
+		return "", fmt.Errorf("error read card err=%s", err)
+	}
+	if len(data) > maxInputSize {
+		return "", fmt.Errorf("error read card is larger than %d bytes", maxInputSize)
+	}
+
+	return string(data), nil
+}
+
+func makeVerifier(policyIDOrPath string, trustPaths []string, metadataPath string, lotsPath string) (verification.Verifier, error) {
+
+	policies, err := pipeline.SelectPolicies(policyIDOrPath)
+	if err != nil {
+		return nil, err
+	}
+
+	trust, err := pipeline.LoadTrustPaths(trustPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &verification.VerifierConfig{
+		Policy:            policies[0],
+		PolicyVersions:    policies[1:],
+		SignatureVerifier: trust.SignatureVerifier,
+		IssuerTrustStore:  trust.IssuerTrustStore,
+	}
+
+	if metadataPath != "" {
+		vaccineMD, err := vaccinemd.LoadMetadataPath(metadataPath)
+		if err != nil {
+			return nil, err
+		}
+		if config.Repo, err = vaccinemd.MakeRepoFromMetadata(vaccineMD); err != nil {
+			return nil, err
+		}
+	}
+
+	if lotsPath != "" {
+		if config.Lots, err = vaccinemd.LoadLotRegistryPath(lotsPath); err != nil {
+			return nil, err
+		}
+	}
+
+	return verification.NewVerifier(config)
+}
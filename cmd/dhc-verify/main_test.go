@@ -0,0 +1,153 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/batch"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+const (
+	testCard        = "../../testdata/card.smart-health-card"
+	testCertificate = "../../testdata/certificate.hc1.txt"
+	testTrust       = "../../testdata/trust.json"
+)
+
+func Test_Run(t *testing.T) {
+
+	qr, err := os.ReadFile("../../testdata/card.qr.txt")
+	require.NoError(t, err)
+
+	type testCase struct {
+		name          string
+		args          []string
+		stdin         string
+		expectedCode  int
+		expectedState string
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should be valid with trust",
+			args:          []string{"-trust", testTrust, "-no-color", testCard},
+			expectedCode:  0,
+			expectedState: "valid (green)",
+		},
+		{
+			name:          "should be unverified without trust",
+			args:          []string{"-no-color", testCard},
+			expectedCode:  exitCodes[verification.CardVerificationStateUnVerified],
+			expectedState: "unverified (orange)",
+		},
+		{
+			name:          "should read the qr code from stdin",
+			args:          []string{"-trust", testTrust, "-no-color"},
+			stdin:         string(qr),
+			expectedCode:  0,
+			expectedState: "valid (green)",
+		},
+		{
+			name:          "should pass the qr code as an argument",
+			args:          []string{"-trust", testTrust, "-no-color", strings.TrimSpace(string(qr))},
+			expectedCode:  0,
+			expectedState: "valid (green)",
+		},
+		{
+			name:          "should verify an eu certificate",
+			args:          []string{"-trust", testTrust, "-policy", "eu", "-no-color", testCertificate},
+			expectedCode:  0,
+			expectedState: "valid (green)",
+		},
+		{
+			name:          "should not meet safety criteria before the last dose",
+			args:          []string{"-trust", testTrust, "-at", "2021-02-01T00:00:00Z", "-no-color", testCard},
+			expectedCode:  exitCodes[verification.CardVerificationStateSafetyCriteriaNotMet],
+			expectedState: "safety_criteria_not_met (orange)",
+		},
+		{
+			name:         "should fail if not a card",
+			args:         []string{"not a card"},
+			expectedCode: exitError,
+		},
+		{
+			name:         "should fail if the policy is unknown",
+			args:         []string{"-policy", "mars", testCard},
+			expectedCode: exitError,
+		},
+		{
+			name:         "should fail if the time is invalid",
+			args:         []string{"-at", "yesterday", testCard},
+			expectedCode: exitUsage,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+			code := run(tc.args, strings.NewReader(tc.stdin), &stdout, &stderr)
+			require.Equal(t, tc.expectedCode, code, stderr.String())
+			if tc.expectedState != "" {
+				require.Contains(t, stdout.String(), "State:         "+tc.expectedState)
+			}
+		})
+	}
+
+	t.Run("should print json", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-json", "-trust", testTrust, testCard}, nil, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+
+		var results verification.CardVerificationResults
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &results))
+		require.Equal(t, verification.CardVerificationStateValid, results.State)
+		require.Equal(t, "usa", results.PolicyID)
+	})
+
+	t.Run("should color the state", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		run([]string{"-trust", testTrust, testCard}, nil, &stdout, &stderr)
+		if _, ok := os.LookupEnv("NO_COLOR"); !ok {
+			require.Contains(t, stdout.String(), ansiColors[verification.StateColorGreen]+"valid (green)"+ansiReset)
+		}
+	})
+}
+
+func Test_RunBatch(t *testing.T) {
+
+	t.Run("should print a csv report and summary", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-batch", "-trust", testTrust, "-at", "2021-09-01T00:00:00Z", testCard, testCertificate,
+			"../../testdata/card.qr.txt"}, nil, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+
+		lines := strings.Split(strings.TrimSpace(stdout.String()), "\n")
+		require.Len(t, lines, 3)
+		require.Contains(t, lines[1], ",shc,valid,")
+		require.Contains(t, stderr.String(), "Duplicates: 1")
+	})
+
+	t.Run("should print a json report", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		code := run([]string{"-batch", "-report", "json", "-trust", testTrust, testCard}, nil, &stdout, &stderr)
+		require.Equal(t, 0, code, stderr.String())
+
+		var report batch.Report
+		require.NoError(t, json.Unmarshal(stdout.Bytes(), &report))
+		require.Equal(t, 1, report.Summary.States[verification.CardVerificationStateValid])
+	})
+
+	t.Run("should fail without paths", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, exitUsage, run([]string{"-batch"}, nil, &stdout, &stderr))
+	})
+
+	t.Run("should fail for an unknown report format", func(t *testing.T) {
+		var stdout, stderr bytes.Buffer
+		require.Equal(t, exitUsage, run([]string{"-batch", "-report", "xlsx", testCard}, nil, &stdout, &stderr))
+	})
+}
@@ -0,0 +1,96 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//ansiColors the terminal escape for each state color
+var ansiColors = map[verification.StateColor]string{
+	verification.StateColorGreen:  "\033[32m",
+	verification.StateColorOrange: "\033[38;5;208m",
+	verification.StateColorRed:    "\033[31m",
+	verification.StateColorGrey:   "\033[90m",
+}
+
+const ansiReset = "\033[0m"
+
+func writeJSON(w io.Writer, results *verification.CardVerificationResults) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	if err := encoder.Encode(results); err != nil {
+		return fmt.Errorf("error write results err=%s", err)
+	}
+	return nil
+}
+
+//summaryWriter keeps the first write error so the summary can be written without checking every line
+type summaryWriter struct {
+	w   io.Writer
+	err error
+}
+
+func (sw *summaryWriter) line(label string, format string, args ...interface{}) {
+	if sw.err == nil {
+		_, sw.err = fmt.Fprintf(sw.w, "%-14s %s\n", label, fmt.Sprintf(format, args...))
+	}
+}
+
+//writeSummary a human readable summary of the results, the state in its color if colored
+func writeSummary(w io.Writer, format pipeline.Format, card *verification.Card,
+	results *verification.CardVerificationResults, colored bool) error {
+
+	sw := &summaryWriter{w: w}
+
+	color := verification.ColorForState(results.State)
+	state := fmt.Sprintf("%s (%s)", results.State, color)
+	if colored {
+		state = ansiColors[color] + state + ansiReset
+	}
+	sw.line("State:", "%s", state)
+	sw.line("Format:", "%s", format)
+	sw.line("Patient:", "%s %s, born %s", card.PatientGivenName, card.PatientFamilyName, card.PatientBirthDate)
+	sw.line("Issuer:", "%s, trusted=%t", card.Issuer, results.Issuer.Trusted)
+
+	structure := results.CardStructure
+	sw.line("Signature:", "checked=%t key found=%t valid=%t", structure.SignatureChecked, structure.FetchedKey,
+		structure.SignatureValid)
+	sw.line("Validity:", "expired=%t not yet valid=%t issued in future=%t", structure.Expired,
+		structure.NotYetValid, structure.IssuedInFuture)
+
+	immunization := results.Immunization
+	vaccine := immunization.VaccineID
+	switch {
+	case immunization.AmbiguousVaccineType:
+		vaccine = "ambiguous, one of " + strings.Join(immunization.VaccineCandidates, " ")
+	case immunization.UnKnownVaccineType:
+		vaccine = "unknown"
+	}
+	sw.line("Vaccine:", "%s, trusted=%t", vaccine, immunization.TrustedVaccineType)
+	sw.line("Doses:", "%d on card, %d excluded, schedule=%s", len(card.Doses), immunization.ExcludedDoses,
+		immunization.Schedule)
+	sw.line("Criteria:", "doses required=%t days between doses=%t days since last dose=%t",
+		immunization.MetDosesRequiredCriteria, immunization.MetDaysBetweenDoesCriteria,
+		immunization.MetDaysSinceLastDoseCriteria)
+	sw.line("Assurance:", "%s score=%d minimum met=%t", results.AssuranceLevel, results.AssuranceScore,
+		results.MinimumAssuranceMet)
+	sw.line("Policy:", "%s version=%s metadata=%s", results.PolicyID, results.PolicyVersion, results.MetadataVersion)
+	if results.VerifiedAt != nil {
+		sw.line("Verified at:", "%s", results.VerifiedAt.Format(time.RFC3339))
+	}
+
+	for _, warning := range immunization.Warnings {
+		sw.line("Warning:", "%s %s", warning.Code, warning.Message)
+	}
+
+	if sw.err != nil {
+		return fmt.Errorf("error write summary err=%s", sw.err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+//
+// Logs are JSON lines so they can be shipped without parsing, each has a time, level and message plus fields.
+// Cards and patient details are never logged, a verification is logged by its card hash, policy and state.
+//
+
+type logLevel string
+
+const (
+	logLevelInfo  logLevel = "info"
+	logLevelError logLevel = "error"
+)
+
+//requestIDHeader the header the request id is returned in, and taken from if a proxy already set one
+const requestIDHeader = "X-Request-ID"
+
+//logger writes a JSON object per line, safe for concurrent use
+type logger struct {
+	mu  sync.Mutex
+	w   io.Writer
+	now func() time.Time
+}
+
+func newLogger(w io.Writer) *logger {
+	return &logger{w: w, now: time.Now}
+}
+
+//log write the entry, the fields can be nil
+func (l *logger) log(level logLevel, msg string, fields map[string]interface{}) {
+
+	entry := make(map[string]interface{}, len(fields)+3)
+	for key, value := range fields {
+		entry[key] = value
+	}
+	entry["time"] = l.now().UTC().Format(time.RFC3339Nano)
+	entry["level"] = level
+	entry["msg"] = msg
+
+	line, err := json.Marshal(entry)
+	if err != nil {
+		line, _ = json.Marshal(map[string]interface{}{"level": logLevelError, "msg": "error marshal log entry", "err": err.Error()})
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	_, _ = l.w.Write(append(line, '\n'))
+}
+
+func (l *logger) info(msg string, fields map[string]interface{}) {
+	l.log(logLevelInfo, msg, fields)
+}
+
+func (l *logger) error(msg string, fields map[string]interface{}) {
+	l.log(logLevelError, msg, fields)
+}
+
+type requestIDKey struct{}
+
+//requestID the id of the request in the context, empty if none
+func requestID(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
+
+//statusRecorder records the status and size of the response for the request log
+type statusRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	sr.status = status
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	if sr.status == 0 {
+		sr.status = http.StatusOK
+	}
+	n, err := sr.ResponseWriter.Write(b)
+	sr.bytes += n
+	return n, err
+}
+
+//logRequests give each request an id and log it once it completes
+func (l *logger) logRequests(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		started := time.Now()
+
+		id := r.Header.Get(requestIDHeader)
+		if id == "" || len(id) > 64 {
+			id = newRequestID()
+		}
+		w.Header().Set(requestIDHeader, id)
+
+		recorder := &statusRecorder{ResponseWriter: w}
+		next.ServeHTTP(recorder, r.WithContext(context.WithValue(r.Context(), requestIDKey{}, id)))
+
+		l.info("request", map[string]interface{}{
+			"request_id":  id,
+			"method":      r.Method,
+			"path":        r.URL.Path,
+			"status":      recorder.status,
+			"bytes":       recorder.bytes,
+			"duration_ms": float64(time.Since(started).Microseconds()) / 1000,
+		})
+	})
+}
+
+func newRequestID() string {
+	b := make([]byte, 8)
+	if _, err := rand.Read(b); err != nil {
+		return "unknown"
+	}
+	return hex.EncodeToString(b)
+}
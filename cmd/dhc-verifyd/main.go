@@ -0,0 +1,243 @@
+//Command dhc-verifyd serves card verification over HTTP for clients that cannot embed Go, for example kiosks
+//and web pages.
+//
+//	POST /verify    a shc:/ QR code, .smart-health-card file, JWS or HC1: code as text/plain, or as JSON
+//	                {"card": "...", "policy": "eu"}, returns the CardVerificationResults
+//	GET  /vaccines  the vaccine metadata, ?q= filters by name and ?region= to those trusted in the region
+//	GET  /policies  the policies cards can be verified against
+//	GET  /healthz   the process is up
+//	GET  /readyz    the server is accepting verifications
+//	GET  /metrics   the verification metrics in the Prometheus text format
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"syscall"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//defaultPolicyID the default policy when the built in policies are used, as for dhc-verify
+const defaultPolicyID = "usa"
+
+//stringList a flag that can be repeated
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, value)
+	return nil
+}
+
+//options the command line flags
+type options struct {
+	addr             string
+	policies         stringList
+	defaultPolicy    string
+	trustPaths       stringList
+	metadataPath     string
+	metadataInterval time.Duration
+	lotsPath         string
+	maxBodySize      int64
+	timeout          time.Duration
+	shutdownTimeout  time.Duration
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stderr))
+}
+
+func run(args []string, logOutput io.Writer) int {
+
+	opts, err := parseOptions(args, logOutput)
+	if err != nil {
+		return 2
+	}
+
+	log := newLogger(logOutput)
+
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	defer stop()
+
+	config, err := makeServerConfig(ctx, opts, log)
+	if err != nil {
+		log.error("error starting", map[string]interface{}{"err": err.Error()})
+		return 1
+	}
+
+	s := newServer(config)
+	httpServer := &http.Server{
+		Addr:              opts.addr,
+		Handler:           s.handler(),
+		ReadHeaderTimeout: 5 * time.Second,
+		ReadTimeout:       opts.timeout,
+		WriteTimeout:      opts.timeout + 5*time.Second,
+		IdleTimeout:       2 * time.Minute,
+		MaxHeaderBytes:    16 << 10,
+	}
+
+	serveErr := make(chan error, 1)
+	go func() {
+		serveErr <- httpServer.ListenAndServe()
+	}()
+
+	s.setReady(true)
+	log.info("listening", map[string]interface{}{
+		"addr":             opts.addr,
+		"default_policy":   config.DefaultPolicy,
+		"metadata_version": config.Repo.Version(),
+	})
+
+	select {
+	case err := <-serveErr:
+		log.error("error serving", map[string]interface{}{"err": err.Error()})
+		return 1
+	case <-ctx.Done():
+	}
+
+	//fail readiness first so load balancers stop sending requests, then let in flight requests finish
+	s.setReady(false)
+	log.info("shutting down", nil)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), opts.shutdownTimeout)
+	defer cancel()
+	if err := httpServer.Shutdown(shutdownCtx); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		log.error("error shutting down", map[string]interface{}{"err": err.Error()})
+		return 1
+	}
+
+	return 0
+}
+
+func parseOptions(args []string, output io.Writer) (*options, error) {
+
+	opts := &options{}
+
+	flags := flag.NewFlagSet("dhc-verifyd", flag.ContinueOnError)
+	flags.SetOutput(output)
+	flags.StringVar(&opts.addr, "addr", ":8080", "the address to listen on")
+	flags.Var(&opts.policies, "policy", fmt.Sprintf("a built in policy %v or a policy file, can be repeated, "+
+		"all the built in policies if not set", pipeline.BuiltInPolicyIDs()))
+	flags.StringVar(&opts.defaultPolicy, "default-policy", "", "the ID of the policy used when a request does not "+
+		"name one, if not set the first policy or usa if the built in policies are used")
+	flags.Var(&opts.trustPaths, "trust", "a trust file of trusted issuers and their keys, can be repeated")
+	flags.StringVar(&opts.metadataPath, "metadata", "", "a vaccine metadata file or directory, reloaded when it "+
+		"changes, the built in metadata if not set")
+	flags.DurationVar(&opts.metadataInterval, "metadata-interval", time.Minute, "how often to check the metadata for changes")
+	flags.StringVar(&opts.lotsPath, "lots", "", "a lot registry file, lots are not checked if not set")
+	flags.Int64Var(&opts.maxBodySize, "max-body", DefaultMaxBodySize, "the largest request body in bytes")
+	flags.DurationVar(&opts.timeout, "timeout", 10*time.Second, "the longest a request can take")
+	flags.DurationVar(&opts.shutdownTimeout, "shutdown-timeout", 15*time.Second, "how long to wait for requests "+
+		"to finish when shutting down")
+
+	if err := flags.Parse(args); err != nil {
+		return nil, err
+	}
+
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return nil, fmt.Errorf("error unexpected arguments")
+	}
+
+	if opts.metadataInterval <= 0 {
+		return nil, fmt.Errorf("error metadata-interval must be positive got=%s", opts.metadataInterval)
+	}
+
+	if len(opts.policies) == 0 {
+		opts.policies = pipeline.BuiltInPolicyIDs()
+		if opts.defaultPolicy == "" {
+			opts.defaultPolicy = defaultPolicyID
+		}
+	}
+
+	return opts, nil
+}
+
+//makeServerConfig load the trust, policies and metadata, one verifier is made per policy and they share the repo
+func makeServerConfig(ctx context.Context, opts *options, log *logger) (*serverConfig, error) {
+
+	trust, err := pipeline.LoadTrustPaths(opts.trustPaths...)
+	if err != nil {
+		return nil, err
+	}
+
+	repo := vaccinemd.MakeReloadableRepo()
+	if opts.metadataPath != "" {
+		if err := repo.LoadPath(opts.metadataPath); err != nil {
+			return nil, err
+		}
+		go repo.Watch(ctx, opts.metadataPath, opts.metadataInterval, func(err error) {
+			log.error("error reloading metadata", map[string]interface{}{"err": err.Error()})
+		})
+	}
+
+	var lots vaccinemd.LotRegistry
+	if opts.lotsPath != "" {
+		if lots, err = vaccinemd.LoadLotRegistryPath(opts.lotsPath); err != nil {
+			return nil, err
+		}
+	}
+
+	metrics := verification.NewPrometheusMetrics()
+
+	config := &serverConfig{
+		Verifiers:     make(map[string]verification.Verifier),
+		DefaultPolicy: opts.defaultPolicy,
+		Repo:          repo,
+		Metrics:       metrics,
+		MaxBodySize:   opts.maxBodySize,
+		Timeout:       opts.timeout,
+		Logger:        log,
+	}
+
+	for _, idOrPath := range opts.policies {
+
+		policies, err := pipeline.SelectPolicies(idOrPath)
+		if err != nil {
+			return nil, err
+		}
+
+		id := policies[0].ID
+		if _, ok := config.Verifiers[id]; ok {
+			return nil, fmt.Errorf("error policy id=%s is loaded more than once", id)
+		}
+
+		verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+			Policy:            policies[0],
+			PolicyVersions:    policies[1:],
+			Repo:              repo,
+			SignatureVerifier: trust.SignatureVerifier,
+			IssuerTrustStore:  trust.IssuerTrustStore,
+			Lots:              lots,
+			Metrics:           metrics,
+		})
+		if err != nil {
+			return nil, err
+		}
+		config.Verifiers[id] = verifier
+
+		if config.DefaultPolicy == "" {
+			config.DefaultPolicy = id
+		}
+	}
+
+	if _, ok := config.Verifiers[config.DefaultPolicy]; !ok {
+		return nil, fmt.Errorf("error default policy=%s is not loaded", config.DefaultPolicy)
+	}
+
+	return config, nil
+}
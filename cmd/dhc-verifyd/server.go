@@ -0,0 +1,273 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"net/http"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//DefaultMaxBodySize a card is a few KB, a chunked QR code or card file at most a few tens of KB
+const DefaultMaxBodySize = 64 << 10
+
+//serverConfig what the server verifies with, Verifiers are keyed by policy ID
+type serverConfig struct {
+	Verifiers     map[string]verification.Verifier
+	DefaultPolicy string
+	Repo          vaccinemd.Repo
+	Metrics       http.Handler
+	MaxBodySize   int64
+	Timeout       time.Duration
+	Logger        *logger
+	Now           func() time.Time
+}
+
+//server the handlers, the verifiers and repo are safe for concurrent use so are shared by all requests
+type server struct {
+	config *serverConfig
+
+	//ready 1 once serving and 0 once shutting down so load balancers stop sending requests
+	ready int32
+}
+
+//verifyRequest the JSON body of POST /verify, a text/plain body is the card itself
+type verifyRequest struct {
+
+	//Card the card as presented, a shc:/ QR code, .smart-health-card file contents, JWS or HC1: code
+	Card string `json:"card"`
+
+	//Policy the ID of the policy to verify against, the default policy if empty
+	Policy string `json:"policy,omitempty"`
+}
+
+//policiesResponse GET /policies, the version of each policy effective now
+type policiesResponse struct {
+	DefaultPolicy string                 `json:"default_policy"`
+	Policies      []*verification.Policy `json:"policies"`
+}
+
+type errorResponse struct {
+	Error     string `json:"error"`
+	RequestID string `json:"request_id,omitempty"`
+}
+
+func newServer(config *serverConfig) *server {
+	if config.MaxBodySize <= 0 {
+		config.MaxBodySize = DefaultMaxBodySize
+	}
+	if config.Now == nil {
+		config.Now = time.Now
+	}
+	return &server{config: config}
+}
+
+//handler the routes, every request is logged and bounded by the timeout
+func (s *server) handler() http.Handler {
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/verify", s.method(http.MethodPost, s.handleVerify))
+	mux.HandleFunc("/vaccines", s.method(http.MethodGet, s.handleVaccines))
+	mux.HandleFunc("/policies", s.method(http.MethodGet, s.handlePolicies))
+	mux.HandleFunc("/healthz", s.method(http.MethodGet, s.handleHealth))
+	mux.HandleFunc("/readyz", s.method(http.MethodGet, s.handleReady))
+	if s.config.Metrics != nil {
+		mux.Handle("/metrics", s.config.Metrics)
+	}
+
+	var handler http.Handler = mux
+	if s.config.Timeout > 0 {
+		body, _ := json.Marshal(&errorResponse{Error: "error request timed out"})
+		handler = http.TimeoutHandler(handler, s.config.Timeout, string(body))
+	}
+
+	return s.config.Logger.logRequests(handler)
+}
+
+func (s *server) setReady(ready bool) {
+	value := int32(0)
+	if ready {
+		value = 1
+	}
+	atomic.StoreInt32(&s.ready, value)
+}
+
+//method reject requests with any other method
+func (s *server) method(method string, handle http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != method {
+			w.Header().Set("Allow", method)
+			s.writeError(w, r, http.StatusMethodNotAllowed, fmt.Errorf("error method %s not allowed", r.Method))
+			return
+		}
+		handle(w, r)
+	}
+}
+
+func (s *server) handleVerify(w http.ResponseWriter, r *http.Request) {
+
+	request, err := s.readVerifyRequest(w, r)
+	if err != nil {
+		status := http.StatusBadRequest
+		if strings.Contains(err.Error(), "request body too large") {
+			status = http.StatusRequestEntityTooLarge
+		}
+		s.writeError(w, r, status, err)
+		return
+	}
+
+	policy := request.Policy
+	if policy == "" {
+		policy = s.config.DefaultPolicy
+	}
+	verifier, ok := s.config.Verifiers[policy]
+	if !ok {
+		s.writeError(w, r, http.StatusBadRequest, fmt.Errorf("error verify unknown policy=%s", policy))
+		return
+	}
+
+	card, format, err := pipeline.Decode(request.Card)
+	if err != nil {
+		s.writeError(w, r, http.StatusUnprocessableEntity, err)
+		return
+	}
+
+	results, err := verifier.VerifyAt(r.Context(), card, s.config.Now())
+	if err != nil {
+		s.config.Logger.error("verify failed", map[string]interface{}{
+			"request_id": requestID(r.Context()),
+			"policy":     policy,
+			"err":        err.Error(),
+		})
+		s.writeError(w, r, http.StatusInternalServerError, fmt.Errorf("error verify card"))
+		return
+	}
+
+	s.config.Logger.info("card verified", map[string]interface{}{
+		"request_id":     requestID(r.Context()),
+		"format":         format,
+		"policy":         results.PolicyID,
+		"policy_version": results.PolicyVersion,
+		"state":          results.State,
+		"card_hash":      results.CardHash,
+	})
+
+	s.writeJSON(w, http.StatusOK, results)
+}
+
+func (s *server) readVerifyRequest(w http.ResponseWriter, r *http.Request) (*verifyRequest, error) {
+
+	body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, s.config.MaxBodySize))
+	if err != nil {
+		return nil, fmt.Errorf("error read request err=%s", err)
+	}
+
+	request := &verifyRequest{Policy: r.URL.Query().Get("policy")}
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType != "application/json" {
+		request.Card = string(body)
+		return request, nil
+	}
+
+	var jsonRequest verifyRequest
+	if err := json.Unmarshal(body, &jsonRequest); err != nil {
+		return nil, fmt.Errorf("error read request err=%s", err)
+	}
+	request.Card = jsonRequest.Card
+	if jsonRequest.Policy != "" {
+		request.Policy = jsonRequest.Policy
+	}
+
+	return request, nil
+}
+
+//handleVaccines all the vaccines, or those matching the q text and trusted in the region
+func (s *server) handleVaccines(w http.ResponseWriter, r *http.Request) {
+
+	repo := s.config.Repo.Snapshot()
+	vaccines := repo.SearchVaccines(r.URL.Query().Get("q"))
+
+	if region := vaccinemd.Region(r.URL.Query().Get("region")); region != "" {
+
+		if _, err := region.Trust(); err != nil {
+			s.writeError(w, r, http.StatusBadRequest, err)
+			return
+		}
+
+		trusted := make(map[string]bool)
+		for _, vmd := range repo.FindTrustedVaccines(region) {
+			trusted[vmd.ID] = true
+		}
+
+		filtered := make([]*vaccinemd.VaccineMetadata, 0, len(vaccines))
+		for _, vmd := range vaccines {
+			if trusted[vmd.ID] {
+				filtered = append(filtered, vmd)
+			}
+		}
+		vaccines = filtered
+	}
+
+	w.Header().Set("X-Metadata-Version", repo.Version())
+	s.writeJSON(w, http.StatusOK, vaccines)
+}
+
+func (s *server) handlePolicies(w http.ResponseWriter, _ *http.Request) {
+
+	ids := make([]string, 0, len(s.config.Verifiers))
+	for id := range s.config.Verifiers {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	response := &policiesResponse{DefaultPolicy: s.config.DefaultPolicy, Policies: make([]*verification.Policy, 0, len(ids))}
+	for _, id := range ids {
+		if policy := s.config.Verifiers[id].Policy(); policy != nil {
+			response.Policies = append(response.Policies, policy)
+		}
+	}
+
+	s.writeJSON(w, http.StatusOK, response)
+}
+
+//handleHealth the process is up
+func (s *server) handleHealth(w http.ResponseWriter, _ *http.Request) {
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ok"})
+}
+
+//handleReady the server can take verifications, it is not once shutdown starts
+func (s *server) handleReady(w http.ResponseWriter, r *http.Request) {
+	if atomic.LoadInt32(&s.ready) == 0 {
+		s.writeError(w, r, http.StatusServiceUnavailable, errors.New("error not ready"))
+		return
+	}
+	s.writeJSON(w, http.StatusOK, map[string]string{"status": "ready", "metadata_version": s.config.Repo.Version()})
+}
+
+func (s *server) writeJSON(w http.ResponseWriter, status int, value interface{}) {
+
+	body, err := json.Marshal(value)
+	if err != nil {
+		s.config.Logger.error("error marshal response", map[string]interface{}{"err": err.Error()})
+		status = http.StatusInternalServerError
+		body, _ = json.Marshal(&errorResponse{Error: "error marshal response"})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	_, _ = w.Write(append(body, '\n'))
+}
+
+func (s *server) writeError(w http.ResponseWriter, r *http.Request, status int, err error) {
+	s.writeJSON(w, status, &errorResponse{Error: err.Error(), RequestID: requestID(r.Context())})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Server(t *testing.T) {
+
+	var logs bytes.Buffer
+	opts, err := parseOptions([]string{"-trust", "../../testdata/trust.json", "-policy", "usa", "-policy", "eu",
+		"-max-body", "4096"}, &logs)
+	require.NoError(t, err)
+
+	config, err := makeServerConfig(context.Background(), opts, newLogger(&logs))
+	require.NoError(t, err)
+	require.Equal(t, "usa", config.DefaultPolicy)
+
+	s := newServer(config)
+	ts := httptest.NewServer(s.handler())
+	defer ts.Close()
+
+	card, err := os.ReadFile("../../testdata/card.smart-health-card")
+	require.NoError(t, err)
+	certificate, err := os.ReadFile("../../testdata/certificate.hc1.txt")
+	require.NoError(t, err)
+
+	t.Run("should verify a card sent as text", func(t *testing.T) {
+		resp, err := http.Post(ts.URL+"/verify", "text/plain", bytes.NewReader(card))
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+		require.NotEmpty(t, resp.Header.Get(requestIDHeader))
+
+		var results verification.CardVerificationResults
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+		require.Equal(t, verification.CardVerificationStateValid, results.State)
+		require.Equal(t, "usa", results.PolicyID)
+	})
+
+	t.Run("should verify a card sent as json with a policy", func(t *testing.T) {
+		body, err := json.Marshal(&verifyRequest{Card: string(certificate), Policy: "eu"})
+		require.NoError(t, err)
+		resp, err := http.Post(ts.URL+"/verify", "application/json", bytes.NewReader(body))
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+
+		var results verification.CardVerificationResults
+		require.NoError(t, json.NewDecoder(resp.Body).Decode(&results))
+		require.Equal(t, verification.CardVerificationStateValid, results.State)
+		require.Equal(t, "eu", results.PolicyID)
+	})
+
+	type errorCase struct {
+		name           string
+		method         string
+		path           string
+		body           string
+		expectedStatus int
+	}
+
+	errorCases := []errorCase{
+		{name: "should reject a card that cannot be decoded", method: http.MethodPost, path: "/verify", body: "not a card",
+			expectedStatus: http.StatusUnprocessableEntity},
+		{name: "should reject an unknown policy", method: http.MethodPost, path: "/verify?policy=mars", body: string(card),
+			expectedStatus: http.StatusBadRequest},
+		{name: "should reject a body that is too large", method: http.MethodPost, path: "/verify",
+			body: strings.Repeat("x", 5000), expectedStatus: http.StatusRequestEntityTooLarge},
+		{name: "should reject the wrong method", method: http.MethodGet, path: "/verify",
+			expectedStatus: http.StatusMethodNotAllowed},
+		{name: "should reject an invalid region", method: http.MethodGet, path: "/vaccines?region=EMA|", expectedStatus: http.StatusBadRequest},
+	}
+
+	for _, tc := range errorCases {
+		t.Run(tc.name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, ts.URL+tc.path, strings.NewReader(tc.body))
+			require.NoError(t, err)
+			resp, err := http.DefaultClient.Do(req)
+			require.NoError(t, err)
+			defer func() { _ = resp.Body.Close() }()
+			require.Equal(t, tc.expectedStatus, resp.StatusCode)
+
+			var errResp errorResponse
+			require.NoError(t, json.NewDecoder(resp.Body).Decode(&errResp))
+			require.NotEmpty(t, errResp.Error)
+			require.NotEmpty(t, errResp.RequestID)
+		})
+	}
+
+	t.Run("should list vaccines", func(t *testing.T) {
+		var all, trusted []*vaccinemd.VaccineMetadata
+		getJSON(t, ts.URL+"/vaccines", http.StatusOK, &all)
+		getJSON(t, ts.URL+"/vaccines?region=USA", http.StatusOK, &trusted)
+		require.NotEmpty(t, trusted)
+		require.Greater(t, len(all), len(trusted))
+	})
+
+	t.Run("should list policies", func(t *testing.T) {
+		var policies policiesResponse
+		getJSON(t, ts.URL+"/policies", http.StatusOK, &policies)
+		require.Equal(t, "usa", policies.DefaultPolicy)
+		require.Len(t, policies.Policies, 2)
+		require.Equal(t, "eu", policies.Policies[0].ID)
+	})
+
+	t.Run("should only be ready while serving", func(t *testing.T) {
+		getJSON(t, ts.URL+"/healthz", http.StatusOK, &map[string]string{})
+		getJSON(t, ts.URL+"/readyz", http.StatusServiceUnavailable, &errorResponse{})
+		s.setReady(true)
+		getJSON(t, ts.URL+"/readyz", http.StatusOK, &map[string]string{})
+	})
+
+	t.Run("should count verifications", func(t *testing.T) {
+		resp, err := http.Get(ts.URL + "/metrics")
+		require.NoError(t, err)
+		defer func() { _ = resp.Body.Close() }()
+		require.Equal(t, http.StatusOK, resp.StatusCode)
+	})
+
+	t.Run("should log json lines without pii", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSpace(logs.String()), "\n")
+		require.NotEmpty(t, lines)
+		for _, line := range lines {
+			var entry map[string]interface{}
+			require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+			require.NotEmpty(t, entry["msg"])
+		}
+		require.Contains(t, logs.String(), `"state":"valid"`)
+		require.NotContains(t, logs.String(), "Anyperson")
+	})
+}
+
+func Test_ServerTimeout(t *testing.T) {
+
+	config := &serverConfig{
+		Verifiers:     map[string]verification.Verifier{"slow": &slowVerifier{}},
+		DefaultPolicy: "slow",
+		Repo:          vaccinemd.MakeRepo(),
+		Timeout:       50 * time.Millisecond,
+		Logger:        newLogger(&bytes.Buffer{}),
+	}
+	ts := httptest.NewServer(newServer(config).handler())
+	defer ts.Close()
+
+	card, err := os.ReadFile("../../testdata/card.smart-health-card")
+	require.NoError(t, err)
+
+	resp, err := http.Post(ts.URL+"/verify", "text/plain", bytes.NewReader(card))
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func Test_ParseOptions(t *testing.T) {
+
+	for _, args := range [][]string{
+		{"-metadata-interval", "0s"},
+		{"-metadata-interval", "-1m"},
+		{"unexpected"},
+	} {
+		_, err := parseOptions(args, &bytes.Buffer{})
+		require.Error(t, err, args)
+	}
+
+	opts, err := parseOptions([]string{"-metadata-interval", "30s"}, &bytes.Buffer{})
+	require.NoError(t, err)
+	require.Equal(t, 30*time.Second, opts.metadataInterval)
+}
+
+//slowVerifier waits for the request to be cancelled
+type slowVerifier struct {
+	verification.Verifier
+}
+
+func (sv *slowVerifier) VerifyAt(ctx context.Context, _ *verification.Card, _ time.Time) (*verification.CardVerificationResults, error) {
+	<-ctx.Done()
+	return nil, ctx.Err()
+}
+
+func getJSON(t *testing.T, url string, expectedStatus int, value interface{}) {
+	resp, err := http.Get(url)
+	require.NoError(t, err)
+	defer func() { _ = resp.Body.Close() }()
+	require.Equal(t, expectedStatus, resp.StatusCode)
+	require.NoError(t, json.NewDecoder(resp.Body).Decode(value))
+}
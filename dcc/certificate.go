@@ -0,0 +1,294 @@
+package dcc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"math/big"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/internal/base45"
+	"github.com/webshield-dev/dhc-common/internal/cbor"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+const (
+	//AlgorithmES256 the COSE alg of ECDSA using P-256 and SHA-256
+	AlgorithmES256 int64 = -7
+
+	//AlgorithmPS256 the COSE alg of RSASSA-PSS using SHA-256
+	AlgorithmPS256 int64 = -37
+
+	//tagCOSESign1 the CBOR tag of a COSE_Sign1, optional
+	tagCOSESign1 = 18
+
+	//COSE header parameters
+	headerAlgorithm = 1
+	headerKeyID     = 4
+
+	//CWT claims
+	claimIssuer    = 1
+	claimExpiresAt = 4
+	claimNotBefore = 5
+	claimIssuedAt  = 6
+	claimHCert     = -260
+
+	//hcertDCC the key of the certificate in the hcert claim
+	hcertDCC = 1
+
+	//es256ByteLen the length of each of r and s in an ES256 signature
+	es256ByteLen = 32
+
+	//maxPayloadSize a QR code holds a few KB so this is generous, it stops decompression bombs
+	maxPayloadSize = 1 << 20
+)
+
+//Certificate a decoded certificate, the signature has not been checked, see Verify
+type Certificate struct {
+
+	//QR the certificate's QR code content, HC1:...
+	QR string
+
+	//KeyID the kid of the signing key, the first 8 bytes of the SHA-256 of the Document Signer Certificate
+	KeyID []byte
+
+	//Algorithm the COSE alg, AlgorithmES256 or AlgorithmPS256
+	Algorithm int64
+
+	//Issuer the CWT iss, the ISO 3166 country that issued the certificate
+	Issuer string
+
+	//ExpiresAt the CWT exp in seconds since the epoch, 0 if not present
+	ExpiresAt int64
+
+	//NotBefore the CWT nbf in seconds since the epoch, 0 if not present
+	NotBefore int64
+
+	//IssuedAt the CWT iat in seconds since the epoch, 0 if not present
+	IssuedAt int64
+
+	//Payload the certificate
+	Payload *Payload
+
+	protected []byte
+	cwt       []byte
+	signature []byte
+}
+
+//Parse decode a certificate from its QR code content, the HC1: prefix is required
+func Parse(qr string) (*Certificate, error) {
+
+	qr = strings.TrimSpace(qr)
+	if !strings.HasPrefix(qr, Prefix) {
+		return nil, fmt.Errorf("error dcc parse missing prefix %s", Prefix)
+	}
+
+	compressed, err := base45.Decode(strings.TrimPrefix(qr, Prefix))
+	if err != nil {
+		return nil, fmt.Errorf("error dcc parse err=%s", err)
+	}
+
+	//the spec allows the COSE to be uncompressed, zlib data starts with the 0x78 CMF byte
+	coseBytes := compressed
+	if len(compressed) > 0 && compressed[0] == 0x78 {
+		zr, err := zlib.NewReader(bytes.NewReader(compressed))
+		if err != nil {
+			return nil, fmt.Errorf("error dcc parse inflate err=%s", err)
+		}
+		coseBytes, err = io.ReadAll(io.LimitReader(zr, maxPayloadSize+1))
+		if err != nil {
+			return nil, fmt.Errorf("error dcc parse inflate err=%s", err)
+		}
+		if len(coseBytes) > maxPayloadSize {
+			return nil, fmt.Errorf("error dcc parse payload too large")
+		}
+	}
+
+	certificate, err := parseCOSE(coseBytes)
+	if err != nil {
+		return nil, err
+	}
+	certificate.QR = qr
+
+	return certificate, nil
+}
+
+func parseCOSE(data []byte) (*Certificate, error) {
+
+	decoded, err := cbor.Decode(data)
+	if err != nil {
+		return nil, fmt.Errorf("error dcc parse cose err=%s", err)
+	}
+
+	if tag, ok := decoded.(cbor.Tag); ok {
+		if tag.Number != tagCOSESign1 {
+			return nil, fmt.Errorf("error dcc parse cose unexpected tag=%d", tag.Number)
+		}
+		decoded = tag.Content
+	}
+
+	parts, ok := decoded.([]interface{})
+	if !ok || len(parts) != 4 {
+		return nil, fmt.Errorf("error dcc parse cose not a COSE_Sign1")
+	}
+
+	protected, protectedOK := parts[0].([]byte)
+	unprotected, unprotectedOK := parts[1].(map[interface{}]interface{})
+	cwt, cwtOK := parts[2].([]byte)
+	signature, signatureOK := parts[3].([]byte)
+	if !protectedOK || !unprotectedOK || !cwtOK || !signatureOK {
+		return nil, fmt.Errorf("error dcc parse cose not a COSE_Sign1")
+	}
+
+	certificate := &Certificate{protected: protected, cwt: cwt, signature: signature}
+
+	//a kid or alg in the protected header takes precedence as it is signed
+	headers := []map[interface{}]interface{}{unprotected}
+	if len(protected) > 0 {
+		decodedProtected, err := cbor.Decode(protected)
+		if err != nil {
+			return nil, fmt.Errorf("error dcc parse cose protected header err=%s", err)
+		}
+		protectedHeader, ok := decodedProtected.(map[interface{}]interface{})
+		if !ok {
+			return nil, fmt.Errorf("error dcc parse cose protected header is not a map")
+		}
+		headers = append(headers, protectedHeader)
+	}
+
+	for _, header := range headers {
+		if alg, ok := header[int64(headerAlgorithm)].(int64); ok {
+			certificate.Algorithm = alg
+		}
+		if kid, ok := header[int64(headerKeyID)].([]byte); ok {
+			certificate.KeyID = kid
+		}
+	}
+
+	if err := certificate.parseCWT(); err != nil {
+		return nil, err
+	}
+
+	return certificate, nil
+}
+
+func (c *Certificate) parseCWT() error {
+
+	decoded, err := cbor.Decode(c.cwt)
+	if err != nil {
+		return fmt.Errorf("error dcc parse cwt err=%s", err)
+	}
+
+	claims, ok := decoded.(map[interface{}]interface{})
+	if !ok {
+		return fmt.Errorf("error dcc parse cwt is not a map")
+	}
+
+	c.Issuer, _ = claims[int64(claimIssuer)].(string)
+	c.ExpiresAt = numericDate(claims[int64(claimExpiresAt)])
+	c.NotBefore = numericDate(claims[int64(claimNotBefore)])
+	c.IssuedAt = numericDate(claims[int64(claimIssuedAt)])
+
+	hcert, ok := claims[int64(claimHCert)].(map[interface{}]interface{})
+	if !ok || hcert[int64(hcertDCC)] == nil {
+		return fmt.Errorf("error dcc parse cwt is missing the hcert claim")
+	}
+
+	if c.Payload, err = payloadFromCBOR(hcert[int64(hcertDCC)]); err != nil {
+		return err
+	}
+
+	if c.Payload.Name == nil {
+		return fmt.Errorf("error dcc parse certificate is missing nam")
+	}
+
+	return nil
+}
+
+//numericDate CWT dates are integers but can be floating point
+func numericDate(value interface{}) int64 {
+	switch v := value.(type) {
+	case int64:
+		return v
+	case float64:
+		return int64(v)
+	}
+	return 0
+}
+
+//KeyIDString the kid as standard base64, as EU trust lists publish it
+func (c *Certificate) KeyIDString() string {
+	return base64.StdEncoding.EncodeToString(c.KeyID)
+}
+
+//Verify check the certificate was signed by the key, an *ecdsa.PublicKey for ES256 or *rsa.PublicKey for PS256
+func (c *Certificate) Verify(key crypto.PublicKey) error {
+
+	sigStructure, err := cbor.Marshal([]interface{}{"Signature1", c.protected, []byte{}, c.cwt})
+	if err != nil {
+		return fmt.Errorf("error dcc verify err=%s", err)
+	}
+	digest := sha256.Sum256(sigStructure)
+
+	switch c.Algorithm {
+	case AlgorithmES256:
+		ecKey, ok := key.(*ecdsa.PublicKey)
+		if !ok || ecKey.Curve != elliptic.P256() {
+			return fmt.Errorf("error dcc verify ES256 requires a P-256 key")
+		}
+		if len(c.signature) != 2*es256ByteLen {
+			return fmt.Errorf("error dcc verify signature length got=%d", len(c.signature))
+		}
+		r := new(big.Int).SetBytes(c.signature[:es256ByteLen])
+		s := new(big.Int).SetBytes(c.signature[es256ByteLen:])
+		if !ecdsa.Verify(ecKey, digest[:], r, s) {
+			return fmt.Errorf("error dcc verify signature invalid")
+		}
+		return nil
+
+	case AlgorithmPS256:
+		rsaKey, ok := key.(*rsa.PublicKey)
+		if !ok {
+			return fmt.Errorf("error dcc verify PS256 requires an RSA key")
+		}
+		options := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+		if err := rsa.VerifyPSS(rsaKey, crypto.SHA256, digest[:], c.signature, options); err != nil {
+			return fmt.Errorf("error dcc verify signature invalid")
+		}
+		return nil
+	}
+
+	return fmt.Errorf("error dcc verify unsupported alg=%d", c.Algorithm)
+}
+
+//Card the card to verify, Raw is the QR code content. Only vaccinations become doses, tests and recoveries
+//are not immunizations. A certificate only records the latest dose of a series, its dn and sd are kept on the
+//dose so dn 2 of sd 2 meets a two dose schedule, see pdm.Dose CompletesSeries
+func (c *Certificate) Card() *verification.Card {
+
+	card := &verification.Card{
+		Issuer:            c.Issuer,
+		Raw:               []byte(c.QR),
+		TimeClaims:        verification.TimeClaimsFromUnix(c.ExpiresAt, c.NotBefore, c.IssuedAt),
+		PatientGivenName:  c.Payload.Name.GivenName,
+		PatientFamilyName: c.Payload.Name.FamilyName,
+		PatientBirthDate:  c.Payload.DateOfBirth,
+	}
+
+	if card.PatientFamilyName == "" {
+		card.PatientFamilyName = c.Payload.Name.FamilyNameTransliterated
+	}
+
+	for _, vaccination := range c.Payload.Vaccinations {
+		card.Doses = append(card.Doses, vaccination.Dose())
+	}
+
+	return card
+}
@@ -0,0 +1,109 @@
+package dcc_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/dcc"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_Parse(t *testing.T) {
+
+	qr := readTestCertificate(t)
+
+	certificate, err := dcc.Parse(qr)
+	require.NoError(t, err)
+	require.Equal(t, "DE", certificate.Issuer)
+	require.Equal(t, dcc.AlgorithmES256, certificate.Algorithm)
+	require.Len(t, certificate.KeyID, 8)
+	require.NotZero(t, certificate.IssuedAt)
+	require.NotZero(t, certificate.ExpiresAt)
+	require.Equal(t, "1.3.0", certificate.Payload.Version)
+	require.Len(t, certificate.Payload.Vaccinations, 1)
+	require.Equal(t, 1, certificate.Payload.Vaccinations[0].SeriesDoses)
+
+	card := certificate.Card()
+	require.Equal(t, "DE", card.Issuer)
+	require.Equal(t, qr, string(card.Raw))
+	require.Equal(t, "Erika", card.PatientGivenName)
+	require.Equal(t, "Musterfrau", card.PatientFamilyName)
+	require.Equal(t, "1964-08-12", card.PatientBirthDate)
+	require.NotNil(t, card.TimeClaims.ExpiresAt)
+	require.NotNil(t, card.TimeClaims.IssuedAt)
+	require.Len(t, card.Doses, 1)
+	require.Equal(t, vaccinemd.Coding{System: vaccinemd.EUProductSystem, Code: "EU/1/20/1525"}, card.Doses[0].Coding)
+	require.Equal(t, []vaccinemd.Coding{{System: vaccinemd.ATCSystem, Code: "J07BX03"}}, card.Doses[0].Codings)
+	require.Equal(t, &vaccinemd.Coding{System: vaccinemd.EUOrgSystem, Code: "ORG-100001417"}, card.Doses[0].Manufacturer)
+	require.Equal(t, "2021-06-01", card.Doses[0].OccurrenceDateTime)
+
+	t.Run("should not parse invalid certificates", func(t *testing.T) {
+		for _, input := range []string{"", strings.TrimPrefix(qr, dcc.Prefix), "HC1:", "HC1:@@@", qr[:len(qr)-10]} {
+			_, err := dcc.Parse(input)
+			require.Error(t, err, input)
+		}
+	})
+}
+
+func Test_KeyVerifier(t *testing.T) {
+
+	certificate, err := dcc.Parse(readTestCertificate(t))
+	require.NoError(t, err)
+
+	var trust struct {
+		DCCKeySets map[string]json.RawMessage `json:"dcc_key_sets"`
+	}
+	data, err := os.ReadFile("../testdata/trust.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &trust))
+
+	keys, err := dcc.ParseKeySet(trust.DCCKeySets["DE"])
+	require.NoError(t, err)
+	require.Contains(t, keys, certificate.KeyIDString())
+	require.NoError(t, certificate.Verify(keys[certificate.KeyIDString()]))
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	require.Error(t, certificate.Verify(&other.PublicKey))
+
+	result, err := dcc.NewKeyVerifier(map[string]map[string]crypto.PublicKey{"DE": keys}).
+		VerifySignature(context.Background(), certificate.Card())
+	require.NoError(t, err)
+	require.True(t, result.Checked && result.FetchedKey && result.Valid)
+
+	result, err = dcc.NewKeyVerifier(nil).VerifySignature(context.Background(), certificate.Card())
+	require.NoError(t, err)
+	require.True(t, result.Checked)
+	require.False(t, result.FetchedKey)
+
+	t.Run("should only verify with a key of the certificate's country", func(t *testing.T) {
+		result, err := dcc.NewKeyVerifier(map[string]map[string]crypto.PublicKey{"XA": keys}).
+			VerifySignature(context.Background(), certificate.Card())
+		require.NoError(t, err)
+		require.False(t, result.FetchedKey)
+	})
+
+	t.Run("should not verify a card whose issuer is not the certificate's", func(t *testing.T) {
+		card := certificate.Card()
+		card.Issuer = "XA"
+		result, err := dcc.NewKeyVerifier(map[string]map[string]crypto.PublicKey{"DE": keys}).
+			VerifySignature(context.Background(), card)
+		require.NoError(t, err)
+		require.True(t, result.FetchedKey)
+		require.False(t, result.Valid)
+	})
+}
+
+func readTestCertificate(t *testing.T) string {
+	data, err := os.ReadFile("../testdata/certificate.hc1.txt")
+	require.NoError(t, err)
+	return strings.TrimSpace(string(data))
+}
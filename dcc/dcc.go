@@ -0,0 +1,189 @@
+//Package dcc decodes EU Digital COVID Certificates from their HC1: QR codes, see
+//https://ec.europa.eu/health/ehealth/covid-19_en, and converts them to cards that can be verified.
+//
+//A QR code is HC1: followed by the Base45 of the zlib compressed COSE_Sign1 whose payload is a CBOR Web Token,
+//the certificate itself is in the CWT hcert claim. Signer issues certificates for tests and interop
+package dcc
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+//Prefix the context identifier at the start of a certificate's QR code
+const Prefix = "HC1:"
+
+//SchemaVersion the DCC schema version certificates are issued with
+const SchemaVersion = "1.3.0"
+
+//Payload the certificate, a person and their vaccinations, tests or recoveries. Usually only one of
+//Vaccinations, Tests or Recoveries is set
+type Payload struct {
+	Version      string         `json:"ver"`
+	Name         *Name          `json:"nam"`
+	DateOfBirth  string         `json:"dob"`
+	Vaccinations []*Vaccination `json:"v,omitempty"`
+	Tests        []*Test        `json:"t,omitempty"`
+	Recoveries   []*Recovery    `json:"r,omitempty"`
+}
+
+//Name the person's name as written and in the ICAO 9303 machine readable form
+type Name struct {
+	FamilyName               string `json:"fn,omitempty"`
+	FamilyNameTransliterated string `json:"fnt"`
+	GivenName                string `json:"gn,omitempty"`
+	GivenNameTransliterated  string `json:"gnt,omitempty"`
+}
+
+//Vaccination a vaccination entry
+type Vaccination struct {
+
+	//TargetDisease tg, SNOMED CT 840539006 for COVID-19
+	TargetDisease string `json:"tg"`
+
+	//VaccineProphylaxis vp, a SNOMED CT or ATC class code
+	VaccineProphylaxis string `json:"vp"`
+
+	//MedicinalProduct mp, an EU product code such as EU/1/20/1528
+	MedicinalProduct string `json:"mp"`
+
+	//Manufacturer ma, an EU organisation code such as ORG-100030215
+	Manufacturer string `json:"ma"`
+
+	//DoseNumber dn, the number of this dose in the series
+	DoseNumber int `json:"dn"`
+
+	//SeriesDoses sd, the number of doses in the series
+	SeriesDoses int `json:"sd"`
+
+	//Date dt, when the dose was administered as YYYY-MM-DD
+	Date string `json:"dt"`
+
+	//Country co, the ISO 3166 country the dose was administered in
+	Country string `json:"co"`
+
+	//Issuer is, who issued the certificate
+	Issuer string `json:"is"`
+
+	//CertificateID ci, the unique certificate identifier
+	CertificateID string `json:"ci"`
+}
+
+//Test a test entry
+type Test struct {
+	TargetDisease   string `json:"tg"`
+	TestType        string `json:"tt"`
+	Name            string `json:"nm,omitempty"`
+	Manufacturer    string `json:"ma,omitempty"`
+	SampleCollected string `json:"sc"`
+	Result          string `json:"tr"`
+	TestingCentre   string `json:"tc,omitempty"`
+	Country         string `json:"co"`
+	Issuer          string `json:"is"`
+	CertificateID   string `json:"ci"`
+}
+
+//Recovery a recovery entry
+type Recovery struct {
+	TargetDisease string `json:"tg"`
+	FirstResult   string `json:"fr"`
+	Country       string `json:"co"`
+	Issuer        string `json:"is"`
+	ValidFrom     string `json:"df"`
+	ValidUntil    string `json:"du"`
+	CertificateID string `json:"ci"`
+}
+
+//Dose the vaccination as a dose, the product is the Coding and the class code is in Codings
+func (v *Vaccination) Dose() *pdm.Dose {
+
+	dose := &pdm.Dose{
+		Coding:             vaccinemd.Coding{System: vaccinemd.EUProductSystem, Code: v.MedicinalProduct},
+		Status:             pdm.CodeCompleted,
+		OccurrenceDateTime: v.Date,
+		Site:               v.Country,
+		Country:            v.Country,
+		DoseNumber:         v.DoseNumber,
+		SeriesDoses:        v.SeriesDoses,
+	}
+
+	if v.VaccineProphylaxis != "" {
+		dose.Codings = append(dose.Codings, vaccinemd.Coding{System: prophylaxisSystem(v.VaccineProphylaxis), Code: v.VaccineProphylaxis})
+	}
+
+	if v.Manufacturer != "" {
+		dose.Manufacturer = &vaccinemd.Coding{System: vaccinemd.EUOrgSystem, Code: v.Manufacturer}
+	}
+
+	return dose
+}
+
+//prophylaxisSystem the value set allows SNOMED CT concept IDs, which are all digits, and ATC codes
+func prophylaxisSystem(code string) string {
+	if strings.Trim(code, "0123456789") == "" {
+		return vaccinemd.SNOMEDSystem
+	}
+	return vaccinemd.ATCSystem
+}
+
+//payloadFromCBOR the hcert claim's map converted to a payload, the CBOR map has the JSON schema's keys
+func payloadFromCBOR(value interface{}) (*Payload, error) {
+
+	converted, err := jsonValue(value)
+	if err != nil {
+		return nil, err
+	}
+
+	b, err := json.Marshal(converted)
+	if err != nil {
+		return nil, fmt.Errorf("error dcc payload err=%s", err)
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(b, &payload); err != nil {
+		return nil, fmt.Errorf("error dcc payload err=%s", err)
+	}
+
+	return &payload, nil
+}
+
+//jsonValue converts decoded CBOR to values encoding/json can marshal, map keys must be strings
+func jsonValue(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case map[interface{}]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			s, ok := key.(string)
+			if !ok {
+				return nil, fmt.Errorf("error dcc payload map key type=%T", key)
+			}
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[s] = converted
+		}
+		return result, nil
+
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := jsonValue(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = converted
+		}
+		return result, nil
+
+	case []byte:
+		return nil, fmt.Errorf("error dcc payload unexpected byte string")
+	}
+
+	return value, nil
+}
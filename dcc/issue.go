@@ -0,0 +1,533 @@
+package dcc
+
+import (
+	"bytes"
+	"compress/zlib"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/base45"
+	"github.com/webshield-dev/dhc-common/internal/cbor"
+	"github.com/webshield-dev/dhc-common/internal/jws"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+//
+// Issuing is the reverse of Parse, the payload is put in the hcert claim of a CWT that is signed as a COSE_Sign1,
+// zlib compressed and Base45 encoded after the HC1: prefix. It is intended for tests and interop, a real
+// certificate is signed with a Document Signer key whose certificate is on the EU trust list.
+//
+
+const (
+	//DefaultValidity how long a certificate is valid for if the expiry is not set
+	DefaultValidity = 365 * 24 * time.Hour
+
+	//keyIDLen the length of a kid, the first 8 bytes of a SHA-256
+	keyIDLen = 8
+
+	//minRSAKeyBits the smallest RSA key the spec allows for PS256
+	minRSAKeyBits = 2048
+)
+
+//Person who a certificate is issued to
+type Person struct {
+
+	//GivenName the person's given names
+	GivenName string
+
+	//FamilyName the person's family name
+	FamilyName string
+
+	//DateOfBirth the date of birth as YYYY-MM-DD, YYYY-MM or YYYY
+	DateOfBirth string
+}
+
+//Record what a certificate is issued for. The spec allows one entry per certificate so exactly one of Doses,
+//Tests or Recoveries is set, and only one test or recovery
+type Record struct {
+
+	//Person who the certificate is for
+	Person *Person
+
+	//Doses the administered doses of the series, only the latest becomes the vaccination entry
+	Doses []*pdm.Dose
+
+	//DoseNumber the dn of the latest dose, if zero the number of administered doses, so the second dose of a
+	//series can be issued alone
+	DoseNumber int
+
+	//SeriesDoses the sd of the latest dose, if zero the number of doses the vaccine requires
+	SeriesDoses int
+
+	//Tests the test entry, the country, issuer and certificate ID are filled in if empty
+	Tests []*Test
+
+	//Recoveries the recovery entry, the country, issuer and certificate ID are filled in if empty
+	Recoveries []*Recovery
+
+	//Country co, the ISO 3166 country the entries are for
+	Country string
+
+	//CertificateIssuer is, the organisation issuing the certificate
+	CertificateIssuer string
+}
+
+//MakePayload the certificate payload for the record, it has exactly one vaccination, test or recovery entry.
+//The latest administered dose is issued, its mp, vp and ma are taken from its codings and manufacturer, or the
+//vaccine the codings resolve to in the repo, so a dose from a SMART Health Card can be issued as a certificate.
+//If repo is nil the default repo is used
+func MakePayload(record *Record, repo vaccinemd.Repo) (*Payload, error) {
+
+	if record == nil || record.Person == nil || record.Country == "" {
+		return nil, fmt.Errorf("error dcc make payload a person and country are required")
+	}
+
+	latest, administered, err := latestDose(record.Doses)
+	if err != nil {
+		return nil, err
+	}
+
+	entries := len(record.Tests) + len(record.Recoveries)
+	if latest != nil {
+		entries++
+	}
+	if entries != 1 {
+		return nil, fmt.Errorf("error dcc make payload exactly one dose, test or recovery is required got=%d", entries)
+	}
+
+	if repo == nil {
+		repo = vaccinemd.MakeRepo()
+	}
+
+	payload := &Payload{
+		Version: SchemaVersion,
+		Name: &Name{
+			FamilyName:               record.Person.FamilyName,
+			FamilyNameTransliterated: transliterate(record.Person.FamilyName),
+			GivenName:                record.Person.GivenName,
+			GivenNameTransliterated:  transliterate(record.Person.GivenName),
+		},
+		DateOfBirth: record.Person.DateOfBirth,
+	}
+
+	if latest != nil {
+
+		vaccination, err := makeVaccination(latest, repo)
+		if err != nil {
+			return nil, fmt.Errorf("error dcc make payload %s", err)
+		}
+
+		vaccination.DoseNumber = administered
+		if record.DoseNumber > 0 {
+			vaccination.DoseNumber = record.DoseNumber
+		}
+		if record.SeriesDoses > 0 {
+			vaccination.SeriesDoses = record.SeriesDoses
+		}
+		vaccination.Country = record.Country
+		vaccination.Issuer = record.CertificateIssuer
+		if vaccination.CertificateID, err = makeCertificateID(record.Country); err != nil {
+			return nil, err
+		}
+
+		payload.Vaccinations = append(payload.Vaccinations, vaccination)
+	}
+
+	for _, test := range record.Tests {
+		t := *test
+		if err := fillEntry(&t.TargetDisease, &t.Country, &t.Issuer, &t.CertificateID, record); err != nil {
+			return nil, err
+		}
+		payload.Tests = append(payload.Tests, &t)
+	}
+
+	for _, recovery := range record.Recoveries {
+		r := *recovery
+		if err := fillEntry(&r.TargetDisease, &r.Country, &r.Issuer, &r.CertificateID, record); err != nil {
+			return nil, err
+		}
+		payload.Recoveries = append(payload.Recoveries, &r)
+	}
+
+	return payload, nil
+}
+
+//latestDose the administered dose with the latest date and the number of administered doses, nil if none.
+//Every administered dose must have a full date so the latest is certain
+func latestDose(doses []*pdm.Dose) (*pdm.Dose, int, error) {
+
+	var latest *pdm.Dose
+	var latestOccurrence *pdm.DateTime
+	administered := 0
+
+	for i, dose := range doses {
+
+		if !dose.Administered() {
+			continue
+		}
+		administered++
+
+		occurrence := pdm.DoseOccurrence(dose)
+		if occurrence == nil || occurrence.IsPartial() {
+			return nil, 0, fmt.Errorf("error dcc make payload dose=%d dose must have a date", i)
+		}
+
+		if latestOccurrence == nil || occurrence.Time.After(latestOccurrence.Time) {
+			latest = dose
+			latestOccurrence = occurrence
+		}
+	}
+
+	return latest, administered, nil
+}
+
+//makeVaccination the vaccination for the dose, the reverse of Vaccination Dose. The series doses are the
+//vaccine's doses if it is known
+func makeVaccination(dose *pdm.Dose, repo vaccinemd.Repo) (*Vaccination, error) {
+
+	occurrence := pdm.DoseOccurrence(dose)
+	if occurrence == nil || occurrence.IsPartial() {
+		return nil, fmt.Errorf("dose must have a date")
+	}
+
+	vaccination := &Vaccination{
+		TargetDisease: vaccinemd.DiseaseCOVID19.Code,
+		Date:          occurrence.Time.Format("2006-01-02"),
+	}
+
+	codings := dose.AllCodings()
+	for _, coding := range codings {
+		switch {
+		case coding.System == vaccinemd.EUProductSystem && vaccination.MedicinalProduct == "":
+			vaccination.MedicinalProduct = coding.Code
+		case (coding.System == vaccinemd.SNOMEDSystem || coding.System == vaccinemd.ATCSystem) &&
+			vaccination.VaccineProphylaxis == "":
+			vaccination.VaccineProphylaxis = coding.Code
+		}
+	}
+
+	if dose.Manufacturer != nil && dose.Manufacturer.System == vaccinemd.EUOrgSystem {
+		vaccination.Manufacturer = dose.Manufacturer.Code
+	}
+
+	if vaccine := repo.ResolveVaccine(codings).Vaccine; vaccine != nil {
+
+		vaccination.SeriesDoses = vaccine.Doses
+
+		if vaccination.MedicinalProduct == "" {
+			vaccination.MedicinalProduct = firstCode(vaccine.Codes, vaccinemd.EUProductSystem)
+		}
+		if vaccination.VaccineProphylaxis == "" {
+			vaccination.VaccineProphylaxis = firstCode(vaccine.GenericCodes, vaccinemd.SNOMEDSystem)
+		}
+		if vaccination.Manufacturer == "" && vaccine.ManufacturerID != "" {
+			if manufacturer := repo.FindManufacturerByID(vaccine.ManufacturerID); manufacturer != nil &&
+				len(manufacturer.EUOrgCodes) > 0 {
+				vaccination.Manufacturer = manufacturer.EUOrgCodes[0]
+			}
+		}
+	}
+
+	if vaccination.MedicinalProduct == "" || vaccination.VaccineProphylaxis == "" || vaccination.Manufacturer == "" {
+		return nil, fmt.Errorf("cannot find the mp, vp and ma got=%s,%s,%s", vaccination.MedicinalProduct,
+			vaccination.VaccineProphylaxis, vaccination.Manufacturer)
+	}
+
+	if vaccination.SeriesDoses == 0 {
+		vaccination.SeriesDoses = 1
+	}
+
+	return vaccination, nil
+}
+
+//fillEntry set the target disease, country, issuer and certificate ID of a test or recovery if empty
+func fillEntry(targetDisease *string, country *string, issuer *string, certificateID *string, record *Record) error {
+
+	if *targetDisease == "" {
+		*targetDisease = vaccinemd.DiseaseCOVID19.Code
+	}
+	if *country == "" {
+		*country = record.Country
+	}
+	if *issuer == "" {
+		*issuer = record.CertificateIssuer
+	}
+
+	if *certificateID == "" {
+		var err error
+		if *certificateID, err = makeCertificateID(*country); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func firstCode(codings []vaccinemd.Coding, system string) string {
+	for _, coding := range codings {
+		if coding.System == system {
+			return coding.Code
+		}
+	}
+	return ""
+}
+
+//makeCertificateID a random UVCI for the country, URN:UVCI:01:DE:...
+func makeCertificateID(country string) (string, error) {
+	b := make([]byte, 12)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("error dcc certificate id err=%s", err)
+	}
+	return fmt.Sprintf("URN:UVCI:01:%s:%s", country, strings.ToUpper(hex.EncodeToString(b))), nil
+}
+
+//transliterate the ICAO 9303 machine readable form of a name, upper case A-Z with < between names. Only ASCII
+//letters are kept, other letters need the ICAO transliteration tables so test names should be ASCII
+func transliterate(name string) string {
+	var b strings.Builder
+	for _, field := range strings.FieldsFunc(strings.ToUpper(name), func(r rune) bool { return r == ' ' || r == '-' }) {
+		if b.Len() > 0 {
+			b.WriteByte('<')
+		}
+		for _, r := range field {
+			if r >= 'A' && r <= 'Z' {
+				b.WriteRune(r)
+			}
+		}
+	}
+	return b.String()
+}
+
+//Signer signs certificates with a Document Signer key, safe for concurrent use
+type Signer struct {
+	issuer    string
+	key       crypto.Signer
+	algorithm int64
+	keyID     []byte
+}
+
+//NewSigner a signer for the issuing country, a P-256 key signs with ES256 and an RSA key with PS256. The keyID is
+//the trust list kid, the first 8 bytes of the SHA-256 of the Document Signer Certificate, if nil the first 8
+//bytes of the SHA-256 of the public key are used
+func NewSigner(issuer string, key crypto.Signer, keyID []byte) (*Signer, error) {
+
+	if issuer == "" {
+		return nil, fmt.Errorf("error dcc new signer an issuer is required")
+	}
+
+	signer := &Signer{issuer: issuer, key: key}
+
+	switch k := key.(type) {
+	case *ecdsa.PrivateKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("error dcc new signer ES256 requires a P-256 key")
+		}
+		signer.algorithm = AlgorithmES256
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("error dcc new signer PS256 requires an RSA key of at least %d bits", minRSAKeyBits)
+		}
+		signer.algorithm = AlgorithmPS256
+	default:
+		return nil, fmt.Errorf("error dcc new signer unsupported key type=%T", key)
+	}
+
+	signer.keyID = keyID
+	if signer.keyID == nil {
+		der, err := x509.MarshalPKIXPublicKey(key.Public())
+		if err != nil {
+			return nil, fmt.Errorf("error dcc new signer err=%s", err)
+		}
+		digest := sha256.Sum256(der)
+		signer.keyID = digest[:keyIDLen]
+	}
+
+	return signer, nil
+}
+
+//KeyID the kid as standard base64, as EU trust lists publish it
+func (s *Signer) KeyID() string {
+	return base64.StdEncoding.EncodeToString(s.keyID)
+}
+
+//KeySet the public key as a JWK set for the signer's country in a trust file's dcc_key_sets, see ParseKeySet
+func (s *Signer) KeySet() ([]byte, error) {
+
+	jwk, err := jws.PublicJWK(s.key.Public())
+	if err != nil {
+		return nil, fmt.Errorf("error dcc key set err=%s", err)
+	}
+	jwk.KeyID = s.KeyID()
+
+	data, err := json.Marshal(&jws.JWKSet{Keys: []*jws.JWK{jwk}})
+	if err != nil {
+		return nil, fmt.Errorf("error dcc key set err=%s", err)
+	}
+
+	return data, nil
+}
+
+//Sign the payload as a certificate issued at issuedAt, if zero now, that expires at expiresAt, if zero
+//DefaultValidity after it is issued
+func (s *Signer) Sign(payload *Payload, issuedAt time.Time, expiresAt time.Time) (*Certificate, error) {
+
+	if payload == nil || payload.Name == nil {
+		return nil, fmt.Errorf("error dcc sign payload with a nam is required")
+	}
+
+	if issuedAt.IsZero() {
+		issuedAt = time.Now()
+	}
+	if expiresAt.IsZero() {
+		expiresAt = issuedAt.Add(DefaultValidity)
+	}
+
+	hcert, err := cborValue(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	cwt, err := cbor.Marshal(map[int64]interface{}{
+		claimIssuer:    s.issuer,
+		claimIssuedAt:  issuedAt.Unix(),
+		claimExpiresAt: expiresAt.Unix(),
+		claimHCert:     map[int64]interface{}{hcertDCC: hcert},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error dcc sign cwt err=%s", err)
+	}
+
+	protected, err := cbor.Marshal(map[int64]interface{}{headerAlgorithm: s.algorithm, headerKeyID: s.keyID})
+	if err != nil {
+		return nil, fmt.Errorf("error dcc sign protected header err=%s", err)
+	}
+
+	signature, err := s.sign(protected, cwt)
+	if err != nil {
+		return nil, err
+	}
+
+	cose, err := cbor.Marshal(cbor.Tag{
+		Number:  tagCOSESign1,
+		Content: []interface{}{protected, map[int64]interface{}{}, cwt, signature},
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error dcc sign cose err=%s", err)
+	}
+
+	var compressed bytes.Buffer
+	writer, err := zlib.NewWriterLevel(&compressed, zlib.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("error dcc sign deflate err=%s", err)
+	}
+	if _, err := writer.Write(cose); err != nil {
+		return nil, fmt.Errorf("error dcc sign deflate err=%s", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error dcc sign deflate err=%s", err)
+	}
+
+	return Parse(Prefix + base45.Encode(compressed.Bytes()))
+}
+
+//sign the Sig_structure of the protected header and CWT, see Certificate Verify
+func (s *Signer) sign(protected []byte, cwt []byte) ([]byte, error) {
+
+	sigStructure, err := cbor.Marshal([]interface{}{"Signature1", protected, []byte{}, cwt})
+	if err != nil {
+		return nil, fmt.Errorf("error dcc sign err=%s", err)
+	}
+	digest := sha256.Sum256(sigStructure)
+
+	switch k := s.key.(type) {
+	case *ecdsa.PrivateKey:
+		r, ss, err := ecdsa.Sign(rand.Reader, k, digest[:])
+		if err != nil {
+			return nil, fmt.Errorf("error dcc sign err=%s", err)
+		}
+		signature := make([]byte, 2*es256ByteLen)
+		r.FillBytes(signature[:es256ByteLen])
+		ss.FillBytes(signature[es256ByteLen:])
+		return signature, nil
+
+	case *rsa.PrivateKey:
+		options := &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash, Hash: crypto.SHA256}
+		signature, err := rsa.SignPSS(rand.Reader, k, crypto.SHA256, digest[:], options)
+		if err != nil {
+			return nil, fmt.Errorf("error dcc sign err=%s", err)
+		}
+		return signature, nil
+	}
+
+	return nil, fmt.Errorf("error dcc sign unsupported key type=%T", s.key)
+}
+
+//cborValue the payload as CBOR maps with the JSON schema's keys, the reverse of payloadFromCBOR. Numbers are
+//integers as dn and sd are
+func cborValue(payload *Payload) (interface{}, error) {
+
+	b, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error dcc payload err=%s", err)
+	}
+
+	decoder := json.NewDecoder(bytes.NewReader(b))
+	decoder.UseNumber()
+	var value interface{}
+	if err := decoder.Decode(&value); err != nil {
+		return nil, fmt.Errorf("error dcc payload err=%s", err)
+	}
+
+	return fromJSON(value)
+}
+
+func fromJSON(value interface{}) (interface{}, error) {
+
+	switch v := value.(type) {
+	case map[string]interface{}:
+		result := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			converted, err := fromJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			result[key] = converted
+		}
+		return result, nil
+
+	case []interface{}:
+		result := make([]interface{}, len(v))
+		for i, item := range v {
+			converted, err := fromJSON(item)
+			if err != nil {
+				return nil, err
+			}
+			result[i] = converted
+		}
+		return result, nil
+
+	case json.Number:
+		if i, err := v.Int64(); err == nil {
+			return i, nil
+		}
+		f, err := v.Float64()
+		if err != nil {
+			return nil, fmt.Errorf("error dcc payload number=%s", v)
+		}
+		return f, nil
+	}
+
+	return value, nil
+}
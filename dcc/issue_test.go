@@ -0,0 +1,252 @@
+package dcc_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/dcc"
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+const testTrust = "../testdata/dcc-test-trust.json"
+
+func Test_Sign(t *testing.T) {
+
+	trust, err := pipeline.LoadTrustPaths(testTrust)
+	require.NoError(t, err)
+	policies, err := pipeline.SelectPolicies("eu")
+	require.NoError(t, err)
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            policies[0],
+		PolicyVersions:    policies[1:],
+		SignatureVerifier: trust.SignatureVerifier,
+		IssuerTrustStore:  trust.IssuerTrustStore,
+	})
+	require.NoError(t, err)
+
+	//a single dose vaccine recorded with CVX as on a SMART Health Card
+	record := &dcc.Record{
+		Person: &dcc.Person{GivenName: "Jane Marie", FamilyName: "Müller-Smith", DateOfBirth: "1980-02-29"},
+		Doses: []*pdm.Dose{{
+			Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "212"},
+			OccurrenceDateTime: "2021-04-06",
+		}},
+		Country:           "XA",
+		CertificateIssuer: "Test Issuer",
+	}
+
+	payload, err := dcc.MakePayload(record, nil)
+	require.NoError(t, err)
+	require.Len(t, payload.Vaccinations, 1)
+	vaccination := payload.Vaccinations[0]
+	require.Equal(t, "EU/1/20/1525", vaccination.MedicinalProduct)
+	require.NotEmpty(t, vaccination.VaccineProphylaxis)
+	require.NotEmpty(t, vaccination.Manufacturer)
+	require.Equal(t, 1, vaccination.DoseNumber)
+	require.Equal(t, 1, vaccination.SeriesDoses)
+	require.Regexp(t, "^URN:UVCI:01:XA:", vaccination.CertificateID)
+	require.Equal(t, "MLLER<SMITH", payload.Name.FamilyNameTransliterated)
+	require.Equal(t, "JANE<MARIE", payload.Name.GivenNameTransliterated)
+
+	issuedAt := time.Date(2021, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, keyFile := range []string{"../testdata/dcc-signer-es256.pem", "../testdata/dcc-signer-ps256.pem"} {
+		t.Run("should sign with "+keyFile, func(t *testing.T) {
+
+			signer, err := dcc.NewSigner("XA", readTestKey(t, keyFile), nil)
+			require.NoError(t, err)
+
+			certificate, err := signer.Sign(payload, issuedAt, time.Time{})
+			require.NoError(t, err)
+			require.Equal(t, signer.KeyID(), certificate.KeyIDString())
+			require.Equal(t, "XA", certificate.Issuer)
+			require.Equal(t, issuedAt.Unix(), certificate.IssuedAt)
+			require.Equal(t, issuedAt.Add(dcc.DefaultValidity).Unix(), certificate.ExpiresAt)
+			require.Equal(t, payload, certificate.Payload)
+
+			//the certificate round trips through its QR code
+			parsed, err := dcc.Parse(certificate.QR)
+			require.NoError(t, err)
+			require.Equal(t, certificate.Payload, parsed.Payload)
+
+			keySet, err := signer.KeySet()
+			require.NoError(t, err)
+			keys, err := dcc.ParseKeySet(keySet)
+			require.NoError(t, err)
+			require.NoError(t, parsed.Verify(keys[signer.KeyID()]))
+
+			card := parsed.Card()
+			require.Equal(t, "Müller-Smith", card.PatientFamilyName)
+			require.Equal(t, "2021-04-06", card.Doses[0].OccurrenceDateTime)
+
+			results, err := verifier.VerifyAt(context.Background(), card, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, err)
+			require.Equal(t, verification.CardVerificationStateValid, results.State, verification.FailedChecks(results))
+		})
+	}
+
+	t.Run("should number doses from the dose number", func(t *testing.T) {
+		payload, err := dcc.MakePayload(&dcc.Record{
+			Person: record.Person,
+			Doses: []*pdm.Dose{{
+				Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"},
+				OccurrenceDateTime: "2021-04-06",
+			}},
+			DoseNumber: 2,
+			Country:    "XA",
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "EU/1/20/1507", payload.Vaccinations[0].MedicinalProduct)
+		require.Equal(t, 2, payload.Vaccinations[0].DoseNumber)
+		require.Equal(t, 2, payload.Vaccinations[0].SeriesDoses)
+	})
+
+	t.Run("should verify the series from the dose number", func(t *testing.T) {
+
+		signer, err := dcc.NewSigner("XA", readTestKey(t, "../testdata/dcc-signer-es256.pem"), nil)
+		require.NoError(t, err)
+
+		for _, tc := range []struct {
+			doseNumber    int
+			expectedState verification.CardVerificationState
+		}{
+			{doseNumber: 2, expectedState: verification.CardVerificationStateValid},
+			{doseNumber: 1, expectedState: verification.CardVerificationStateSafetyCriteriaNotMet},
+		} {
+			payload, err := dcc.MakePayload(&dcc.Record{
+				Person: record.Person,
+				Doses: []*pdm.Dose{{
+					Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"},
+					OccurrenceDateTime: "2021-04-06",
+				}},
+				DoseNumber: tc.doseNumber,
+				Country:    "XA",
+			}, nil)
+			require.NoError(t, err)
+
+			certificate, err := signer.Sign(payload, issuedAt, time.Time{})
+			require.NoError(t, err)
+
+			card := certificate.Card()
+			require.Len(t, card.Doses, 1)
+			require.Equal(t, tc.doseNumber, card.Doses[0].DoseNumber)
+			require.Equal(t, 2, card.Doses[0].SeriesDoses)
+			require.Equal(t, "XA", card.Doses[0].Country)
+
+			results, err := verifier.VerifyAt(context.Background(), card, time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC))
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedState, results.State, verification.FailedChecks(results))
+			if tc.doseNumber == 1 {
+				require.Equal(t, []string{verification.AuditRuleDosesRequired}, verification.FailedChecks(results))
+			}
+		}
+	})
+
+	t.Run("should issue only the latest dose", func(t *testing.T) {
+		payload, err := dcc.MakePayload(&dcc.Record{
+			Person: record.Person,
+			Doses: []*pdm.Dose{
+				{Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}, OccurrenceDateTime: "2021-04-06"},
+				{Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}, OccurrenceDateTime: "2021-03-09"},
+				{Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}, OccurrenceDateTime: "2021-05-01",
+					Status: pdm.CodeNotDone},
+			},
+			Country: "XA",
+		}, nil)
+		require.NoError(t, err)
+		require.Len(t, payload.Vaccinations, 1)
+		require.Equal(t, "2021-04-06", payload.Vaccinations[0].Date)
+		require.Equal(t, 2, payload.Vaccinations[0].DoseNumber)
+		require.Equal(t, 2, payload.Vaccinations[0].SeriesDoses)
+	})
+
+	t.Run("should sign a test", func(t *testing.T) {
+		payload, err := dcc.MakePayload(&dcc.Record{
+			Person:  record.Person,
+			Tests:   []*dcc.Test{{TestType: "LP6464-4", SampleCollected: "2021-05-01T10:00:00Z", Result: "260415000"}},
+			Country: "XA",
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "XA", payload.Tests[0].Country)
+		require.Empty(t, payload.Vaccinations)
+
+		signer, err := dcc.NewSigner("XA", readTestKey(t, "../testdata/dcc-signer-es256.pem"), []byte("12345678"))
+		require.NoError(t, err)
+		require.Equal(t, "MTIzNDU2Nzg=", signer.KeyID())
+
+		certificate, err := signer.Sign(payload, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, payload.Tests, certificate.Payload.Tests)
+		require.Empty(t, certificate.Card().Doses)
+	})
+
+	t.Run("should sign a recovery", func(t *testing.T) {
+		payload, err := dcc.MakePayload(&dcc.Record{
+			Person:     record.Person,
+			Recoveries: []*dcc.Recovery{{FirstResult: "2021-01-10", ValidFrom: "2021-01-29", ValidUntil: "2021-06-29"}},
+			Country:    "XA",
+		}, nil)
+		require.NoError(t, err)
+		require.Equal(t, "840539006", payload.Recoveries[0].TargetDisease)
+		require.Empty(t, payload.Vaccinations)
+
+		signer, err := dcc.NewSigner("XA", readTestKey(t, "../testdata/dcc-signer-es256.pem"), nil)
+		require.NoError(t, err)
+
+		certificate, err := signer.Sign(payload, time.Time{}, time.Time{})
+		require.NoError(t, err)
+		require.Equal(t, payload.Recoveries, certificate.Payload.Recoveries)
+		require.Empty(t, certificate.Card().Doses)
+	})
+
+	t.Run("should not make invalid payloads", func(t *testing.T) {
+		for _, invalid := range []*dcc.Record{
+			nil,
+			{Person: record.Person, Doses: record.Doses},
+			{Person: record.Person, Country: "XA"},
+			{Person: record.Person, Country: "XA", Doses: []*pdm.Dose{{
+				Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "999"}, OccurrenceDateTime: "2021-04-06"}}},
+			{Person: record.Person, Country: "XA", Doses: []*pdm.Dose{{
+				Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}, OccurrenceDateTime: "2021-04"}}},
+			{Person: record.Person, Country: "XA", Doses: []*pdm.Dose{{Status: pdm.CodeNotDone}}},
+			{Person: record.Person, Country: "XA", Doses: record.Doses, Tests: []*dcc.Test{{TestType: "LP6464-4"}}},
+			{Person: record.Person, Country: "XA", Tests: []*dcc.Test{{TestType: "LP6464-4"}}, Recoveries: []*dcc.Recovery{{}}},
+			{Person: record.Person, Country: "XA", Tests: []*dcc.Test{{TestType: "LP6464-4"}, {TestType: "LP217198-3"}}},
+			{Person: record.Person, Country: "XA", Recoveries: []*dcc.Recovery{{}, {}}},
+		} {
+			_, err := dcc.MakePayload(invalid, nil)
+			require.Error(t, err)
+		}
+	})
+
+	t.Run("should not sign with unsupported keys", func(t *testing.T) {
+		small, err := rsa.GenerateKey(rand.Reader, 1024)
+		require.NoError(t, err)
+		_, err = dcc.NewSigner("XA", small, nil)
+		require.Error(t, err)
+
+		_, err = dcc.NewSigner("", readTestKey(t, "../testdata/dcc-signer-es256.pem"), nil)
+		require.Error(t, err)
+	})
+}
+
+func readTestKey(t *testing.T, path string) crypto.Signer {
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	block, _ := pem.Decode(data)
+	require.NotNil(t, block)
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	require.NoError(t, err)
+	return key.(crypto.Signer)
+}
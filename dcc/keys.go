@@ -0,0 +1,84 @@
+package dcc
+
+import (
+	"context"
+	"crypto"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/jws"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//ParseKeySet the Document Signer keys in a JWK set keyed by kid, each key's kid must be the standard base64
+//kid from the trust list. EC P-256 and RSA keys are supported
+func ParseKeySet(data []byte) (map[string]crypto.PublicKey, error) {
+
+	var set jws.JWKSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error dcc parse key set err=%s", err)
+	}
+
+	keys := make(map[string]crypto.PublicKey)
+	for _, jwk := range set.Keys {
+
+		if jwk.KeyID == "" {
+			return nil, fmt.Errorf("error dcc parse key set key has no kid")
+		}
+
+		public, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("error dcc parse key set kid=%s err=%s", jwk.KeyID, err)
+		}
+		keys[jwk.KeyID] = public
+	}
+
+	return keys, nil
+}
+
+//NewKeyVerifier a signature verifier for cards whose Raw is an HC1: QR code, countryKeys are the Document Signer
+//keys of each issuing country keyed by country then the standard base64 kid, see ParseKeySet. A certificate is
+//only verified with a key of the country in its iss so a country's key cannot sign for another country, a
+//certificate from a country or with a kid that is not known is reported as the key not being fetched
+func NewKeyVerifier(countryKeys map[string]map[string]crypto.PublicKey) verification.SignatureVerifier {
+
+	copied := make(map[string]map[string]crypto.PublicKey, len(countryKeys))
+	for country, keys := range countryKeys {
+		copied[country] = make(map[string]crypto.PublicKey, len(keys))
+		for kid, key := range keys {
+			copied[country][kid] = key
+		}
+	}
+
+	return &keyVerifier{countryKeys: copied}
+}
+
+//keyVerifier never modified once made so safe for concurrent use
+type keyVerifier struct {
+	countryKeys map[string]map[string]crypto.PublicKey
+}
+
+func (kv *keyVerifier) VerifySignature(_ context.Context, card *verification.Card) (*verification.SignatureResult, error) {
+
+	certificate, err := Parse(string(card.Raw))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &verification.SignatureResult{Checked: true}
+
+	//the certificate's iss is signed so use it rather than the card's Issuer which the caller could have changed
+	fetchStarted := time.Now()
+	key, ok := kv.countryKeys[certificate.Issuer][certificate.KeyIDString()]
+	result.KeyFetchDuration = time.Since(fetchStarted)
+	if !ok {
+		return result, nil
+	}
+	result.FetchedKey = true
+
+	//the issuer trusted is the card's so it must be the country whose key signed it
+	result.Valid = card.Issuer == certificate.Issuer && certificate.Verify(key) == nil
+
+	return result, nil
+}
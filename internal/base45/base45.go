@@ -0,0 +1,66 @@
+//Package base45 the Base45 encoding of RFC 9285 used by EU Digital COVID Certificate QR codes
+package base45
+
+import (
+	"fmt"
+	"strings"
+)
+
+const alphabet = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZ $%*+-./:"
+
+//Encode encode the bytes, each pair of bytes becomes three characters and a final single byte two
+func Encode(data []byte) string {
+
+	var b strings.Builder
+	for i := 0; i+1 < len(data); i += 2 {
+		n := int(data[i])*256 + int(data[i+1])
+		b.WriteByte(alphabet[n%45])
+		b.WriteByte(alphabet[(n/45)%45])
+		b.WriteByte(alphabet[n/(45*45)])
+	}
+
+	if len(data)%2 == 1 {
+		n := int(data[len(data)-1])
+		b.WriteByte(alphabet[n%45])
+		b.WriteByte(alphabet[n/45])
+	}
+
+	return b.String()
+}
+
+//Decode decode the string, an error if it has characters outside the alphabet or is not a valid length
+func Decode(value string) ([]byte, error) {
+
+	if len(value)%3 == 1 {
+		return nil, fmt.Errorf("error base45 decode invalid length got=%d", len(value))
+	}
+
+	digits := make([]int, len(value))
+	for i := 0; i < len(value); i++ {
+		digits[i] = strings.IndexByte(alphabet, value[i])
+		if digits[i] < 0 {
+			return nil, fmt.Errorf("error base45 decode invalid character at=%d", i)
+		}
+	}
+
+	result := make([]byte, 0, len(value)*2/3)
+	for i := 0; i < len(digits); i += 3 {
+
+		if i+2 < len(digits) {
+			n := digits[i] + digits[i+1]*45 + digits[i+2]*45*45
+			if n > 0xffff {
+				return nil, fmt.Errorf("error base45 decode invalid triplet at=%d", i)
+			}
+			result = append(result, byte(n>>8), byte(n))
+			continue
+		}
+
+		n := digits[i] + digits[i+1]*45
+		if n > 0xff {
+			return nil, fmt.Errorf("error base45 decode invalid pair at=%d", i)
+		}
+		result = append(result, byte(n))
+	}
+
+	return result, nil
+}
@@ -0,0 +1,33 @@
+package base45_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/internal/base45"
+)
+
+func Test_Base45(t *testing.T) {
+
+	//examples from RFC 9285
+	require.Equal(t, "BB8", base45.Encode([]byte("AB")))
+	require.Equal(t, "%69 VD92EX0", base45.Encode([]byte("Hello!!")))
+	require.Equal(t, "UJCLQE7W581", base45.Encode([]byte("base-45")))
+
+	decoded, err := base45.Decode("QED8WEX0")
+	require.NoError(t, err)
+	require.Equal(t, "ietf!", string(decoded))
+
+	for _, value := range []string{"", "a", "ab", "abc", "\x00\xff\x10"} {
+		decoded, err := base45.Decode(base45.Encode([]byte(value)))
+		require.NoError(t, err)
+		require.Equal(t, value, string(decoded))
+	}
+
+	_, err = base45.Decode("GGW")
+	require.Error(t, err, "triplet over 65535")
+	_, err = base45.Decode("ab")
+	require.Error(t, err, "lower case is not in the alphabet")
+	_, err = base45.Decode("BB8A")
+	require.Error(t, err, "invalid length")
+}
@@ -0,0 +1,445 @@
+//Package cbor a minimal CBOR (RFC 8949) encoder and decoder for COSE and CWT, see package dcc.
+//
+//Decoded values are int64 for integers, float64, bool, nil, string, []byte, []interface{},
+//map[interface{}]interface{} and Tag. Maps are encoded in the deterministic order of RFC 8949 4.2.1
+package cbor
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"sort"
+)
+
+const (
+	majorUnsigned = 0
+	majorNegative = 1
+	majorBytes    = 2
+	majorText     = 3
+	majorArray    = 4
+	majorMap      = 5
+	majorTag      = 6
+	majorSimple   = 7
+
+	additionalIndefinite = 31
+	breakCode            = 0xff
+
+	maxDepth = 32
+)
+
+//Tag a tagged value, for example COSE_Sign1 is tag 18
+type Tag struct {
+	Number  uint64
+	Content interface{}
+}
+
+//Decode decode a single CBOR data item, trailing bytes are an error
+func Decode(data []byte) (interface{}, error) {
+
+	d := &decoder{data: data}
+	value, err := d.decode(0)
+	if err != nil {
+		return nil, err
+	}
+
+	if d.pos != len(d.data) {
+		return nil, fmt.Errorf("error cbor decode trailing bytes got=%d", len(d.data)-d.pos)
+	}
+
+	return value, nil
+}
+
+type decoder struct {
+	data []byte
+	pos  int
+}
+
+func (d *decoder) readByte() (byte, error) {
+	if d.pos >= len(d.data) {
+		return 0, fmt.Errorf("error cbor decode unexpected end of data")
+	}
+	b := d.data[d.pos]
+	d.pos++
+	return b, nil
+}
+
+func (d *decoder) read(n uint64) ([]byte, error) {
+	if n > uint64(len(d.data)-d.pos) {
+		return nil, fmt.Errorf("error cbor decode length=%d exceeds data", n)
+	}
+	b := d.data[d.pos : d.pos+int(n)]
+	d.pos += int(n)
+	return b, nil
+}
+
+//argument the value following the initial byte, indefinite is true for additional info 31
+func (d *decoder) argument(additional byte) (value uint64, indefinite bool, err error) {
+
+	switch {
+	case additional < 24:
+		return uint64(additional), false, nil
+	case additional == 24:
+		b, err := d.read(1)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b[0]), false, nil
+	case additional == 25:
+		b, err := d.read(2)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint16(b)), false, nil
+	case additional == 26:
+		b, err := d.read(4)
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(binary.BigEndian.Uint32(b)), false, nil
+	case additional == 27:
+		b, err := d.read(8)
+		if err != nil {
+			return 0, false, err
+		}
+		return binary.BigEndian.Uint64(b), false, nil
+	case additional == additionalIndefinite:
+		return 0, true, nil
+	}
+
+	return 0, false, fmt.Errorf("error cbor decode reserved additional info=%d", additional)
+}
+
+func (d *decoder) decode(depth int) (interface{}, error) {
+
+	if depth > maxDepth {
+		return nil, fmt.Errorf("error cbor decode nested too deeply")
+	}
+
+	initial, err := d.readByte()
+	if err != nil {
+		return nil, err
+	}
+	major, additional := initial>>5, initial&0x1f
+
+	if major == majorSimple {
+		return d.decodeSimple(additional)
+	}
+
+	arg, indefinite, err := d.argument(additional)
+	if err != nil {
+		return nil, err
+	}
+
+	if indefinite && (major == majorUnsigned || major == majorNegative || major == majorTag) {
+		return nil, fmt.Errorf("error cbor decode indefinite length not allowed for major type=%d", major)
+	}
+
+	switch major {
+	case majorUnsigned:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("error cbor decode integer too large")
+		}
+		return int64(arg), nil
+
+	case majorNegative:
+		if arg > math.MaxInt64 {
+			return nil, fmt.Errorf("error cbor decode integer too small")
+		}
+		return -1 - int64(arg), nil
+
+	case majorBytes, majorText:
+		b, err := d.decodeString(major, arg, indefinite)
+		if err != nil {
+			return nil, err
+		}
+		if major == majorText {
+			return string(b), nil
+		}
+		return b, nil
+
+	case majorArray:
+		result := make([]interface{}, 0)
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.atBreak() {
+				break
+			}
+			item, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			result = append(result, item)
+		}
+		return result, nil
+
+	case majorMap:
+		result := make(map[interface{}]interface{})
+		for i := uint64(0); indefinite || i < arg; i++ {
+			if indefinite && d.atBreak() {
+				break
+			}
+			key, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			switch key.(type) {
+			case int64, string, bool:
+			default:
+				return nil, fmt.Errorf("error cbor decode unsupported map key type=%T", key)
+			}
+			value, err := d.decode(depth + 1)
+			if err != nil {
+				return nil, err
+			}
+			if _, ok := result[key]; ok {
+				return nil, fmt.Errorf("error cbor decode duplicate map key=%v", key)
+			}
+			result[key] = value
+		}
+		return result, nil
+
+	default: //majorTag
+		content, err := d.decode(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return Tag{Number: arg, Content: content}, nil
+	}
+}
+
+//atBreak consumes the break code if it is next
+func (d *decoder) atBreak() bool {
+	if d.pos < len(d.data) && d.data[d.pos] == breakCode {
+		d.pos++
+		return true
+	}
+	return false
+}
+
+func (d *decoder) decodeString(major byte, length uint64, indefinite bool) ([]byte, error) {
+
+	if !indefinite {
+		b, err := d.read(length)
+		if err != nil {
+			return nil, err
+		}
+		return append([]byte{}, b...), nil
+	}
+
+	//an indefinite string is a series of definite strings of the same major type ended by a break
+	var result []byte
+	for !d.atBreak() {
+		initial, err := d.readByte()
+		if err != nil {
+			return nil, err
+		}
+		if initial>>5 != major || initial&0x1f == additionalIndefinite {
+			return nil, fmt.Errorf("error cbor decode invalid indefinite string chunk")
+		}
+		n, _, err := d.argument(initial & 0x1f)
+		if err != nil {
+			return nil, err
+		}
+		chunk, err := d.read(n)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, chunk...)
+	}
+
+	return result, nil
+}
+
+func (d *decoder) decodeSimple(additional byte) (interface{}, error) {
+
+	switch additional {
+	case 20:
+		return false, nil
+	case 21:
+		return true, nil
+	case 22, 23: //null and undefined
+		return nil, nil
+	case 25:
+		b, err := d.read(2)
+		if err != nil {
+			return nil, err
+		}
+		return halfToFloat(binary.BigEndian.Uint16(b)), nil
+	case 26:
+		b, err := d.read(4)
+		if err != nil {
+			return nil, err
+		}
+		return float64(math.Float32frombits(binary.BigEndian.Uint32(b))), nil
+	case 27:
+		b, err := d.read(8)
+		if err != nil {
+			return nil, err
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), nil
+	}
+
+	return nil, fmt.Errorf("error cbor decode unsupported simple value=%d", additional)
+}
+
+func halfToFloat(h uint16) float64 {
+
+	exponent := int(h>>10) & 0x1f
+	mantissa := float64(h & 0x3ff)
+
+	var value float64
+	switch exponent {
+	case 0:
+		value = math.Ldexp(mantissa, -24)
+	case 31:
+		if mantissa == 0 {
+			value = math.Inf(1)
+		} else {
+			value = math.NaN()
+		}
+	default:
+		value = math.Ldexp(mantissa+1024, exponent-25)
+	}
+
+	if h&0x8000 != 0 {
+		return -value
+	}
+	return value
+}
+
+//Marshal encode the value, supports the types Decode returns plus int, uint64, map[string]interface{} and
+//map[int64]interface{}
+func Marshal(value interface{}) ([]byte, error) {
+	var b bytes.Buffer
+	if err := encode(&b, value, 0); err != nil {
+		return nil, err
+	}
+	return b.Bytes(), nil
+}
+
+func writeHead(b *bytes.Buffer, major byte, arg uint64) {
+
+	switch {
+	case arg < 24:
+		b.WriteByte(major<<5 | byte(arg))
+	case arg <= math.MaxUint8:
+		b.WriteByte(major<<5 | 24)
+		b.WriteByte(byte(arg))
+	case arg <= math.MaxUint16:
+		b.WriteByte(major<<5 | 25)
+		_ = binary.Write(b, binary.BigEndian, uint16(arg))
+	case arg <= math.MaxUint32:
+		b.WriteByte(major<<5 | 26)
+		_ = binary.Write(b, binary.BigEndian, uint32(arg))
+	default:
+		b.WriteByte(major<<5 | 27)
+		_ = binary.Write(b, binary.BigEndian, arg)
+	}
+}
+
+func encode(b *bytes.Buffer, value interface{}, depth int) error {
+
+	if depth > maxDepth {
+		return fmt.Errorf("error cbor encode nested too deeply")
+	}
+
+	switch v := value.(type) {
+	case nil:
+		b.WriteByte(majorSimple<<5 | 22)
+	case bool:
+		if v {
+			b.WriteByte(majorSimple<<5 | 21)
+		} else {
+			b.WriteByte(majorSimple<<5 | 20)
+		}
+	case int:
+		return encode(b, int64(v), depth)
+	case int64:
+		if v >= 0 {
+			writeHead(b, majorUnsigned, uint64(v))
+		} else {
+			writeHead(b, majorNegative, uint64(-1-v))
+		}
+	case uint64:
+		writeHead(b, majorUnsigned, v)
+	case float64:
+		b.WriteByte(majorSimple<<5 | 27)
+		_ = binary.Write(b, binary.BigEndian, math.Float64bits(v))
+	case string:
+		writeHead(b, majorText, uint64(len(v)))
+		b.WriteString(v)
+	case []byte:
+		writeHead(b, majorBytes, uint64(len(v)))
+		b.Write(v)
+	case []interface{}:
+		writeHead(b, majorArray, uint64(len(v)))
+		for _, item := range v {
+			if err := encode(b, item, depth+1); err != nil {
+				return err
+			}
+		}
+	case map[interface{}]interface{}:
+		return encodeMap(b, len(v), func(add func(key interface{}, value interface{})) {
+			for key, value := range v {
+				add(key, value)
+			}
+		}, depth)
+	case map[string]interface{}:
+		return encodeMap(b, len(v), func(add func(key interface{}, value interface{})) {
+			for key, value := range v {
+				add(key, value)
+			}
+		}, depth)
+	case map[int64]interface{}:
+		return encodeMap(b, len(v), func(add func(key interface{}, value interface{})) {
+			for key, value := range v {
+				add(key, value)
+			}
+		}, depth)
+	case Tag:
+		writeHead(b, majorTag, v.Number)
+		return encode(b, v.Content, depth+1)
+	default:
+		return fmt.Errorf("error cbor encode unsupported type=%T", value)
+	}
+
+	return nil
+}
+
+//encodeMap sorts the entries by their encoded keys so the encoding is deterministic
+func encodeMap(b *bytes.Buffer, length int, entries func(add func(key interface{}, value interface{})), depth int) error {
+
+	type entry struct {
+		key   []byte
+		value interface{}
+	}
+
+	sorted := make([]entry, 0, length)
+	var err error
+	entries(func(key interface{}, value interface{}) {
+		if err != nil {
+			return
+		}
+		var k bytes.Buffer
+		if err = encode(&k, key, depth+1); err == nil {
+			sorted = append(sorted, entry{key: k.Bytes(), value: value})
+		}
+	})
+	if err != nil {
+		return err
+	}
+
+	sort.Slice(sorted, func(i, j int) bool {
+		return bytes.Compare(sorted[i].key, sorted[j].key) < 0
+	})
+
+	writeHead(b, majorMap, uint64(len(sorted)))
+	for _, e := range sorted {
+		b.Write(e.key)
+		if err := encode(b, e.value, depth+1); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,108 @@
+package cbor_test
+
+import (
+	"encoding/hex"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/internal/cbor"
+)
+
+func Test_Decode(t *testing.T) {
+
+	type testCase struct {
+		name     string
+		hex      string
+		expected interface{}
+	}
+
+	//examples from RFC 8949 appendix A
+	testCases := []testCase{
+		{name: "should decode small int", hex: "17", expected: int64(23)},
+		{name: "should decode uint16", hex: "190100", expected: int64(256)},
+		{name: "should decode uint64", hex: "1b000000e8d4a51000", expected: int64(1000000000000)},
+		{name: "should decode negative", hex: "3903e7", expected: int64(-1000)},
+		{name: "should decode half float", hex: "f93e00", expected: 1.5},
+		{name: "should decode double", hex: "fb3ff199999999999a", expected: 1.1},
+		{name: "should decode bool", hex: "f5", expected: true},
+		{name: "should decode null", hex: "f6", expected: nil},
+		{name: "should decode bytes", hex: "4401020304", expected: []byte{1, 2, 3, 4}},
+		{name: "should decode text", hex: "6449455446", expected: "IETF"},
+		{name: "should decode array", hex: "8301820203820405",
+			expected: []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{name: "should decode map", hex: "a26161016162820203",
+			expected: map[interface{}]interface{}{"a": int64(1), "b": []interface{}{int64(2), int64(3)}}},
+		{name: "should decode indefinite array", hex: "9f018202039f0405ffff",
+			expected: []interface{}{int64(1), []interface{}{int64(2), int64(3)}, []interface{}{int64(4), int64(5)}}},
+		{name: "should decode indefinite text", hex: "7f657374726561646d696e67ff", expected: "streaming"},
+		{name: "should decode tag", hex: "c11a514b67b0", expected: cbor.Tag{Number: 1, Content: int64(1363896240)}},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := hex.DecodeString(tc.hex)
+			require.NoError(t, err)
+
+			value, err := cbor.Decode(data)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, value)
+		})
+	}
+
+	t.Run("should reject bad data", func(t *testing.T) {
+		for _, value := range []string{"", "18", "5a0000ffff", "0102", "a10102a1", "a201020103", "1bffffffffffffffff"} {
+			data, err := hex.DecodeString(value)
+			require.NoError(t, err)
+			_, err = cbor.Decode(data)
+			require.Error(t, err, value)
+		}
+	})
+}
+
+func Test_Marshal(t *testing.T) {
+
+	type testCase struct {
+		name     string
+		value    interface{}
+		expected string
+	}
+
+	testCases := []testCase{
+		{name: "should encode int", value: 1000000, expected: "1a000f4240"},
+		{name: "should encode negative", value: int64(-100), expected: "3863"},
+		{name: "should encode text", value: "IETF", expected: "6449455446"},
+		{name: "should encode bytes", value: []byte{1, 2}, expected: "420102"},
+		{name: "should encode null and bool", value: []interface{}{nil, false}, expected: "82f6f4"},
+		{name: "should sort map keys", value: map[interface{}]interface{}{"b": 2, 10: 1, -1: 3, "a": 4},
+			expected: "a40a0120036161046162" + "02"},
+		{name: "should encode tag", value: cbor.Tag{Number: 18, Content: []interface{}{}}, expected: "d280"},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			data, err := cbor.Marshal(tc.value)
+			require.NoError(t, err)
+			require.Equal(t, tc.expected, hex.EncodeToString(data))
+		})
+	}
+
+	t.Run("should round trip", func(t *testing.T) {
+		value := map[interface{}]interface{}{
+			int64(1): "DE", int64(4): int64(1700000000), int64(-260): map[interface{}]interface{}{
+				int64(1): map[interface{}]interface{}{"ver": "1.3.0", "v": []interface{}{math.Pi}},
+			},
+		}
+		data, err := cbor.Marshal(value)
+		require.NoError(t, err)
+
+		decoded, err := cbor.Decode(data)
+		require.NoError(t, err)
+		require.Equal(t, value, decoded)
+	})
+
+	t.Run("should reject unsupported type", func(t *testing.T) {
+		_, err := cbor.Marshal(struct{}{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,116 @@
+package jws
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"crypto/sha256"
+	"fmt"
+	"math/big"
+)
+
+//JWK a public JSON Web Key, only P-256 EC keys and RSA keys are supported
+type JWK struct {
+	KeyType   string `json:"kty"`
+	KeyID     string `json:"kid,omitempty"`
+	Use       string `json:"use,omitempty"`
+	Algorithm string `json:"alg,omitempty"`
+	Curve     string `json:"crv,omitempty"`
+	X         string `json:"x,omitempty"`
+	Y         string `json:"y,omitempty"`
+	N         string `json:"n,omitempty"`
+	E         string `json:"e,omitempty"`
+}
+
+//JWKSet a set of keys, as published by a SMART Health Card issuer at /.well-known/jwks.json
+type JWKSet struct {
+	Keys []*JWK `json:"keys"`
+}
+
+//PublicKey the key as an *ecdsa.PublicKey or *rsa.PublicKey
+func (k *JWK) PublicKey() (crypto.PublicKey, error) {
+
+	switch k.KeyType {
+	case "EC":
+		if k.Curve != "P-256" {
+			return nil, fmt.Errorf("error jwk unsupported curve got=%s", k.Curve)
+		}
+		x, err := decode(k.X)
+		if err != nil {
+			return nil, fmt.Errorf("error jwk x err=%s", err)
+		}
+		y, err := decode(k.Y)
+		if err != nil {
+			return nil, fmt.Errorf("error jwk y err=%s", err)
+		}
+		key := &ecdsa.PublicKey{Curve: elliptic.P256(), X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !key.Curve.IsOnCurve(key.X, key.Y) {
+			return nil, fmt.Errorf("error jwk point is not on the curve")
+		}
+		return key, nil
+
+	case "RSA":
+		n, err := decode(k.N)
+		if err != nil {
+			return nil, fmt.Errorf("error jwk n err=%s", err)
+		}
+		e, err := decode(k.E)
+		if err != nil {
+			return nil, fmt.Errorf("error jwk e err=%s", err)
+		}
+		exponent := new(big.Int).SetBytes(e)
+		if len(n) == 0 || !exponent.IsInt64() || exponent.Int64() < 3 || exponent.Int64() > 1<<31 {
+			return nil, fmt.Errorf("error jwk invalid rsa key")
+		}
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(exponent.Int64())}, nil
+	}
+
+	return nil, fmt.Errorf("error jwk unsupported kty got=%s", k.KeyType)
+}
+
+//PublicJWK the JWK for a public key, an EC key's kid is its thumbprint as SMART Health Cards require
+func PublicJWK(key crypto.PublicKey) (*JWK, error) {
+
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		if k.Curve != elliptic.P256() {
+			return nil, fmt.Errorf("error jwk unsupported curve")
+		}
+		jwk := &JWK{
+			KeyType:   "EC",
+			Use:       "sig",
+			Algorithm: AlgorithmES256,
+			Curve:     "P-256",
+			X:         encode(k.X.FillBytes(make([]byte, es256ByteLen))),
+			Y:         encode(k.Y.FillBytes(make([]byte, es256ByteLen))),
+		}
+		jwk.KeyID = jwk.thumbprint()
+		return jwk, nil
+
+	case *rsa.PublicKey:
+		return &JWK{
+			KeyType: "RSA",
+			Use:     "sig",
+			N:       encode(k.N.Bytes()),
+			E:       encode(big.NewInt(int64(k.E)).Bytes()),
+		}, nil
+	}
+
+	return nil, fmt.Errorf("error jwk unsupported key type=%T", key)
+}
+
+//thumbprint RFC 7638 thumbprint of an EC key, the required members in lexicographic order
+func (k *JWK) thumbprint() string {
+	digest := sha256.Sum256([]byte(fmt.Sprintf(`{"crv":"%s","kty":"%s","x":"%s","y":"%s"}`, k.Curve, k.KeyType, k.X, k.Y)))
+	return encode(digest[:])
+}
+
+//Thumbprint the RFC 7638 thumbprint of a P-256 key, the kid of a SMART Health Card key
+func Thumbprint(key *ecdsa.PublicKey) (string, error) {
+	jwk, err := PublicJWK(key)
+	if err != nil {
+		return "", err
+	}
+	return jwk.KeyID, nil
+}
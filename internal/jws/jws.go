@@ -0,0 +1,140 @@
+//Package jws minimal compact JWS signing and verification, only ES256 is supported as that is what
+//SMART Health Cards and verification receipts use
+package jws
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"math/big"
+	"strings"
+)
+
+//AlgorithmES256 ECDSA using P-256 and SHA-256
+const AlgorithmES256 = "ES256"
+
+//es256ByteLen the length of each of r and s in the signature
+const es256ByteLen = 32
+
+//Header the protected header
+type Header struct {
+
+	//Algorithm alg
+	Algorithm string `json:"alg"`
+
+	//KeyID kid, identifies the key within the issuer's key set
+	KeyID string `json:"kid,omitempty"`
+
+	//Type typ
+	Type string `json:"typ,omitempty"`
+
+	//Zip the payload compression, DEF for raw deflate
+	Zip string `json:"zip,omitempty"`
+}
+
+//Token a parsed compact JWS, the signature has not been verified
+type Token struct {
+
+	//Header the decoded protected header
+	Header Header
+
+	//Payload the decoded payload, still compressed if the header has a zip
+	Payload []byte
+
+	signingInput string
+	signature    []byte
+}
+
+//SignES256 sign the payload, the header algorithm is set to ES256
+func SignES256(header Header, payload []byte, key *ecdsa.PrivateKey) (string, error) {
+
+	if key == nil || key.Curve != elliptic.P256() {
+		return "", fmt.Errorf("error jws sign key must be a P-256 private key")
+	}
+
+	header.Algorithm = AlgorithmES256
+	headerJSON, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("error jws sign marshal header err=%s", err)
+	}
+
+	signingInput := encode(headerJSON) + "." + encode(payload)
+	digest := sha256.Sum256([]byte(signingInput))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("error jws sign err=%s", err)
+	}
+
+	signature := make([]byte, 2*es256ByteLen)
+	r.FillBytes(signature[:es256ByteLen])
+	s.FillBytes(signature[es256ByteLen:])
+
+	return signingInput + "." + encode(signature), nil
+}
+
+//Parse decode a compact JWS without verifying it
+func Parse(compact string) (*Token, error) {
+
+	parts := strings.Split(strings.TrimSpace(compact), ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("error jws parse expected 3 parts got=%d", len(parts))
+	}
+
+	headerJSON, err := decode(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("error jws parse header err=%s", err)
+	}
+
+	token := &Token{signingInput: parts[0] + "." + parts[1]}
+	if err := json.Unmarshal(headerJSON, &token.Header); err != nil {
+		return nil, fmt.Errorf("error jws parse header err=%s", err)
+	}
+
+	if token.Payload, err = decode(parts[1]); err != nil {
+		return nil, fmt.Errorf("error jws parse payload err=%s", err)
+	}
+
+	if token.signature, err = decode(parts[2]); err != nil {
+		return nil, fmt.Errorf("error jws parse signature err=%s", err)
+	}
+
+	return token, nil
+}
+
+//VerifyES256 verify the signature with the public key
+func (t *Token) VerifyES256(key *ecdsa.PublicKey) error {
+
+	if t.Header.Algorithm != AlgorithmES256 {
+		return fmt.Errorf("error jws verify unsupported alg got=%s", t.Header.Algorithm)
+	}
+
+	if key == nil || key.Curve != elliptic.P256() {
+		return fmt.Errorf("error jws verify key must be a P-256 public key")
+	}
+
+	if len(t.signature) != 2*es256ByteLen {
+		return fmt.Errorf("error jws verify signature length got=%d", len(t.signature))
+	}
+
+	r := new(big.Int).SetBytes(t.signature[:es256ByteLen])
+	s := new(big.Int).SetBytes(t.signature[es256ByteLen:])
+	digest := sha256.Sum256([]byte(t.signingInput))
+	if !ecdsa.Verify(key, digest[:], r, s) {
+		return fmt.Errorf("error jws verify signature invalid")
+	}
+
+	return nil
+}
+
+func encode(value []byte) string {
+	return base64.RawURLEncoding.EncodeToString(value)
+}
+
+func decode(value string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(value)
+}
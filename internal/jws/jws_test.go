@@ -0,0 +1,82 @@
+package jws_test
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/internal/jws"
+)
+
+func Test_SignAndVerify(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	compact, err := jws.SignES256(jws.Header{KeyID: "key1", Zip: "DEF"}, []byte(`{"a":1}`), key)
+	require.NoError(t, err)
+
+	token, err := jws.Parse(compact)
+	require.NoError(t, err)
+	require.Equal(t, jws.AlgorithmES256, token.Header.Algorithm)
+	require.Equal(t, "key1", token.Header.KeyID)
+	require.Equal(t, "DEF", token.Header.Zip)
+	require.Equal(t, `{"a":1}`, string(token.Payload))
+
+	require.NoError(t, token.VerifyES256(&key.PublicKey))
+	require.Error(t, token.VerifyES256(&other.PublicKey), "wrong key should fail")
+
+	parts := strings.Split(compact, ".")
+	tampered, err := jws.Parse(parts[0] + ".eyJhIjoyfQ." + parts[2])
+	require.NoError(t, err)
+	require.Error(t, tampered.VerifyES256(&key.PublicKey), "changed payload should fail")
+
+	_, err = jws.Parse("not-a-jws")
+	require.Error(t, err)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = jws.SignES256(jws.Header{}, []byte("x"), p384)
+	require.Error(t, err, "only P-256 keys are supported")
+}
+
+func Test_JWK(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	jwk, err := jws.PublicJWK(&key.PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, "EC", jwk.KeyType)
+	require.Len(t, jwk.KeyID, 43, "kid is a base64url sha-256 thumbprint")
+
+	thumbprint, err := jws.Thumbprint(&key.PublicKey)
+	require.NoError(t, err)
+	require.Equal(t, jwk.KeyID, thumbprint)
+
+	public, err := jwk.PublicKey()
+	require.NoError(t, err)
+	require.True(t, key.PublicKey.Equal(public))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	rsaJWK, err := jws.PublicJWK(&rsaKey.PublicKey)
+	require.NoError(t, err)
+	rsaPublic, err := rsaJWK.PublicKey()
+	require.NoError(t, err)
+	require.True(t, rsaKey.PublicKey.Equal(rsaPublic))
+
+	jwk.Y = jwk.X
+	_, err = jwk.PublicKey()
+	require.Error(t, err, "point not on curve")
+
+	_, err = (&jws.JWK{KeyType: "oct"}).PublicKey()
+	require.Error(t, err)
+}
@@ -0,0 +1,65 @@
+//Package pipeline the steps shared by the command line tools and services that verify cards as presented,
+//decoding the input, loading trust and policy files and verifying
+package pipeline
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/dcc"
+	"github.com/webshield-dev/dhc-common/shc"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//Format the format of a decoded card
+type Format string
+
+const (
+	//FormatSHC a SMART Health Card
+	FormatSHC Format = "shc"
+
+	//FormatDCC an EU Digital COVID Certificate
+	FormatDCC Format = "dcc"
+)
+
+//Decode a card from text as presented, a shc:/ QR code or all the chunks of a chunked one, a
+//.smart-health-card file, a JWS or an HC1: QR code. The text can be the output of a scanner so codes can be
+//surrounded by other text, for example a QR code pasted into an email
+func Decode(input string) (*verification.Card, Format, error) {
+
+	input = strings.TrimSpace(input)
+	if input == "" {
+		return nil, "", fmt.Errorf("error decode no card")
+	}
+
+	//Base45 includes the space so an HC1: code runs to the end of its line
+	if i := strings.Index(input, dcc.Prefix); i >= 0 {
+		qr := input[i:]
+		if end := strings.IndexAny(qr, "\r\n"); end >= 0 {
+			qr = qr[:end]
+		}
+		certificate, err := dcc.Parse(qr)
+		if err != nil {
+			return nil, "", err
+		}
+		return certificate.Card(), FormatDCC, nil
+	}
+
+	chunks := make([]string, 0)
+	for _, field := range strings.Fields(input) {
+		if i := strings.Index(field, shc.QRPrefix); i >= 0 {
+			chunks = append(chunks, field[i:])
+		}
+	}
+
+	if len(chunks) > 0 && !strings.HasPrefix(input, "{") {
+		input = strings.Join(chunks, " ")
+	}
+
+	hc, err := shc.Parse(input)
+	if err != nil {
+		return nil, "", err
+	}
+
+	return hc.Card(), FormatSHC, nil
+}
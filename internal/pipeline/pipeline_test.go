@@ -0,0 +1,167 @@
+package pipeline_test
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/dcc"
+	"github.com/webshield-dev/dhc-common/internal/pipeline"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Decode(t *testing.T) {
+
+	file := readTestData(t, "card.smart-health-card")
+	qr := strings.TrimSpace(readTestData(t, "card.qr.txt"))
+	hc1 := strings.TrimSpace(readTestData(t, "certificate.hc1.txt"))
+
+	type testCase struct {
+		name           string
+		input          string
+		expectedFormat pipeline.Format
+	}
+
+	testCases := []testCase{
+		{name: "should decode a card file", input: file, expectedFormat: pipeline.FormatSHC},
+		{name: "should decode a qr code", input: qr, expectedFormat: pipeline.FormatSHC},
+		{name: "should decode a qr code in other text", input: "scanned:\n" + qr + "\nend", expectedFormat: pipeline.FormatSHC},
+		{name: "should decode an hc1 code", input: hc1, expectedFormat: pipeline.FormatDCC},
+		{name: "should decode an hc1 code in other text", input: "scanned: " + hc1 + "\r\nend", expectedFormat: pipeline.FormatDCC},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			card, format, err := pipeline.Decode(tc.input)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedFormat, format)
+			require.NotEmpty(t, card.Doses)
+		})
+	}
+
+	for _, input := range []string{"", "  ", "not a card", "HC1:XYZ"} {
+		_, _, err := pipeline.Decode(input)
+		require.Error(t, err, input)
+	}
+}
+
+func Test_Trust(t *testing.T) {
+
+	trust, err := pipeline.LoadTrustPaths("../../testdata/trust.json")
+	require.NoError(t, err)
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            &verification.Policy{ID: "eu", Region: vaccinemd.RegionEU},
+		SignatureVerifier: trust.SignatureVerifier,
+		IssuerTrustStore:  trust.IssuerTrustStore,
+	})
+	require.NoError(t, err)
+
+	for _, name := range []string{"card.smart-health-card", "certificate.hc1.txt"} {
+		card, _, err := pipeline.Decode(readTestData(t, name))
+		require.NoError(t, err)
+
+		results, err := verifier.Verify(context.Background(), card)
+		require.NoError(t, err)
+		require.True(t, results.CardStructure.SignatureValid, name)
+		require.True(t, results.Issuer.Trusted, name)
+	}
+
+	t.Run("should trust nothing without trust files", func(t *testing.T) {
+		trust, err := pipeline.LoadTrustPaths()
+		require.NoError(t, err)
+
+		card, _, err := pipeline.Decode(readTestData(t, "certificate.hc1.txt"))
+		require.NoError(t, err)
+
+		result, err := trust.SignatureVerifier.VerifySignature(context.Background(), card)
+		require.NoError(t, err)
+		require.False(t, result.FetchedKey)
+	})
+
+	t.Run("should not trust a certificate signed with another country's key", func(t *testing.T) {
+		trust, err := pipeline.LoadTrustPaths("../../testdata/trust.json", "../../testdata/dcc-test-trust.json")
+		require.NoError(t, err)
+		verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+			Policy:            &verification.Policy{ID: "eu", Region: vaccinemd.RegionEU},
+			SignatureVerifier: trust.SignatureVerifier,
+			IssuerTrustStore:  trust.IssuerTrustStore,
+		})
+		require.NoError(t, err)
+
+		data, err := os.ReadFile("../../testdata/dcc-signer-es256.pem")
+		require.NoError(t, err)
+		block, _ := pem.Decode(data)
+		require.NotNil(t, block)
+		key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+		require.NoError(t, err)
+
+		//a key of XA claiming to be DE which is trusted
+		signer, err := dcc.NewSigner("DE", key.(crypto.Signer), nil)
+		require.NoError(t, err)
+		certificate, err := signer.Sign(&dcc.Payload{Version: dcc.SchemaVersion, Name: &dcc.Name{FamilyName: "Doe"}},
+			time.Time{}, time.Time{})
+		require.NoError(t, err)
+
+		card, _, err := pipeline.Decode(certificate.QR)
+		require.NoError(t, err)
+		require.Equal(t, "DE", card.Issuer)
+
+		results, err := verifier.Verify(context.Background(), card)
+		require.NoError(t, err)
+		require.False(t, results.CardStructure.SignatureValid)
+		require.NotEqual(t, verification.CardVerificationStateValid, results.State)
+		require.Contains(t, verification.FailedChecks(results), verification.AuditRuleSignature)
+	})
+
+	t.Run("should not load unknown fields", func(t *testing.T) {
+		_, err := pipeline.LoadTrust(strings.NewReader(`{"issuer": ["DE"]}`))
+		require.Error(t, err)
+	})
+}
+
+func Test_SelectPolicies(t *testing.T) {
+
+	policies, err := pipeline.SelectPolicies("EU")
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	require.Equal(t, vaccinemd.RegionEU, policies[0].Region)
+
+	path := t.TempDir() + "/policy.json"
+	require.NoError(t, os.WriteFile(path, []byte(`[
+		{"id": "venue", "version": "1", "region": "EMA|WHO-EUL", "effective_to": "2022-01-01T00:00:00Z"},
+		{"id": "venue", "version": "2", "region": "EMA", "effective_from": "2022-01-01T00:00:00Z"}
+	]`), 0o600))
+
+	policies, err = pipeline.SelectPolicies(path)
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	require.Equal(t, "2", policies[1].Version)
+
+	_, err = pipeline.SelectPolicies("mars")
+	require.Error(t, err)
+
+	//rules written before not yet valid was a state are still valid
+	require.NoError(t, os.WriteFile(path, []byte(`{"id": "venue", "region": "EMA",
+		"state_rules": ["corrupt", "safety_criteria_not_met", "unverified"]}`), 0o600))
+	_, err = pipeline.SelectPolicies(path)
+	require.NoError(t, err)
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"id": "venue", "region": "EMA",
+		"state_rules": ["safety_criteria_not_met", "corrupt", "unverified"]}`), 0o600))
+	_, err = pipeline.SelectPolicies(path)
+	require.Error(t, err, "invalid state rules should fail when loaded")
+}
+
+func readTestData(t *testing.T, name string) string {
+	data, err := os.ReadFile("../../testdata/" + name)
+	require.NoError(t, err)
+	return string(data)
+}
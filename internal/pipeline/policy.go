@@ -0,0 +1,100 @@
+package pipeline
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//builtInPolicies a policy for each named region, selected by ID so a policy file is only needed for custom rules
+var builtInPolicies = map[string]*verification.Policy{
+	"usa":       {ID: "usa", Region: vaccinemd.RegionUSA},
+	"eu":        {ID: "eu", Region: vaccinemd.RegionEU},
+	"canada":    {ID: "canada", Region: vaccinemd.RegionCanada},
+	"australia": {ID: "australia", Region: vaccinemd.RegionAustralia},
+}
+
+//BuiltInPolicyIDs the IDs of the built in policies in order
+func BuiltInPolicyIDs() []string {
+	ids := make([]string, 0, len(builtInPolicies))
+	for id := range builtInPolicies {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+//BuiltInPolicy a copy of the built in policy, nil if there is none with the ID
+func BuiltInPolicy(id string) *verification.Policy {
+	policy, ok := builtInPolicies[strings.ToLower(id)]
+	if !ok {
+		return nil
+	}
+	copied := *policy
+	return &copied
+}
+
+//LoadPolicies read a policy file, a JSON policy or an array of versions of the same policy
+func LoadPolicies(r io.Reader) ([]*verification.Policy, error) {
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, fmt.Errorf("error load policies err=%s", err)
+	}
+
+	policies := make([]*verification.Policy, 0)
+	if trimmed := strings.TrimSpace(string(data)); strings.HasPrefix(trimmed, "[") {
+		err = json.Unmarshal(data, &policies)
+	} else {
+		var policy verification.Policy
+		err = json.Unmarshal(data, &policy)
+		policies = append(policies, &policy)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error load policies err=%s", err)
+	}
+
+	if len(policies) == 0 {
+		return nil, fmt.Errorf("error load policies no policies")
+	}
+
+	//fail when the policy is loaded rather than when it is used
+	for _, policy := range policies {
+		if len(policy.StateRules) > 0 {
+			if err := verification.ValidateStateRules(policy.StateRules); err != nil {
+				return nil, fmt.Errorf("error load policies id=%s version=%s %s", policy.ID, policy.Version, err)
+			}
+		}
+	}
+
+	return policies, nil
+}
+
+//SelectPolicies the versions of a built in policy by ID, or of the policy in a policy file
+func SelectPolicies(idOrPath string) ([]*verification.Policy, error) {
+
+	if policy := BuiltInPolicy(idOrPath); policy != nil {
+		return []*verification.Policy{policy}, nil
+	}
+
+	f, err := os.Open(filepath.Clean(idOrPath))
+	if err != nil {
+		return nil, fmt.Errorf("error select policy %s is not a built in policy %v or a policy file err=%s",
+			idOrPath, BuiltInPolicyIDs(), err)
+	}
+	defer func() { _ = f.Close() }()
+
+	policies, err := LoadPolicies(f)
+	if err != nil {
+		return nil, fmt.Errorf("error select policy file=%s %s", idOrPath, err)
+	}
+
+	return policies, nil
+}
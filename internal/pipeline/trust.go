@@ -0,0 +1,159 @@
+package pipeline
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"github.com/webshield-dev/dhc-common/dcc"
+	"github.com/webshield-dev/dhc-common/shc"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//TrustFile the issuers that are trusted and the keys cards are verified with, for example
+//
+//  {
+//    "issuers": ["https://spec.smarthealth.cards/examples/issuer", "DE"],
+//    "shc_key_sets": {"https://spec.smarthealth.cards/examples/issuer": {"keys": [{"kty": "EC", ...}]}},
+//    "dcc_key_sets": {"DE": {"keys": [{"kid": "DEsVUSvpFAE=", "kty": "EC", ...}]}}
+//  }
+//
+//An issuer can have keys without being trusted, its cards are then issuer unknown rather than unverified
+type TrustFile struct {
+
+	//Issuers the trusted issuers, a SMART Health Card iss or an EU certificate's issuing country
+	Issuers []string `json:"issuers"`
+
+	//SHCKeySets each SMART Health Card issuer's JWK set keyed by iss
+	SHCKeySets map[string]json.RawMessage `json:"shc_key_sets,omitempty"`
+
+	//DCCKeySets each country's EU Document Signer keys as a JWK set keyed by the country's code, each key's kid
+	//is its trust list kid. A key only verifies certificates whose iss is its country
+	DCCKeySets map[string]json.RawMessage `json:"dcc_key_sets,omitempty"`
+}
+
+//Trust the trust store and signature verifier made from trust files
+type Trust struct {
+	IssuerTrustStore  verification.IssuerTrustStore
+	SignatureVerifier verification.SignatureVerifier
+}
+
+//LoadTrust read a trust file
+func LoadTrust(r io.Reader) (*Trust, error) {
+
+	file, err := decodeTrustFile(r)
+	if err != nil {
+		return nil, fmt.Errorf("error load trust %s", err)
+	}
+
+	return MakeTrust(file)
+}
+
+//LoadTrustPaths read and merge trust files, no paths trusts nothing
+func LoadTrustPaths(paths ...string) (*Trust, error) {
+
+	merged := &TrustFile{SHCKeySets: make(map[string]json.RawMessage), DCCKeySets: make(map[string]json.RawMessage)}
+
+	for _, path := range paths {
+
+		file, err := loadTrustFile(path)
+		if err != nil {
+			return nil, err
+		}
+
+		merged.Issuers = append(merged.Issuers, file.Issuers...)
+		for issuer, keySet := range file.SHCKeySets {
+			if _, ok := merged.SHCKeySets[issuer]; ok {
+				return nil, fmt.Errorf("error load trust file=%s issuer=%s keys are in more than one file", path, issuer)
+			}
+			merged.SHCKeySets[issuer] = keySet
+		}
+
+		for country, keySet := range file.DCCKeySets {
+			if _, ok := merged.DCCKeySets[country]; ok {
+				return nil, fmt.Errorf("error load trust file=%s country=%s keys are in more than one file", path, country)
+			}
+			merged.DCCKeySets[country] = keySet
+		}
+	}
+
+	return MakeTrust(merged)
+}
+
+func loadTrustFile(path string) (*TrustFile, error) {
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("error load trust file=%s err=%s", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	file, err := decodeTrustFile(f)
+	if err != nil {
+		return nil, fmt.Errorf("error load trust file=%s %s", path, err)
+	}
+
+	return file, nil
+}
+
+//decodeTrustFile unknown fields are an error so a misspelt key set is not silently ignored
+func decodeTrustFile(r io.Reader) (*TrustFile, error) {
+
+	var file TrustFile
+	decoder := json.NewDecoder(r)
+	decoder.DisallowUnknownFields()
+	if err := decoder.Decode(&file); err != nil {
+		return nil, fmt.Errorf("err=%s", err)
+	}
+
+	return &file, nil
+}
+
+//MakeTrust make the trust store and signature verifier for the file
+func MakeTrust(file *TrustFile) (*Trust, error) {
+
+	issuerKeys := make(map[string]map[string]*ecdsa.PublicKey)
+	for issuer, keySet := range file.SHCKeySets {
+		keys, err := shc.ParseKeySet(keySet)
+		if err != nil {
+			return nil, fmt.Errorf("error make trust issuer=%s %s", issuer, err)
+		}
+		issuerKeys[issuer] = keys
+	}
+
+	countryKeys := make(map[string]map[string]crypto.PublicKey)
+	for country, keySet := range file.DCCKeySets {
+		keys, err := dcc.ParseKeySet(keySet)
+		if err != nil {
+			return nil, fmt.Errorf("error make trust country=%s %s", country, err)
+		}
+		countryKeys[country] = keys
+	}
+
+	return &Trust{
+		IssuerTrustStore: verification.NewStaticIssuerTrustStore(file.Issuers...),
+		SignatureVerifier: &formatSignatureVerifier{
+			shc: shc.NewKeyVerifier(issuerKeys),
+			dcc: dcc.NewKeyVerifier(countryKeys),
+		},
+	}, nil
+}
+
+//formatSignatureVerifier passes the card to the verifier for its format, an EU certificate's Raw is its HC1: code
+type formatSignatureVerifier struct {
+	shc verification.SignatureVerifier
+	dcc verification.SignatureVerifier
+}
+
+func (fv *formatSignatureVerifier) VerifySignature(ctx context.Context, card *verification.Card) (*verification.SignatureResult, error) {
+	if bytes.HasPrefix(card.Raw, []byte(dcc.Prefix)) {
+		return fv.dcc.VerifySignature(ctx, card)
+	}
+	return fv.shc.VerifySignature(ctx, card)
+}
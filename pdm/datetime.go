@@ -0,0 +1,220 @@
+package pdm
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+)
+
+//
+// FHIR date and dateTime handling, see http://hl7.org/fhir/R4/datatypes.html#dateTime
+//
+// A FHIR dateTime can be partial, for example 2021 or 2021-05, so each parsed value keeps the
+// precision it was recorded to. The interval rules (days since last dose, days between doses) never
+// know exactly when a partial date happened, so they use a conservative interpretation that can only
+// make a check fail, never pass:
+//
+//  - days since a dose uses the LATEST instant the dose could have happened, see DateTime.Latest
+//  - a minimum number of days between doses uses the SMALLEST possible gap, see MinDaysBetween
+//  - a maximum number of days between doses uses the LARGEST possible gap, see MaxDaysBetween
+//
+// A dateTime without a time zone, or a date without a time, is interpreted as UTC.
+//
+
+//DatePrecision how precisely a date was recorded
+type DatePrecision int
+
+const (
+	//DatePrecisionYear only the year is known, for example 2021
+	DatePrecisionYear DatePrecision = iota + 1
+
+	//DatePrecisionMonth the year and month are known, for example 2021-05
+	DatePrecisionMonth
+
+	//DatePrecisionDay the full date is known, for example 2021-05-03
+	DatePrecisionDay
+
+	//DatePrecisionTime the date and time are known, for example 2021-05-03T10:00:00Z
+	DatePrecisionTime
+)
+
+//String the precision name
+func (p DatePrecision) String() string {
+	switch p {
+	case DatePrecisionYear:
+		return "year"
+	case DatePrecisionMonth:
+		return "month"
+	case DatePrecisionDay:
+		return "day"
+	case DatePrecisionTime:
+		return "time"
+	}
+
+	return "unknown"
+}
+
+//DateTime a parsed date along with the precision it was recorded to
+type DateTime struct {
+
+	//Time the start of the recorded period, for example 2021-05 is 2021-05-01T00:00:00Z
+	Time time.Time
+
+	//Precision how precisely the date was recorded
+	Precision DatePrecision
+
+	//FreeText true if the date came from a free-text string rather than a FHIR date or dateTime
+	FreeText bool
+}
+
+//Earliest the earliest instant the date could refer to
+func (dt *DateTime) Earliest() time.Time {
+	return dt.Time
+}
+
+//Latest the latest instant the date could refer to, for example 2021-05 is 2021-05-31T23:59:59.999999999Z
+func (dt *DateTime) Latest() time.Time {
+
+	switch dt.Precision {
+	case DatePrecisionYear:
+		return dt.Time.AddDate(1, 0, 0).Add(-time.Nanosecond)
+	case DatePrecisionMonth:
+		return dt.Time.AddDate(0, 1, 0).Add(-time.Nanosecond)
+	case DatePrecisionDay:
+		return dt.Time.AddDate(0, 0, 1).Add(-time.Nanosecond)
+	}
+
+	return dt.Time
+}
+
+//IsPartial true if recorded with less than day precision
+func (dt *DateTime) IsPartial() bool {
+	return dt.Precision < DatePrecisionDay
+}
+
+//MinDaysBetween the smallest possible number of calendar days between an earlier and a later date, dates a
+//day apart are 1 day apart whatever their precision
+func MinDaysBetween(earlier *DateTime, later *DateTime) int {
+	return daysBetween(earlier.Latest(), later.Earliest())
+}
+
+//MaxDaysBetween the largest possible number of calendar days between an earlier and a later date
+func MaxDaysBetween(earlier *DateTime, later *DateTime) int {
+	return daysBetween(earlier.Earliest(), later.Latest())
+}
+
+//daysBetween the calendar days between the dates of the instants, so the time of day does not matter
+func daysBetween(from time.Time, to time.Time) int {
+	fromDay := time.Date(from.Year(), from.Month(), from.Day(), 0, 0, 0, 0, time.UTC)
+	toDay := time.Date(to.Year(), to.Month(), to.Day(), 0, 0, 0, 0, time.UTC)
+	return int(toDay.Sub(fromDay).Hours() / 24)
+}
+
+//ParseDateTime parses a FHIR date or dateTime, a dateTime without a time zone is treated as UTC
+//see http://hl7.org/fhir/R4/datatypes.html#dateTime
+func ParseDateTime(value string) (*DateTime, error) {
+
+	value = strings.TrimSpace(value)
+
+	switch {
+	case len(value) == 4:
+		return parseWithLayout(value, "2006", DatePrecisionYear)
+	case len(value) == 7:
+		return parseWithLayout(value, "2006-01", DatePrecisionMonth)
+	case len(value) == 10:
+		return parseWithLayout(value, "2006-01-02", DatePrecisionDay)
+	case len(value) > 10 && value[10] == 'T':
+		if dt, err := parseWithLayout(value, time.RFC3339Nano, DatePrecisionTime); err == nil {
+			return dt, nil
+		}
+		//no time zone, fractional seconds are accepted by the layout
+		return parseWithLayout(value, "2006-01-02T15:04:05", DatePrecisionTime)
+	}
+
+	return nil, fmt.Errorf("error parse fhir dateTime unknown format got=%s", value)
+}
+
+func parseWithLayout(value string, layout string, precision DatePrecision) (*DateTime, error) {
+
+	t, err := time.Parse(layout, value)
+	if err != nil {
+		return nil, fmt.Errorf("error parse fhir dateTime got=%s err=%s", value, err)
+	}
+
+	return &DateTime{Time: t, Precision: precision}, nil
+}
+
+// free text layouts, month names are matched case-insensitive by time.Parse
+var freeTextLayouts = []struct {
+	layout    string
+	precision DatePrecision
+}{
+	{layout: "January 2 2006", precision: DatePrecisionDay},
+	{layout: "Jan 2 2006", precision: DatePrecisionDay},
+	{layout: "2 January 2006", precision: DatePrecisionDay},
+	{layout: "2 Jan 2006", precision: DatePrecisionDay},
+	{layout: "January 2006", precision: DatePrecisionMonth},
+	{layout: "Jan 2006", precision: DatePrecisionMonth},
+}
+
+var (
+	ordinalSuffixRegExp = regexp.MustCompile(`(?i)\b(\d{1,2})(st|nd|rd|th)\b`)
+	numericDateRegExp   = regexp.MustCompile(`^(\d{1,2})[/.-](\d{1,2})[/.-](\d{4})$`)
+)
+
+//ParseOccurrenceString parses a free-text occurrence such as "May 3 2021", "3rd May, 2021" or "2021-05-03"
+//see http://hl7.org/fhir/r4/immunization-definitions.html#Immunization.occurrence_x_
+//
+//Numeric dates such as 05/03/2021 are only accepted when the day and month cannot be confused,
+//otherwise an error is returned rather than guessing
+func ParseOccurrenceString(value string) (*DateTime, error) {
+
+	if dt, err := ParseDateTime(value); err == nil {
+		dt.FreeText = true
+		return dt, nil
+	}
+
+	normalized := strings.ReplaceAll(value, ",", " ")
+	normalized = ordinalSuffixRegExp.ReplaceAllString(normalized, "$1")
+	normalized = strings.Join(strings.Fields(normalized), " ")
+
+	for _, ftl := range freeTextLayouts {
+		t, err := time.Parse(ftl.layout, normalized)
+		if err == nil {
+			return &DateTime{Time: t, Precision: ftl.precision, FreeText: true}, nil
+		}
+	}
+
+	if matches := numericDateRegExp.FindStringSubmatch(normalized); matches != nil {
+		return parseNumericDate(value, matches[1], matches[2], matches[3])
+	}
+
+	return nil, fmt.Errorf("error parse occurrence string unknown format got=%s", value)
+}
+
+//parseNumericDate accepts d/m/y or m/d/y only if only one reading is a valid date, or both readings are the same
+func parseNumericDate(value string, first string, second string, year string) (*DateTime, error) {
+
+	a, _ := strconv.Atoi(first)
+	b, _ := strconv.Atoi(second)
+	y, _ := strconv.Atoi(year)
+
+	var day, month int
+	switch {
+	case a == b || (a > 12 && b <= 12):
+		day, month = a, b
+	case b > 12 && a <= 12:
+		day, month = b, a
+	default:
+		return nil, fmt.Errorf("error parse occurrence string ambiguous day and month got=%s", value)
+	}
+
+	t := time.Date(y, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day || int(t.Month()) != month {
+		return nil, fmt.Errorf("error parse occurrence string invalid date got=%s", value)
+	}
+
+	return &DateTime{Time: t, Precision: DatePrecisionDay, FreeText: true}, nil
+}
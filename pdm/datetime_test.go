@@ -0,0 +1,189 @@
+package pdm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+)
+
+func Test_ParseDateTime(t *testing.T) {
+
+	type testCase struct {
+		name              string
+		value             string
+		expectError       bool
+		expectedPrecision pdm.DatePrecision
+		expectedEarliest  time.Time
+		expectedLatest    time.Time
+	}
+
+	testCases := []testCase{
+		{
+			name:              "should parse a year",
+			value:             "2021",
+			expectedPrecision: pdm.DatePrecisionYear,
+			expectedEarliest:  time.Date(2021, 1, 1, 0, 0, 0, 0, time.UTC),
+			expectedLatest:    time.Date(2021, 12, 31, 23, 59, 59, 999999999, time.UTC),
+		},
+		{
+			name:              "should parse a year and month",
+			value:             "2021-02",
+			expectedPrecision: pdm.DatePrecisionMonth,
+			expectedEarliest:  time.Date(2021, 2, 1, 0, 0, 0, 0, time.UTC),
+			expectedLatest:    time.Date(2021, 2, 28, 23, 59, 59, 999999999, time.UTC),
+		},
+		{
+			name:              "should parse a date",
+			value:             "2021-05-03",
+			expectedPrecision: pdm.DatePrecisionDay,
+			expectedEarliest:  time.Date(2021, 5, 3, 0, 0, 0, 0, time.UTC),
+			expectedLatest:    time.Date(2021, 5, 3, 23, 59, 59, 999999999, time.UTC),
+		},
+		{
+			name:              "should parse a dateTime with a zone",
+			value:             "2021-05-03T10:00:00+02:00",
+			expectedPrecision: pdm.DatePrecisionTime,
+			expectedEarliest:  time.Date(2021, 5, 3, 8, 0, 0, 0, time.UTC),
+			expectedLatest:    time.Date(2021, 5, 3, 8, 0, 0, 0, time.UTC),
+		},
+		{
+			name:              "should parse a dateTime without a zone as UTC",
+			value:             "2021-05-03T10:00:00",
+			expectedPrecision: pdm.DatePrecisionTime,
+			expectedEarliest:  time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+			expectedLatest:    time.Date(2021, 5, 3, 10, 0, 0, 0, time.UTC),
+		},
+		{
+			name:              "should parse a dateTime without a zone with fractional seconds",
+			value:             "2021-05-03T10:00:00.5",
+			expectedPrecision: pdm.DatePrecisionTime,
+			expectedEarliest:  time.Date(2021, 5, 3, 10, 0, 0, 500000000, time.UTC),
+			expectedLatest:    time.Date(2021, 5, 3, 10, 0, 0, 500000000, time.UTC),
+		},
+		{
+			name:        "should not parse free text",
+			value:       "May 3 2021",
+			expectError: true,
+		},
+		{
+			name:        "should not parse an invalid month",
+			value:       "2021-13",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			dt, err := pdm.ParseDateTime(tc.value)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPrecision, dt.Precision)
+			require.True(t, tc.expectedEarliest.Equal(dt.Earliest()), "earliest got=%s", dt.Earliest())
+			require.True(t, tc.expectedLatest.Equal(dt.Latest()), "latest got=%s", dt.Latest())
+			require.False(t, dt.FreeText)
+		})
+	}
+}
+
+func Test_ParseOccurrenceString(t *testing.T) {
+
+	type testCase struct {
+		name              string
+		value             string
+		expectError       bool
+		expectedPrecision pdm.DatePrecision
+		expectedDate      time.Time
+	}
+
+	testCases := []testCase{
+		{
+			name:              "should parse month name day year",
+			value:             "May 3 2021",
+			expectedPrecision: pdm.DatePrecisionDay,
+			expectedDate:      time.Date(2021, 5, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:              "should parse with ordinal and comma",
+			value:             "3rd  may, 2021",
+			expectedPrecision: pdm.DatePrecisionDay,
+			expectedDate:      time.Date(2021, 5, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:              "should parse month and year",
+			value:             "September 2021",
+			expectedPrecision: pdm.DatePrecisionMonth,
+			expectedDate:      time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:              "should parse a fhir date",
+			value:             "2021-05-03",
+			expectedPrecision: pdm.DatePrecisionDay,
+			expectedDate:      time.Date(2021, 5, 3, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:              "should parse an unambiguous numeric date",
+			value:             "03/16/2021",
+			expectedPrecision: pdm.DatePrecisionDay,
+			expectedDate:      time.Date(2021, 3, 16, 0, 0, 0, 0, time.UTC),
+		},
+		{
+			name:        "should not guess an ambiguous numeric date",
+			value:       "05/03/2021",
+			expectError: true,
+		},
+		{
+			name:        "should not parse an invalid numeric date",
+			value:       "31/02/2021",
+			expectError: true,
+		},
+		{
+			name:        "should not parse text",
+			value:       "second dose",
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			dt, err := pdm.ParseOccurrenceString(tc.value)
+			if tc.expectError {
+				require.Error(t, err)
+				return
+			}
+
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedPrecision, dt.Precision)
+			require.True(t, tc.expectedDate.Equal(dt.Time), "got=%s", dt.Time)
+			require.True(t, dt.FreeText)
+		})
+	}
+}
+
+func Test_DaysBetween(t *testing.T) {
+
+	may, err := pdm.ParseDateTime("2021-05")
+	require.NoError(t, err)
+
+	june20, err := pdm.ParseDateTime("2021-06-20")
+	require.NoError(t, err)
+
+	require.Equal(t, 20, pdm.MinDaysBetween(may, june20), "smallest gap is from end of may")
+	require.Equal(t, 50, pdm.MaxDaysBetween(may, june20), "largest gap is from start of may")
+
+	june1, err := pdm.ParseDateTime("2021-06-01")
+	require.NoError(t, err)
+	require.Equal(t, 19, pdm.MinDaysBetween(june1, june20), "days are whole calendar days")
+	require.Equal(t, 19, pdm.MaxDaysBetween(june1, june20))
+
+	lateJune1, err := pdm.ParseDateTime("2021-06-01T23:30:00Z")
+	require.NoError(t, err)
+	require.Equal(t, 19, pdm.MinDaysBetween(lateJune1, june20), "time of day should not matter")
+}
@@ -11,6 +11,10 @@ type Dose struct {
     //Code vaccine code
     Coding vaccinemd.Coding
 
+    //Codings other codes for the same vaccine, for example the rest of a FHIR vaccineCode.coding,
+    //used to resolve the vaccine when Coding is unknown or only a class code
+    Codings []vaccinemd.Coding `json:"codings,omitempty"`
+
     //Status http://hl7.org/fhir/R4/immunization-definitions.html#Immunization.status
     Status Code `json:"status,omitempty"`
 
@@ -23,16 +27,58 @@ type Dose struct {
     //LotNumber http://hl7.org/fhir/R4/immunization-definitions.html#Immunization.lotNumber
     LotNumber string `json:"lotNumber,omitempty"`
 
+    //Manufacturer the manufacturer recorded on the card, an MVX code on a SMART Health Card or the
+    //EU certificate ma organisation code, nil if not recorded
+    Manufacturer *vaccinemd.Coding `json:"manufacturer,omitempty"`
+
     //Site where the dose was administered
     Site string `json:"site,omitempty"`
+
+    //Country ISO 3166-1 alpha-2 code of the country the dose was administered in, empty if not known
+    Country string `json:"country,omitempty"`
+
+    //DoseNumber the position of the dose in its series as recorded by the issuer, the EU certificate dn,
+    //0 if not recorded
+    DoseNumber int `json:"doseNumber,omitempty"`
+
+    //SeriesDoses the number of doses in the series as recorded by the issuer, the EU certificate sd,
+    //0 if not recorded
+    SeriesDoses int `json:"seriesDoses,omitempty"`
 }
 
 
 
+//AllCodings Coding followed by Codings, empty codings are left out
+func (d *Dose) AllCodings() []vaccinemd.Coding {
+    result := make([]vaccinemd.Coding, 0, 1+len(d.Codings))
+    if d.Coding.System != "" || d.Coding.Code != "" {
+        result = append(result, d.Coding)
+    }
+    return append(result, d.Codings...)
+}
+
+//CompletesSeries true if the issuer recorded the dose as the last of its series, for example an EU certificate
+//only carries the latest dose so dn 2 of sd 2 is a complete series without the earlier dose
+func (d *Dose) CompletesSeries() bool {
+    return d.SeriesDoses > 0 && d.DoseNumber >= d.SeriesDoses
+}
+
 //Code https://www.hl7.org/fhir/datatypes.html#code
 type Code string
 
 const (
     //CodeCompleted action taken
     CodeCompleted Code = "completed"
+
+    //CodeEnteredInError the dose was recorded in error so should be ignored
+    CodeEnteredInError Code = "entered-in-error"
+
+    //CodeNotDone the dose was not administered
+    CodeNotDone Code = "not-done"
 )
+
+//Administered true if the dose counts as administered, a missing status is treated as completed
+//as some cards leave it out
+func (d *Dose) Administered() bool {
+    return d.Status == "" || d.Status == CodeCompleted
+}
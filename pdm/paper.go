@@ -0,0 +1,270 @@
+package pdm
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+//
+// A paper record is what is written on a paper vaccination card, typed in by the verifier or the holder.
+// ConvertPaperRecord maps it to doses that can be verified like a digital card, matching the handwritten
+// product names to vaccines and flagging anything implausible, as paper is easy to forge.
+//
+
+//DateOrder how numeric dates are written on a paper card, the CDC card is month first
+type DateOrder string
+
+const (
+	//DateOrderUnknown numeric dates are only accepted if the day and month cannot be confused
+	DateOrderUnknown DateOrder = ""
+
+	//DateOrderMonthFirst for example 03/16/21
+	DateOrderMonthFirst DateOrder = "mdy"
+
+	//DateOrderDayFirst for example 16/03/21
+	DateOrderDayFirst DateOrder = "dmy"
+)
+
+//PaperRecord the data on a paper vaccination card, as written
+type PaperRecord struct {
+
+	//BirthDate the patient's birth date as written
+	BirthDate string `json:"birth_date,omitempty"`
+
+	//DateOrder how numeric dates are written on the card
+	DateOrder DateOrder `json:"date_order,omitempty"`
+
+	//SelfAttested the record was entered by the holder rather than read from a paper card by the verifier
+	SelfAttested bool `json:"self_attested,omitempty"`
+
+	//Doses the doses written on the card
+	Doses []*PaperDose `json:"doses"`
+}
+
+//PaperDose a dose written on a paper card
+type PaperDose struct {
+
+	//ProductName the vaccine as written, for example "Pfizer" or "J&J"
+	ProductName string `json:"product_name"`
+
+	//LotNumber the lot number as written
+	LotNumber string `json:"lot_number,omitempty"`
+
+	//Date the date as written
+	Date string `json:"date"`
+
+	//Site the clinic or site as written
+	Site string `json:"site,omitempty"`
+}
+
+//PaperConversion the doses and birth date from a paper record and the issues found converting it
+type PaperConversion struct {
+
+	//Doses one for each paper dose in the same order, a dose whose product was not recognised has no coding
+	//so is reported as an unknown vaccine, an ambiguous product has the codings of each candidate
+	Doses []*Dose
+
+	//BirthDate the FHIR birth date, as written if it could not be parsed
+	BirthDate string
+
+	//Warnings the plausibility issues found, DoseIndex is the index of the paper dose
+	Warnings []*Warning
+}
+
+const (
+	//WarningCodeUnrecognizedProduct the written product name did not match a vaccine
+	WarningCodeUnrecognizedProduct WarningCode = "unrecognized-product"
+
+	//WarningCodeFuzzyProductMatch the written product name only matched allowing for spelling mistakes
+	WarningCodeFuzzyProductMatch WarningCode = "fuzzy-product-match"
+
+	//WarningCodeMissingLot a paper dose has no lot number, genuine cards almost always have one
+	WarningCodeMissingLot WarningCode = "missing-lot"
+
+	//WarningCodeImplausibleLot the lot number does not look like a lot number
+	WarningCodeImplausibleLot WarningCode = "implausible-lot"
+
+	//WarningCodeDosesTooClose two doses of the vaccine are closer together than the schedule allows
+	WarningCodeDosesTooClose WarningCode = "doses-too-close"
+)
+
+//lotNumberRegExp lot numbers are short runs of letters and digits, with at least one digit
+var lotNumberRegExp = regexp.MustCompile(`^[A-Z0-9-]{4,12}$`)
+
+//ConvertPaperRecord converts the paper record to doses, the repo is used to match product names.
+//The doses can then be verified as usual, paper checks that do not apply to digital cards are
+//returned as warnings
+func ConvertPaperRecord(record *PaperRecord, repo vaccinemd.Repo) *PaperConversion {
+
+	result := &PaperConversion{
+		Doses:     make([]*Dose, 0, len(record.Doses)),
+		BirthDate: record.BirthDate,
+		Warnings:  make([]*Warning, 0),
+	}
+
+	if birthDate, err := parsePaperDate(record.BirthDate, record.DateOrder); err == nil {
+		result.BirthDate = birthDate.Time.Format(birthDateLayout(birthDate))
+	}
+
+	vaccines := make([]*vaccinemd.VaccineMetadata, len(record.Doses))
+	for i, paperDose := range record.Doses {
+
+		dose := &Dose{
+			Status:    CodeCompleted,
+			LotNumber: strings.TrimSpace(paperDose.LotNumber),
+			Site:      paperDose.Site,
+		}
+
+		//a date that cannot be parsed is kept as written so it is reported when verified
+		if occurrence, err := parsePaperDate(paperDose.Date, record.DateOrder); err == nil && !occurrence.IsPartial() {
+			dose.OccurrenceDateTime = occurrence.Time.Format("2006-01-02")
+		} else {
+			dose.OccurrenceString = paperDose.Date
+		}
+
+		match := repo.MatchVaccineName(paperDose.ProductName)
+		switch {
+		case match.Vaccine != nil:
+			dose.Coding = match.Vaccine.Codes[0]
+			vaccines[i] = match.Vaccine
+		case match.Ambiguous:
+			for _, candidate := range match.Candidates {
+				dose.Codings = append(dose.Codings, candidate.Codes[0])
+			}
+		default:
+			result.Warnings = append(result.Warnings, &Warning{
+				Code:      WarningCodeUnrecognizedProduct,
+				DoseIndex: i,
+				Message:   fmt.Sprintf("product name not recognised got=%s", paperDose.ProductName),
+			})
+		}
+
+		if match.Fuzzy {
+			result.Warnings = append(result.Warnings, &Warning{
+				Code:      WarningCodeFuzzyProductMatch,
+				DoseIndex: i,
+				Message:   fmt.Sprintf("product name only matched allowing for spelling mistakes got=%s", paperDose.ProductName),
+			})
+		}
+
+		switch {
+		case dose.LotNumber == "":
+			result.Warnings = append(result.Warnings, &Warning{
+				Code:      WarningCodeMissingLot,
+				DoseIndex: i,
+				Message:   "dose has no lot number",
+			})
+		case !plausibleLotNumber(dose.LotNumber):
+			result.Warnings = append(result.Warnings, &Warning{
+				Code:      WarningCodeImplausibleLot,
+				DoseIndex: i,
+				Message:   fmt.Sprintf("lot number does not look like a lot number got=%s", dose.LotNumber),
+			})
+		}
+
+		result.Doses = append(result.Doses, dose)
+	}
+
+	result.Warnings = append(result.Warnings, checkDoseSpacing(result.Doses, vaccines)...)
+
+	return result
+}
+
+//plausibleLotNumber letters, digits and hyphens with at least one digit
+func plausibleLotNumber(lotNumber string) bool {
+	lotNumber = strings.ToUpper(lotNumber)
+	return lotNumberRegExp.MatchString(lotNumber) && strings.ContainsAny(lotNumber, "0123456789")
+}
+
+//checkDoseSpacing flags consecutive doses of the same vaccine that are certainly closer than the
+//vaccine's minimum days between doses
+func checkDoseSpacing(doses []*Dose, vaccines []*vaccinemd.VaccineMetadata) []*Warning {
+
+	type dated struct {
+		index      int
+		occurrence *DateTime
+	}
+
+	byDate := make([]dated, 0, len(doses))
+	for i, dose := range doses {
+		if occurrence := DoseOccurrence(dose); occurrence != nil && vaccines[i] != nil {
+			byDate = append(byDate, dated{index: i, occurrence: occurrence})
+		}
+	}
+	sort.SliceStable(byDate, func(i, j int) bool {
+		return byDate[i].occurrence.Earliest().Before(byDate[j].occurrence.Earliest())
+	})
+
+	warnings := make([]*Warning, 0)
+	for i := 1; i < len(byDate); i++ {
+
+		earlier, later := byDate[i-1], byDate[i]
+		vmd := vaccines[later.index]
+		if vaccines[earlier.index].ID != vmd.ID || vmd.DaysBetweenDoesCriteriaBegin == 0 {
+			continue
+		}
+
+		//same day doses are reported as duplicates by ValidateDoses
+		days := MaxDaysBetween(earlier.occurrence, later.occurrence)
+		if days > 0 && days < vmd.DaysBetweenDoesCriteriaBegin {
+			warnings = append(warnings, &Warning{
+				Code:      WarningCodeDosesTooClose,
+				DoseIndex: later.index,
+				Message: fmt.Sprintf("dose is %d days after dose %d, at least %d days are required",
+					days, earlier.index, vmd.DaysBetweenDoesCriteriaBegin),
+			})
+		}
+	}
+
+	return warnings
+}
+
+var paperNumericDateRegExp = regexp.MustCompile(`^(\d{1,2})[/.-](\d{1,2})[/.-](\d{2}|\d{4})$`)
+
+//parsePaperDate parses a written date, numeric dates are read using the date order if known and
+//two digit years are taken to be in the 2000s
+func parsePaperDate(value string, order DateOrder) (*DateTime, error) {
+
+	value = strings.TrimSpace(value)
+
+	matches := paperNumericDateRegExp.FindStringSubmatch(value)
+	if matches == nil || order == DateOrderUnknown {
+		return ParseOccurrenceString(value)
+	}
+
+	first, _ := strconv.Atoi(matches[1])
+	second, _ := strconv.Atoi(matches[2])
+	year, _ := strconv.Atoi(matches[3])
+	if len(matches[3]) == 2 {
+		year += 2000
+	}
+
+	month, day := first, second
+	if order == DateOrderDayFirst {
+		day, month = first, second
+	}
+
+	t := time.Date(year, time.Month(month), day, 0, 0, 0, 0, time.UTC)
+	if t.Day() != day || int(t.Month()) != month {
+		return nil, fmt.Errorf("error parse paper date invalid date got=%s", value)
+	}
+
+	return &DateTime{Time: t, Precision: DatePrecisionDay, FreeText: true}, nil
+}
+
+//birthDateLayout the FHIR date layout for the precision the birth date was written with
+func birthDateLayout(dt *DateTime) string {
+	switch dt.Precision {
+	case DatePrecisionYear:
+		return "2006"
+	case DatePrecisionMonth:
+		return "2006-01"
+	}
+	return "2006-01-02"
+}
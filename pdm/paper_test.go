@@ -0,0 +1,128 @@
+package pdm_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_ConvertPaperRecord(t *testing.T) {
+
+	type testCase struct {
+		name              string
+		record            *pdm.PaperRecord
+		expectedCodes     []pdm.WarningCode
+		expectedCoding    string
+		expectedOccurence string
+		expectedBirthDate string
+	}
+
+	testCases := []testCase{
+		{
+			name: "should convert a cdc card",
+			record: &pdm.PaperRecord{
+				BirthDate: "01/02/1970",
+				DateOrder: pdm.DateOrderMonthFirst,
+				Doses: []*pdm.PaperDose{
+					{ProductName: "Moderna", LotNumber: "025J20A", Date: "3/16/21", Site: "CVS"},
+					{ProductName: "moderna 2nd dose", LotNumber: "030A21A", Date: "4/13/21", Site: "CVS"},
+				},
+			},
+			expectedCodes:     []pdm.WarningCode{},
+			expectedCoding:    "207",
+			expectedOccurence: "2021-03-16",
+			expectedBirthDate: "1970-01-02",
+		},
+		{
+			name: "should read day first dates",
+			record: &pdm.PaperRecord{
+				BirthDate: "1970",
+				DateOrder: pdm.DateOrderDayFirst,
+				Doses:     []*pdm.PaperDose{{ProductName: "J&J", LotNumber: "1805031", Date: "16/03/2021"}},
+			},
+			expectedCodes:     []pdm.WarningCode{},
+			expectedCoding:    "212",
+			expectedOccurence: "2021-03-16",
+			expectedBirthDate: "1970",
+		},
+		{
+			name: "should keep a partial date as written",
+			record: &pdm.PaperRecord{
+				Doses: []*pdm.PaperDose{{ProductName: "Pfizer", LotNumber: "EN6201", Date: "March 2021"}},
+			},
+			expectedCodes:     []pdm.WarningCode{},
+			expectedCoding:    "208",
+			expectedOccurence: "March 2021",
+		},
+		{
+			name: "should warn for unrecognized product",
+			record: &pdm.PaperRecord{
+				Doses: []*pdm.PaperDose{{ProductName: "Acme", LotNumber: "EN6201", Date: "2021-03-16"}},
+			},
+			expectedCodes:     []pdm.WarningCode{pdm.WarningCodeUnrecognizedProduct},
+			expectedOccurence: "2021-03-16",
+		},
+		{
+			name: "should warn for misspelt product",
+			record: &pdm.PaperRecord{
+				Doses: []*pdm.PaperDose{{ProductName: "Modrena", LotNumber: "025J20A", Date: "2021-03-16"}},
+			},
+			expectedCodes:     []pdm.WarningCode{pdm.WarningCodeFuzzyProductMatch},
+			expectedCoding:    "207",
+			expectedOccurence: "2021-03-16",
+		},
+		{
+			name: "should warn for missing and implausible lots",
+			record: &pdm.PaperRecord{
+				Doses: []*pdm.PaperDose{
+					{ProductName: "Pfizer", Date: "2021-03-16"},
+					{ProductName: "Pfizer", LotNumber: "ABCDEF", Date: "2021-04-06"},
+				},
+			},
+			expectedCodes:     []pdm.WarningCode{pdm.WarningCodeMissingLot, pdm.WarningCodeImplausibleLot},
+			expectedCoding:    "208",
+			expectedOccurence: "2021-03-16",
+		},
+		{
+			name: "should warn for doses too close",
+			record: &pdm.PaperRecord{
+				Doses: []*pdm.PaperDose{
+					{ProductName: "Pfizer", LotNumber: "EN6201", Date: "2021-03-16"},
+					{ProductName: "Pfizer", LotNumber: "EN6202", Date: "2021-03-26"},
+				},
+			},
+			expectedCodes:     []pdm.WarningCode{pdm.WarningCodeDosesTooClose},
+			expectedCoding:    "208",
+			expectedOccurence: "2021-03-16",
+		},
+	}
+
+	repo := vaccinemd.MakeRepo()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			conversion := pdm.ConvertPaperRecord(tc.record, repo)
+			require.Equal(t, len(tc.record.Doses), len(conversion.Doses))
+			require.Equal(t, tc.expectedCoding, conversion.Doses[0].Coding.Code)
+			require.Equal(t, tc.expectedOccurence,
+				conversion.Doses[0].OccurrenceDateTime+conversion.Doses[0].OccurrenceString)
+			require.Equal(t, tc.expectedBirthDate, conversion.BirthDate)
+
+			codes := make([]pdm.WarningCode, 0)
+			for _, warning := range conversion.Warnings {
+				codes = append(codes, warning.Code)
+			}
+			require.Equal(t, tc.expectedCodes, codes)
+		})
+	}
+
+	t.Run("should keep candidates for an ambiguous product", func(t *testing.T) {
+		conversion := pdm.ConvertPaperRecord(&pdm.PaperRecord{
+			Doses: []*pdm.PaperDose{{ProductName: "Hep B", LotNumber: "EN6201", Date: "2021-03-16"}},
+		}, repo)
+		require.Empty(t, conversion.Doses[0].Coding.Code)
+		require.Greater(t, len(conversion.Doses[0].Codings), 1)
+	})
+}
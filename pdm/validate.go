@@ -0,0 +1,336 @@
+package pdm
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+//WarningCode identifies a data quality issue found in a set of doses
+type WarningCode string
+
+const (
+	//WarningCodeDuplicateDose the dose has the same date and lot number as an earlier dose
+	WarningCodeDuplicateDose WarningCode = "duplicate-dose"
+
+	//WarningCodeFutureDose the dose is dated in the future
+	WarningCodeFutureDose WarningCode = "future-dose"
+
+	//WarningCodeDoseBeforeAuthorization the dose is dated before the vaccine was authorized
+	WarningCodeDoseBeforeAuthorization WarningCode = "dose-before-authorization"
+
+	//WarningCodeDoseBeforeBirth the dose is dated before the patient was born
+	WarningCodeDoseBeforeBirth WarningCode = "dose-before-birth"
+
+	//WarningCodeUnknownManufacturer the dose manufacturer code is not a known manufacturer
+	WarningCodeUnknownManufacturer WarningCode = "unknown-manufacturer"
+
+	//WarningCodeManufacturerMismatch the dose manufacturer is not the manufacturer of the vaccine
+	WarningCodeManufacturerMismatch WarningCode = "manufacturer-mismatch"
+
+	//WarningCodeLotProductMismatch the dose lot number is not a lot of the dose's vaccine
+	WarningCodeLotProductMismatch WarningCode = "lot-product-mismatch"
+
+	//WarningCodeDoseAfterLotExpiry the dose is dated after its lot expired
+	WarningCodeDoseAfterLotExpiry WarningCode = "dose-after-lot-expiry"
+
+	//WarningCodeCounterfeitLot the dose lot number has been reported as counterfeit
+	WarningCodeCounterfeitLot WarningCode = "counterfeit-lot"
+
+	//WarningCodeLotOutsideDistribution the dose was administered in a country its lot was not distributed to
+	WarningCodeLotOutsideDistribution WarningCode = "lot-outside-distribution"
+
+	//WarningCodeInvalidBirthDate the patient birth date could not be parsed so was not checked
+	WarningCodeInvalidBirthDate WarningCode = "invalid-birth-date"
+)
+
+//Warning a data quality issue, it does not stop verification but should be reported
+type Warning struct {
+
+	//Code what the issue is
+	Code WarningCode `json:"code"`
+
+	//DoseIndex index of the dose in the doses validated, -1 if not about a single dose
+	DoseIndex int `json:"dose_index"`
+
+	//Message human readable description
+	Message string `json:"message"`
+}
+
+//ValidationContext what the doses are validated against
+type ValidationContext struct {
+
+	//Now the time of validation
+	Now time.Time
+
+	//BirthDate the patient's FHIR birth date, if empty not checked
+	BirthDate string
+
+	//Repo used to find when a vaccine was authorized, if nil not checked
+	Repo vaccinemd.Repo
+
+	//Lots used to check dose lot numbers, if nil not checked
+	Lots vaccinemd.LotRegistry
+
+	//Excluded the indexes of doses that are not checked, for example doses of vaccines for other diseases
+	Excluded map[int]bool
+}
+
+//ValidateDoses the data quality warnings for the administered doses that are not Excluded, only certain
+//issues are flagged. A warning's DoseIndex is the dose's position in doses
+func ValidateDoses(doses []*Dose, vc *ValidationContext) []*Warning {
+
+	warnings := make([]*Warning, 0)
+
+	var birthDate *DateTime
+	if vc.BirthDate != "" {
+		var err error
+		birthDate, err = ParseDateTime(vc.BirthDate)
+		if err != nil {
+			warnings = append(warnings, &Warning{
+				Code:      WarningCodeInvalidBirthDate,
+				DoseIndex: -1,
+				Message:   fmt.Sprintf("birth date could not be parsed got=%s", vc.BirthDate),
+			})
+		}
+	}
+
+	//key is date and lot
+	seen := make(map[string]int)
+
+	for i, dose := range doses {
+
+		if !dose.Administered() || vc.Excluded[i] {
+			continue
+		}
+
+		if warning := checkManufacturer(dose, vc.Repo); warning != nil {
+			warning.DoseIndex = i
+			warnings = append(warnings, warning)
+		}
+
+		occurrence := DoseOccurrence(dose)
+
+		for _, warning := range checkLot(dose, occurrence, vc) {
+			warning.DoseIndex = i
+			warnings = append(warnings, warning)
+		}
+
+		if occurrence == nil {
+			continue
+		}
+
+		key := occurrence.Time.Format(time.RFC3339Nano) + "|" + occurrence.Precision.String() + "|" + dose.LotNumber
+		if first, ok := seen[key]; ok {
+			warnings = append(warnings, &Warning{
+				Code:      WarningCodeDuplicateDose,
+				DoseIndex: i,
+				Message:   fmt.Sprintf("dose has same date and lot number as dose %d", first),
+			})
+		} else {
+			seen[key] = i
+		}
+
+		if !vc.Now.IsZero() && occurrence.Earliest().After(vc.Now) {
+			warnings = append(warnings, &Warning{
+				Code:      WarningCodeFutureDose,
+				DoseIndex: i,
+				Message:   fmt.Sprintf("dose is dated in the future got=%s", occurrence.Earliest().Format("2006-01-02")),
+			})
+		}
+
+		if birthDate != nil && occurrence.Latest().Before(birthDate.Earliest()) {
+			warnings = append(warnings, &Warning{
+				Code:      WarningCodeDoseBeforeBirth,
+				DoseIndex: i,
+				Message:   "dose is dated before the patient was born",
+			})
+		}
+
+		if authorized := authorizedDate(dose, vc.Repo); authorized != nil &&
+			occurrence.Latest().Before(authorized.Earliest()) {
+			warnings = append(warnings, &Warning{
+				Code:      WarningCodeDoseBeforeAuthorization,
+				DoseIndex: i,
+				Message:   fmt.Sprintf("dose is dated before vaccine was authorized on %s", authorized.Time.Format("2006-01-02")),
+			})
+		}
+	}
+
+	return warnings
+}
+
+//DoseOccurrence the doses date, nil if none or cannot be parsed
+func DoseOccurrence(dose *Dose) *DateTime {
+
+	if dose.OccurrenceDateTime != "" {
+		occurrence, err := ParseDateTime(dose.OccurrenceDateTime)
+		if err != nil {
+			return nil
+		}
+		return occurrence
+	}
+
+	if dose.OccurrenceString != "" {
+		occurrence, err := ParseOccurrenceString(dose.OccurrenceString)
+		if err != nil {
+			return nil
+		}
+		return occurrence
+	}
+
+	return nil
+}
+
+func authorizedDate(dose *Dose, repo vaccinemd.Repo) *DateTime {
+
+	if repo == nil {
+		return nil
+	}
+
+	vmd := repo.ResolveVaccine(dose.AllCodings()).Vaccine
+	if vmd == nil || vmd.AuthorizedDate == "" {
+		return nil
+	}
+
+	authorized, err := ParseDateTime(vmd.AuthorizedDate)
+	if err != nil {
+		return nil
+	}
+
+	return authorized
+}
+
+//checkManufacturer a warning if the dose manufacturer is unknown or does not make the vaccine, nil if ok or
+//cannot be checked
+func checkManufacturer(dose *Dose, repo vaccinemd.Repo) *Warning {
+
+	if repo == nil || dose.Manufacturer == nil || dose.Manufacturer.Code == "" {
+		return nil
+	}
+
+	manufacturer := repo.FindManufacturer(dose.Manufacturer.System, dose.Manufacturer.Code)
+	if manufacturer == nil {
+		return &Warning{
+			Code:    WarningCodeUnknownManufacturer,
+			Message: fmt.Sprintf("dose manufacturer is not known got=%s", dose.Manufacturer.Code),
+		}
+	}
+
+	vmd := repo.ResolveVaccine(dose.AllCodings()).Vaccine
+	if vmd == nil || vmd.ManufacturerID == "" || vmd.ManufacturerID == manufacturer.ID {
+		return nil
+	}
+
+	return &Warning{
+		Code: WarningCodeManufacturerMismatch,
+		Message: fmt.Sprintf("dose manufacturer %s does not make vaccine %s",
+			manufacturer.DisplayName, vmd.DisplayName),
+	}
+}
+
+//checkLot warnings for the dose lot, a lot number that is not in the registry is not flagged as registries
+//are rarely complete. The occurrence can be nil
+func checkLot(dose *Dose, occurrence *DateTime, vc *ValidationContext) []*Warning {
+
+	warnings := make([]*Warning, 0)
+
+	if vc.Lots == nil || dose.LotNumber == "" {
+		return warnings
+	}
+
+	lots := vc.Lots.FindLots(dose.LotNumber)
+	if len(lots) == 0 {
+		return warnings
+	}
+
+	//only the lots of the dose's vaccine, if the vaccine is not known all lots with the number
+	var vmd *vaccinemd.VaccineMetadata
+	if vc.Repo != nil {
+		vmd = vc.Repo.ResolveVaccine(dose.AllCodings()).Vaccine
+	}
+
+	matching := lots
+	if vmd != nil {
+		matching = make([]*vaccinemd.Lot, 0, len(lots))
+		for _, lot := range lots {
+			if lot.VaccineID == vmd.ID {
+				matching = append(matching, lot)
+			}
+		}
+
+		if len(matching) == 0 {
+			return append(warnings, &Warning{
+				Code:    WarningCodeLotProductMismatch,
+				Message: fmt.Sprintf("lot %s is not a lot of vaccine %s", dose.LotNumber, vmd.DisplayName),
+			})
+		}
+	}
+
+	for _, lot := range matching {
+		if lot.Counterfeit {
+			warnings = append(warnings, &Warning{
+				Code:    WarningCodeCounterfeitLot,
+				Message: fmt.Sprintf("lot %s has been reported as counterfeit", dose.LotNumber),
+			})
+			break
+		}
+	}
+
+	if occurrence != nil && afterAllExpiries(occurrence, matching) {
+		warnings = append(warnings, &Warning{
+			Code:    WarningCodeDoseAfterLotExpiry,
+			Message: fmt.Sprintf("dose is dated after lot %s expired", dose.LotNumber),
+		})
+	}
+
+	if dose.Country != "" && outsideAllDistributions(dose.Country, matching) {
+		warnings = append(warnings, &Warning{
+			Code:    WarningCodeLotOutsideDistribution,
+			Message: fmt.Sprintf("dose was administered in %s where lot %s was not distributed", dose.Country, dose.LotNumber),
+		})
+	}
+
+	return warnings
+}
+
+//outsideAllDistributions true if no lot was distributed to the country, lots without distribution regions
+//could have been distributed anywhere
+func outsideAllDistributions(country string, lots []*vaccinemd.Lot) bool {
+
+	for _, lot := range lots {
+
+		if len(lot.DistributionRegions) == 0 {
+			return false
+		}
+
+		for _, region := range lot.DistributionRegions {
+			if strings.EqualFold(strings.TrimSpace(region), strings.TrimSpace(country)) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+//afterAllExpiries true if the dose was certainly given after every lot with an expiry date expired, lots
+//without an expiry date never expire
+func afterAllExpiries(occurrence *DateTime, lots []*vaccinemd.Lot) bool {
+
+	for _, lot := range lots {
+
+		if lot.ExpiryDate == "" {
+			return false
+		}
+
+		expiry, err := ParseDateTime(lot.ExpiryDate)
+		if err != nil || !occurrence.Earliest().After(expiry.Latest()) {
+			return false
+		}
+	}
+
+	return true
+}
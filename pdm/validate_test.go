@@ -0,0 +1,233 @@
+package pdm_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_ValidateDoses(t *testing.T) {
+
+	type testCase struct {
+		name          string
+		birthDate     string
+		doses         []*pdm.Dose
+		expectedCodes []pdm.WarningCode
+	}
+
+	pfizer := vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "208"}
+
+	testCases := []testCase{
+		{
+			name:      "should not warn for good doses",
+			birthDate: "1970-01-01",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6201"},
+				{Coding: pfizer, OccurrenceDateTime: "2021-04-06", LotNumber: "EN6202"},
+			},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name: "should warn for duplicate date and lot",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6201"},
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6201"},
+			},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeDuplicateDose},
+		},
+		{
+			name: "should not warn for same date different lot",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6201"},
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6202"},
+			},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name: "should warn for dose in the future",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-11-01"},
+			},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeFutureDose},
+		},
+		{
+			name: "should not warn for the vaccine's manufacturer",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", Manufacturer: &vaccinemd.Coding{System: vaccinemd.MVXSystem, Code: "PFR"}},
+				{Coding: pfizer, OccurrenceDateTime: "2021-04-06", Manufacturer: &vaccinemd.Coding{System: vaccinemd.EUOrgSystem, Code: "ORG-100030215"}},
+			},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name: "should warn for another manufacturer",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", Manufacturer: &vaccinemd.Coding{System: vaccinemd.MVXSystem, Code: "MOD"}},
+			},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeManufacturerMismatch},
+		},
+		{
+			name: "should warn for unknown manufacturer",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16", Manufacturer: &vaccinemd.Coding{System: vaccinemd.EUOrgSystem, Code: "ORG-1"}},
+			},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeUnknownManufacturer},
+		},
+		{
+			name: "should warn for dose before authorization",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2020-11-01"},
+			},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeDoseBeforeAuthorization},
+		},
+		{
+			name: "should not warn for partial date that could be after authorization",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2020-12"},
+			},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name:      "should warn for dose before birth",
+			birthDate: "2021-04-01",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16"},
+			},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeDoseBeforeBirth},
+		},
+		{
+			name:      "should warn for invalid birth date",
+			birthDate: "01/04/2021",
+			doses: []*pdm.Dose{
+				{Coding: pfizer, OccurrenceDateTime: "2021-03-16"},
+			},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeInvalidBirthDate},
+		},
+	}
+
+	repo := vaccinemd.MakeRepo()
+	now := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			warnings := pdm.ValidateDoses(tc.doses, &pdm.ValidationContext{
+				Now:       now,
+				BirthDate: tc.birthDate,
+				Repo:      repo,
+			})
+
+			codes := make([]pdm.WarningCode, 0)
+			for _, w := range warnings {
+				codes = append(codes, w.Code)
+			}
+			require.Equal(t, tc.expectedCodes, codes)
+		})
+	}
+
+	t.Run("should skip excluded doses and keep the dose index", func(t *testing.T) {
+
+		warnings := pdm.ValidateDoses([]*pdm.Dose{
+			{Coding: pfizer, OccurrenceDateTime: "2021-11-01", Status: pdm.CodeNotDone},
+			{Coding: pfizer, OccurrenceDateTime: "2021-11-01"},
+			{Coding: pfizer, OccurrenceDateTime: "2021-11-01"},
+		}, &pdm.ValidationContext{Now: now, Excluded: map[int]bool{1: true}})
+
+		require.Equal(t, 1, len(warnings))
+		require.Equal(t, pdm.WarningCodeFutureDose, warnings[0].Code)
+		require.Equal(t, 2, warnings[0].DoseIndex)
+	})
+}
+
+func Test_ValidateDoseLots(t *testing.T) {
+
+	type testCase struct {
+		name          string
+		dose          *pdm.Dose
+		expectedCodes []pdm.WarningCode
+	}
+
+	pfizer := vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "208"}
+	moderna := vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}
+
+	testCases := []testCase{
+		{
+			name:          "should not warn for lot of the vaccine",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: " en6201 "},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name:          "should not warn for unknown lot",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "XX0000"},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name:          "should warn for lot of another vaccine",
+			dose:          &pdm.Dose{Coding: moderna, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6201"},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeLotProductMismatch},
+		},
+		{
+			name:          "should not warn on expiry date",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-06-30", LotNumber: "EN6201"},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name:          "should warn after expiry",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-07-01", LotNumber: "EN6201"},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeDoseAfterLotExpiry},
+		},
+		{
+			name:          "should not warn if partial date could be before expiry",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-06", LotNumber: "EN6201"},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name:          "should not warn for a dose in a distribution region",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6201", Country: "us"},
+			expectedCodes: []pdm.WarningCode{},
+		},
+		{
+			name:          "should warn for a dose outside the distribution regions",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "EN6201", Country: "DE"},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeLotOutsideDistribution},
+		},
+		{
+			name:          "should not warn if the lot distribution is not known",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "FAKE01", Country: "DE"},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeCounterfeitLot},
+		},
+		{
+			name:          "should warn for counterfeit lot",
+			dose:          &pdm.Dose{Coding: pfizer, OccurrenceDateTime: "2021-03-16", LotNumber: "FAKE01"},
+			expectedCodes: []pdm.WarningCode{pdm.WarningCodeCounterfeitLot},
+		},
+	}
+
+	lots, err := vaccinemd.MakeLotRegistry([]*vaccinemd.Lot{
+		{LotNumber: "EN6201", VaccineID: vaccinemd.CVXSystem + "#208", ManufacturerID: "pfizer-biontech", ExpiryDate: "2021-06-30", DistributionRegions: []string{"US", "CA"}},
+		{LotNumber: "FAKE01", VaccineID: vaccinemd.CVXSystem + "#208", Counterfeit: true},
+	})
+	require.NoError(t, err)
+
+	repo := vaccinemd.MakeRepo()
+	now := time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC)
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			warnings := pdm.ValidateDoses([]*pdm.Dose{tc.dose}, &pdm.ValidationContext{
+				Now:  now,
+				Repo: repo,
+				Lots: lots,
+			})
+
+			codes := make([]pdm.WarningCode, 0)
+			for _, w := range warnings {
+				codes = append(codes, w.Code)
+			}
+			require.Equal(t, tc.expectedCodes, codes)
+		})
+	}
+}
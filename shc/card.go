@@ -0,0 +1,249 @@
+//Package shc decodes SMART Health Cards from their QR codes, .smart-health-card files or JWS, see
+//https://spec.smarthealth.cards, and converts them to cards that can be verified. Issuer signs new cards
+package shc
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/internal/jws"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+const (
+	//QRPrefix the prefix of a card's QR code
+	QRPrefix = "shc:/"
+
+	//FileExtension the extension of a card file
+	FileExtension = ".smart-health-card"
+
+	//CredentialTypeHealthCard the verifiable credential type of all cards
+	CredentialTypeHealthCard = "https://smarthealth.cards#health-card"
+
+	//CredentialTypeImmunization the verifiable credential type of cards with immunizations
+	CredentialTypeImmunization = "https://smarthealth.cards#immunization"
+
+	//CredentialTypeCOVID19 the verifiable credential type of cards about COVID-19
+	CredentialTypeCOVID19 = "https://smarthealth.cards#covid19"
+
+	//zipDeflate the JWS zip header for raw deflate
+	zipDeflate = "DEF"
+
+	//maxPayloadSize a card QR code holds at most a few KB so this is generous, it stops decompression bombs
+	maxPayloadSize = 1 << 20
+)
+
+//Payload the JWS payload
+type Payload struct {
+	Issuer     string      `json:"iss"`
+	NotBefore  int64       `json:"nbf"`
+	ExpiresAt  int64       `json:"exp,omitempty"`
+	Credential *Credential `json:"vc"`
+}
+
+//Credential the verifiable credential
+type Credential struct {
+	Type              []string           `json:"type"`
+	CredentialSubject *CredentialSubject `json:"credentialSubject"`
+}
+
+//CredentialSubject the FHIR content
+type CredentialSubject struct {
+	FHIRVersion string  `json:"fhirVersion"`
+	FHIRBundle  *Bundle `json:"fhirBundle"`
+}
+
+//File the contents of a .smart-health-card file
+type File struct {
+	VerifiableCredential []string `json:"verifiableCredential"`
+}
+
+//HealthCard a decoded card, the signature has not been checked, see Verify
+type HealthCard struct {
+
+	//JWS the card's compact JWS
+	JWS string
+
+	//KeyID the kid of the key the card was signed with, the thumbprint of one of the issuer's keys
+	KeyID string
+
+	//Payload the decoded payload
+	Payload *Payload
+
+	token *jws.Token
+}
+
+//Parse decode a card from a QR code, all chunks of a chunked QR code separated by white space, a
+//.smart-health-card file or a JWS. Only the first card in a file is decoded
+func Parse(input string) (*HealthCard, error) {
+
+	input = strings.TrimSpace(input)
+
+	switch {
+	case strings.HasPrefix(input, QRPrefix):
+		compact, err := DecodeQR(strings.Fields(input)...)
+		if err != nil {
+			return nil, err
+		}
+		return ParseJWS(compact)
+
+	case strings.HasPrefix(input, "{"):
+		var file File
+		if err := json.Unmarshal([]byte(input), &file); err != nil {
+			return nil, fmt.Errorf("error shc parse file err=%s", err)
+		}
+		if len(file.VerifiableCredential) == 0 {
+			return nil, fmt.Errorf("error shc parse file has no verifiableCredential")
+		}
+		return ParseJWS(file.VerifiableCredential[0])
+	}
+
+	return ParseJWS(input)
+}
+
+//DecodeQR the JWS from the QR code, a chunked card's chunks are shc:/index/total/digits and can be in any order
+func DecodeQR(chunks ...string) (string, error) {
+
+	if len(chunks) == 0 {
+		return "", fmt.Errorf("error shc decode qr no qr code")
+	}
+
+	digits := make([]string, len(chunks))
+	for _, chunk := range chunks {
+
+		if !strings.HasPrefix(chunk, QRPrefix) {
+			return "", fmt.Errorf("error shc decode qr missing prefix %s", QRPrefix)
+		}
+
+		parts := strings.Split(strings.TrimPrefix(chunk, QRPrefix), "/")
+		switch len(parts) {
+		case 1:
+			if len(chunks) != 1 {
+				return "", fmt.Errorf("error shc decode qr unchunked qr code with other chunks")
+			}
+			digits[0] = parts[0]
+		case 3:
+			index, indexErr := strconv.Atoi(parts[0])
+			total, totalErr := strconv.Atoi(parts[1])
+			if indexErr != nil || totalErr != nil || total != len(chunks) || index < 1 || index > total {
+				return "", fmt.Errorf("error shc decode qr invalid chunk got=%s/%s", parts[0], parts[1])
+			}
+			if digits[index-1] != "" {
+				return "", fmt.Errorf("error shc decode qr chunk=%d repeated", index)
+			}
+			digits[index-1] = parts[2]
+		default:
+			return "", fmt.Errorf("error shc decode qr invalid qr code")
+		}
+	}
+
+	all := strings.Join(digits, "")
+	if len(all)%2 != 0 {
+		return "", fmt.Errorf("error shc decode qr odd number of digits")
+	}
+
+	var b strings.Builder
+	for i := 0; i < len(all); i += 2 {
+		n, err := strconv.Atoi(all[i : i+2])
+		if err != nil || n > 'z'-45 {
+			return "", fmt.Errorf("error shc decode qr invalid digits at=%d", i)
+		}
+		b.WriteByte(byte(n + 45))
+	}
+
+	return b.String(), nil
+}
+
+//EncodeQR the QR code content for the JWS, see HealthCard QRCodes for chunking
+func EncodeQR(compact string) string {
+	var b strings.Builder
+	b.WriteString(QRPrefix)
+	for i := 0; i < len(compact); i++ {
+		fmt.Fprintf(&b, "%02d", int(compact[i])-45)
+	}
+	return b.String()
+}
+
+//ParseJWS decode the card's JWS, the payload must be deflated
+func ParseJWS(compact string) (*HealthCard, error) {
+
+	token, err := jws.Parse(compact)
+	if err != nil {
+		return nil, fmt.Errorf("error shc parse err=%s", err)
+	}
+
+	if token.Header.Zip != zipDeflate {
+		return nil, fmt.Errorf("error shc parse payload must be deflated zip=%s", token.Header.Zip)
+	}
+
+	inflated, err := io.ReadAll(io.LimitReader(flate.NewReader(bytes.NewReader(token.Payload)), maxPayloadSize+1))
+	if err != nil {
+		return nil, fmt.Errorf("error shc parse inflate err=%s", err)
+	}
+	if len(inflated) > maxPayloadSize {
+		return nil, fmt.Errorf("error shc parse payload too large")
+	}
+
+	var payload Payload
+	if err := json.Unmarshal(inflated, &payload); err != nil {
+		return nil, fmt.Errorf("error shc parse payload err=%s", err)
+	}
+
+	if payload.Issuer == "" || payload.Credential == nil || payload.Credential.CredentialSubject == nil ||
+		payload.Credential.CredentialSubject.FHIRBundle == nil {
+		return nil, fmt.Errorf("error shc parse payload is missing iss or fhirBundle")
+	}
+
+	return &HealthCard{
+		JWS:     strings.TrimSpace(compact),
+		KeyID:   token.Header.KeyID,
+		Payload: &payload,
+		token:   token,
+	}, nil
+}
+
+//Verify check the card was signed by the key
+func (hc *HealthCard) Verify(key *ecdsa.PublicKey) error {
+	if err := hc.token.VerifyES256(key); err != nil {
+		return fmt.Errorf("error shc verify err=%s", err)
+	}
+	return nil
+}
+
+//Card the card to verify, Raw is the JWS
+func (hc *HealthCard) Card() *verification.Card {
+
+	bundle := hc.Payload.Credential.CredentialSubject.FHIRBundle
+	card := &verification.Card{
+		Issuer:     hc.Payload.Issuer,
+		Raw:        []byte(hc.JWS),
+		TimeClaims: verification.TimeClaimsFromUnix(hc.Payload.ExpiresAt, hc.Payload.NotBefore, 0),
+	}
+
+	if patient := bundle.Patient(); patient != nil {
+		card.PatientGivenName = patient.GivenName()
+		card.PatientFamilyName = patient.FamilyName()
+		card.PatientBirthDate = patient.BirthDate
+	}
+
+	for _, immunization := range bundle.Immunizations() {
+		card.Doses = append(card.Doses, immunization.Dose())
+	}
+
+	return card
+}
+
+//HasType true if the credential has the type
+func (hc *HealthCard) HasType(credentialType string) bool {
+	types := append([]string{}, hc.Payload.Credential.Type...)
+	sort.Strings(types)
+	i := sort.SearchStrings(types, credentialType)
+	return i < len(types) && types[i] == credentialType
+}
@@ -0,0 +1,124 @@
+package shc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/shc"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+func Test_Parse(t *testing.T) {
+
+	file, err := os.ReadFile("../testdata/card.smart-health-card")
+	require.NoError(t, err)
+	qr, err := os.ReadFile("../testdata/card.qr.txt")
+	require.NoError(t, err)
+
+	var cardFile shc.File
+	require.NoError(t, json.Unmarshal(file, &cardFile))
+	compact := cardFile.VerifiableCredential[0]
+
+	decoded, err := shc.DecodeQR(strings.TrimSpace(string(qr)))
+	require.NoError(t, err)
+	require.Equal(t, compact, decoded)
+	require.Equal(t, strings.TrimSpace(string(qr)), shc.EncodeQR(compact))
+
+	for _, input := range []string{string(file), string(qr), compact} {
+
+		hc, err := shc.Parse(input)
+		require.NoError(t, err)
+		require.Equal(t, testIssuer, hc.Payload.Issuer)
+		require.True(t, hc.HasType(shc.CredentialTypeImmunization))
+		require.False(t, hc.HasType("https://smarthealth.cards#laboratory"))
+
+		card := hc.Card()
+		require.Equal(t, testIssuer, card.Issuer)
+		require.Equal(t, compact, string(card.Raw))
+		require.Equal(t, "John B.", card.PatientGivenName)
+		require.Equal(t, "Anyperson", card.PatientFamilyName)
+		require.Equal(t, "1951-01-20", card.PatientBirthDate)
+		require.NotNil(t, card.TimeClaims.NotBefore)
+		require.Nil(t, card.TimeClaims.ExpiresAt)
+		require.Len(t, card.Doses, 2)
+		require.Equal(t, vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}, card.Doses[0].Coding)
+		require.Equal(t, "2021-01-29", card.Doses[1].OccurrenceDateTime)
+		require.Equal(t, "ABC General Hospital", card.Doses[1].Site)
+	}
+
+	t.Run("should decode chunks in any order", func(t *testing.T) {
+		digits := strings.TrimPrefix(strings.TrimSpace(string(qr)), shc.QRPrefix)
+		half := len(digits) / 4 * 2
+		decoded, err := shc.DecodeQR("shc:/2/2/"+digits[half:], "shc:/1/2/"+digits[:half])
+		require.NoError(t, err)
+		require.Equal(t, compact, decoded)
+
+		_, err = shc.DecodeQR("shc:/1/2/"+digits[:half], "shc:/1/2/"+digits[half:])
+		require.Error(t, err, "repeated chunk")
+	})
+
+	t.Run("should not parse invalid cards", func(t *testing.T) {
+		for _, input := range []string{"", "shc:/123", "shc:/9999", `{"verifiableCredential":[]}`, "a.b.c"} {
+			_, err := shc.Parse(input)
+			require.Error(t, err, input)
+		}
+	})
+}
+
+func Test_KeyVerifier(t *testing.T) {
+
+	hc, err := shc.Parse(readTestCard(t))
+	require.NoError(t, err)
+
+	var trust struct {
+		SHCKeySets map[string]json.RawMessage `json:"shc_key_sets"`
+	}
+	data, err := os.ReadFile("../testdata/trust.json")
+	require.NoError(t, err)
+	require.NoError(t, json.Unmarshal(data, &trust))
+
+	keys, err := shc.ParseKeySet(trust.SHCKeySets[testIssuer])
+	require.NoError(t, err)
+	require.Contains(t, keys, hc.KeyID)
+	require.NoError(t, hc.Verify(keys[hc.KeyID]))
+
+	verifier := shc.NewKeyVerifier(map[string]map[string]*ecdsa.PublicKey{testIssuer: keys})
+
+	result, err := verifier.VerifySignature(context.Background(), hc.Card())
+	require.NoError(t, err)
+	require.True(t, result.Checked && result.FetchedKey && result.Valid)
+
+	t.Run("should be invalid if the signature is changed", func(t *testing.T) {
+		card := hc.Card()
+		signature := card.Raw[strings.LastIndex(string(card.Raw), ".")+1:]
+		if signature[0] == 'A' {
+			signature[0] = 'B'
+		} else {
+			signature[0] = 'A'
+		}
+		result, err := verifier.VerifySignature(context.Background(), card)
+		require.NoError(t, err)
+		require.True(t, result.FetchedKey)
+		require.False(t, result.Valid)
+	})
+
+	t.Run("should not fetch the key of an unknown issuer", func(t *testing.T) {
+		result, err := shc.NewKeyVerifier(nil).VerifySignature(context.Background(), hc.Card())
+		require.NoError(t, err)
+		require.True(t, result.Checked)
+		require.False(t, result.FetchedKey)
+	})
+}
+
+func readTestCard(t *testing.T) string {
+	data, err := os.ReadFile("../testdata/card.smart-health-card")
+	require.NoError(t, err)
+	return string(data)
+}
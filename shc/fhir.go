@@ -0,0 +1,161 @@
+package shc
+
+import (
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+//
+// The subset of FHIR R4 carried in a SMART Health Card, a collection Bundle of one Patient and their
+// Immunizations, minified as the spec requires so resources only have the elements cards use.
+//
+
+const (
+	//ResourceTypePatient a Patient resource
+	ResourceTypePatient = "Patient"
+
+	//ResourceTypeImmunization an Immunization resource
+	ResourceTypeImmunization = "Immunization"
+)
+
+//Bundle a FHIR Bundle of type collection
+type Bundle struct {
+	ResourceType string         `json:"resourceType"`
+	Type         string         `json:"type"`
+	Entry        []*BundleEntry `json:"entry"`
+}
+
+//BundleEntry an entry in the bundle, cards use short resource:N full URLs
+type BundleEntry struct {
+	FullURL  string    `json:"fullUrl"`
+	Resource *Resource `json:"resource"`
+}
+
+//Resource the Patient and Immunization elements used by cards, which are set depends on ResourceType
+type Resource struct {
+	ResourceType string `json:"resourceType"`
+
+	//Patient
+	Name      []*HumanName `json:"name,omitempty"`
+	BirthDate string       `json:"birthDate,omitempty"`
+
+	//Immunization
+	Status             string           `json:"status,omitempty"`
+	VaccineCode        *CodeableConcept `json:"vaccineCode,omitempty"`
+	Patient            *Reference       `json:"patient,omitempty"`
+	OccurrenceDateTime string           `json:"occurrenceDateTime,omitempty"`
+	OccurrenceString   string           `json:"occurrenceString,omitempty"`
+	Manufacturer       *Reference       `json:"manufacturer,omitempty"`
+	LotNumber          string           `json:"lotNumber,omitempty"`
+	Performer          []*Performer     `json:"performer,omitempty"`
+}
+
+//HumanName a patient name
+type HumanName struct {
+	Family string   `json:"family,omitempty"`
+	Given  []string `json:"given,omitempty"`
+}
+
+//CodeableConcept a set of codings for the same concept
+type CodeableConcept struct {
+	Coding []*Coding `json:"coding"`
+}
+
+//Coding a code in a code system
+type Coding struct {
+	System string `json:"system"`
+	Code   string `json:"code"`
+}
+
+//Reference a reference to another resource or an identifier
+type Reference struct {
+	Reference  string      `json:"reference,omitempty"`
+	Identifier *Identifier `json:"identifier,omitempty"`
+}
+
+//Identifier an identifier in a system, for example a manufacturer's MVX code
+type Identifier struct {
+	System string `json:"system"`
+	Value  string `json:"value"`
+}
+
+//Performer who administered the immunization
+type Performer struct {
+	Actor *Actor `json:"actor"`
+}
+
+//Actor the organization that administered the immunization
+type Actor struct {
+	Display string `json:"display"`
+}
+
+//Patient the first Patient resource, nil if none
+func (b *Bundle) Patient() *Resource {
+	for _, entry := range b.Entry {
+		if entry.Resource != nil && entry.Resource.ResourceType == ResourceTypePatient {
+			return entry.Resource
+		}
+	}
+	return nil
+}
+
+//Immunizations the Immunization resources in bundle order
+func (b *Bundle) Immunizations() []*Resource {
+	result := make([]*Resource, 0)
+	for _, entry := range b.Entry {
+		if entry.Resource != nil && entry.Resource.ResourceType == ResourceTypeImmunization {
+			result = append(result, entry.Resource)
+		}
+	}
+	return result
+}
+
+//Dose the immunization as a dose, the first vaccine coding is the Coding and the rest are Codings
+func (r *Resource) Dose() *pdm.Dose {
+
+	dose := &pdm.Dose{
+		Status:             pdm.Code(r.Status),
+		OccurrenceDateTime: r.OccurrenceDateTime,
+		OccurrenceString:   r.OccurrenceString,
+		LotNumber:          r.LotNumber,
+	}
+
+	if r.VaccineCode != nil {
+		for i, coding := range r.VaccineCode.Coding {
+			c := vaccinemd.Coding{System: coding.System, Code: coding.Code}
+			if i == 0 {
+				dose.Coding = c
+			} else {
+				dose.Codings = append(dose.Codings, c)
+			}
+		}
+	}
+
+	if r.Manufacturer != nil && r.Manufacturer.Identifier != nil {
+		dose.Manufacturer = &vaccinemd.Coding{System: r.Manufacturer.Identifier.System, Code: r.Manufacturer.Identifier.Value}
+	}
+
+	if len(r.Performer) > 0 && r.Performer[0].Actor != nil {
+		dose.Site = r.Performer[0].Actor.Display
+	}
+
+	return dose
+}
+
+//GivenName the given names of the first name joined by spaces
+func (r *Resource) GivenName() string {
+	if len(r.Name) == 0 {
+		return ""
+	}
+	return strings.Join(r.Name[0].Given, " ")
+}
+
+//FamilyName the family name of the first name
+func (r *Resource) FamilyName() string {
+	if len(r.Name) == 0 {
+		return ""
+	}
+	return r.Name[0].Family
+}
@@ -0,0 +1,313 @@
+package shc
+
+import (
+	"bytes"
+	"compress/flate"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/jws"
+	"github.com/webshield-dev/dhc-common/pdm"
+)
+
+//
+// Issuing is the reverse of Parse, the patient and doses become a minified FHIR bundle that is deflated and signed
+// as a JWS. The JWS is what a .smart-health-card file holds and what a QR code encodes, chunked if it is too long
+// for one code.
+//
+
+const (
+	//FHIRVersion the FHIR version of the bundles issued
+	FHIRVersion = "4.0.1"
+
+	//MaxSingleQRSize the longest JWS encoded in one QR code, a version 22 code at error correction level L
+	MaxSingleQRSize = 1195
+
+	//MaxChunkQRSize the longest JWS chunk in each code of a chunked QR code
+	MaxChunkQRSize = 1191
+
+	//bundleTypeCollection the type of a card's bundle
+	bundleTypeCollection = "collection"
+
+	//patientReference the patient's full URL, the patient is always the first entry
+	patientReference = "resource:0"
+)
+
+//Patient who a card is issued to
+type Patient struct {
+
+	//GivenNames the patient's given names
+	GivenNames []string
+
+	//FamilyName the patient's family name
+	FamilyName string
+
+	//BirthDate the patient's FHIR birth date, for example 1951-01-20
+	BirthDate string
+}
+
+//Record what a card is issued for
+type Record struct {
+
+	//Patient who the card is for
+	Patient *Patient
+
+	//Doses the doses on the card in order, a dose without a Status is completed
+	Doses []*pdm.Dose
+
+	//Types the credential types after health-card and immunization, for example CredentialTypeCOVID19
+	Types []string
+
+	//NotBefore when the card was issued, if zero now
+	NotBefore time.Time
+
+	//ExpiresAt when the card expires, if zero it does not
+	ExpiresAt time.Time
+}
+
+//Issuer signs cards with one of an issuer's keys, safe for concurrent use
+type Issuer struct {
+	url   string
+	key   *ecdsa.PrivateKey
+	keyID string
+}
+
+//NewIssuer an issuer for the iss URL signing with the P-256 key, the kid is the key's thumbprint so the public
+//key must be published in the issuer's key set, see KeySet
+func NewIssuer(url string, key *ecdsa.PrivateKey) (*Issuer, error) {
+
+	if url == "" || strings.HasSuffix(url, "/") {
+		return nil, fmt.Errorf("error shc new issuer url is required and cannot end with / got=%s", url)
+	}
+
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("error shc new issuer key must be a P-256 private key")
+	}
+
+	keyID, err := jws.Thumbprint(&key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error shc new issuer err=%s", err)
+	}
+
+	return &Issuer{url: url, key: key, keyID: keyID}, nil
+}
+
+//ParsePrivateKey a P-256 private key in a PEM block, SEC 1 EC PRIVATE KEY or PKCS #8 PRIVATE KEY
+func ParsePrivateKey(data []byte) (*ecdsa.PrivateKey, error) {
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("error shc parse private key no pem block")
+	}
+
+	var key interface{}
+	var err error
+	switch block.Type {
+	case "EC PRIVATE KEY":
+		key, err = x509.ParseECPrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		key, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("error shc parse private key unsupported pem type=%s", block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("error shc parse private key err=%s", err)
+	}
+
+	ecKey, ok := key.(*ecdsa.PrivateKey)
+	if !ok || ecKey.Curve != elliptic.P256() {
+		return nil, fmt.Errorf("error shc parse private key must be a P-256 key")
+	}
+
+	return ecKey, nil
+}
+
+//KeyID the kid cards are signed with
+func (i *Issuer) KeyID() string {
+	return i.keyID
+}
+
+//KeySet the JWK set to publish at the issuer's URL + /.well-known/jwks.json so cards can be verified
+func (i *Issuer) KeySet() ([]byte, error) {
+
+	jwk, err := jws.PublicJWK(&i.key.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("error shc key set err=%s", err)
+	}
+
+	data, err := json.Marshal(&jws.JWKSet{Keys: []*jws.JWK{jwk}})
+	if err != nil {
+		return nil, fmt.Errorf("error shc key set err=%s", err)
+	}
+
+	return data, nil
+}
+
+//Issue sign a card for the record
+func (i *Issuer) Issue(record *Record) (*HealthCard, error) {
+
+	if record == nil || record.Patient == nil || len(record.Doses) == 0 {
+		return nil, fmt.Errorf("error shc issue a patient and doses are required")
+	}
+
+	bundle, err := MakeBundle(record.Patient, record.Doses)
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore := record.NotBefore
+	if notBefore.IsZero() {
+		notBefore = time.Now()
+	}
+
+	payload := &Payload{
+		Issuer:    i.url,
+		NotBefore: notBefore.Unix(),
+		Credential: &Credential{
+			Type: append([]string{CredentialTypeHealthCard, CredentialTypeImmunization}, record.Types...),
+			CredentialSubject: &CredentialSubject{
+				FHIRVersion: FHIRVersion,
+				FHIRBundle:  bundle,
+			},
+		},
+	}
+	if !record.ExpiresAt.IsZero() {
+		payload.ExpiresAt = record.ExpiresAt.Unix()
+	}
+
+	payloadJSON, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error shc issue marshal payload err=%s", err)
+	}
+
+	var deflated bytes.Buffer
+	writer, err := flate.NewWriter(&deflated, flate.BestCompression)
+	if err != nil {
+		return nil, fmt.Errorf("error shc issue deflate err=%s", err)
+	}
+	if _, err := writer.Write(payloadJSON); err != nil {
+		return nil, fmt.Errorf("error shc issue deflate err=%s", err)
+	}
+	if err := writer.Close(); err != nil {
+		return nil, fmt.Errorf("error shc issue deflate err=%s", err)
+	}
+
+	compact, err := jws.SignES256(jws.Header{KeyID: i.keyID, Zip: zipDeflate}, deflated.Bytes(), i.key)
+	if err != nil {
+		return nil, fmt.Errorf("error shc issue err=%s", err)
+	}
+
+	return ParseJWS(compact)
+}
+
+//MakeBundle the minified FHIR bundle for the patient and doses, the patient is resource:0 and each dose an
+//Immunization referencing it
+func MakeBundle(patient *Patient, doses []*pdm.Dose) (*Bundle, error) {
+
+	bundle := &Bundle{
+		ResourceType: "Bundle",
+		Type:         bundleTypeCollection,
+		Entry: []*BundleEntry{{
+			FullURL: patientReference,
+			Resource: &Resource{
+				ResourceType: ResourceTypePatient,
+				Name:         []*HumanName{{Family: patient.FamilyName, Given: patient.GivenNames}},
+				BirthDate:    patient.BirthDate,
+			},
+		}},
+	}
+
+	for n, dose := range doses {
+
+		if dose == nil || len(dose.AllCodings()) == 0 {
+			return nil, fmt.Errorf("error shc make bundle dose=%d has no vaccine code", n)
+		}
+
+		if dose.OccurrenceDateTime == "" && dose.OccurrenceString == "" {
+			return nil, fmt.Errorf("error shc make bundle dose=%d has no occurrence", n)
+		}
+
+		bundle.Entry = append(bundle.Entry, &BundleEntry{
+			FullURL:  fmt.Sprintf("resource:%d", n+1),
+			Resource: immunization(dose),
+		})
+	}
+
+	return bundle, nil
+}
+
+//immunization the Immunization resource for the dose, the reverse of Resource Dose
+func immunization(dose *pdm.Dose) *Resource {
+
+	status := dose.Status
+	if status == "" {
+		status = pdm.CodeCompleted
+	}
+
+	resource := &Resource{
+		ResourceType:       ResourceTypeImmunization,
+		Status:             string(status),
+		VaccineCode:        &CodeableConcept{},
+		Patient:            &Reference{Reference: patientReference},
+		OccurrenceDateTime: dose.OccurrenceDateTime,
+		LotNumber:          dose.LotNumber,
+	}
+
+	//a string is only used when there is no dateTime
+	if dose.OccurrenceDateTime == "" {
+		resource.OccurrenceString = dose.OccurrenceString
+	}
+
+	for _, coding := range dose.AllCodings() {
+		resource.VaccineCode.Coding = append(resource.VaccineCode.Coding, &Coding{System: coding.System, Code: coding.Code})
+	}
+
+	if dose.Manufacturer != nil {
+		resource.Manufacturer = &Reference{Identifier: &Identifier{System: dose.Manufacturer.System, Value: dose.Manufacturer.Code}}
+	}
+
+	if dose.Site != "" {
+		resource.Performer = []*Performer{{Actor: &Actor{Display: dose.Site}}}
+	}
+
+	return resource
+}
+
+//File the contents of a .smart-health-card file holding the card
+func (hc *HealthCard) File() ([]byte, error) {
+	data, err := json.Marshal(&File{VerifiableCredential: []string{hc.JWS}})
+	if err != nil {
+		return nil, fmt.Errorf("error shc file err=%s", err)
+	}
+	return data, nil
+}
+
+//QRCodes the content of the card's QR codes, one unless the JWS is longer than MaxSingleQRSize when it is split
+//into equal chunks of at most MaxChunkQRSize as shc:/index/total/digits, see DecodeQR
+func (hc *HealthCard) QRCodes() []string {
+
+	if len(hc.JWS) <= MaxSingleQRSize {
+		return []string{EncodeQR(hc.JWS)}
+	}
+
+	total := (len(hc.JWS) + MaxChunkQRSize - 1) / MaxChunkQRSize
+	size := (len(hc.JWS) + total - 1) / total
+
+	codes := make([]string, 0, total)
+	for index := 0; index < total; index++ {
+		end := (index + 1) * size
+		if end > len(hc.JWS) {
+			end = len(hc.JWS)
+		}
+		digits := strings.TrimPrefix(EncodeQR(hc.JWS[index*size:end]), QRPrefix)
+		codes = append(codes, fmt.Sprintf("%s%d/%d/%s", QRPrefix, index+1, total, digits))
+	}
+
+	return codes
+}
@@ -0,0 +1,172 @@
+package shc_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/shc"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Issue(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	issuer, err := shc.NewIssuer(testIssuer, key)
+	require.NoError(t, err)
+
+	record := &shc.Record{
+		Patient: &shc.Patient{GivenNames: []string{"Jane", "Q."}, FamilyName: "Public", BirthDate: "1980-02-29"},
+		Doses: []*pdm.Dose{
+			makeTestDose("2021-03-09", "EL3246"),
+			makeTestDose("2021-04-06", "EL3247"),
+		},
+		Types:     []string{shc.CredentialTypeCOVID19},
+		NotBefore: time.Date(2021, 6, 1, 0, 0, 0, 0, time.UTC),
+	}
+
+	hc, err := issuer.Issue(record)
+	require.NoError(t, err)
+	require.Equal(t, issuer.KeyID(), hc.KeyID)
+	require.True(t, hc.HasType(shc.CredentialTypeCOVID19))
+
+	bundle := hc.Payload.Credential.CredentialSubject.FHIRBundle
+	require.Len(t, bundle.Entry, 3)
+	for i, entry := range bundle.Entry {
+		require.Equal(t, fmt.Sprintf("resource:%d", i), entry.FullURL)
+	}
+	require.Equal(t, "resource:0", bundle.Immunizations()[1].Patient.Reference)
+
+	file, err := hc.File()
+	require.NoError(t, err)
+	codes := hc.QRCodes()
+	require.Len(t, codes, 1)
+
+	for _, input := range []string{string(file), codes[0], hc.JWS} {
+
+		parsed, err := shc.Parse(input)
+		require.NoError(t, err)
+
+		card := parsed.Card()
+		require.Equal(t, "Jane Q.", card.PatientGivenName)
+		require.Equal(t, "Public", card.PatientFamilyName)
+		require.Equal(t, "1980-02-29", card.PatientBirthDate)
+		require.Equal(t, record.NotBefore, *card.TimeClaims.NotBefore)
+		require.Nil(t, card.TimeClaims.ExpiresAt)
+		require.Len(t, card.Doses, 2)
+		require.Equal(t, pdm.CodeCompleted, card.Doses[0].Status)
+		require.Equal(t, record.Doses[1].LotNumber, card.Doses[1].LotNumber)
+		require.Equal(t, record.Doses[1].Manufacturer, card.Doses[1].Manufacturer)
+		require.Equal(t, record.Doses[1].Site, card.Doses[1].Site)
+	}
+
+	t.Run("should verify with the published key set", func(t *testing.T) {
+		keySet, err := issuer.KeySet()
+		require.NoError(t, err)
+		keys, err := shc.ParseKeySet(keySet)
+		require.NoError(t, err)
+
+		verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+			Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+			SignatureVerifier: shc.NewKeyVerifier(map[string]map[string]*ecdsa.PublicKey{testIssuer: keys}),
+			IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+		})
+		require.NoError(t, err)
+
+		results, err := verifier.VerifyAt(context.Background(), hc.Card(), time.Date(2021, 7, 1, 0, 0, 0, 0, time.UTC))
+		require.NoError(t, err)
+		require.Equal(t, verification.CardVerificationStateValid, results.State)
+	})
+
+	t.Run("should chunk a long card", func(t *testing.T) {
+		long := *record
+		long.Doses = nil
+		for i := 0; i < 20; i++ {
+			dose := makeTestDose("2021-04-06", fmt.Sprintf("LOT%d", i))
+			site := make([]byte, 32)
+			_, err := rand.Read(site)
+			require.NoError(t, err)
+			dose.Site = hex.EncodeToString(site)
+			long.Doses = append(long.Doses, dose)
+		}
+
+		hc, err := issuer.Issue(&long)
+		require.NoError(t, err)
+		require.Greater(t, len(hc.JWS), shc.MaxSingleQRSize)
+
+		codes := hc.QRCodes()
+		require.Greater(t, len(codes), 1)
+		for i, code := range codes {
+			require.True(t, strings.HasPrefix(code, fmt.Sprintf("shc:/%d/%d/", i+1, len(codes))))
+			digits := code[strings.LastIndex(code, "/")+1:]
+			require.LessOrEqual(t, len(digits), 2*shc.MaxChunkQRSize)
+		}
+
+		decoded, err := shc.DecodeQR(codes...)
+		require.NoError(t, err)
+		require.Equal(t, hc.JWS, decoded)
+	})
+
+	t.Run("should not issue invalid records", func(t *testing.T) {
+		for _, invalid := range []*shc.Record{
+			nil,
+			{Doses: record.Doses},
+			{Patient: record.Patient},
+			{Patient: record.Patient, Doses: []*pdm.Dose{{OccurrenceDateTime: "2021-01-01"}}},
+			{Patient: record.Patient, Doses: []*pdm.Dose{{Coding: record.Doses[0].Coding}}},
+		} {
+			_, err := issuer.Issue(invalid)
+			require.Error(t, err)
+		}
+	})
+}
+
+func Test_NewIssuer(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sec1, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+	pkcs8, err := x509.MarshalPKCS8PrivateKey(key)
+	require.NoError(t, err)
+
+	for _, block := range []*pem.Block{{Type: "EC PRIVATE KEY", Bytes: sec1}, {Type: "PRIVATE KEY", Bytes: pkcs8}} {
+		parsed, err := shc.ParsePrivateKey(pem.EncodeToMemory(block))
+		require.NoError(t, err, block.Type)
+		require.True(t, key.Equal(parsed))
+	}
+
+	_, err = shc.ParsePrivateKey([]byte("not a key"))
+	require.Error(t, err)
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+	_, err = shc.NewIssuer(testIssuer, p384)
+	require.Error(t, err)
+
+	_, err = shc.NewIssuer(testIssuer+"/", key)
+	require.Error(t, err)
+}
+
+func makeTestDose(occurrence string, lot string) *pdm.Dose {
+	return &pdm.Dose{
+		Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"},
+		OccurrenceDateTime: occurrence,
+		LotNumber:          lot,
+		Manufacturer:       &vaccinemd.Coding{System: "http://hl7.org/fhir/sid/mvx", Code: "MOD"},
+		Site:               "ABC General Hospital",
+	}
+}
@@ -0,0 +1,89 @@
+package shc
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/jws"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+//ParseKeySet the signing keys in an issuer's JWK set, as published at iss + /.well-known/jwks.json, keyed by kid.
+//Keys that are not P-256 EC keys are ignored as cards can only be signed with ES256
+func ParseKeySet(data []byte) (map[string]*ecdsa.PublicKey, error) {
+
+	var set jws.JWKSet
+	if err := json.Unmarshal(data, &set); err != nil {
+		return nil, fmt.Errorf("error shc parse key set err=%s", err)
+	}
+
+	keys := make(map[string]*ecdsa.PublicKey)
+	for _, jwk := range set.Keys {
+
+		if jwk.KeyType != "EC" || jwk.Curve != "P-256" {
+			continue
+		}
+
+		public, err := jwk.PublicKey()
+		if err != nil {
+			return nil, fmt.Errorf("error shc parse key set kid=%s err=%s", jwk.KeyID, err)
+		}
+
+		kid := jwk.KeyID
+		if kid == "" {
+			if kid, err = jws.Thumbprint(public.(*ecdsa.PublicKey)); err != nil {
+				return nil, fmt.Errorf("error shc parse key set err=%s", err)
+			}
+		}
+		keys[kid] = public.(*ecdsa.PublicKey)
+	}
+
+	return keys, nil
+}
+
+//NewKeyVerifier a signature verifier for cards whose Raw is a JWS, issuerKeys are the keys of each issuer keyed
+//by iss then kid, see ParseKeySet. A card from an issuer or with a kid that is not known is reported as the key
+//not being fetched
+func NewKeyVerifier(issuerKeys map[string]map[string]*ecdsa.PublicKey) verification.SignatureVerifier {
+
+	copied := make(map[string]map[string]*ecdsa.PublicKey, len(issuerKeys))
+	for issuer, keys := range issuerKeys {
+		copied[issuer] = make(map[string]*ecdsa.PublicKey, len(keys))
+		for kid, key := range keys {
+			copied[issuer][kid] = key
+		}
+	}
+
+	return &keyVerifier{issuerKeys: copied}
+}
+
+//keyVerifier never modified once made so safe for concurrent use
+type keyVerifier struct {
+	issuerKeys map[string]map[string]*ecdsa.PublicKey
+}
+
+func (kv *keyVerifier) VerifySignature(_ context.Context, card *verification.Card) (*verification.SignatureResult, error) {
+
+	hc, err := ParseJWS(string(card.Raw))
+	if err != nil {
+		return nil, err
+	}
+
+	result := &verification.SignatureResult{Checked: true}
+
+	//the card's iss is signed so use it rather than the card's Issuer which the caller could have changed
+	fetchStarted := time.Now()
+	key, ok := kv.issuerKeys[hc.Payload.Issuer][hc.KeyID]
+	result.KeyFetchDuration = time.Since(fetchStarted)
+	if !ok {
+		return result, nil
+	}
+	result.FetchedKey = true
+
+	result.Valid = hc.Verify(key) == nil
+
+	return result, nil
+}
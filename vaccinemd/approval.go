@@ -0,0 +1,223 @@
+package vaccinemd
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+//
+// A vaccine is trusted in a region if it is approved by the region's jurisdictions. A Region is either a named
+// region, for example RegionUSA trusts FDA approvals, or a jurisdiction expression, "EMA|WHO-EUL" trusts vaccines
+// approved by any of the jurisdictions and "FDA&EMA" only those approved by all of them, see RegionAnyOf and
+// RegionAllOf. Travel destinations often accept WHO EUL vaccines or keep their own national list.
+//
+
+//Jurisdiction a regulator or list that approves vaccines
+type Jurisdiction string
+
+const (
+	//JurisdictionFDA US Food and Drug Administration, emergency use or full approval
+	JurisdictionFDA Jurisdiction = "FDA"
+
+	//JurisdictionEMA European Medicines Agency, conditional or full marketing authorisation
+	JurisdictionEMA Jurisdiction = "EMA"
+
+	//JurisdictionWHOEUL World Health Organization Emergency Use Listing
+	JurisdictionWHOEUL Jurisdiction = "WHO-EUL"
+
+	//JurisdictionHealthCanada Health Canada
+	JurisdictionHealthCanada Jurisdiction = "HEALTH-CANADA"
+
+	//JurisdictionTGA Australian Therapeutic Goods Administration, approved or recognised for travel
+	JurisdictionTGA Jurisdiction = "TGA"
+
+	//nationalPrefix prefix of a national list jurisdiction, followed by the ISO 3166-1 alpha-2 country code
+	nationalPrefix = "NATIONAL:"
+)
+
+//NationalJurisdiction the national list of the country, the ISO 3166-1 alpha-2 code, for example HU
+func NationalJurisdiction(country string) Jurisdiction {
+	return Jurisdiction(nationalPrefix + strings.ToUpper(strings.TrimSpace(country)))
+}
+
+//Valid true if a known jurisdiction or a national list
+func (j Jurisdiction) Valid() bool {
+
+	switch j {
+	case JurisdictionFDA, JurisdictionEMA, JurisdictionWHOEUL, JurisdictionHealthCanada, JurisdictionTGA:
+		return true
+	}
+
+	country := strings.TrimPrefix(string(j), nationalPrefix)
+	return country != string(j) && len(country) == 2
+}
+
+//ApprovalStatus the status of an approval
+type ApprovalStatus string
+
+const (
+	//ApprovalStatusApproved full approval or marketing authorisation
+	ApprovalStatusApproved ApprovalStatus = "approved"
+
+	//ApprovalStatusEmergencyUse emergency use authorization, listing or conditional authorisation
+	ApprovalStatusEmergencyUse ApprovalStatus = "emergency-use"
+
+	//ApprovalStatusRecognized not approved for use but recognised as proof of vaccination, for example for travel
+	ApprovalStatusRecognized ApprovalStatus = "recognized"
+)
+
+//Approval a vaccine's approval by a jurisdiction
+type Approval struct {
+
+	//Jurisdiction who approved the vaccine
+	Jurisdiction Jurisdiction `json:"jurisdiction"`
+
+	//Status the approval status
+	Status ApprovalStatus `json:"status"`
+
+	//ApprovedDate FHIR date the approval started
+	ApprovedDate string `json:"approved_date"`
+
+	//RevokedDate FHIR date the approval was revoked or expired, empty if still approved
+	RevokedDate string `json:"revoked_date,omitempty"`
+}
+
+//ActiveAt true if the approval applies at the time, from the start of the approved date until the start of
+//the revoked date
+func (a *Approval) ActiveAt(at time.Time) bool {
+
+	approved, ok := parseFHIRDate(a.ApprovedDate)
+	if !ok || at.Before(approved) {
+		return false
+	}
+
+	if a.RevokedDate != "" {
+		revoked, ok := parseFHIRDate(a.RevokedDate)
+		if !ok || !at.Before(revoked) {
+			return false
+		}
+	}
+
+	return true
+}
+
+//ApprovedBy true if the vaccine is approved by the jurisdiction at the time. Metadata without approvals,
+//written before they were added, is FDA approved if its CVXStatus is active
+func (vmd *VaccineMetadata) ApprovedBy(jurisdiction Jurisdiction, at time.Time) bool {
+
+	if len(vmd.Approvals) == 0 {
+		return jurisdiction == JurisdictionFDA && vmd.CVXStatus == CVSStatusActive
+	}
+
+	for _, approval := range vmd.Approvals {
+		if approval.Jurisdiction == jurisdiction && approval.ActiveAt(at) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//Trust the jurisdictions a region trusts approvals from
+type Trust struct {
+
+	//Jurisdictions the jurisdictions
+	Jurisdictions []Jurisdiction
+
+	//All if true a vaccine must be approved by all the jurisdictions, otherwise by any of them
+	All bool
+}
+
+//Trusts true if the vaccine is approved as required at the time
+func (t *Trust) Trusts(vmd *VaccineMetadata, at time.Time) bool {
+
+	if len(t.Jurisdictions) == 0 {
+		return false
+	}
+
+	for _, jurisdiction := range t.Jurisdictions {
+		approved := vmd.ApprovedBy(jurisdiction, at)
+		if approved && !t.All {
+			return true
+		}
+		if !approved && t.All {
+			return false
+		}
+	}
+
+	return t.All
+}
+
+//regionTrust the jurisdictions each named region trusts
+var regionTrust = map[Region]Jurisdiction{
+	RegionUSA:       JurisdictionFDA,
+	RegionEU:        JurisdictionEMA,
+	RegionCanada:    JurisdictionHealthCanada,
+	RegionAustralia: JurisdictionTGA,
+}
+
+const (
+	anyOfSeparator = "|"
+	allOfSeparator = "&"
+)
+
+//RegionAnyOf a region that trusts vaccines approved by any of the jurisdictions
+func RegionAnyOf(jurisdictions ...Jurisdiction) Region {
+	return Region(joinJurisdictions(jurisdictions, anyOfSeparator))
+}
+
+//RegionAllOf a region that trusts vaccines approved by all of the jurisdictions
+func RegionAllOf(jurisdictions ...Jurisdiction) Region {
+	return Region(joinJurisdictions(jurisdictions, allOfSeparator))
+}
+
+func joinJurisdictions(jurisdictions []Jurisdiction, separator string) string {
+	parts := make([]string, 0, len(jurisdictions))
+	for _, jurisdiction := range jurisdictions {
+		parts = append(parts, string(jurisdiction))
+	}
+	return strings.Join(parts, separator)
+}
+
+//Trust the jurisdictions the region trusts, an error if the region is not a named region or a valid
+//jurisdiction expression
+func (r Region) Trust() (*Trust, error) {
+
+	if jurisdiction, ok := regionTrust[r]; ok {
+		return &Trust{Jurisdictions: []Jurisdiction{jurisdiction}}, nil
+	}
+
+	expression := string(r)
+	if strings.Contains(expression, anyOfSeparator) && strings.Contains(expression, allOfSeparator) {
+		return nil, fmt.Errorf("error region cannot mix any of and all of got=%s", r)
+	}
+
+	trust := &Trust{All: strings.Contains(expression, allOfSeparator)}
+	separator := anyOfSeparator
+	if trust.All {
+		separator = allOfSeparator
+	}
+
+	for _, part := range strings.Split(expression, separator) {
+		jurisdiction := Jurisdiction(strings.ToUpper(strings.TrimSpace(part)))
+		if !jurisdiction.Valid() {
+			return nil, fmt.Errorf("error region unknown region or jurisdiction got=%s", r)
+		}
+		trust.Jurisdictions = append(trust.Jurisdictions, jurisdiction)
+	}
+
+	return trust, nil
+}
+
+//parseFHIRDate the start of a FHIR date, year, year-month or date
+func parseFHIRDate(value string) (time.Time, bool) {
+	for _, layout := range []string{"2006", "2006-01", "2006-01-02"} {
+		if len(value) == len(layout) {
+			if t, err := time.Parse(layout, value); err == nil {
+				return t, true
+			}
+		}
+	}
+	return time.Time{}, false
+}
@@ -0,0 +1,122 @@
+package vaccinemd_test
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_RegionTrust(t *testing.T) {
+
+	type testCase struct {
+		name          string
+		region        vaccinemd.Region
+		expectedError bool
+		expectedNames []string
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should trust fda for usa",
+			region:        vaccinemd.RegionUSA,
+			expectedNames: []string{"Moderna", "Pfizer", "Johnson & Johnson Janssen"},
+		},
+		{
+			name:          "should trust ema for eu",
+			region:        vaccinemd.RegionEU,
+			expectedNames: []string{"Moderna", "Pfizer", "AstraZeneca", "Johnson & Johnson Janssen"},
+		},
+		{
+			name:          "should trust tga recognized vaccines for australia",
+			region:        vaccinemd.RegionAustralia,
+			expectedNames: []string{"Moderna", "Pfizer", "AstraZeneca", "Johnson & Johnson Janssen", "Covaxin", "Sinovac"},
+		},
+		{
+			name:          "should trust a single jurisdiction",
+			region:        vaccinemd.Region(vaccinemd.NationalJurisdiction("hu")),
+			expectedNames: []string{"Sputnik V"},
+		},
+		{
+			name:          "should trust any of",
+			region:        vaccinemd.RegionAnyOf(vaccinemd.JurisdictionWHOEUL, vaccinemd.NationalJurisdiction("RU")),
+			expectedNames: []string{"Moderna", "Pfizer", "AstraZeneca", "Johnson & Johnson Janssen", "Covaxin", "Sputnik V", "Sinovac"},
+		},
+		{
+			name:          "should trust all of",
+			region:        vaccinemd.RegionAllOf(vaccinemd.JurisdictionFDA, vaccinemd.JurisdictionEMA),
+			expectedNames: []string{"Moderna", "Pfizer", "Johnson & Johnson Janssen"},
+		},
+		{
+			name:          "should reject unknown jurisdiction",
+			region:        vaccinemd.Region("FDA|bogus"),
+			expectedError: true,
+		},
+		{
+			name:          "should reject mixed expression",
+			region:        vaccinemd.Region("FDA|EMA&TGA"),
+			expectedError: true,
+		},
+	}
+
+	repo := vaccinemd.MakeRepo()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			_, err := tc.region.Trust()
+			if tc.expectedError {
+				require.Error(t, err)
+				require.Equal(t, 0, len(repo.FindTrustedVaccinesForRegion(tc.region)))
+				return
+			}
+			require.NoError(t, err)
+
+			names := make([]string, 0)
+			for _, vmd := range repo.FindTrustedVaccinesForRegion(tc.region) {
+				names = append(names, vmd.DisplayName)
+			}
+			require.Equal(t, tc.expectedNames, names)
+		})
+	}
+}
+
+func Test_ApprovalDates(t *testing.T) {
+
+	repo := vaccinemd.MakeRepo()
+	whoEUL := vaccinemd.Region(vaccinemd.JurisdictionWHOEUL)
+
+	covaxin := repo.FindVaccine(vaccinemd.CVXSystem, "502")
+	require.NotNil(t, covaxin)
+
+	before := time.Date(2021, 11, 2, 23, 0, 0, 0, time.UTC)
+	require.False(t, covaxin.ApprovedBy(vaccinemd.JurisdictionWHOEUL, before))
+	require.True(t, covaxin.ApprovedBy(vaccinemd.JurisdictionWHOEUL, before.Add(time.Hour)))
+
+	require.Equal(t, 5, len(repo.AsOf(before).FindTrustedVaccinesForRegion(whoEUL)))
+	require.Equal(t, 6, len(repo.AsOf(before.Add(time.Hour)).FindTrustedVaccinesForRegion(whoEUL)))
+
+	t.Run("should not be approved once revoked", func(t *testing.T) {
+		approval := &vaccinemd.Approval{
+			Jurisdiction: vaccinemd.JurisdictionFDA,
+			Status:       vaccinemd.ApprovalStatusEmergencyUse,
+			ApprovedDate: "2021-02-27",
+			RevokedDate:  "2023-06-01",
+		}
+		require.True(t, approval.ActiveAt(time.Date(2023, 5, 31, 0, 0, 0, 0, time.UTC)))
+		require.False(t, approval.ActiveAt(time.Date(2023, 6, 1, 0, 0, 0, 0, time.UTC)))
+	})
+
+	t.Run("should reject invalid approvals", func(t *testing.T) {
+		for _, approval := range []*vaccinemd.Approval{
+			{Jurisdiction: "bogus", Status: vaccinemd.ApprovalStatusApproved, ApprovedDate: "2021"},
+			{Jurisdiction: vaccinemd.JurisdictionFDA, Status: "bogus", ApprovedDate: "2021"},
+			{Jurisdiction: vaccinemd.JurisdictionFDA, Status: vaccinemd.ApprovalStatusApproved, ApprovedDate: "bogus"},
+			{Jurisdiction: vaccinemd.JurisdictionFDA, Status: vaccinemd.ApprovalStatusApproved, ApprovedDate: "2021", RevokedDate: "2020"},
+		} {
+			vaccineMD := copyMetadata(t, repo.CovidVaccines())
+			vaccineMD[0].Approvals = []*vaccinemd.Approval{approval}
+			require.Error(t, vaccinemd.ValidateMetadata(vaccineMD))
+		}
+	})
+}
@@ -0,0 +1,186 @@
+package vaccinemd
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+//
+// Vaccines are coded in many systems, a product can be known by a CVX, NDC, EU product or GTIN code and
+// by class codes such as the SNOMED CT "SARS-CoV-2 mRNA vaccine" or the ATC J07BX03 that cover several
+// products. VaccineMetadata Codes hold the product codes, which must be unique to one vaccine, and
+// GenericCodes the class codes, which can be shared. Each system can also be written with several URIs,
+// see NormalizeSystem.
+//
+
+const (
+	//NDCSystem US National Drug Code
+	NDCSystem string = "http://hl7.org/fhir/sid/ndc"
+
+	//ATCSystem WHO Anatomical Therapeutic Chemical classification
+	ATCSystem string = "http://www.whocc.no/atc"
+
+	//ICD11System ICD-11 for Mortality and Morbidity Statistics
+	ICD11System string = "http://id.who.int/icd11/mms"
+
+	//GTINSystem GS1 Global Trade Item Number
+	GTINSystem string = "https://www.gs1.org/gtin"
+
+	//EUProductSystem EU Union Register of medicinal products, as used by the EU DCC mp field
+	EUProductSystem string = "https://ec.europa.eu/health/documents/community-register/html/"
+)
+
+//systemAliases the URIs, mostly OIDs, that identify a system, keys are lower case without a trailing slash
+var systemAliases = map[string]string{
+	"http://hl7.org/fhir/sid/cvx":                                   CVXSystem,
+	"urn:oid:2.16.840.1.113883.12.292":                              CVXSystem,
+	"http://snomed.info/sct":                                        SNOMEDSystem,
+	"urn:oid:2.16.840.1.113883.6.96":                                SNOMEDSystem,
+	"http://hl7.org/fhir/sid/ndc":                                   NDCSystem,
+	"urn:oid:2.16.840.1.113883.6.69":                                NDCSystem,
+	"http://www.whocc.no/atc":                                       ATCSystem,
+	"urn:oid:2.16.840.1.113883.6.73":                                ATCSystem,
+	"http://hl7.org/fhir/sid/icd-10":                                ICD10System,
+	"urn:oid:2.16.840.1.113883.6.3":                                 ICD10System,
+	"http://id.who.int/icd11/mms":                                   ICD11System,
+	"https://www.gs1.org/gtin":                                      GTINSystem,
+	"urn:oid:1.3.160":                                               GTINSystem,
+	"https://ec.europa.eu/health/documents/community-register/html": EUProductSystem,
+	"http://hl7.org/fhir/sid/mvx":                                   MVXSystem,
+	"urn:oid:2.16.840.1.113883.12.227":                              MVXSystem,
+	"https://spor.ema.europa.eu/v1/organisations":                   EUOrgSystem,
+}
+
+//NormalizeSystem returns the canonical URI for a code system, for example urn:oid:2.16.840.1.113883.12.292
+//becomes http://hl7.org/fhir/sid/cvx. Unknown systems are returned trimmed
+func NormalizeSystem(system string) string {
+
+	system = strings.TrimSpace(system)
+
+	key := strings.TrimSuffix(strings.ToLower(system), "/")
+	if canonical, ok := systemAliases[key]; ok {
+		return canonical
+	}
+
+	return system
+}
+
+//NormalizeCode returns the canonical form of a code in a system, for example CVX 3 becomes 03 and
+//NDC 59267-1000-1 becomes 5926710001
+func NormalizeCode(system string, code string) string {
+
+	code = strings.TrimSpace(code)
+
+	switch NormalizeSystem(system) {
+	case CVXSystem:
+		if n, err := strconv.Atoi(code); err == nil {
+			return fmt.Sprintf("%02d", n)
+		}
+	case NDCSystem:
+		return strings.ReplaceAll(code, "-", "")
+	case GTINSystem:
+		if _, err := strconv.ParseUint(code, 10, 64); err == nil && len(code) < 14 {
+			return strings.Repeat("0", 14-len(code)) + code
+		}
+	case ATCSystem, ICD10System, ICD11System, MVXSystem, EUOrgSystem:
+		return strings.ToUpper(code)
+	}
+
+	return code
+}
+
+//codingKey the key a coding is indexed by
+func codingKey(system string, code string) string {
+	normalized := NormalizeSystem(system)
+	return normalized + "#" + NormalizeCode(normalized, code)
+}
+
+//Resolution the vaccine that a set of codings for one dose refer to
+type Resolution struct {
+
+	//Vaccine the vaccine the codings resolve to, nil if unknown or ambiguous
+	Vaccine *VaccineMetadata
+
+	//Ambiguous the codings could be more than one vaccine, see Candidates
+	Ambiguous bool
+
+	//Candidates the vaccines the codings could be, in ID order, set when ambiguous
+	Candidates []*VaccineMetadata
+
+	//GenericOnly the vaccine was only found through class codes, for example there is only one known
+	//mRNA vaccine, rather than a product code
+	GenericOnly bool
+}
+
+//resolve product codes are used first as they are the most specific, if they all agree that is the vaccine.
+//If there are none then the vaccines that match every recognised class code are the candidates
+func (vmi *v1Repo) resolve(codings []Coding) *Resolution {
+
+	products := make(map[string]*VaccineMetadata)
+	for _, coding := range codings {
+		if vmd := vmi.effective(vmi.code2CodingMap[codingKey(coding.System, coding.Code)]); vmd != nil {
+			products[vmd.ID] = vmd
+		}
+	}
+
+	if len(products) > 0 {
+		return makeResolution(products, false)
+	}
+
+	var candidates map[string]*VaccineMetadata
+	for _, coding := range codings {
+
+		matches := make(map[string]*VaccineMetadata)
+		at := vmi.at()
+		for _, vmd := range vmi.generic2CodingMap[codingKey(coding.System, coding.Code)] {
+			if vmd.EffectiveAt(at) {
+				matches[vmd.ID] = vmd
+			}
+		}
+
+		if len(matches) == 0 {
+			//class code not known so tells us nothing
+			continue
+		}
+
+		if candidates == nil {
+			candidates = matches
+			continue
+		}
+
+		for id := range candidates {
+			if _, ok := matches[id]; !ok {
+				delete(candidates, id)
+			}
+		}
+	}
+
+	return makeResolution(candidates, true)
+}
+
+func makeResolution(matches map[string]*VaccineMetadata, generic bool) *Resolution {
+
+	result := &Resolution{}
+
+	if len(matches) == 1 {
+		for _, vmd := range matches {
+			result.Vaccine = vmd
+		}
+		result.GenericOnly = generic
+		return result
+	}
+
+	if len(matches) > 1 {
+		result.Ambiguous = true
+		for _, vmd := range matches {
+			result.Candidates = append(result.Candidates, vmd)
+		}
+		sort.Slice(result.Candidates, func(i, j int) bool {
+			return result.Candidates[i].ID < result.Candidates[j].ID
+		})
+	}
+
+	return result
+}
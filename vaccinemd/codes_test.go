@@ -0,0 +1,112 @@
+package vaccinemd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_NormalizeCoding(t *testing.T) {
+
+	require.Equal(t, vaccinemd.CVXSystem, vaccinemd.NormalizeSystem("urn:oid:2.16.840.1.113883.12.292"))
+	require.Equal(t, vaccinemd.CVXSystem, vaccinemd.NormalizeSystem(" http://hl7.org/fhir/sid/cvx/ "))
+	require.Equal(t, vaccinemd.SNOMEDSystem, vaccinemd.NormalizeSystem("URN:OID:2.16.840.1.113883.6.96"))
+	require.Equal(t, vaccinemd.EUProductSystem, vaccinemd.NormalizeSystem("https://ec.europa.eu/health/documents/community-register/html"))
+	require.Equal(t, "bogus", vaccinemd.NormalizeSystem("bogus"))
+
+	require.Equal(t, "03", vaccinemd.NormalizeCode(vaccinemd.CVXSystem, "3"))
+	require.Equal(t, "5926710001", vaccinemd.NormalizeCode(vaccinemd.NDCSystem, "59267-1000-1"))
+	require.Equal(t, "00012345678905", vaccinemd.NormalizeCode(vaccinemd.GTINSystem, "12345678905"))
+	require.Equal(t, "J07BX03", vaccinemd.NormalizeCode(vaccinemd.ATCSystem, "j07bx03"))
+}
+
+func Test_ResolveVaccine(t *testing.T) {
+
+	type testCase struct {
+		name               string
+		codings            []vaccinemd.Coding
+		expectedID         string
+		expectedAmbiguous  bool
+		expectedCandidates int
+		expectedGeneric    bool
+	}
+
+	pfizerID := vaccinemd.CVXSystem + "#208"
+
+	testCases := []testCase{
+		{
+			name:       "should resolve cvx oid",
+			codings:    []vaccinemd.Coding{{System: "urn:oid:2.16.840.1.113883.12.292", Code: "208"}},
+			expectedID: pfizerID,
+		},
+		{
+			name:       "should resolve ndc",
+			codings:    []vaccinemd.Coding{{System: vaccinemd.NDCSystem, Code: "59267-1000-1"}},
+			expectedID: pfizerID,
+		},
+		{
+			name: "should prefer product code to class code",
+			codings: []vaccinemd.Coding{
+				{System: vaccinemd.SNOMEDSystem, Code: "1119349007"},
+				{System: vaccinemd.EUProductSystem, Code: "EU/1/20/1528"},
+			},
+			expectedID: pfizerID,
+		},
+		{
+			name: "should be ambiguous for conflicting product codes",
+			codings: []vaccinemd.Coding{
+				{System: vaccinemd.CVXSystem, Code: "208"},
+				{System: vaccinemd.CVXSystem, Code: "207"},
+			},
+			expectedAmbiguous:  true,
+			expectedCandidates: 2,
+		},
+		{
+			name:               "should be ambiguous for mrna class code",
+			codings:            []vaccinemd.Coding{{System: vaccinemd.SNOMEDSystem, Code: "1119349007"}},
+			expectedAmbiguous:  true,
+			expectedCandidates: 2,
+		},
+		{
+			name: "should narrow class codes",
+			codings: []vaccinemd.Coding{
+				{System: vaccinemd.ATCSystem, Code: "J07BX03"},
+				{System: vaccinemd.SNOMEDSystem, Code: "29061000087103"},
+			},
+			expectedAmbiguous:  true,
+			expectedCandidates: 3,
+		},
+		{
+			name:    "should not resolve unknown",
+			codings: []vaccinemd.Coding{{System: vaccinemd.CVXSystem, Code: "999"}},
+		},
+	}
+
+	repo := vaccinemd.MakeRepo()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			resolution := repo.ResolveVaccine(tc.codings)
+			if tc.expectedID != "" {
+				require.NotNil(t, resolution.Vaccine)
+				require.Equal(t, tc.expectedID, resolution.Vaccine.ID)
+			} else {
+				require.Nil(t, resolution.Vaccine)
+			}
+			require.Equal(t, tc.expectedAmbiguous, resolution.Ambiguous)
+			require.Equal(t, tc.expectedCandidates, len(resolution.Candidates))
+			require.Equal(t, tc.expectedGeneric, resolution.GenericOnly)
+		})
+	}
+
+	t.Run("should resolve class code with a single known vaccine", func(t *testing.T) {
+		vaccineMD := []*vaccinemd.VaccineMetadata{repo.FindVaccine(vaccinemd.CVXSystem, "208")}
+		pfizerOnly, err := vaccinemd.MakeRepoFromMetadata(vaccineMD)
+		require.NoError(t, err)
+
+		resolution := pfizerOnly.ResolveVaccine([]vaccinemd.Coding{{System: vaccinemd.SNOMEDSystem, Code: "1119349007"}})
+		require.NotNil(t, resolution.Vaccine)
+		require.True(t, resolution.GenericOnly)
+	})
+}
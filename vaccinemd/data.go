@@ -1,9 +1,9 @@
 package vaccinemd
 
-// createCovidVaccineMetadata
-func createCovidVaccineMetadata() []*CovidVaccineMetadata {
+// createVaccineMetadata the built in vaccine metadata, covid vaccines followed by other vaccine-preventable diseases
+func createVaccineMetadata() []*VaccineMetadata {
 
-	result := []*CovidVaccineMetadata{
+	result := []*VaccineMetadata{
 		{
 			ID: CVXSystem + "#" + "207",
 			Codes: []Coding{
@@ -11,15 +11,41 @@ func createCovidVaccineMetadata() []*CovidVaccineMetadata {
 					System: CVXSystem,
 					Code:   "207",
 				},
+				{
+					System: NDCSystem,
+					Code:   "80777-0273-10",
+				},
+				{
+					System: NDCSystem,
+					Code:   "80777-0273-99",
+				},
+				{
+					System: EUProductSystem,
+					Code:   "EU/1/20/1507",
+				},
+			},
+			GenericCodes:   covidMRNAGenericCodes(),
+			TargetDiseases: covid19TargetDiseases(),
+			CVXStatus:      CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2020-12-18"},
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "2022-01-31"},
+				{Jurisdiction: JurisdictionEMA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-01-06"},
+				{Jurisdiction: JurisdictionWHOEUL, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-04-30"},
+				{Jurisdiction: JurisdictionHealthCanada, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2020-12-23"},
+				{Jurisdiction: JurisdictionTGA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-08-09"},
 			},
-			CVXStatus:                    CVSStatusActive,
 			Doses:                        2,
 			DaysSinceLastDoseCriteria:    14,
 			DaysBetweenDoesCriteriaBegin: 24,
 			DaysBetweenDoesCriteriaEnd:   92,
+			Schedules:                    []*Schedule{immunocompromisedMRNASchedule()},
 			DisplayName:                  "Moderna",
 			SaleProprietaryName:          "Moderna COVID-19 Vaccine",
+			Aliases:                      []string{"Spikevax", "mRNA-1273"},
 			ManufacturerName:             "Moderna US, Inc",
+			ManufacturerID:               "moderna",
+			AuthorizedDate:               "2020-12-18",
 		},
 		{
 			ID: CVXSystem + "#" + "208",
@@ -28,15 +54,52 @@ func createCovidVaccineMetadata() []*CovidVaccineMetadata {
 					System: CVXSystem,
 					Code:   "208",
 				},
+				{
+					System: NDCSystem,
+					Code:   "59267-1000-1",
+				},
+				{
+					System: NDCSystem,
+					Code:   "59267-1000-2",
+				},
+				{
+					System: EUProductSystem,
+					Code:   "EU/1/20/1528",
+				},
+			},
+			GenericCodes:   covidMRNAGenericCodes(),
+			TargetDiseases: covid19TargetDiseases(),
+			CVXStatus:      CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2020-12-11"},
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "2021-08-23"},
+				{Jurisdiction: JurisdictionEMA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2020-12-21"},
+				{Jurisdiction: JurisdictionWHOEUL, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2020-12-31"},
+				{Jurisdiction: JurisdictionHealthCanada, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2020-12-09"},
+				{Jurisdiction: JurisdictionTGA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-01-25"},
 			},
-			CVXStatus:                    CVSStatusActive,
 			Doses:                        2,
 			DaysSinceLastDoseCriteria:    14,
 			DaysBetweenDoesCriteriaBegin: 17,
 			DaysBetweenDoesCriteriaEnd:   92,
-			DisplayName:                  "Pfizer",
-			SaleProprietaryName:          "Pfizer-BioNTech COVID-19 Vaccine",
-			ManufacturerName:             "Pfizer-BioNTech",
+			Schedules: []*Schedule{
+				{
+					Name:                         "pediatric-6m-4y",
+					MinAgeMonths:                 6,
+					MaxAgeMonths:                 60,
+					Doses:                        3,
+					DaysSinceLastDoseCriteria:    14,
+					DaysBetweenDoesCriteriaBegin: 17,
+					DaysBetweenDoesCriteriaEnd:   92,
+				},
+				immunocompromisedMRNASchedule(),
+			},
+			DisplayName:         "Pfizer",
+			SaleProprietaryName: "Pfizer-BioNTech COVID-19 Vaccine",
+			Aliases:             []string{"Comirnaty", "BioNTech", "BNT162b2"},
+			ManufacturerName:    "Pfizer-BioNTech",
+			ManufacturerID:      "pfizer-biontech",
+			AuthorizedDate:      "2020-12-11",
 		},
 		{
 			ID: CVXSystem + "#" + "210",
@@ -45,13 +108,28 @@ func createCovidVaccineMetadata() []*CovidVaccineMetadata {
 					System: CVXSystem,
 					Code:   "210",
 				},
+				{
+					System: EUProductSystem,
+					Code:   "EU/1/21/1529",
+				},
+			},
+			GenericCodes:   covidViralVectorGenericCodes(),
+			TargetDiseases: covid19TargetDiseases(),
+			CVXStatus:      CVSStatusNonUS,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionEMA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-01-29"},
+				{Jurisdiction: JurisdictionWHOEUL, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-02-15"},
+				{Jurisdiction: JurisdictionHealthCanada, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-02-26"},
+				{Jurisdiction: JurisdictionTGA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-02-16"},
 			},
-			CVXStatus:                 CVSStatusNonUS,
 			Doses:                     2,
 			DaysSinceLastDoseCriteria: 14,
 			DisplayName:               "AstraZeneca",
 			SaleProprietaryName:       "AstraZeneca COVID-19 Vaccine",
+			Aliases:                   []string{"AZ", "Vaxzevria", "Covishield", "Oxford"},
 			ManufacturerName:          "AstraZeneca Pharmaceuticals LP",
+			ManufacturerID:            "astrazeneca",
+			AuthorizedDate:            "2020-12-30",
 		},
 		{
 			ID: CVXSystem + "#" + "212",
@@ -60,15 +138,340 @@ func createCovidVaccineMetadata() []*CovidVaccineMetadata {
 					System: CVXSystem,
 					Code:   "212",
 				},
+				{
+					System: NDCSystem,
+					Code:   "59676-0580-05",
+				},
+				{
+					System: NDCSystem,
+					Code:   "59676-0580-15",
+				},
+				{
+					System: EUProductSystem,
+					Code:   "EU/1/20/1525",
+				},
+			},
+			GenericCodes:   covidViralVectorGenericCodes(),
+			TargetDiseases: covid19TargetDiseases(),
+			CVXStatus:      CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-02-27"},
+				{Jurisdiction: JurisdictionEMA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-03-11"},
+				{Jurisdiction: JurisdictionWHOEUL, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-03-12"},
+				{Jurisdiction: JurisdictionHealthCanada, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-03-05"},
+				{Jurisdiction: JurisdictionTGA, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-06-25"},
 			},
-			CVXStatus:                 CVSStatusActive,
 			Doses:                     1,
 			DaysSinceLastDoseCriteria: 14,
 			DisplayName:               "Johnson & Johnson Janssen",
 			SaleProprietaryName:       "Janssen COVID-19 Vaccine",
+			Aliases:                   []string{"J&J", "JNJ", "JJ", "Johnson & Johnson", "Janssen", "Ad26.COV2.S"},
 			ManufacturerName:          "Janssen Products, LP",
+			ManufacturerID:            "janssen",
+			AuthorizedDate:            "2021-02-27",
+		},
+		{
+			ID: CVXSystem + "#" + "511",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "511",
+				},
+				{
+					System: EUProductSystem,
+					Code:   "CoronaVac",
+				},
+			},
+			GenericCodes:   covidInactivatedGenericCodes(),
+			TargetDiseases: covid19TargetDiseases(),
+			CVXStatus:      CVSStatusNonUS,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionWHOEUL, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-06-01"},
+				{Jurisdiction: JurisdictionTGA, Status: ApprovalStatusRecognized, ApprovedDate: "2021-10-01"},
+				{Jurisdiction: NationalJurisdiction("CN"), Status: ApprovalStatusApproved, ApprovedDate: "2021-02-05"},
+			},
+			Doses:                        2,
+			DaysSinceLastDoseCriteria:    14,
+			DaysBetweenDoesCriteriaBegin: 14,
+			DaysBetweenDoesCriteriaEnd:   92,
+			DisplayName:                  "Sinovac",
+			SaleProprietaryName:          "CoronaVac",
+			Aliases:                      []string{"CoronaVac"},
+			ManufacturerName:             "Sinovac Life Sciences Co., Ltd.",
+			ManufacturerID:               "sinovac",
+			AuthorizedDate:               "2020-08",
+		},
+		{
+			ID: CVXSystem + "#" + "502",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "502",
+				},
+				{
+					System: EUProductSystem,
+					Code:   "Covaxin",
+				},
+			},
+			GenericCodes:   covidInactivatedGenericCodes(),
+			TargetDiseases: covid19TargetDiseases(),
+			CVXStatus:      CVSStatusNonUS,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionWHOEUL, Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-11-03"},
+				{Jurisdiction: JurisdictionTGA, Status: ApprovalStatusRecognized, ApprovedDate: "2021-11-01"},
+				{Jurisdiction: NationalJurisdiction("IN"), Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-01-03"},
+			},
+			Doses:                        2,
+			DaysSinceLastDoseCriteria:    14,
+			DaysBetweenDoesCriteriaBegin: 28,
+			DaysBetweenDoesCriteriaEnd:   92,
+			DisplayName:                  "Covaxin",
+			SaleProprietaryName:          "COVAXIN",
+			Aliases:                      []string{"Bharat", "BBV152"},
+			ManufacturerName:             "Bharat Biotech International Ltd",
+			ManufacturerID:               "bharat-biotech",
+			AuthorizedDate:               "2021-01-03",
+		},
+		{
+			ID: CVXSystem + "#" + "505",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "505",
+				},
+				{
+					System: EUProductSystem,
+					Code:   "Sputnik-V",
+				},
+			},
+			GenericCodes:   covidViralVectorGenericCodes(),
+			TargetDiseases: covid19TargetDiseases(),
+			CVXStatus:      CVSStatusNonUS,
+			Approvals: []*Approval{
+				{Jurisdiction: NationalJurisdiction("RU"), Status: ApprovalStatusApproved, ApprovedDate: "2020-08-11"},
+				{Jurisdiction: NationalJurisdiction("HU"), Status: ApprovalStatusEmergencyUse, ApprovedDate: "2021-01-21"},
+			},
+			Doses:                        2,
+			DaysSinceLastDoseCriteria:    14,
+			DaysBetweenDoesCriteriaBegin: 21,
+			DaysBetweenDoesCriteriaEnd:   92,
+			DisplayName:                  "Sputnik V",
+			SaleProprietaryName:          "Sputnik V",
+			Aliases:                      []string{"Sputnik", "Gam-COVID-Vac"},
+			ManufacturerName:             "Gamaleya Research Institute",
+			ManufacturerID:               "gamaleya",
+			AuthorizedDate:               "2020-08-11",
+		},
+	}
+
+	result = append(result, createNonCovidVaccineMetadata()...)
+
+	return result
+}
+
+// immunocompromisedMRNASchedule an additional primary dose for immunocompromised patients 5 and over
+func immunocompromisedMRNASchedule() *Schedule {
+	return &Schedule{
+		Name:                         "immunocompromised",
+		MinAgeMonths:                 60,
+		Condition:                    PatientConditionImmunocompromised,
+		Doses:                        3,
+		DaysSinceLastDoseCriteria:    14,
+		DaysBetweenDoesCriteriaBegin: 17,
+		DaysBetweenDoesCriteriaEnd:   92,
+	}
+}
+
+// createNonCovidVaccineMetadata criteria from the CDC/ACIP schedules, for yellow fever the ICVP is valid
+// from 10 days after vaccination
+func createNonCovidVaccineMetadata() []*VaccineMetadata {
+
+	result := []*VaccineMetadata{
+		{
+			ID: CVXSystem + "#" + "03",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "03",
+				},
+			},
+			TargetDiseases: []Coding{
+				DiseaseMeasles,
+				{System: ICD10System, Code: "B05"},
+				DiseaseMumps,
+				{System: ICD10System, Code: "B26"},
+				DiseaseRubella,
+				{System: ICD10System, Code: "B06"},
+			},
+			CVXStatus: CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "1978"},
+			},
+			Doses:                        2,
+			DaysSinceLastDoseCriteria:    14,
+			DaysBetweenDoesCriteriaBegin: 24,
+			DisplayName:                  "MMR",
+			SaleProprietaryName:          "M-M-R II",
+			Aliases:                      []string{"M-M-R"},
+			ManufacturerName:             "Merck and Co., Inc.",
+			ManufacturerID:               "merck",
+		},
+		{
+			ID: CVXSystem + "#" + "08",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "08",
+				},
+			},
+			TargetDiseases: hepatitisBTargetDiseases(),
+			CVXStatus:      CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "1986"},
+			},
+			Doses:                        3,
+			DaysBetweenDoesCriteriaBegin: 24,
+			DisplayName:                  "Hepatitis B (pediatric/adolescent)",
+			SaleProprietaryName:          "Hep B, adolescent or pediatric",
+		},
+		{
+			ID: CVXSystem + "#" + "43",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "43",
+				},
+			},
+			TargetDiseases: hepatitisBTargetDiseases(),
+			CVXStatus:      CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "1986"},
+			},
+			Doses:                        3,
+			DaysBetweenDoesCriteriaBegin: 24,
+			DisplayName:                  "Hepatitis B (adult)",
+			SaleProprietaryName:          "Hep B, adult",
+		},
+		{
+			ID: CVXSystem + "#" + "37",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "37",
+				},
+			},
+			TargetDiseases: []Coding{
+				DiseaseYellowFever,
+				{System: ICD10System, Code: "A95"},
+			},
+			CVXStatus: CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "1953"},
+			},
+			Doses:                     1,
+			DaysSinceLastDoseCriteria: 10,
+			DisplayName:               "Yellow Fever",
+			SaleProprietaryName:       "YF-VAX",
+			Aliases:                   []string{"Stamaril"},
+			ManufacturerName:          "Sanofi Pasteur",
+			ManufacturerID:            "sanofi-pasteur",
+		},
+		{
+			ID: CVXSystem + "#" + "150",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "150",
+				},
+			},
+			TargetDiseases: []Coding{
+				DiseaseInfluenza,
+				{System: ICD10System, Code: "J11"},
+			},
+			CVXStatus: CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "2012"},
+			},
+			Doses:                     1,
+			DaysSinceLastDoseCriteria: 14,
+			DisplayName:               "Influenza",
+			SaleProprietaryName:       "Influenza, injectable, quadrivalent, preservative free",
+		},
+		{
+			ID: CVXSystem + "#" + "206",
+			Codes: []Coding{
+				{
+					System: CVXSystem,
+					Code:   "206",
+				},
+			},
+			TargetDiseases: []Coding{
+				DiseaseMpox,
+				{System: ICD10System, Code: "B04"},
+			},
+			CVXStatus: CVSStatusActive,
+			Approvals: []*Approval{
+				{Jurisdiction: JurisdictionFDA, Status: ApprovalStatusApproved, ApprovedDate: "2019-09-24"},
+			},
+			Doses:                        2,
+			DaysSinceLastDoseCriteria:    14,
+			DaysBetweenDoesCriteriaBegin: 24,
+			DisplayName:                  "JYNNEOS",
+			SaleProprietaryName:          "JYNNEOS",
+			Aliases:                      []string{"Imvanex", "Imvamune"},
+			ManufacturerName:             "Bavarian Nordic A/S",
+			ManufacturerID:               "bavarian-nordic",
+			AuthorizedDate:               "2019-09-24",
 		},
 	}
 
 	return result
 }
+
+// covidMRNAGenericCodes class codes for COVID-19 mRNA vaccines, the SNOMED CT and ATC codes
+// used by the EU DCC vp field and ICD-11
+func covidMRNAGenericCodes() []Coding {
+	return []Coding{
+		{System: SNOMEDSystem, Code: "1119349007"},
+		{System: SNOMEDSystem, Code: "1119305005"},
+		{System: ATCSystem, Code: "J07BX03"},
+		{System: ICD11System, Code: "XM0GQ8"},
+		{System: ICD11System, Code: "XM68M6"},
+	}
+}
+
+// covidViralVectorGenericCodes class codes for COVID-19 non-replicating viral vector vaccines
+func covidViralVectorGenericCodes() []Coding {
+	return []Coding{
+		{System: SNOMEDSystem, Code: "29061000087103"},
+		{System: SNOMEDSystem, Code: "1119305005"},
+		{System: ATCSystem, Code: "J07BX03"},
+		{System: ICD11System, Code: "XM9QW8"},
+		{System: ICD11System, Code: "XM68M6"},
+	}
+}
+
+// covidInactivatedGenericCodes class codes for COVID-19 inactivated whole virus vaccines
+func covidInactivatedGenericCodes() []Coding {
+	return []Coding{
+		{System: SNOMEDSystem, Code: "1157024006"},
+		{System: SNOMEDSystem, Code: "1119305005"},
+		{System: ATCSystem, Code: "J07BX03"},
+		{System: ICD11System, Code: "XM1NL1"},
+		{System: ICD11System, Code: "XM68M6"},
+	}
+}
+
+func covid19TargetDiseases() []Coding {
+	return []Coding{
+		DiseaseCOVID19,
+		{System: ICD10System, Code: "U07.1"},
+	}
+}
+
+func hepatitisBTargetDiseases() []Coding {
+	return []Coding{
+		DiseaseHepatitisB,
+		{System: ICD10System, Code: "B16"},
+	}
+}
@@ -0,0 +1,287 @@
+package vaccinemd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+//LoadMetadata reads a JSON array of vaccine metadata
+func LoadMetadata(r io.Reader) ([]*VaccineMetadata, error) {
+
+	var vaccineMD []*VaccineMetadata
+	if err := json.NewDecoder(r).Decode(&vaccineMD); err != nil {
+		return nil, fmt.Errorf("error load vaccine metadata err=%s", err)
+	}
+
+	return vaccineMD, nil
+}
+
+//LoadMetadataPath reads vaccine metadata from a JSON file, or from all the .json files in a directory
+//in name order
+func LoadMetadataPath(path string) ([]*VaccineMetadata, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("error load vaccine metadata path=%s err=%s", path, err)
+	}
+
+	files := []string{path}
+	if info.IsDir() {
+		files, err = filepath.Glob(filepath.Join(path, "*.json"))
+		if err != nil {
+			return nil, fmt.Errorf("error load vaccine metadata path=%s err=%s", path, err)
+		}
+		sort.Strings(files)
+	}
+
+	result := make([]*VaccineMetadata, 0)
+	for _, file := range files {
+		vaccineMD, err := loadMetadataFile(file)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, vaccineMD...)
+	}
+
+	return result, nil
+}
+
+func loadMetadataFile(file string) ([]*VaccineMetadata, error) {
+
+	f, err := os.Open(filepath.Clean(file))
+	if err != nil {
+		return nil, fmt.Errorf("error load vaccine metadata file=%s err=%s", file, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	vaccineMD, err := LoadMetadata(f)
+	if err != nil {
+		return nil, fmt.Errorf("error load vaccine metadata file=%s err=%s", file, err)
+	}
+
+	return vaccineMD, nil
+}
+
+//ValidateMetadata checks the metadata is complete and consistent, a repo cannot be made from invalid metadata.
+//Several versions can share an ID as long as their effective periods do not overlap
+func ValidateMetadata(vaccineMD []*VaccineMetadata) error {
+
+	if len(vaccineMD) == 0 {
+		return fmt.Errorf("error validate vaccine metadata no vaccines")
+	}
+
+	versions := make(map[string][]*VaccineMetadata)
+	codes := make(map[string]string)
+	genericCodes := make(map[string]bool)
+
+	for i, vmd := range vaccineMD {
+
+		if vmd == nil {
+			return fmt.Errorf("error validate vaccine metadata entry=%d is empty", i)
+		}
+
+		if vmd.ID == "" {
+			return fmt.Errorf("error validate vaccine metadata entry=%d has no id", i)
+		}
+
+		if vmd.EffectiveFrom != nil && vmd.EffectiveTo != nil && !vmd.EffectiveFrom.Before(*vmd.EffectiveTo) {
+			return fmt.Errorf("error validate vaccine metadata id=%s effective from is not before effective to", vmd.ID)
+		}
+		versions[vmd.ID] = append(versions[vmd.ID], vmd)
+
+		if len(vmd.Codes) == 0 {
+			return fmt.Errorf("error validate vaccine metadata id=%s has no codes", vmd.ID)
+		}
+
+		for _, code := range vmd.Codes {
+			if code.System == "" || code.Code == "" {
+				return fmt.Errorf("error validate vaccine metadata id=%s has a code without system or code", vmd.ID)
+			}
+
+			key := codingKey(code.System, code.Code)
+			if other, ok := codes[key]; ok && other != vmd.ID {
+				return fmt.Errorf("error validate vaccine metadata code=%s used by id=%s and id=%s", key, other, vmd.ID)
+			}
+			codes[key] = vmd.ID
+		}
+
+		for _, code := range vmd.GenericCodes {
+			if code.System == "" || code.Code == "" {
+				return fmt.Errorf("error validate vaccine metadata id=%s has a generic code without system or code", vmd.ID)
+			}
+			genericCodes[codingKey(code.System, code.Code)] = true
+		}
+
+		if vmd.ManufacturerID != "" && builtInManufacturers.id2Manufacturer[vmd.ManufacturerID] == nil {
+			return fmt.Errorf("error validate vaccine metadata id=%s unknown manufacturer id=%s", vmd.ID, vmd.ManufacturerID)
+		}
+
+		if len(vmd.TargetDiseases) == 0 {
+			return fmt.Errorf("error validate vaccine metadata id=%s has no target diseases", vmd.ID)
+		}
+
+		if vmd.Doses < 1 {
+			return fmt.Errorf("error validate vaccine metadata id=%s doses must be at least 1 got=%d", vmd.ID, vmd.Doses)
+		}
+
+		if vmd.DaysSinceLastDoseCriteria < 0 || vmd.DaysBetweenDoesCriteriaBegin < 0 || vmd.DaysBetweenDoesCriteriaEnd < 0 {
+			return fmt.Errorf("error validate vaccine metadata id=%s days criteria cannot be negative", vmd.ID)
+		}
+
+		if vmd.DaysBetweenDoesCriteriaEnd != 0 && vmd.DaysBetweenDoesCriteriaEnd < vmd.DaysBetweenDoesCriteriaBegin {
+			return fmt.Errorf("error validate vaccine metadata id=%s days between doses end before begin", vmd.ID)
+		}
+
+		if err := validateSchedules(vmd); err != nil {
+			return err
+		}
+
+		if err := validateApprovals(vmd); err != nil {
+			return err
+		}
+
+		if vmd.AuthorizedDate != "" && !isFHIRDate(vmd.AuthorizedDate) {
+			return fmt.Errorf("error validate vaccine metadata id=%s authorized date not a FHIR date got=%s",
+				vmd.ID, vmd.AuthorizedDate)
+		}
+	}
+
+	//a code cannot be both a product and a class code as resolving would be ambiguous
+	for key := range genericCodes {
+		if id, ok := codes[key]; ok {
+			return fmt.Errorf("error validate vaccine metadata code=%s is a product code for id=%s and a generic code", key, id)
+		}
+	}
+
+	for id, idVersions := range versions {
+		if err := validateNoOverlap(id, idVersions); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+//validateNoOverlap checks at most one version is effective at any time
+func validateNoOverlap(id string, versions []*VaccineMetadata) error {
+
+	sorted := make([]*VaccineMetadata, len(versions))
+	copy(sorted, versions)
+
+	//no from sorts first as effective from the beginning
+	sort.Slice(sorted, func(i, j int) bool {
+		if sorted[i].EffectiveFrom == nil {
+			return sorted[j].EffectiveFrom != nil
+		}
+		return sorted[j].EffectiveFrom != nil && sorted[i].EffectiveFrom.Before(*sorted[j].EffectiveFrom)
+	})
+
+	for i := 1; i < len(sorted); i++ {
+		previous, next := sorted[i-1], sorted[i]
+		if previous.EffectiveTo == nil || next.EffectiveFrom == nil || next.EffectiveFrom.Before(*previous.EffectiveTo) {
+			return fmt.Errorf("error validate vaccine metadata id=%s has versions with overlapping effective periods", id)
+		}
+	}
+
+	return nil
+}
+
+//validateApprovals the approvals are for known jurisdictions with valid dates
+func validateApprovals(vmd *VaccineMetadata) error {
+
+	for i, approval := range vmd.Approvals {
+
+		if approval == nil {
+			return fmt.Errorf("error validate vaccine metadata id=%s approval=%d is empty", vmd.ID, i)
+		}
+
+		if !approval.Jurisdiction.Valid() {
+			return fmt.Errorf("error validate vaccine metadata id=%s unknown jurisdiction got=%s",
+				vmd.ID, approval.Jurisdiction)
+		}
+
+		switch approval.Status {
+		case ApprovalStatusApproved, ApprovalStatusEmergencyUse, ApprovalStatusRecognized:
+		default:
+			return fmt.Errorf("error validate vaccine metadata id=%s jurisdiction=%s unknown approval status got=%s",
+				vmd.ID, approval.Jurisdiction, approval.Status)
+		}
+
+		approved, ok := parseFHIRDate(approval.ApprovedDate)
+		if !ok {
+			return fmt.Errorf("error validate vaccine metadata id=%s jurisdiction=%s approved date not a FHIR date got=%s",
+				vmd.ID, approval.Jurisdiction, approval.ApprovedDate)
+		}
+
+		if approval.RevokedDate != "" {
+			revoked, ok := parseFHIRDate(approval.RevokedDate)
+			if !ok || !approved.Before(revoked) {
+				return fmt.Errorf("error validate vaccine metadata id=%s jurisdiction=%s revoked date must be a FHIR date after approved got=%s",
+					vmd.ID, approval.Jurisdiction, approval.RevokedDate)
+			}
+		}
+	}
+
+	return nil
+}
+
+//validateSchedules the schedules are complete with unique names and age bands that make sense
+func validateSchedules(vmd *VaccineMetadata) error {
+
+	names := make(map[string]bool)
+	for i, schedule := range vmd.Schedules {
+
+		if schedule == nil {
+			return fmt.Errorf("error validate vaccine metadata id=%s schedule=%d is empty", vmd.ID, i)
+		}
+
+		if schedule.Name == "" || schedule.Name == DefaultScheduleName || names[schedule.Name] {
+			return fmt.Errorf("error validate vaccine metadata id=%s schedule=%d needs a unique name got=%s",
+				vmd.ID, i, schedule.Name)
+		}
+		names[schedule.Name] = true
+
+		if schedule.Doses < 1 {
+			return fmt.Errorf("error validate vaccine metadata id=%s schedule=%s doses must be at least 1 got=%d",
+				vmd.ID, schedule.Name, schedule.Doses)
+		}
+
+		if schedule.MinAgeMonths < 0 || schedule.MaxAgeMonths < 0 || schedule.DaysSinceLastDoseCriteria < 0 ||
+			schedule.DaysBetweenDoesCriteriaBegin < 0 || schedule.DaysBetweenDoesCriteriaEnd < 0 {
+			return fmt.Errorf("error validate vaccine metadata id=%s schedule=%s ages and days cannot be negative",
+				vmd.ID, schedule.Name)
+		}
+
+		if schedule.MaxAgeMonths != 0 && schedule.MaxAgeMonths <= schedule.MinAgeMonths {
+			return fmt.Errorf("error validate vaccine metadata id=%s schedule=%s max age must be after min age",
+				vmd.ID, schedule.Name)
+		}
+
+		if schedule.DaysBetweenDoesCriteriaEnd != 0 && schedule.DaysBetweenDoesCriteriaEnd < schedule.DaysBetweenDoesCriteriaBegin {
+			return fmt.Errorf("error validate vaccine metadata id=%s schedule=%s days between doses end before begin",
+				vmd.ID, schedule.Name)
+		}
+	}
+
+	return nil
+}
+
+func isFHIRDate(value string) bool {
+	_, ok := parseFHIRDate(value)
+	return ok
+}
+
+//metadataVersion a hash of the metadata contents
+func metadataVersion(metadata interface{}) string {
+
+	//metadata is plain data so marshal cannot fail
+	b, _ := json.Marshal(metadata)
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:])
+}
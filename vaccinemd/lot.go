@@ -0,0 +1,154 @@
+package vaccinemd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+//
+// Lots are optional, when a LotRegistry is configured each dose's lot number is checked against it. A lot that
+// belongs to another product, a dose given after the lot expired or outside the countries the lot was distributed
+// to, or a lot reported as counterfeit are strong signs a record is not genuine, especially for paper and
+// self-attested records.
+//
+
+//Lot a manufactured lot of a vaccine
+type Lot struct {
+
+	//LotNumber as printed on the vial and recorded on the card
+	LotNumber string `json:"lot_number"`
+
+	//VaccineID the VaccineMetadata ID of the product in the lot
+	VaccineID string `json:"vaccine_id"`
+
+	//ManufacturerID the Manufacturer ID, empty if not known
+	ManufacturerID string `json:"manufacturer_id,omitempty"`
+
+	//ExpiryDate FHIR date the lot expires, the lot can be used up to the end of the date
+	ExpiryDate string `json:"expiry_date,omitempty"`
+
+	//DistributionRegions ISO 3166-1 alpha-2 codes of the countries the lot was distributed to, empty if not known.
+	//A dose with a Country outside them is flagged
+	DistributionRegions []string `json:"distribution_regions,omitempty"`
+
+	//Counterfeit the lot number has been reported as used on counterfeit vaccine
+	Counterfeit bool `json:"counterfeit,omitempty"`
+}
+
+//LotRegistry finds lots by lot number, implementations must be safe for concurrent use.
+//The returned lots are shared so must not be modified
+type LotRegistry interface {
+
+	//FindLots the lots with the lot number, lot numbers are only unique within a manufacturer so there can be
+	//more than one. Lot numbers are matched ignoring case and surrounding space
+	FindLots(lotNumber string) []*Lot
+
+	//Version identifies the lots, a hash of their contents
+	Version() string
+}
+
+//MakeLotRegistry make a registry from the passed in lots, the lots are validated first
+func MakeLotRegistry(lots []*Lot) (LotRegistry, error) {
+
+	if err := ValidateLots(lots); err != nil {
+		return nil, err
+	}
+
+	number2Lots := make(map[string][]*Lot)
+	for _, lot := range lots {
+		key := lotKey(lot.LotNumber)
+		number2Lots[key] = append(number2Lots[key], lot)
+	}
+
+	return &v1LotRegistry{
+		number2Lots: number2Lots,
+		version:     metadataVersion(lots),
+	}, nil
+}
+
+//v1LotRegistry is never modified once made so is safe for concurrent use without a mutex
+type v1LotRegistry struct {
+	number2Lots map[string][]*Lot
+	version     string
+}
+
+func (lr *v1LotRegistry) FindLots(lotNumber string) []*Lot {
+	return lr.number2Lots[lotKey(lotNumber)]
+}
+
+func (lr *v1LotRegistry) Version() string {
+	return lr.version
+}
+
+func lotKey(lotNumber string) string {
+	return strings.ToUpper(strings.TrimSpace(lotNumber))
+}
+
+//ValidateLots checks the lots are complete, a lot number can only be listed once for a vaccine
+func ValidateLots(lots []*Lot) error {
+
+	seen := make(map[string]bool)
+	for i, lot := range lots {
+
+		if lot == nil {
+			return fmt.Errorf("error validate lots entry=%d is empty", i)
+		}
+
+		if lotKey(lot.LotNumber) == "" {
+			return fmt.Errorf("error validate lots entry=%d has no lot number", i)
+		}
+
+		if lot.VaccineID == "" {
+			return fmt.Errorf("error validate lots lot=%s has no vaccine id", lot.LotNumber)
+		}
+
+		key := lotKey(lot.LotNumber) + "#" + lot.VaccineID
+		if seen[key] {
+			return fmt.Errorf("error validate lots lot=%s listed more than once for vaccine id=%s",
+				lot.LotNumber, lot.VaccineID)
+		}
+		seen[key] = true
+
+		if lot.ManufacturerID != "" && builtInManufacturers.id2Manufacturer[lot.ManufacturerID] == nil {
+			return fmt.Errorf("error validate lots lot=%s unknown manufacturer id=%s", lot.LotNumber, lot.ManufacturerID)
+		}
+
+		if lot.ExpiryDate != "" && !isFHIRDate(lot.ExpiryDate) {
+			return fmt.Errorf("error validate lots lot=%s expiry date not a FHIR date got=%s", lot.LotNumber, lot.ExpiryDate)
+		}
+	}
+
+	return nil
+}
+
+//LoadLots reads a JSON array of lots
+func LoadLots(r io.Reader) ([]*Lot, error) {
+
+	var lots []*Lot
+	if err := json.NewDecoder(r).Decode(&lots); err != nil {
+		return nil, fmt.Errorf("error load lots err=%s", err)
+	}
+
+	return lots, nil
+}
+
+//LoadLotRegistryPath make a registry from a JSON file of lots
+func LoadLotRegistryPath(path string) (LotRegistry, error) {
+
+	f, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("error load lots file=%s err=%s", path, err)
+	}
+	defer func() { _ = f.Close() }()
+
+	lots, err := LoadLots(f)
+	if err != nil {
+		return nil, fmt.Errorf("error load lots file=%s err=%s", path, err)
+	}
+
+	return MakeLotRegistry(lots)
+}
@@ -0,0 +1,54 @@
+package vaccinemd_test
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_LotRegistry(t *testing.T) {
+
+	lots := []*vaccinemd.Lot{
+		{LotNumber: "EN6201", VaccineID: vaccinemd.CVXSystem + "#208", ManufacturerID: "pfizer-biontech", ExpiryDate: "2021-06-30", DistributionRegions: []string{"US"}},
+		{LotNumber: "en6201", VaccineID: vaccinemd.CVXSystem + "#207"},
+		{LotNumber: "FAKE01", VaccineID: vaccinemd.CVXSystem + "#208", Counterfeit: true},
+	}
+
+	t.Run("should load from file and find ignoring case", func(t *testing.T) {
+		b, err := json.Marshal(lots)
+		require.NoError(t, err)
+
+		path := filepath.Join(t.TempDir(), "lots.json")
+		require.NoError(t, os.WriteFile(path, b, 0600))
+
+		registry, err := vaccinemd.LoadLotRegistryPath(path)
+		require.NoError(t, err)
+		require.NotEmpty(t, registry.Version())
+
+		require.Equal(t, 2, len(registry.FindLots(" En6201")))
+		require.Equal(t, 1, len(registry.FindLots("fake01")))
+		require.Equal(t, 0, len(registry.FindLots("bogus")))
+	})
+
+	t.Run("should reject invalid lots", func(t *testing.T) {
+		for _, lot := range []*vaccinemd.Lot{
+			{LotNumber: " ", VaccineID: vaccinemd.CVXSystem + "#208"},
+			{LotNumber: "EN6202"},
+			{LotNumber: "EN6201", VaccineID: vaccinemd.CVXSystem + "#208"},
+			{LotNumber: "EN6202", VaccineID: vaccinemd.CVXSystem + "#208", ManufacturerID: "bogus"},
+			{LotNumber: "EN6202", VaccineID: vaccinemd.CVXSystem + "#208", ExpiryDate: "30/06/2021"},
+		} {
+			_, err := vaccinemd.MakeLotRegistry(append([]*vaccinemd.Lot{lots[0]}, lot))
+			require.Error(t, err, lot.LotNumber)
+		}
+	})
+
+	t.Run("should error for missing file", func(t *testing.T) {
+		_, err := vaccinemd.LoadLotRegistryPath(filepath.Join(t.TempDir(), "bogus.json"))
+		require.Error(t, err)
+	})
+}
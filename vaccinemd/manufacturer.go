@@ -0,0 +1,188 @@
+package vaccinemd
+
+import "strings"
+
+//
+// Manufacturers are identified by an MVX code on SMART Health Cards and by the EU marketing authorisation
+// holder organisation code, ORG-..., in the ma field of EU certificates. Products link to their manufacturer
+// by VaccineMetadata ManufacturerID so the same name can be shown whatever the card format.
+// SEE https://www2a.cdc.gov/vaccines/iis/iisstandards/vaccines.asp?rpt=tradename
+// and https://github.com/ehn-dcc-development/ehn-dcc-valuesets/blob/release/2.0.0/vaccines-covid-19-auth-holders.json
+//
+
+const (
+	//MVXSystem CDC manufacturers of vaccines code system
+	MVXSystem string = "http://hl7.org/fhir/sid/mvx"
+
+	//EUOrgSystem EMA SPOR organisation code system, the codes used for the EU marketing authorisation holder
+	EUOrgSystem string = "https://spor.ema.europa.eu/v1/organisations"
+)
+
+//Manufacturer a vaccine manufacturer or marketing authorisation holder
+type Manufacturer struct {
+
+	//ID for the manufacturer
+	ID string `json:"id"`
+
+	//MVXCode the CDC MVX code, empty if none
+	MVXCode string `json:"mvx_code,omitempty"`
+
+	//EUOrgCodes the EMA organisation codes the manufacturer holds EU marketing authorisations under
+	EUOrgCodes []string `json:"eu_org_codes,omitempty"`
+
+	//Name the legal name
+	Name string `json:"name"`
+
+	//DisplayName what to display to the user
+	DisplayName string `json:"display_name"`
+
+	//OtherNames other names the manufacturer is known by on cards, for example the EU authorisation holder name
+	OtherNames []string `json:"other_names,omitempty"`
+
+	//Country ISO 3166-1 alpha-2 code of the country the manufacturer is based in
+	Country string `json:"country"`
+}
+
+//Codings the codes the manufacturer is known by in every system
+func (m *Manufacturer) Codings() []Coding {
+
+	result := make([]Coding, 0, 1+len(m.EUOrgCodes))
+	if m.MVXCode != "" {
+		result = append(result, Coding{System: MVXSystem, Code: m.MVXCode})
+	}
+	for _, code := range m.EUOrgCodes {
+		result = append(result, Coding{System: EUOrgSystem, Code: code})
+	}
+
+	return result
+}
+
+//HasName true if the name is one of the manufacturer's names, ignoring case
+func (m *Manufacturer) HasName(name string) bool {
+
+	name = strings.TrimSpace(name)
+	if strings.EqualFold(m.Name, name) || strings.EqualFold(m.DisplayName, name) {
+		return true
+	}
+
+	for _, other := range m.OtherNames {
+		if strings.EqualFold(other, name) {
+			return true
+		}
+	}
+
+	return false
+}
+
+//manufacturerRegistry the manufacturers indexed by ID and code, never modified once made
+type manufacturerRegistry struct {
+	manufacturers     []*Manufacturer
+	id2Manufacturer   map[string]*Manufacturer
+	code2Manufacturer map[string]*Manufacturer
+}
+
+func makeManufacturerRegistry(manufacturers []*Manufacturer) *manufacturerRegistry {
+
+	registry := &manufacturerRegistry{
+		manufacturers:     manufacturers,
+		id2Manufacturer:   make(map[string]*Manufacturer),
+		code2Manufacturer: make(map[string]*Manufacturer),
+	}
+
+	for _, m := range manufacturers {
+		registry.id2Manufacturer[m.ID] = m
+		for _, coding := range m.Codings() {
+			registry.code2Manufacturer[codingKey(coding.System, coding.Code)] = m
+		}
+	}
+
+	return registry
+}
+
+//builtInManufacturers made once as the registry is read only
+var builtInManufacturers = makeManufacturerRegistry(createManufacturers())
+
+// createManufacturers the built in manufacturers
+func createManufacturers() []*Manufacturer {
+
+	return []*Manufacturer{
+		{
+			ID:          "astrazeneca",
+			MVXCode:     "ASZ",
+			EUOrgCodes:  []string{"ORG-100001699"},
+			Name:        "AstraZeneca Pharmaceuticals LP",
+			DisplayName: "AstraZeneca",
+			OtherNames:  []string{"AstraZeneca AB"},
+			Country:     "GB",
+		},
+		{
+			ID:          "bavarian-nordic",
+			MVXCode:     "BN",
+			Name:        "Bavarian Nordic A/S",
+			DisplayName: "Bavarian Nordic",
+			Country:     "DK",
+		},
+		{
+			ID:          "bharat-biotech",
+			Name:        "Bharat Biotech International Ltd",
+			DisplayName: "Bharat Biotech",
+			Country:     "IN",
+		},
+		{
+			ID:          "gamaleya",
+			Name:        "Gamaleya Research Institute",
+			DisplayName: "Gamaleya",
+			OtherNames:  []string{"Gamaleya Research Institute of Epidemiology and Microbiology"},
+			Country:     "RU",
+		},
+		{
+			ID:          "janssen",
+			MVXCode:     "JSN",
+			EUOrgCodes:  []string{"ORG-100001417"},
+			Name:        "Janssen Products, LP",
+			DisplayName: "Johnson & Johnson Janssen",
+			OtherNames:  []string{"Janssen-Cilag International", "Janssen"},
+			Country:     "BE",
+		},
+		{
+			ID:          "merck",
+			MVXCode:     "MSD",
+			Name:        "Merck and Co., Inc.",
+			DisplayName: "Merck",
+			OtherNames:  []string{"Merck Sharp & Dohme"},
+			Country:     "US",
+		},
+		{
+			ID:          "moderna",
+			MVXCode:     "MOD",
+			EUOrgCodes:  []string{"ORG-100031184"},
+			Name:        "Moderna US, Inc",
+			DisplayName: "Moderna",
+			OtherNames:  []string{"Moderna Biotech Spain S.L."},
+			Country:     "US",
+		},
+		{
+			ID:          "pfizer-biontech",
+			MVXCode:     "PFR",
+			EUOrgCodes:  []string{"ORG-100030215"},
+			Name:        "Pfizer-BioNTech",
+			DisplayName: "Pfizer-BioNTech",
+			OtherNames:  []string{"Pfizer, Inc", "Biontech Manufacturing GmbH"},
+			Country:     "US",
+		},
+		{
+			ID:          "sanofi-pasteur",
+			MVXCode:     "PMC",
+			Name:        "Sanofi Pasteur",
+			DisplayName: "Sanofi Pasteur",
+			Country:     "FR",
+		},
+		{
+			ID:          "sinovac",
+			Name:        "Sinovac Life Sciences Co., Ltd.",
+			DisplayName: "Sinovac",
+			OtherNames:  []string{"Sinovac Biotech"},
+			Country:     "CN",
+		},
+	}
+}
@@ -1,21 +1,38 @@
 package vaccinemd
 
+import "time"
+
 //
 // SEE https://www.cdc.gov/vaccines/programs/iis/COVID-19-related-codes.html
+// and https://www2a.cdc.gov/vaccines/iis/iisstandards/vaccines.asp?rpt=cvx
 //
 
-//CovidVaccineMetadata metadata about a covid vaccine
+//VaccineMetadata metadata about a vaccine for one or more target diseases
 //from https://www.cdc.gov/vaccines/programs/iis/COVID-19-related-codes.html
-type CovidVaccineMetadata struct {
+type VaccineMetadata struct {
 
 	//ID for the vaccine metadata
 	ID string `json:"id"`
 
-	Codes []Coding
+	//Codes the product codes the vaccine is known by, in any system, each code identifies only this vaccine.
+	//Written as codes, metadata written before it had a tag uses Codes which is still read as keys are
+	//matched case insensitively
+	Codes []Coding `json:"codes"`
+
+	//GenericCodes class codes that include this vaccine and may include others, for example
+	//the SNOMED CT code for any SARS-CoV-2 mRNA vaccine
+	GenericCodes []Coding `json:"generic_codes,omitempty"`
+
+	//TargetDiseases the diseases the vaccine protects against, each disease can be listed with several
+	//codes, for example SNOMED CT and ICD-10, so it can be found by either
+	TargetDiseases []Coding `json:"target_diseases"`
 
 	//CVXStatus cvx status from the cdc table
 	CVXStatus CVSStatus `json:"cvs_status"`
 
+	//Approvals the vaccine's approvals by jurisdiction, used to decide if trusted in a region
+	Approvals []*Approval `json:"approvals,omitempty"`
+
 	//Doses number of doses required
 	Doses int `json:"doses"`
 
@@ -28,6 +45,10 @@ type CovidVaccineMetadata struct {
 	//DaysBetweenDoesCriteriaEnd end of range took from common pass recommendations
 	DaysBetweenDoesCriteriaEnd int `json:"days_between_does_criteria_end"`
 
+	//Schedules age band and condition specific schedules, Doses and the day criteria above are used
+	//when none apply, see ScheduleFor
+	Schedules []*Schedule `json:"schedules,omitempty"`
+
 	//DisplayName what display to user so can be different from SaleProprietaryName if it makes more sense to user
 	//used in UI
 	DisplayName string `json:"display_name"`
@@ -35,8 +56,51 @@ type CovidVaccineMetadata struct {
 	//SaleProprietaryName from cdc table
 	SaleProprietaryName string `json:"sale_proprietary_name"`
 
+	//Aliases other names the vaccine is written as, for example on paper cards, see Repo MatchVaccineName
+	Aliases []string `json:"aliases,omitempty"`
+
 	//ManufacturerName name of manufacturer
 	ManufacturerName string `json:"manufacturer_name"`
+
+	//ManufacturerID the Manufacturer ID of the manufacturer, empty if not known, see Repo FindManufacturerByID
+	ManufacturerID string `json:"manufacturer_id,omitempty"`
+
+	//AuthorizedDate FHIR date the vaccine was first authorized for use, doses before this are suspect
+	AuthorizedDate string `json:"authorized_date,omitempty"`
+
+	//EffectiveFrom when this version of the metadata starts to apply, inclusive, nil if always applied
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+
+	//EffectiveTo when this version of the metadata stops applying, exclusive, nil if never stops
+	EffectiveTo *time.Time `json:"effective_to,omitempty"`
+}
+
+//CovidVaccineMetadata kept so existing code continues to work, covid vaccines are VaccineMetadata
+//with a target disease of DiseaseCOVID19
+type CovidVaccineMetadata = VaccineMetadata
+
+//TargetsDisease true if the vaccine protects against the disease
+func (vmd *VaccineMetadata) TargetsDisease(disease Coding) bool {
+	for _, td := range vmd.TargetDiseases {
+		if td == disease {
+			return true
+		}
+	}
+	return false
+}
+
+//EffectiveAt true if this version of the metadata applies at the passed in time
+func (vmd *VaccineMetadata) EffectiveAt(at time.Time) bool {
+
+	if vmd.EffectiveFrom != nil && at.Before(*vmd.EffectiveFrom) {
+		return false
+	}
+
+	if vmd.EffectiveTo != nil && !at.Before(*vmd.EffectiveTo) {
+		return false
+	}
+
+	return true
 }
 
 //CVSStatus if CDC states from table
@@ -63,9 +127,46 @@ type Coding struct {
 const (
 	//CVXSystem system code
 	CVXSystem string = "http://hl7.org/fhir/sid/cvx"
+
+	//SNOMEDSystem SNOMED CT system code
+	SNOMEDSystem string = "http://snomed.info/sct"
+
+	//ICD10System ICD-10 system code
+	ICD10System string = "http://hl7.org/fhir/sid/icd-10"
+)
+
+//
+// Target diseases, the SNOMED CT codes are the preferred codes, the vaccine metadata also lists the ICD-10
+// code for each so either can be used to find vaccines
+//
+
+var (
+	//DiseaseCOVID19 COVID-19
+	DiseaseCOVID19 = Coding{System: SNOMEDSystem, Code: "840539006"}
+
+	//DiseaseInfluenza influenza
+	DiseaseInfluenza = Coding{System: SNOMEDSystem, Code: "6142004"}
+
+	//DiseaseMeasles measles
+	DiseaseMeasles = Coding{System: SNOMEDSystem, Code: "14189004"}
+
+	//DiseaseMumps mumps
+	DiseaseMumps = Coding{System: SNOMEDSystem, Code: "36989005"}
+
+	//DiseaseRubella rubella
+	DiseaseRubella = Coding{System: SNOMEDSystem, Code: "36653000"}
+
+	//DiseaseHepatitisB hepatitis B
+	DiseaseHepatitisB = Coding{System: SNOMEDSystem, Code: "66071002"}
+
+	//DiseaseYellowFever yellow fever
+	DiseaseYellowFever = Coding{System: SNOMEDSystem, Code: "16541001"}
+
+	//DiseaseMpox mpox (monkeypox)
+	DiseaseMpox = Coding{System: SNOMEDSystem, Code: "359814004"}
 )
 
-//Region that checking tests for
+//Region that checking tests for, a named region or jurisdiction expression, see Region Trust
 type Region string
 
 const (
@@ -74,4 +175,10 @@ const (
 
 	//RegionEU EU approved
 	RegionEU Region = "EU"
+
+	//RegionCanada Health Canada approved
+	RegionCanada Region = "Canada"
+
+	//RegionAustralia TGA approved or recognised
+	RegionAustralia Region = "Australia"
 )
@@ -0,0 +1,213 @@
+package vaccinemd
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+//
+// Paper records give the product as written by hand, for example "Pfizer", "moderna #2" or "J&J". Names are
+// matched against each vaccine's display, proprietary and manufacturer names and its Aliases, first exactly,
+// then by a single word of the name, then as the start of a name, then allowing for small spelling mistakes.
+//
+
+//NameMatch the vaccine a written product name refers to
+type NameMatch struct {
+
+	//Vaccine the vaccine the name matched, nil if no match or ambiguous
+	Vaccine *VaccineMetadata
+
+	//Ambiguous the name matched more than one vaccine, see Candidates
+	Ambiguous bool
+
+	//Candidates the vaccines the name could be, in ID order, set when ambiguous
+	Candidates []*VaccineMetadata
+
+	//Fuzzy the name only matched allowing for spelling mistakes so should be reviewed
+	Fuzzy bool
+}
+
+//nameNoiseWords words often written with the product name that do not identify it
+var nameNoiseWords = map[string]bool{
+	"covid": true, "covid19": true, "sars": true, "cov": true, "vaccine": true, "vaccination": true,
+	"vax": true, "dose": true, "booster": true, "shot": true, "first": true, "second": true, "third": true,
+	"1st": true, "2nd": true, "3rd": true, "lot": true,
+}
+
+var (
+	nameAmpersandRegExp = regexp.MustCompile(`\s*&\s*`)
+	nameSeparatorRegExp = regexp.MustCompile(`[^a-z0-9&]+`)
+)
+
+//NormalizeVaccineName lower cases the name, removes punctuation other than &, noise words such as
+//"vaccine" or "dose" and short numbers such as a dose number
+func NormalizeVaccineName(name string) string {
+
+	name = strings.ToLower(name)
+	name = nameAmpersandRegExp.ReplaceAllString(name, "&")
+	name = nameSeparatorRegExp.ReplaceAllString(name, " ")
+
+	words := make([]string, 0)
+	for _, word := range strings.Fields(name) {
+		if nameNoiseWords[word] || (len(word) <= 2 && isDigits(word)) {
+			continue
+		}
+		words = append(words, word)
+	}
+
+	return strings.Join(words, " ")
+}
+
+func isDigits(value string) bool {
+	for _, r := range value {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return value != ""
+}
+
+//vaccineNames the normalized names a vaccine can be written as
+func (vmi *v1Repo) vaccineNames(vmd *VaccineMetadata) []string {
+
+	names := []string{vmd.DisplayName, vmd.SaleProprietaryName, vmd.ManufacturerName}
+	names = append(names, vmd.Aliases...)
+	if m := vmi.FindManufacturerByID(vmd.ManufacturerID); m != nil {
+		names = append(names, m.Name, m.DisplayName)
+		names = append(names, m.OtherNames...)
+	}
+
+	result := make([]string, 0, len(names))
+	for _, name := range names {
+		if normalized := NormalizeVaccineName(name); normalized != "" {
+			result = append(result, normalized)
+		}
+	}
+
+	return result
+}
+
+//matchName exact match, then a word of the written name matching a whole name, then the written name
+//being the first words of a name, then the closest names within a small edit distance
+func (vmi *v1Repo) matchName(name string) *NameMatch {
+
+	written := NormalizeVaccineName(name)
+	if written == "" {
+		return &NameMatch{}
+	}
+	words := strings.Fields(written)
+
+	exact := make(map[string]*VaccineMetadata)
+	byWord := make(map[string]*VaccineMetadata)
+	byPrefix := make(map[string]*VaccineMetadata)
+	fuzzy := make(map[string]*VaccineMetadata)
+	bestDistance := maxNameDistance(written) + 1
+
+	for _, vmd := range vmi.effectiveVaccines() {
+		for _, candidate := range vmi.vaccineNames(vmd) {
+
+			if candidate == written {
+				exact[vmd.ID] = vmd
+			}
+
+			for _, word := range words {
+				if word == candidate {
+					byWord[vmd.ID] = vmd
+				}
+			}
+
+			if strings.HasPrefix(candidate, written+" ") {
+				byPrefix[vmd.ID] = vmd
+			}
+
+			distance := editDistance(written, candidate)
+			if distance < bestDistance {
+				bestDistance = distance
+				fuzzy = make(map[string]*VaccineMetadata)
+			}
+			if distance == bestDistance {
+				fuzzy[vmd.ID] = vmd
+			}
+		}
+	}
+
+	switch {
+	case len(exact) > 0:
+		return makeNameMatch(exact, false)
+	case len(byWord) > 0:
+		return makeNameMatch(byWord, false)
+	case len(byPrefix) > 0:
+		return makeNameMatch(byPrefix, false)
+	default:
+		return makeNameMatch(fuzzy, true)
+	}
+}
+
+//maxNameDistance the spelling mistakes allowed, none for short names as they are too easily confused
+func maxNameDistance(name string) int {
+	switch {
+	case len(name) < 4:
+		return 0
+	case len(name) < 8:
+		return 1
+	}
+	return 2
+}
+
+func makeNameMatch(matches map[string]*VaccineMetadata, fuzzy bool) *NameMatch {
+
+	result := &NameMatch{}
+	if len(matches) == 0 {
+		return result
+	}
+
+	result.Fuzzy = fuzzy
+	if len(matches) == 1 {
+		for _, vmd := range matches {
+			result.Vaccine = vmd
+		}
+		return result
+	}
+
+	result.Ambiguous = true
+	for _, vmd := range matches {
+		result.Candidates = append(result.Candidates, vmd)
+	}
+	sort.Slice(result.Candidates, func(i, j int) bool {
+		return result.Candidates[i].ID < result.Candidates[j].ID
+	})
+
+	return result
+}
+
+//editDistance the Levenshtein distance between a and b
+func editDistance(a string, b string) int {
+
+	previous := make([]int, len(b)+1)
+	current := make([]int, len(b)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+
+	for i := 1; i <= len(a); i++ {
+		current[0] = i
+		for j := 1; j <= len(b); j++ {
+			cost := 1
+			if a[i-1] == b[j-1] {
+				cost = 0
+			}
+			current[j] = minInt(previous[j]+1, minInt(current[j-1]+1, previous[j-1]+cost))
+		}
+		previous, current = current, previous
+	}
+
+	return previous[len(b)]
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,51 @@
+package vaccinemd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_MatchVaccineName(t *testing.T) {
+
+	type testCase struct {
+		name               string
+		written            string
+		expectedName       string
+		expectedFuzzy      bool
+		expectedCandidates int
+	}
+
+	testCases := []testCase{
+		{name: "should match display name", written: "Pfizer", expectedName: "Pfizer"},
+		{name: "should ignore case and noise", written: "MODERNA covid-19 vaccine dose 2", expectedName: "Moderna"},
+		{name: "should match alias", written: "J&J", expectedName: "Johnson & Johnson Janssen"},
+		{name: "should match alias with spaces", written: "J & J", expectedName: "Johnson & Johnson Janssen"},
+		{name: "should match manufacturer name", written: "Pfizer-BioNTech", expectedName: "Pfizer"},
+		{name: "should match a word", written: "Janssen single shot", expectedName: "Johnson & Johnson Janssen"},
+		{name: "should match spelling mistake", written: "Modema", expectedName: "Moderna", expectedFuzzy: true},
+		{name: "should match longer spelling mistake", written: "Astrazenica", expectedName: "AstraZeneca", expectedFuzzy: true},
+		{name: "should not fuzzy match short names", written: "XY"},
+		{name: "should not match unknown", written: "Novavax"},
+		{name: "should not match empty", written: "vaccine"},
+		{name: "should be ambiguous for hepatitis b", written: "Hep B", expectedCandidates: 2},
+	}
+
+	repo := vaccinemd.MakeRepo()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			match := repo.MatchVaccineName(tc.written)
+			if tc.expectedName == "" {
+				require.Nil(t, match.Vaccine)
+			} else {
+				require.NotNil(t, match.Vaccine)
+				require.Equal(t, tc.expectedName, match.Vaccine.DisplayName)
+			}
+			require.Equal(t, tc.expectedFuzzy, match.Fuzzy)
+			require.Equal(t, tc.expectedCandidates > 0, match.Ambiguous)
+			require.Equal(t, tc.expectedCandidates, len(match.Candidates))
+		})
+	}
+}
@@ -0,0 +1,210 @@
+package vaccinemd
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+//ReloadableRepo a repo whose metadata can be replaced while it is in use. Each update is validated and then
+//swapped in atomically, if validation fails the current metadata is kept. Use Snapshot for the duration of a
+//verification so it sees one consistent version.
+type ReloadableRepo interface {
+	Repo
+
+	//Update replace the metadata, if the metadata is invalid an error is returned and the current metadata kept.
+	//The metadata is owned by the repo after the call so must not be modified
+	Update(vaccineMD []*VaccineMetadata) error
+
+	//LoadPath replace the metadata with the contents of a JSON file or directory, see LoadMetadataPath
+	LoadPath(path string) error
+
+	//Watch polls path every interval and loads it when it changes, it blocks until the context is done.
+	//Load and validation errors are passed to onError, which can be nil, and the current metadata kept. Each
+	//failure is only passed once rather than on every poll. An interval that is not positive is
+	//DefaultWatchInterval
+	Watch(ctx context.Context, path string, interval time.Duration, onError func(error))
+}
+
+//DefaultWatchInterval how often Watch polls if the interval is not positive
+const DefaultWatchInterval = time.Minute
+
+//MakeReloadableRepo make a reloadable repo starting with the built in metadata
+func MakeReloadableRepo() ReloadableRepo {
+
+	rr := &reloadableRepo{}
+	rr.current.Store(makeV1Repo(createVaccineMetadata()))
+	return rr
+}
+
+//reloadableRepo each version is an immutable v1Repo so readers only need an atomic load
+type reloadableRepo struct {
+	current atomic.Value //*v1Repo
+
+	//mu serializes updates, readers never take it
+	mu sync.Mutex
+}
+
+func (rr *reloadableRepo) snapshot() *v1Repo {
+	return rr.current.Load().(*v1Repo)
+}
+
+func (rr *reloadableRepo) Snapshot() Repo {
+	return rr.snapshot()
+}
+
+func (rr *reloadableRepo) AsOf(at time.Time) Repo {
+	return rr.snapshot().AsOf(at)
+}
+
+func (rr *reloadableRepo) Version() string {
+	return rr.snapshot().Version()
+}
+
+func (rr *reloadableRepo) FindVaccine(system string, code string) *VaccineMetadata {
+	return rr.snapshot().FindVaccine(system, code)
+}
+
+func (rr *reloadableRepo) ResolveVaccine(codings []Coding) *Resolution {
+	return rr.snapshot().ResolveVaccine(codings)
+}
+
+func (rr *reloadableRepo) FindVaccineByID(id string) *VaccineMetadata {
+	return rr.snapshot().FindVaccineByID(id)
+}
+
+func (rr *reloadableRepo) Vaccines() []*VaccineMetadata {
+	return rr.snapshot().Vaccines()
+}
+
+func (rr *reloadableRepo) FindVaccinesForDisease(disease Coding) []*VaccineMetadata {
+	return rr.snapshot().FindVaccinesForDisease(disease)
+}
+
+func (rr *reloadableRepo) FindTrustedVaccinesForRegionAndDisease(region Region, disease Coding) []*VaccineMetadata {
+	return rr.snapshot().FindTrustedVaccinesForRegionAndDisease(region, disease)
+}
+
+func (rr *reloadableRepo) FindTrustedVaccines(region Region) []*VaccineMetadata {
+	return rr.snapshot().FindTrustedVaccines(region)
+}
+
+func (rr *reloadableRepo) FindVaccinesByManufacturer(manufacturer string) []*VaccineMetadata {
+	return rr.snapshot().FindVaccinesByManufacturer(manufacturer)
+}
+
+func (rr *reloadableRepo) FindVaccinesByStatus(status CVSStatus) []*VaccineMetadata {
+	return rr.snapshot().FindVaccinesByStatus(status)
+}
+
+func (rr *reloadableRepo) SearchVaccines(text string) []*VaccineMetadata {
+	return rr.snapshot().SearchVaccines(text)
+}
+
+func (rr *reloadableRepo) MatchVaccineName(name string) *NameMatch {
+	return rr.snapshot().MatchVaccineName(name)
+}
+
+func (rr *reloadableRepo) FindManufacturer(system string, code string) *Manufacturer {
+	return rr.snapshot().FindManufacturer(system, code)
+}
+
+func (rr *reloadableRepo) FindManufacturerByID(id string) *Manufacturer {
+	return rr.snapshot().FindManufacturerByID(id)
+}
+
+func (rr *reloadableRepo) Manufacturers() []*Manufacturer {
+	return rr.snapshot().Manufacturers()
+}
+
+func (rr *reloadableRepo) FindCovidVaccine(system string, code string) *VaccineMetadata {
+	return rr.snapshot().FindCovidVaccine(system, code)
+}
+
+func (rr *reloadableRepo) FindTrustedVaccinesForRegion(region Region) []*VaccineMetadata {
+	return rr.snapshot().FindTrustedVaccinesForRegion(region)
+}
+
+func (rr *reloadableRepo) CovidVaccines() []*VaccineMetadata {
+	return rr.snapshot().CovidVaccines()
+}
+
+func (rr *reloadableRepo) FindCovidVaccineByID(id string) *VaccineMetadata {
+	return rr.snapshot().FindCovidVaccineByID(id)
+}
+
+func (rr *reloadableRepo) Update(vaccineMD []*VaccineMetadata) error {
+
+	if err := ValidateMetadata(vaccineMD); err != nil {
+		return err
+	}
+
+	next := makeV1Repo(vaccineMD)
+
+	rr.mu.Lock()
+	defer rr.mu.Unlock()
+
+	if next.Version() != rr.snapshot().Version() {
+		rr.current.Store(next)
+	}
+
+	return nil
+}
+
+func (rr *reloadableRepo) LoadPath(path string) error {
+
+	vaccineMD, err := LoadMetadataPath(path)
+	if err != nil {
+		return err
+	}
+
+	return rr.Update(vaccineMD)
+}
+
+func (rr *reloadableRepo) Watch(ctx context.Context, path string, interval time.Duration, onError func(error)) {
+
+	//a ticker panics if the interval is not positive
+	if interval <= 0 {
+		interval = DefaultWatchInterval
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	//only report a bad version or a load error once rather than every poll
+	lastFailedVersion := ""
+	lastLoadErr := ""
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		vaccineMD, err := LoadMetadataPath(path)
+		if err != nil {
+			if err.Error() == lastLoadErr {
+				continue
+			}
+			lastLoadErr = err.Error()
+		} else {
+			lastLoadErr = ""
+
+			version := metadataVersion(vaccineMD)
+			if version == rr.Version() || version == lastFailedVersion {
+				continue
+			}
+
+			err = rr.Update(vaccineMD)
+			if err != nil {
+				lastFailedVersion = version
+			}
+		}
+
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	}
+}
@@ -0,0 +1,216 @@
+package vaccinemd_test
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_ValidateMetadata(t *testing.T) {
+
+	type testCase struct {
+		name        string
+		change      func(vmd *vaccinemd.CovidVaccineMetadata)
+		expectError bool
+	}
+
+	testCases := []testCase{
+		{
+			name:   "should accept valid metadata",
+			change: func(vmd *vaccinemd.CovidVaccineMetadata) {},
+		},
+		{
+			name:        "should reject no id",
+			change:      func(vmd *vaccinemd.CovidVaccineMetadata) { vmd.ID = "" },
+			expectError: true,
+		},
+		{
+			name:        "should reject no codes",
+			change:      func(vmd *vaccinemd.CovidVaccineMetadata) { vmd.Codes = nil },
+			expectError: true,
+		},
+		{
+			name:        "should reject zero doses",
+			change:      func(vmd *vaccinemd.CovidVaccineMetadata) { vmd.Doses = 0 },
+			expectError: true,
+		},
+		{
+			name: "should reject days between doses end before begin",
+			change: func(vmd *vaccinemd.CovidVaccineMetadata) {
+				vmd.DaysBetweenDoesCriteriaBegin = 30
+				vmd.DaysBetweenDoesCriteriaEnd = 20
+			},
+			expectError: true,
+		},
+		{
+			name:        "should reject a bad authorized date",
+			change:      func(vmd *vaccinemd.CovidVaccineMetadata) { vmd.AuthorizedDate = "12/11/2020" },
+			expectError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			vaccineMD := copyMetadata(t, vaccinemd.MakeRepo().CovidVaccines())
+			tc.change(vaccineMD[0])
+
+			err := vaccinemd.ValidateMetadata(vaccineMD)
+			if tc.expectError {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("should reject a duplicate code", func(t *testing.T) {
+		vaccineMD := copyMetadata(t, vaccinemd.MakeRepo().CovidVaccines())
+		vaccineMD[1].Codes = vaccineMD[0].Codes
+		require.Error(t, vaccinemd.ValidateMetadata(vaccineMD))
+	})
+}
+
+func Test_ReloadableRepoUpdate(t *testing.T) {
+
+	repo := vaccinemd.MakeReloadableRepo()
+	originalVersion := repo.Version()
+	require.Equal(t, vaccinemd.MakeRepo().Version(), originalVersion, "should start with built in metadata")
+
+	snapshot := repo.Snapshot()
+
+	//change the criteria for moderna
+	vaccineMD := copyMetadata(t, repo.CovidVaccines())
+	moderna := repo.FindCovidVaccine(vaccinemd.CVXSystem, "207")
+	for _, vmd := range vaccineMD {
+		if vmd.ID == moderna.ID {
+			vmd.DaysSinceLastDoseCriteria = 21
+		}
+	}
+
+	require.NoError(t, repo.Update(vaccineMD))
+	require.NotEqual(t, originalVersion, repo.Version(), "version should change")
+	require.Equal(t, 21, repo.FindCovidVaccine(vaccinemd.CVXSystem, "207").DaysSinceLastDoseCriteria)
+
+	require.Equal(t, originalVersion, snapshot.Version(), "snapshot should not change")
+	require.Equal(t, 14, snapshot.FindCovidVaccine(vaccinemd.CVXSystem, "207").DaysSinceLastDoseCriteria)
+
+	//invalid metadata should keep current
+	updatedVersion := repo.Version()
+	vaccineMD = copyMetadata(t, repo.CovidVaccines())
+	vaccineMD[0].Doses = 0
+	require.Error(t, repo.Update(vaccineMD))
+	require.Equal(t, updatedVersion, repo.Version(), "should keep the previous version")
+}
+
+func Test_LoadMetadataCodesKey(t *testing.T) {
+
+	vaccineMD := vaccinemd.MakeRepo().CovidVaccines()
+	b, err := json.Marshal(vaccineMD)
+	require.NoError(t, err)
+	require.Contains(t, string(b), `"codes":`)
+
+	//metadata written before the codes key had a tag
+	old := strings.ReplaceAll(string(b), `"codes":`, `"Codes":`)
+	loaded, err := vaccinemd.LoadMetadata(strings.NewReader(old))
+	require.NoError(t, err)
+	require.Len(t, loaded, len(vaccineMD))
+	for i, vmd := range loaded {
+		require.NotEmpty(t, vmd.Codes)
+		require.Equal(t, vaccineMD[i].Codes, vmd.Codes)
+	}
+}
+
+func Test_ReloadableRepoWatch(t *testing.T) {
+
+	dir := t.TempDir()
+	file := filepath.Join(dir, "vaccines.json")
+
+	vaccineMD := copyMetadata(t, vaccinemd.MakeRepo().CovidVaccines())
+	writeMetadata(t, file, vaccineMD[:2])
+
+	repo := vaccinemd.MakeReloadableRepo()
+	require.NoError(t, repo.LoadPath(dir))
+	require.Equal(t, 2, len(repo.CovidVaccines()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	var wg sync.WaitGroup
+	wg.Add(1)
+
+	var mu sync.Mutex
+	watchErrors := make([]error, 0)
+	go func() {
+		defer wg.Done()
+		repo.Watch(ctx, dir, 10*time.Millisecond, func(err error) {
+			mu.Lock()
+			defer mu.Unlock()
+			watchErrors = append(watchErrors, err)
+		})
+	}()
+
+	//a bad version should be reported and ignored
+	bad := copyMetadata(t, vaccineMD)
+	bad[0].ID = ""
+	writeMetadata(t, file, bad)
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(watchErrors) > 0
+	}, time.Second, 10*time.Millisecond)
+	require.Equal(t, 2, len(repo.CovidVaccines()), "should keep previous version")
+
+	//a file that cannot be loaded should be reported once not every poll
+	require.NoError(t, os.WriteFile(file, []byte("{"), 0600))
+	require.Eventually(t, func() bool {
+		mu.Lock()
+		defer mu.Unlock()
+		return len(watchErrors) == 2
+	}, time.Second, 10*time.Millisecond)
+	time.Sleep(100 * time.Millisecond)
+	mu.Lock()
+	require.Len(t, watchErrors, 2, "should only report the load error once")
+	mu.Unlock()
+
+	writeMetadata(t, file, vaccineMD)
+	require.Eventually(t, func() bool {
+		return len(repo.CovidVaccines()) == len(vaccineMD)
+	}, time.Second, 10*time.Millisecond)
+
+	cancel()
+	wg.Wait()
+
+	t.Run("should not panic if the interval is not positive", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		require.NotPanics(t, func() { repo.Watch(ctx, dir, 0, nil) })
+		require.NotPanics(t, func() { repo.Watch(ctx, dir, -time.Second, nil) })
+	})
+}
+
+//-----------------
+//Helpers
+//------------------
+
+//copyMetadata deep copy so changes do not affect the shared repo
+func copyMetadata(t *testing.T, vaccineMD []*vaccinemd.CovidVaccineMetadata) []*vaccinemd.CovidVaccineMetadata {
+	b, err := json.Marshal(vaccineMD)
+	require.NoError(t, err)
+
+	result := make([]*vaccinemd.CovidVaccineMetadata, 0)
+	require.NoError(t, json.Unmarshal(b, &result))
+	return result
+}
+
+func writeMetadata(t *testing.T, file string, vaccineMD []*vaccinemd.CovidVaccineMetadata) {
+	b, err := json.Marshal(vaccineMD)
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(file, b, 0600))
+}
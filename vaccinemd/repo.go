@@ -1,80 +1,349 @@
 package vaccinemd
 
+import (
+	"sort"
+	"strings"
+	"time"
+)
+
 //
 // SEE https://www.cdc.gov/vaccines/programs/iis/COVID-19-related-codes.html
 //
 
-//Repo provides methods to find out vaccine info
+//Repo provides methods to find out vaccine info, implementations must be safe for concurrent use.
+//The returned metadata is shared so must not be modified. Methods returning several vaccines return
+//them in ID order so listings are stable.
+//
+//Metadata can have several versions with the same ID that are effective over different periods, the
+//repo only returns the versions effective now, or at the time passed to AsOf
 type Repo interface {
 
+	//FindVaccine return vaccine metadata if the passed in coding is a known vaccine product for any disease,
+	//the system and code are normalized so aliases such as the CVX OID are found
+	FindVaccine(system string, code string) *VaccineMetadata
+
+	//ResolveVaccine find the vaccine for a dose coded with one or more codings, possibly from different
+	//systems, using product codes first and then class codes. Reports ambiguity rather than guessing
+	ResolveVaccine(codings []Coding) *Resolution
+
+	//FindVaccineByID by id for any disease
+	FindVaccineByID(id string) *VaccineMetadata
+
+	//Vaccines returns all the known vaccines for all diseases
+	Vaccines() []*VaccineMetadata
+
+	//FindVaccinesForDisease returns the vaccines that protect against the disease, the disease can be
+	//any of the codes in VaccineMetadata TargetDiseases
+	FindVaccinesForDisease(disease Coding) []*VaccineMetadata
+
+	//FindTrustedVaccinesForRegionAndDisease find the vaccines for the disease trusted in the specified region,
+	//the region can be a named region or a jurisdiction expression, see Region Trust
+	FindTrustedVaccinesForRegionAndDisease(region Region, disease Coding) []*VaccineMetadata
+
+	//FindTrustedVaccines find the vaccines for any disease trusted in the specified region
+	FindTrustedVaccines(region Region) []*VaccineMetadata
+
+	//FindVaccinesByManufacturer find the vaccines made by the manufacturer, matched ignoring case against the
+	//vaccine's manufacturer name and the ID and names of its linked Manufacturer
+	FindVaccinesByManufacturer(manufacturer string) []*VaccineMetadata
+
+	//FindVaccinesByStatus find the vaccines with the CVX status
+	FindVaccinesByStatus(status CVSStatus) []*VaccineMetadata
+
+	//SearchVaccines find the vaccines whose display or proprietary name contains the text, ignoring case,
+	//empty text matches all vaccines
+	SearchVaccines(text string) []*VaccineMetadata
+
+	//MatchVaccineName find the vaccine a written product name refers to, for example "Pfizer" or "J&J" on a
+	//paper card, allowing for small spelling mistakes. Reports ambiguity rather than guessing
+	MatchVaccineName(name string) *NameMatch
+
+	//FindManufacturer return the manufacturer known by the coding, an MVX or EU organisation code
+	FindManufacturer(system string, code string) *Manufacturer
+
+	//FindManufacturerByID by id
+	FindManufacturerByID(id string) *Manufacturer
+
+	//Manufacturers returns all the known manufacturers
+	Manufacturers() []*Manufacturer
+
+	//
+	// COVID-19 methods, the same as the disease methods with DiseaseCOVID19
+	//
+
 	//FindCovidVaccine return vaccine metadata if the passed in coding is known CovidVaccine
-	FindCovidVaccine(system string, code string) *CovidVaccineMetadata
+	FindCovidVaccine(system string, code string) *VaccineMetadata
 
-	//FindTrustedVaccinesForRegion find for the specified region
-	FindTrustedVaccinesForRegion(region Region) []*CovidVaccineMetadata
+	//FindTrustedVaccinesForRegion find covid vaccines for the specified region, the region can be a named region
+	//such as RegionUSA or any jurisdictions, for example RegionAnyOf(JurisdictionEMA, JurisdictionWHOEUL)
+	FindTrustedVaccinesForRegion(region Region) []*VaccineMetadata
 
 	//CovidVaccines returns all the known covid vaccines
-	CovidVaccines() []*CovidVaccineMetadata
+	CovidVaccines() []*VaccineMetadata
 
 	//FindCovidVaccineByID by id
-	FindCovidVaccineByID(id string) *CovidVaccineMetadata
+	FindCovidVaccineByID(id string) *VaccineMetadata
+
+	//Version identifies the metadata, a hash of its contents so changes when the metadata changes
+	Version() string
+
+	//Snapshot returns a repo that will not change, use for the duration of a verification so all
+	//lookups see the same metadata even if the repo is reloaded
+	Snapshot() Repo
+
+	//AsOf returns a repo that answers with the metadata effective at the passed in time, so the rules
+	//applied at a past scan can be reproduced or upcoming rules checked
+	AsOf(at time.Time) Repo
 }
 
-//MakeRepo fixme in the future pass in the metadata config
+//MakeRepo make a repo from the built in metadata
 func MakeRepo() Repo {
+	return makeV1Repo(createVaccineMetadata())
+}
 
-	vaccineMD := createCovidVaccineMetadata()
+//MakeRepoFromMetadata make a repo from the passed in metadata, the metadata is validated first
+func MakeRepoFromMetadata(vaccineMD []*VaccineMetadata) (Repo, error) {
 
-	code2CodingMap := make(map[string]*CovidVaccineMetadata)
+	if err := ValidateMetadata(vaccineMD); err != nil {
+		return nil, err
+	}
+
+	return makeV1Repo(vaccineMD), nil
+}
 
-	for _, vmd := range vaccineMD {
+func makeV1Repo(vaccineMD []*VaccineMetadata) *v1Repo {
+
+	//version is of the metadata as passed in, so must be taken before sorting
+	version := metadataVersion(vaccineMD)
+
+	//sorted by ID so listings are stable, a stable sort keeps versions of the same ID in the order given
+	sorted := make([]*VaccineMetadata, len(vaccineMD))
+	copy(sorted, vaccineMD)
+	sort.SliceStable(sorted, func(i, j int) bool {
+		return sorted[i].ID < sorted[j].ID
+	})
+
+	//each ID and code can map to several versions of the same vaccine, generic codes also to several vaccines
+	id2VaccineMap := make(map[string][]*VaccineMetadata)
+	code2CodingMap := make(map[string][]*VaccineMetadata)
+	generic2CodingMap := make(map[string][]*VaccineMetadata)
+
+	for _, vmd := range sorted {
+
+		id2VaccineMap[vmd.ID] = append(id2VaccineMap[vmd.ID], vmd)
 
 		for _, code := range vmd.Codes {
 			//code is unique within system, start with code as more unique
-			key := code.System + "#" + string(code.Code)
-			code2CodingMap[key] = vmd
+			key := codingKey(code.System, code.Code)
+			code2CodingMap[key] = append(code2CodingMap[key], vmd)
+		}
+
+		for _, code := range vmd.GenericCodes {
+			key := codingKey(code.System, code.Code)
+			generic2CodingMap[key] = append(generic2CodingMap[key], vmd)
 		}
 	}
 
-	return &v1Repo{vaccineMD: vaccineMD, code2CodingMap: code2CodingMap}
+	return &v1Repo{
+		vaccineMD:         sorted,
+		id2VaccineMap:     id2VaccineMap,
+		code2CodingMap:    code2CodingMap,
+		generic2CodingMap: generic2CodingMap,
+		manufacturers:     builtInManufacturers,
+		version:           version,
+	}
 }
 
+//v1Repo is never modified once made so is safe for concurrent use without a mutex
 type v1Repo struct {
-	//fixme for now not mutex protected as all readonly
-	vaccineMD      []*CovidVaccineMetadata
-	code2CodingMap map[string]*CovidVaccineMetadata
+	vaccineMD         []*VaccineMetadata
+	id2VaccineMap     map[string][]*VaccineMetadata
+	code2CodingMap    map[string][]*VaccineMetadata
+	generic2CodingMap map[string][]*VaccineMetadata
+	manufacturers     *manufacturerRegistry
+	version           string
+
+	//asOf if set only metadata effective at this time is returned, otherwise metadata effective now
+	asOf *time.Time
+}
+
+func (vmi *v1Repo) at() time.Time {
+	if vmi.asOf != nil {
+		return *vmi.asOf
+	}
+	return time.Now()
+}
+
+//effective the version effective at the repo's time, nil if none
+func (vmi *v1Repo) effective(versions []*VaccineMetadata) *VaccineMetadata {
+	at := vmi.at()
+	for _, vmd := range versions {
+		if vmd.EffectiveAt(at) {
+			return vmd
+		}
+	}
+	return nil
+}
+
+//effectiveVaccines all the metadata effective at the repo's time
+func (vmi *v1Repo) effectiveVaccines() []*VaccineMetadata {
+	at := vmi.at()
+	result := make([]*VaccineMetadata, 0, len(vmi.vaccineMD))
+	for _, vmd := range vmi.vaccineMD {
+		if vmd.EffectiveAt(at) {
+			result = append(result, vmd)
+		}
+	}
+	return result
+}
+
+func (vmi *v1Repo) AsOf(at time.Time) Repo {
+	//shares the read only maps
+	asOf := *vmi
+	asOf.asOf = &at
+	return &asOf
 }
 
-func (vmi *v1Repo) FindCovidVaccineByID(id string) *CovidVaccineMetadata {
-	return vmi.code2CodingMap[id]
+func (vmi *v1Repo) Version() string {
+	return vmi.version
 }
 
-func (vmi *v1Repo) CovidVaccines() []*CovidVaccineMetadata {
-	return vmi.vaccineMD
+func (vmi *v1Repo) Snapshot() Repo {
+	return vmi
 }
 
-func (vmi *v1Repo) FindCovidVaccine(system string, code string) *CovidVaccineMetadata {
+func (vmi *v1Repo) FindVaccineByID(id string) *VaccineMetadata {
+	return vmi.effective(vmi.id2VaccineMap[id])
+}
+
+func (vmi *v1Repo) FindVaccine(system string, code string) *VaccineMetadata {
+
+	key := codingKey(system, code)
+	return vmi.effective(vmi.code2CodingMap[key])
+
+}
+
+func (vmi *v1Repo) ResolveVaccine(codings []Coding) *Resolution {
+	return vmi.resolve(codings)
+}
+
+func (vmi *v1Repo) Vaccines() []*VaccineMetadata {
+	//a new slice so callers cannot change the repo's slice
+	return vmi.effectiveVaccines()
+}
+
+func (vmi *v1Repo) FindVaccinesForDisease(disease Coding) []*VaccineMetadata {
+
+	return vmi.filter(func(md *VaccineMetadata) bool {
+		return md.TargetsDisease(disease)
+	})
+}
 
-	key := system + "#" + code
-	return vmi.code2CodingMap[key]
+func (vmi *v1Repo) FindTrustedVaccinesForRegionAndDisease(region Region, disease Coding) []*VaccineMetadata {
+
+	trusted := vmi.trustedInRegion(region)
+	return vmi.filter(func(md *VaccineMetadata) bool {
+		return md.TargetsDisease(disease) && trusted(md)
+	})
 
 }
 
-func (vmi *v1Repo) FindTrustedVaccinesForRegion(region Region) []*CovidVaccineMetadata {
+func (vmi *v1Repo) FindTrustedVaccines(region Region) []*VaccineMetadata {
+	return vmi.filter(vmi.trustedInRegion(region))
+}
 
-	result := make([]*CovidVaccineMetadata, 0)
-	for _, md := range vmi.vaccineMD {
-		switch region {
-		case RegionUSA:
-			{
-				if md.CVXStatus == CVSStatusActive {
-					result = append(result, md)
-				}
-			}
-			//fixme how to handle other regions
+func (vmi *v1Repo) FindVaccinesByManufacturer(manufacturer string) []*VaccineMetadata {
+	manufacturer = strings.TrimSpace(manufacturer)
+	return vmi.filter(func(md *VaccineMetadata) bool {
+		if strings.EqualFold(md.ManufacturerName, manufacturer) {
+			return true
+		}
+		m := vmi.FindManufacturerByID(md.ManufacturerID)
+		return m != nil && (strings.EqualFold(m.ID, manufacturer) || m.HasName(manufacturer))
+	})
+}
+
+func (vmi *v1Repo) MatchVaccineName(name string) *NameMatch {
+	return vmi.matchName(name)
+}
+
+func (vmi *v1Repo) FindManufacturer(system string, code string) *Manufacturer {
+	return vmi.manufacturers.code2Manufacturer[codingKey(system, code)]
+}
+
+func (vmi *v1Repo) FindManufacturerByID(id string) *Manufacturer {
+	return vmi.manufacturers.id2Manufacturer[id]
+}
+
+func (vmi *v1Repo) Manufacturers() []*Manufacturer {
+	//a new slice so callers cannot change the repo's slice
+	result := make([]*Manufacturer, len(vmi.manufacturers.manufacturers))
+	copy(result, vmi.manufacturers.manufacturers)
+	return result
+}
+
+func (vmi *v1Repo) FindVaccinesByStatus(status CVSStatus) []*VaccineMetadata {
+	return vmi.filter(func(md *VaccineMetadata) bool {
+		return md.CVXStatus == status
+	})
+}
+
+func (vmi *v1Repo) SearchVaccines(text string) []*VaccineMetadata {
+	text = strings.ToLower(strings.TrimSpace(text))
+	return vmi.filter(func(md *VaccineMetadata) bool {
+		return strings.Contains(strings.ToLower(md.DisplayName), text) ||
+			strings.Contains(strings.ToLower(md.SaleProprietaryName), text)
+	})
+}
+
+//filter the effective vaccines that match, in ID order
+func (vmi *v1Repo) filter(match func(md *VaccineMetadata) bool) []*VaccineMetadata {
+
+	result := make([]*VaccineMetadata, 0)
+	for _, md := range vmi.effectiveVaccines() {
+		if match(md) {
+			result = append(result, md)
 		}
 	}
 
 	return result
+}
+
+//trustedInRegion a filter for the vaccines approved by the region's jurisdictions at the repo's time,
+//an invalid region trusts no vaccines
+func (vmi *v1Repo) trustedInRegion(region Region) func(md *VaccineMetadata) bool {
 
+	trust, err := region.Trust()
+	if err != nil {
+		return func(md *VaccineMetadata) bool { return false }
+	}
+
+	at := vmi.at()
+	return func(md *VaccineMetadata) bool {
+		return trust.Trusts(md, at)
+	}
+}
+
+func (vmi *v1Repo) FindCovidVaccineByID(id string) *VaccineMetadata {
+	return covidOnly(vmi.FindVaccineByID(id))
+}
+
+func (vmi *v1Repo) CovidVaccines() []*VaccineMetadata {
+	return vmi.FindVaccinesForDisease(DiseaseCOVID19)
+}
+
+func (vmi *v1Repo) FindCovidVaccine(system string, code string) *VaccineMetadata {
+	return covidOnly(vmi.FindVaccine(system, code))
+}
+
+func (vmi *v1Repo) FindTrustedVaccinesForRegion(region Region) []*VaccineMetadata {
+	return vmi.FindTrustedVaccinesForRegionAndDisease(region, DiseaseCOVID19)
+}
+
+//covidOnly returns the vaccine if a covid vaccine, otherwise nil
+func covidOnly(vmd *VaccineMetadata) *VaccineMetadata {
+	if vmd == nil || !vmd.TargetsDisease(DiseaseCOVID19) {
+		return nil
+	}
+	return vmd
 }
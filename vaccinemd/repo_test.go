@@ -2,6 +2,7 @@ package vaccinemd_test
 
 import (
 	"testing"
+	"time"
 
 	"github.com/stretchr/testify/require"
 	"github.com/webshield-dev/dhc-common/vaccinemd"
@@ -35,7 +36,7 @@ func Test_FindVaccine(t *testing.T) {
 
 	repo := vaccinemd.MakeRepo()
 
-	require.Equal(t, 4, len(repo.CovidVaccines()), "should return all vaccines")
+	require.Equal(t, 7, len(repo.CovidVaccines()), "should return all vaccines")
 
 	for _, tc := range testCases {
 		t.Run(tc.name, func(t *testing.T) {
@@ -82,3 +83,205 @@ func Test_FindTrustedVaccine(t *testing.T) {
 	}
 
 }
+
+func Test_FindVaccineAsOf(t *testing.T) {
+
+	change := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	//janssen criteria changed to need two doses
+	before := &vaccinemd.CovidVaccineMetadata{
+		ID:             "janssen",
+		Codes:          []vaccinemd.Coding{{System: vaccinemd.CVXSystem, Code: "212"}},
+		TargetDiseases: []vaccinemd.Coding{vaccinemd.DiseaseCOVID19},
+		CVXStatus:      vaccinemd.CVSStatusActive,
+		Doses:          1,
+		EffectiveTo:    &change,
+	}
+	after := &vaccinemd.CovidVaccineMetadata{
+		ID:             "janssen",
+		Codes:          []vaccinemd.Coding{{System: vaccinemd.CVXSystem, Code: "212"}},
+		TargetDiseases: []vaccinemd.Coding{vaccinemd.DiseaseCOVID19},
+		CVXStatus:      vaccinemd.CVSStatusActive,
+		Doses:          2,
+		EffectiveFrom:  &change,
+	}
+
+	repo, err := vaccinemd.MakeRepoFromMetadata([]*vaccinemd.CovidVaccineMetadata{before, after})
+	require.NoError(t, err)
+
+	type testCase struct {
+		name          string
+		at            time.Time
+		expectedDoses int
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should find version before change",
+			at:            change.Add(-time.Second),
+			expectedDoses: 1,
+		},
+		{
+			name:          "should find version from change",
+			at:            change,
+			expectedDoses: 2,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			asOf := repo.AsOf(tc.at)
+			require.Equal(t, tc.expectedDoses, asOf.FindCovidVaccine(vaccinemd.CVXSystem, "212").Doses)
+			require.Equal(t, 1, len(asOf.CovidVaccines()), "should only return one version")
+			require.Equal(t, 1, len(asOf.FindTrustedVaccinesForRegion(vaccinemd.RegionUSA)))
+			require.Equal(t, repo.Version(), asOf.Version())
+		})
+	}
+
+	t.Run("should reject overlapping versions", func(t *testing.T) {
+		overlapping := *after
+		overlapping.EffectiveFrom = nil
+		_, err := vaccinemd.MakeRepoFromMetadata([]*vaccinemd.CovidVaccineMetadata{before, &overlapping})
+		require.Error(t, err)
+	})
+}
+
+func Test_FindVaccinesForDisease(t *testing.T) {
+
+	type testCase struct {
+		name                string
+		disease             vaccinemd.Coding
+		expectedResultCount int
+		expectedTrusted     int
+	}
+
+	testCases := []testCase{
+		{
+			name:                "should find covid by snomed",
+			disease:             vaccinemd.DiseaseCOVID19,
+			expectedResultCount: 7,
+			expectedTrusted:     3,
+		},
+		{
+			name:                "should find covid by icd-10",
+			disease:             vaccinemd.Coding{System: vaccinemd.ICD10System, Code: "U07.1"},
+			expectedResultCount: 7,
+			expectedTrusted:     3,
+		},
+		{
+			name:                "should find hepatitis b",
+			disease:             vaccinemd.DiseaseHepatitisB,
+			expectedResultCount: 2,
+			expectedTrusted:     2,
+		},
+		{
+			name:                "should find mmr by rubella",
+			disease:             vaccinemd.DiseaseRubella,
+			expectedResultCount: 1,
+			expectedTrusted:     1,
+		},
+		{
+			name:                "should not find unknown disease",
+			disease:             vaccinemd.Coding{System: vaccinemd.SNOMEDSystem, Code: "1"},
+			expectedResultCount: 0,
+			expectedTrusted:     0,
+		},
+	}
+
+	repo := vaccinemd.MakeRepo()
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			require.Equal(t, tc.expectedResultCount, len(repo.FindVaccinesForDisease(tc.disease)))
+			require.Equal(t, tc.expectedTrusted, len(repo.FindTrustedVaccinesForRegionAndDisease(vaccinemd.RegionUSA, tc.disease)))
+		})
+	}
+
+	t.Run("covid methods should only return covid vaccines", func(t *testing.T) {
+		require.NotNil(t, repo.FindVaccine(vaccinemd.CVXSystem, "37"), "yellow fever should be known")
+		require.Nil(t, repo.FindCovidVaccine(vaccinemd.CVXSystem, "37"), "yellow fever is not covid")
+		require.Greater(t, len(repo.Vaccines()), len(repo.CovidVaccines()))
+	})
+}
+
+func Test_QueryVaccines(t *testing.T) {
+
+	repo := vaccinemd.MakeRepo()
+
+	t.Run("should list vaccines in id order", func(t *testing.T) {
+		vaccines := repo.Vaccines()
+		for i := 1; i < len(vaccines); i++ {
+			require.Less(t, vaccines[i-1].ID, vaccines[i].ID)
+		}
+	})
+
+	t.Run("should find by manufacturer ignoring case", func(t *testing.T) {
+		vaccines := repo.FindVaccinesByManufacturer("moderna us, inc")
+		require.Equal(t, 1, len(vaccines))
+		require.Equal(t, "Moderna", vaccines[0].DisplayName)
+		require.Equal(t, 0, len(repo.FindVaccinesByManufacturer("bogus")))
+	})
+
+	t.Run("should find by status", func(t *testing.T) {
+		vaccines := repo.FindVaccinesByStatus(vaccinemd.CVSStatusNonUS)
+		require.Equal(t, 4, len(vaccines))
+		require.Equal(t, "AstraZeneca", vaccines[0].DisplayName)
+		require.Equal(t, len(repo.FindTrustedVaccines(vaccinemd.RegionUSA)), len(repo.FindVaccinesByStatus(vaccinemd.CVSStatusActive)))
+	})
+
+	t.Run("should search display and proprietary names", func(t *testing.T) {
+		require.Equal(t, 1, len(repo.SearchVaccines("jynneos")))
+		require.Equal(t, 2, len(repo.SearchVaccines("hepatitis b")))
+		require.Equal(t, len(repo.Vaccines()), len(repo.SearchVaccines("")))
+	})
+
+	t.Run("should find by id when ids are not codes", func(t *testing.T) {
+		vaccineMD := copyMetadata(t, repo.CovidVaccines())
+		for _, vmd := range vaccineMD {
+			vmd.ID = "vaccine-" + vmd.DisplayName
+		}
+
+		renamed, err := vaccinemd.MakeRepoFromMetadata(vaccineMD)
+		require.NoError(t, err)
+
+		vmd := renamed.FindCovidVaccineByID("vaccine-Pfizer")
+		require.NotNil(t, vmd)
+		require.Equal(t, "Pfizer", vmd.DisplayName)
+		require.Nil(t, renamed.FindVaccineByID(vaccinemd.CVXSystem+"#208"))
+	})
+}
+
+func Test_FindManufacturer(t *testing.T) {
+
+	repo := vaccinemd.MakeRepo()
+
+	t.Run("should link every vaccine manufacturer", func(t *testing.T) {
+		for _, vmd := range repo.Vaccines() {
+			if vmd.ManufacturerID != "" {
+				require.NotNil(t, repo.FindManufacturerByID(vmd.ManufacturerID), vmd.ID)
+			}
+		}
+	})
+
+	t.Run("should find by mvx and eu organisation code", func(t *testing.T) {
+		byMVX := repo.FindManufacturer("urn:oid:2.16.840.1.113883.12.227", "mod")
+		require.NotNil(t, byMVX)
+		require.Equal(t, "moderna", byMVX.ID)
+
+		byOrg := repo.FindManufacturer(vaccinemd.EUOrgSystem, "ORG-100031184")
+		require.Equal(t, byMVX, byOrg)
+
+		require.Nil(t, repo.FindManufacturer(vaccinemd.MVXSystem, "bogus"))
+	})
+
+	t.Run("should find vaccines by any manufacturer name", func(t *testing.T) {
+		require.Equal(t, 1, len(repo.FindVaccinesByManufacturer("Biontech Manufacturing GmbH")))
+		require.Equal(t, 1, len(repo.FindVaccinesByManufacturer("janssen")))
+	})
+
+	t.Run("should reject unknown manufacturer", func(t *testing.T) {
+		vaccineMD := copyMetadata(t, repo.CovidVaccines())
+		vaccineMD[0].ManufacturerID = "bogus"
+		require.Error(t, vaccinemd.ValidateMetadata(vaccineMD))
+	})
+}
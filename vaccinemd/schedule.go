@@ -0,0 +1,110 @@
+package vaccinemd
+
+//
+// A vaccine's Doses and day criteria are its default schedule, for adults without conditions. Pediatric and
+// condition specific schedules, for example 3 doses for children 6 months to 4 years or an additional primary
+// dose when immunocompromised, are listed in VaccineMetadata Schedules.
+// SEE https://www.cdc.gov/vaccines/covid-19/clinical-considerations/interim-considerations-us.html
+//
+
+//PatientCondition a patient condition that changes the schedule
+type PatientCondition string
+
+const (
+	//PatientConditionImmunocompromised moderately or severely immunocompromised
+	PatientConditionImmunocompromised PatientCondition = "immunocompromised"
+)
+
+//DefaultScheduleName name of the schedule made from the VaccineMetadata Doses and day criteria
+const DefaultScheduleName = "default"
+
+//Schedule the doses required for an age band and optional patient condition
+type Schedule struct {
+
+	//Name identifies the schedule, unique within the vaccine
+	Name string `json:"name"`
+
+	//MinAgeMonths age the schedule starts to apply at, inclusive
+	MinAgeMonths int `json:"min_age_months,omitempty"`
+
+	//MaxAgeMonths age the schedule stops applying at, exclusive, 0 if no upper limit
+	MaxAgeMonths int `json:"max_age_months,omitempty"`
+
+	//Condition the schedule only applies to patients with the condition, empty if applies to all
+	Condition PatientCondition `json:"condition,omitempty"`
+
+	//Doses number of doses required
+	Doses int `json:"doses"`
+
+	//DaysSinceLastDoseCriteria days after the last dose before protected
+	DaysSinceLastDoseCriteria int `json:"days_since_last_dose_criteria"`
+
+	//DaysBetweenDoesCriteriaBegin fewest days between the doses of the schedule, 0 if no minimum
+	DaysBetweenDoesCriteriaBegin int `json:"days_between_does_criteria_begin"`
+
+	//DaysBetweenDoesCriteriaEnd most days between the doses of the schedule, 0 if no maximum
+	DaysBetweenDoesCriteriaEnd int `json:"days_between_does_criteria_end"`
+}
+
+//HasAgeBand true if the schedule is limited to an age band
+func (s *Schedule) HasAgeBand() bool {
+	return s.MinAgeMonths != 0 || s.MaxAgeMonths != 0
+}
+
+//AppliesToAge true if the age in months is in the schedule's age band
+func (s *Schedule) AppliesToAge(ageMonths int) bool {
+
+	if ageMonths < s.MinAgeMonths {
+		return false
+	}
+
+	if s.MaxAgeMonths != 0 && ageMonths >= s.MaxAgeMonths {
+		return false
+	}
+
+	return true
+}
+
+//DefaultSchedule the schedule made from the Doses and day criteria
+func (vmd *VaccineMetadata) DefaultSchedule() *Schedule {
+	return &Schedule{
+		Name:                         DefaultScheduleName,
+		Doses:                        vmd.Doses,
+		DaysSinceLastDoseCriteria:    vmd.DaysSinceLastDoseCriteria,
+		DaysBetweenDoesCriteriaBegin: vmd.DaysBetweenDoesCriteriaBegin,
+		DaysBetweenDoesCriteriaEnd:   vmd.DaysBetweenDoesCriteriaEnd,
+	}
+}
+
+//ScheduleFor the schedule for a patient of the age in months with the conditions, an age less than 0
+//means the age is not known so only schedules without an age band can apply. A schedule for one of the
+//patient's conditions is preferred, then one for the age, then the default schedule
+func (vmd *VaccineMetadata) ScheduleFor(ageMonths int, conditions []PatientCondition) *Schedule {
+
+	var forAge *Schedule
+	for _, schedule := range vmd.Schedules {
+
+		if schedule.HasAgeBand() && (ageMonths < 0 || !schedule.AppliesToAge(ageMonths)) {
+			continue
+		}
+
+		if schedule.Condition == "" {
+			if forAge == nil {
+				forAge = schedule
+			}
+			continue
+		}
+
+		for _, condition := range conditions {
+			if condition == schedule.Condition {
+				return schedule
+			}
+		}
+	}
+
+	if forAge != nil {
+		return forAge
+	}
+
+	return vmd.DefaultSchedule()
+}
@@ -0,0 +1,83 @@
+package vaccinemd_test
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+func Test_ScheduleFor(t *testing.T) {
+
+	type testCase struct {
+		name             string
+		ageMonths        int
+		conditions       []vaccinemd.PatientCondition
+		expectedSchedule string
+		expectedDoses    int
+	}
+
+	testCases := []testCase{
+		{
+			name:             "should use default for adult",
+			ageMonths:        40 * 12,
+			expectedSchedule: vaccinemd.DefaultScheduleName,
+			expectedDoses:    2,
+		},
+		{
+			name:             "should use default if age not known",
+			ageMonths:        -1,
+			expectedSchedule: vaccinemd.DefaultScheduleName,
+			expectedDoses:    2,
+		},
+		{
+			name:             "should use age band at lower bound",
+			ageMonths:        6,
+			expectedSchedule: "pediatric-6m-4y",
+			expectedDoses:    3,
+		},
+		{
+			name:             "should not use age band at upper bound",
+			ageMonths:        60,
+			expectedSchedule: vaccinemd.DefaultScheduleName,
+			expectedDoses:    2,
+		},
+		{
+			name:             "should prefer condition schedule",
+			ageMonths:        40 * 12,
+			conditions:       []vaccinemd.PatientCondition{vaccinemd.PatientConditionImmunocompromised},
+			expectedSchedule: "immunocompromised",
+			expectedDoses:    3,
+		},
+		{
+			name:             "should not use condition schedule outside its age band",
+			ageMonths:        12,
+			conditions:       []vaccinemd.PatientCondition{vaccinemd.PatientConditionImmunocompromised},
+			expectedSchedule: "pediatric-6m-4y",
+			expectedDoses:    3,
+		},
+	}
+
+	vmd := vaccinemd.MakeRepo().FindVaccine(vaccinemd.CVXSystem, "208")
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			schedule := vmd.ScheduleFor(tc.ageMonths, tc.conditions)
+			require.Equal(t, tc.expectedSchedule, schedule.Name)
+			require.Equal(t, tc.expectedDoses, schedule.Doses)
+		})
+	}
+
+	t.Run("should reject invalid schedules", func(t *testing.T) {
+		for _, schedule := range []*vaccinemd.Schedule{
+			{Name: "", Doses: 1},
+			{Name: vaccinemd.DefaultScheduleName, Doses: 1},
+			{Name: "none", Doses: 0},
+			{Name: "band", Doses: 1, MinAgeMonths: 60, MaxAgeMonths: 6},
+			{Name: "negative", Doses: 1, MinAgeMonths: -1},
+		} {
+			vaccineMD := copyMetadata(t, vaccinemd.MakeRepo().CovidVaccines())
+			vaccineMD[0].Schedules = []*vaccinemd.Schedule{schedule}
+			require.Error(t, vaccinemd.ValidateMetadata(vaccineMD), schedule.Name)
+		}
+	})
+}
@@ -0,0 +1,234 @@
+package verification
+
+import (
+	"fmt"
+
+	"github.com/webshield-dev/dhc-common/pdm"
+)
+
+//
+// The State says whether a card can be accepted, the assurance says how much its origin can be relied on. A paper
+// card with perfect data and a signed card from a trusted issuer can both meet the immunization criteria, the
+// assurance level and score tell them apart so a policy can set a minimum, see Policy MinimumAssurance.
+//
+
+//AssuranceLevel how much the card's origin can be relied on, independent of whether the immunization
+//criteria were met. Levels are ordered, see AtLeast
+type AssuranceLevel string
+
+const (
+
+	//AssuranceLevelUnknown the card's origin could not be established, for example the signature was not checked
+	AssuranceLevelUnknown AssuranceLevel = "unknown"
+
+	//AssuranceLevelSelfAttested the holder entered the record themselves
+	AssuranceLevelSelfAttested AssuranceLevel = "self-attested"
+
+	//AssuranceLevelPaper the record was read from a paper card by the verifier
+	AssuranceLevelPaper AssuranceLevel = "paper"
+
+	//AssuranceLevelDigitallySignedUnknownIssuer the signature is valid but the issuer is not trusted
+	AssuranceLevelDigitallySignedUnknownIssuer AssuranceLevel = "digitally-signed-unknown-issuer"
+
+	//AssuranceLevelTrustedIssuer the signature is valid and the issuer is trusted
+	AssuranceLevelTrustedIssuer AssuranceLevel = "trusted-issuer"
+
+	//AssuranceLevelIdentityMatched the signature is valid, the issuer is trusted and the verifier matched the
+	//holder's identity document to the card
+	AssuranceLevelIdentityMatched AssuranceLevel = "identity-matched"
+)
+
+//assuranceLevelRanks lowest first
+var assuranceLevelRanks = map[AssuranceLevel]int{
+	AssuranceLevelUnknown:                      0,
+	AssuranceLevelSelfAttested:                 1,
+	AssuranceLevelPaper:                        2,
+	AssuranceLevelDigitallySignedUnknownIssuer: 3,
+	AssuranceLevelTrustedIssuer:                4,
+	AssuranceLevelIdentityMatched:              5,
+}
+
+//Valid true if a known level
+func (l AssuranceLevel) Valid() bool {
+	_, ok := assuranceLevelRanks[l]
+	return ok
+}
+
+//AtLeast true if this level is the same as or higher than the passed in level, an unknown level is lowest
+func (l AssuranceLevel) AtLeast(minimum AssuranceLevel) bool {
+	return assuranceLevelRanks[l] >= assuranceLevelRanks[minimum]
+}
+
+//AssuranceFactorCode identifies something that contributed to the assurance score
+type AssuranceFactorCode string
+
+const (
+	//AssuranceFactorSignatureValid the card's signature is valid
+	AssuranceFactorSignatureValid AssuranceFactorCode = "signature-valid"
+
+	//AssuranceFactorSignatureInvalid the card's signature is invalid so nothing on it can be relied on
+	AssuranceFactorSignatureInvalid AssuranceFactorCode = "signature-invalid"
+
+	//AssuranceFactorIssuerTrusted the issuer is trusted
+	AssuranceFactorIssuerTrusted AssuranceFactorCode = "issuer-trusted"
+
+	//AssuranceFactorIdentityMatched the holder's identity document matched the card
+	AssuranceFactorIdentityMatched AssuranceFactorCode = "identity-matched"
+
+	//AssuranceFactorPaperCard the verifier read the record from a paper card
+	AssuranceFactorPaperCard AssuranceFactorCode = "paper-card"
+
+	//AssuranceFactorSelfAttested the holder entered the record
+	AssuranceFactorSelfAttested AssuranceFactorCode = "self-attested"
+
+	//AssuranceFactorCleanData the doses had no data quality warnings
+	AssuranceFactorCleanData AssuranceFactorCode = "clean-data"
+
+	//AssuranceFactorDataWarnings the doses had data quality warnings
+	AssuranceFactorDataWarnings AssuranceFactorCode = "data-warnings"
+
+	//AssuranceFactorCounterfeitLot a dose's lot has been reported as counterfeit
+	AssuranceFactorCounterfeitLot AssuranceFactorCode = "counterfeit-lot"
+
+	//AssuranceFactorExpired the card has expired
+	AssuranceFactorExpired AssuranceFactorCode = "expired"
+)
+
+//the points each factor contributes, a signed card from a trusted issuer with clean data whose holder's identity
+//was checked scores 100
+const (
+	signatureValidPoints   = 40
+	issuerTrustedPoints    = 30
+	identityMatchedPoints  = 20
+	paperCardPoints        = 25
+	selfAttestedPoints     = 10
+	cleanDataPoints        = 10
+	dataWarningPoints      = -5
+	maxDataWarningPoints   = -20
+	counterfeitLotPoints   = -50
+	expiredPoints          = -10
+	signatureInvalidPoints = -100
+	maxAssuranceScore      = 100
+)
+
+//AssuranceFactor something that contributed to the assurance score
+type AssuranceFactor struct {
+
+	//Code identifies the factor
+	Code AssuranceFactorCode `json:"code"`
+
+	//Points added to the score, negative if it reduced the score
+	Points int `json:"points"`
+}
+
+//calcAssurance the level only depends on the card's origin, the score also takes account of the data
+func (e *v1Processor) calcAssurance() {
+
+	results := e.results
+	switch {
+	case results.CardStructure.IsSelfAttested:
+		results.AssuranceLevel = AssuranceLevelSelfAttested
+	case results.CardStructure.IsPaperCard:
+		results.AssuranceLevel = AssuranceLevelPaper
+	case !e.CardStructureVerified():
+		results.AssuranceLevel = AssuranceLevelUnknown
+	case !e.IssuerVerified():
+		results.AssuranceLevel = AssuranceLevelDigitallySignedUnknownIssuer
+	case !results.IdentityMatched:
+		results.AssuranceLevel = AssuranceLevelTrustedIssuer
+	default:
+		results.AssuranceLevel = AssuranceLevelIdentityMatched
+	}
+
+	factors := make([]*AssuranceFactor, 0)
+	add := func(code AssuranceFactorCode, points int) {
+		factors = append(factors, &AssuranceFactor{Code: code, Points: points})
+	}
+
+	switch {
+	case results.CardStructure.IsSelfAttested:
+		add(AssuranceFactorSelfAttested, selfAttestedPoints)
+	case results.CardStructure.IsPaperCard:
+		add(AssuranceFactorPaperCard, paperCardPoints)
+	case e.CardCorrupted():
+		add(AssuranceFactorSignatureInvalid, signatureInvalidPoints)
+	case e.CardStructureVerified():
+		add(AssuranceFactorSignatureValid, signatureValidPoints)
+		if e.IssuerVerified() {
+			add(AssuranceFactorIssuerTrusted, issuerTrustedPoints)
+		}
+	}
+
+	if results.IdentityMatched {
+		add(AssuranceFactorIdentityMatched, identityMatchedPoints)
+	}
+
+	if results.CardStructure.Expired {
+		add(AssuranceFactorExpired, expiredPoints)
+	}
+
+	//the data only counts if the doses were checked
+	if results.Immunization.VerificationPerformed {
+
+		warnings := 0
+		for _, warning := range results.Immunization.Warnings {
+			if warning.Code != pdm.WarningCodeCounterfeitLot {
+				warnings++
+			}
+		}
+
+		switch {
+		case warnings == 0 && !results.Immunization.CounterfeitLot:
+			add(AssuranceFactorCleanData, cleanDataPoints)
+		case warnings > 0:
+			add(AssuranceFactorDataWarnings, maxInt(warnings*dataWarningPoints, maxDataWarningPoints))
+		}
+
+		if results.Immunization.CounterfeitLot {
+			add(AssuranceFactorCounterfeitLot, counterfeitLotPoints)
+		}
+	}
+
+	score := 0
+	for _, factor := range factors {
+		score += factor.Points
+	}
+
+	results.AssuranceScore = minInt(maxInt(score, 0), maxAssuranceScore)
+	results.AssuranceFactors = factors
+}
+
+//validateMinimumAssurance the policy's minimum level must be known and score between 0 and 100
+func validateMinimumAssurance(policy *Policy) error {
+
+	if policy.MinimumAssurance != "" && !policy.MinimumAssurance.Valid() {
+		return fmt.Errorf("error new verifier policy id=%s version=%s unknown minimum assurance got=%s",
+			policy.ID, policy.Version, policy.MinimumAssurance)
+	}
+
+	if policy.MinimumAssuranceScore < 0 || policy.MinimumAssuranceScore > maxAssuranceScore {
+		return fmt.Errorf("error new verifier policy id=%s version=%s minimum assurance score must be 0 to %d got=%d",
+			policy.ID, policy.Version, maxAssuranceScore, policy.MinimumAssuranceScore)
+	}
+
+	return nil
+}
+
+//MeetsMinimumAssurance true if the results meet the policy's minimum assurance level and score
+func (p *Policy) MeetsMinimumAssurance(results *CardVerificationResults) bool {
+	return results.AssuranceLevel.AtLeast(p.MinimumAssurance) && results.AssuranceScore >= p.MinimumAssuranceScore
+}
+
+func minInt(a int, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func maxInt(a int, b int) int {
+	if a > b {
+		return a
+	}
+	return b
+}
@@ -0,0 +1,208 @@
+package verification_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Assurance(t *testing.T) {
+
+	type testCase struct {
+		name            string
+		card            *verification.Card
+		signature       *verification.SignatureResult
+		expectedLevel   verification.AssuranceLevel
+		expectedScore   int
+		expectedFactors []verification.AssuranceFactorCode
+	}
+
+	goodSignature := &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}
+
+	paperRecord := func(selfAttested bool) *verification.Card {
+		return &verification.Card{PaperRecord: &pdm.PaperRecord{
+			SelfAttested: selfAttested,
+			Doses: []*pdm.PaperDose{
+				{ProductName: "Moderna", LotNumber: "025J20A", Date: "2021-03-16"},
+				{ProductName: "Moderna", LotNumber: "030A21A", Date: "2021-04-13"},
+			},
+		}}
+	}
+
+	testCases := []testCase{
+		{
+			name: "should be identity matched",
+			card: func() *verification.Card {
+				card := makeTestCard(testIssuer)
+				card.IdentityMatched = true
+				return card
+			}(),
+			signature:     goodSignature,
+			expectedLevel: verification.AssuranceLevelIdentityMatched,
+			expectedScore: 100,
+			expectedFactors: []verification.AssuranceFactorCode{
+				verification.AssuranceFactorSignatureValid,
+				verification.AssuranceFactorIssuerTrusted,
+				verification.AssuranceFactorIdentityMatched,
+				verification.AssuranceFactorCleanData,
+			},
+		},
+		{
+			name:          "should be trusted issuer",
+			card:          makeTestCard(testIssuer),
+			signature:     goodSignature,
+			expectedLevel: verification.AssuranceLevelTrustedIssuer,
+			expectedScore: 80,
+			expectedFactors: []verification.AssuranceFactorCode{
+				verification.AssuranceFactorSignatureValid,
+				verification.AssuranceFactorIssuerTrusted,
+				verification.AssuranceFactorCleanData,
+			},
+		},
+		{
+			name:          "should be digitally signed unknown issuer",
+			card:          makeTestCard("https://unknown.example.com"),
+			signature:     goodSignature,
+			expectedLevel: verification.AssuranceLevelDigitallySignedUnknownIssuer,
+			expectedScore: 50,
+			expectedFactors: []verification.AssuranceFactorCode{
+				verification.AssuranceFactorSignatureValid,
+				verification.AssuranceFactorCleanData,
+			},
+		},
+		{
+			name:          "should score zero if corrupt",
+			card:          makeTestCard(testIssuer),
+			signature:     &verification.SignatureResult{Checked: true, FetchedKey: true},
+			expectedLevel: verification.AssuranceLevelUnknown,
+			expectedScore: 0,
+			expectedFactors: []verification.AssuranceFactorCode{
+				verification.AssuranceFactorSignatureInvalid,
+				verification.AssuranceFactorCleanData,
+			},
+		},
+		{
+			name:          "should be paper",
+			card:          paperRecord(false),
+			expectedLevel: verification.AssuranceLevelPaper,
+			expectedScore: 35,
+			expectedFactors: []verification.AssuranceFactorCode{
+				verification.AssuranceFactorPaperCard,
+				verification.AssuranceFactorCleanData,
+			},
+		},
+		{
+			name: "should reduce score for data warnings",
+			card: func() *verification.Card {
+				card := paperRecord(false)
+				card.PaperRecord.Doses[0].LotNumber = ""
+				card.PaperRecord.Doses[1].LotNumber = ""
+				return card
+			}(),
+			expectedLevel: verification.AssuranceLevelPaper,
+			expectedScore: 15,
+			expectedFactors: []verification.AssuranceFactorCode{
+				verification.AssuranceFactorPaperCard,
+				verification.AssuranceFactorDataWarnings,
+			},
+		},
+		{
+			name:          "should be self attested",
+			card:          paperRecord(true),
+			expectedLevel: verification.AssuranceLevelSelfAttested,
+			expectedScore: 20,
+			expectedFactors: []verification.AssuranceFactorCode{
+				verification.AssuranceFactorSelfAttested,
+				verification.AssuranceFactorCleanData,
+			},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+				Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+				SignatureVerifier: &testSignatureVerifier{result: tc.signature},
+				IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+			})
+			require.NoError(t, err)
+
+			results, err := verifier.Verify(context.Background(), tc.card)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedLevel, results.AssuranceLevel)
+			require.Equal(t, tc.expectedScore, results.AssuranceScore)
+
+			codes := make([]verification.AssuranceFactorCode, 0)
+			for _, factor := range results.AssuranceFactors {
+				codes = append(codes, factor.Code)
+			}
+			require.Equal(t, tc.expectedFactors, codes)
+		})
+	}
+}
+
+func Test_MinimumAssurance(t *testing.T) {
+
+	type testCase struct {
+		name        string
+		policy      *verification.Policy
+		expectedMet bool
+	}
+
+	testCases := []testCase{
+		{
+			name:        "should meet no minimum",
+			policy:      &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+			expectedMet: true,
+		},
+		{
+			name: "should meet paper minimum",
+			policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA,
+				MinimumAssurance: verification.AssuranceLevelPaper},
+			expectedMet: true,
+		},
+		{
+			name: "should not meet trusted issuer minimum",
+			policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA,
+				MinimumAssurance: verification.AssuranceLevelTrustedIssuer},
+		},
+		{
+			name: "should not meet score minimum",
+			policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA,
+				MinimumAssuranceScore: 50},
+		},
+	}
+
+	card := makeTestCard("")
+	card.IsPaperCard = true
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			verifier, err := verification.NewVerifier(&verification.VerifierConfig{Policy: tc.policy})
+			require.NoError(t, err)
+
+			results, err := verifier.Verify(context.Background(), card)
+			require.NoError(t, err)
+			require.Equal(t, verification.CardVerificationStatePaperCard, results.State, "state is not changed")
+			require.Equal(t, tc.expectedMet, results.MinimumAssuranceMet)
+		})
+	}
+
+	t.Run("should reject unknown minimum", func(t *testing.T) {
+		_, err := verification.NewVerifier(&verification.VerifierConfig{
+			Policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA, MinimumAssurance: "bogus"},
+		})
+		require.Error(t, err)
+
+		_, err = verification.NewVerifier(&verification.VerifierConfig{
+			Policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA, MinimumAssuranceScore: 101},
+		})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,251 @@
+package verification
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"fmt"
+	"time"
+)
+
+//
+// An audit trail records how a decision was reached, each check in the order it is made with its outcome, so a
+// decision can be explained and reproduced later with VerifyAt. A Verifier with an AuditSink writes a trail for
+// every verification, PII is redacted unless the sink is configured to keep it, see VerifierConfig.
+//
+
+//AuditOutcome the outcome of a check
+type AuditOutcome string
+
+const (
+	//AuditOutcomePassed the check passed
+	AuditOutcomePassed AuditOutcome = "passed"
+
+	//AuditOutcomeFailed the check failed
+	AuditOutcomeFailed AuditOutcome = "failed"
+
+	//AuditOutcomeNotChecked the check was not made, for example a paper card has no signature
+	AuditOutcomeNotChecked AuditOutcome = "not-checked"
+
+	//AuditOutcomeWarning the check found issues to report that do not change the state, for example dose
+	//data quality warnings
+	AuditOutcomeWarning AuditOutcome = "warning"
+)
+
+//the rule IDs of the checks, in the order a Verifier makes them
+const (
+	AuditRuleSignature         = "card-signature"
+	AuditRuleExpiry            = "card-expiry"
+	AuditRuleNotBefore         = "card-not-before"
+	AuditRuleIssuer            = "issuer-trusted"
+	AuditRuleDoseWarnings      = "dose-warnings"
+	AuditRuleCounterfeitLot    = "counterfeit-lot"
+	AuditRuleVaccineKnown      = "vaccine-known"
+	AuditRuleVaccineTrusted    = "vaccine-trusted"
+	AuditRuleDosesRequired     = "doses-required"
+	AuditRuleDaysBetweenDoses  = "days-between-doses"
+	AuditRuleDaysSinceLastDose = "days-since-last-dose"
+	AuditRuleState             = "state"
+)
+
+//immunizationAuditRules the immunization checks in the order they are made, a check that is not reached
+//because an earlier one failed is recorded as not checked
+var immunizationAuditRules = []string{AuditRuleDoseWarnings, AuditRuleCounterfeitLot, AuditRuleVaccineKnown,
+	AuditRuleVaccineTrusted, AuditRuleDosesRequired, AuditRuleDaysBetweenDoses, AuditRuleDaysSinceLastDose}
+
+//AuditEvent a check that was made
+type AuditEvent struct {
+
+	//Sequence the order of the check in the trail starting at 1
+	Sequence int `json:"seq"`
+
+	//Time when the check was made, the rules are applied as of the trail's VerifiedAt
+	Time time.Time `json:"time"`
+
+	//Rule identifies the check, see AuditRuleSignature etc
+	Rule string `json:"rule"`
+
+	//Outcome of the check
+	Outcome AuditOutcome `json:"outcome"`
+
+	//Input a summary of what was checked, never contains PII
+	Input string `json:"input,omitempty"`
+}
+
+//AuditInput a summary of the card verified
+type AuditInput struct {
+
+	//Issuer who issued the card, empty for paper cards
+	Issuer string `json:"issuer,omitempty"`
+
+	//Format digital, paper or self-attested
+	Format string `json:"format"`
+
+	//Doses the number of doses on the card
+	Doses int `json:"doses"`
+
+	//Card the card, redacted unless the sink keeps PII, see RedactCard
+	Card *Card `json:"card,omitempty"`
+}
+
+//AuditTrail the evidence for a verification decision
+type AuditTrail struct {
+
+	//ID unique for each verification
+	ID string `json:"id"`
+
+	//RecordedAt when the trail was written
+	RecordedAt time.Time `json:"recorded_at"`
+
+	//VerifiedAt the time the rules were applied at
+	VerifiedAt time.Time `json:"verified_at"`
+
+	//PolicyID the policy applied
+	PolicyID string `json:"policy_id"`
+
+	//PolicyVersion the version of the policy applied
+	PolicyVersion string `json:"policy_version,omitempty"`
+
+	//MetadataVersion the version of the vaccine metadata used
+	MetadataVersion string `json:"metadata_version"`
+
+	//LotsVersion the version of the lot registry used, empty if lots were not checked
+	LotsVersion string `json:"lots_version,omitempty"`
+
+	//CardHash identifies the card, see HashCard
+	CardHash string `json:"card_hash"`
+
+	//State the decision
+	State CardVerificationState `json:"state"`
+
+	//AssuranceLevel the assurance level of the card
+	AssuranceLevel AssuranceLevel `json:"assurance_level"`
+
+	//Input the card verified
+	Input *AuditInput `json:"input"`
+
+	//Events the checks made in order
+	Events []*AuditEvent `json:"events"`
+}
+
+//AuditSink receives the audit trail of each verification, must be safe for concurrent use
+type AuditSink interface {
+	WriteAuditTrail(ctx context.Context, trail *AuditTrail) error
+}
+
+func (e *v1Processor) RecordCheck(rule string, outcome AuditOutcome, input string) {
+	e.events = appendAuditEvent(e.events, rule, outcome, input)
+}
+
+//recordSkippedChecks record the rules that have not been checked yet as not checked, in order
+func (e *v1Processor) recordSkippedChecks(rules []string, input string) {
+
+	checked := make(map[string]bool)
+	for _, event := range e.events {
+		checked[event.Rule] = true
+	}
+
+	for _, rule := range rules {
+		if !checked[rule] {
+			e.RecordCheck(rule, AuditOutcomeNotChecked, input)
+		}
+	}
+}
+
+//AuditEvents the checks recorded in order followed by the state, call after GetVerificationResults
+func (e *v1Processor) AuditEvents() []*AuditEvent {
+
+	events := append([]*AuditEvent{}, e.events...)
+
+	if !e.results.Immunization.VerificationPerformed {
+		for _, rule := range immunizationAuditRules {
+			events = appendAuditEvent(events, rule, AuditOutcomeNotChecked, "")
+		}
+	}
+
+	return appendAuditEvent(events, AuditRuleState, outcome(e.results.State == CardVerificationStateValid),
+		"state="+string(e.results.State))
+}
+
+//FailedChecks the rules of the checks that failed in the order they were made, see AuditRuleSignature etc,
+//empty if the card is valid. Only checks that were made are included, for example the days since the last
+//dose are not checked if there are not enough doses. The results must be from a Processor or Verifier
+func FailedChecks(results *CardVerificationResults) []string {
+
+	failed := make([]string, 0)
+	if results == nil {
+		return failed
+	}
+
+	for _, event := range results.checks {
+		if event.Outcome == AuditOutcomeFailed {
+			failed = append(failed, event.Rule)
+		}
+	}
+
+	return failed
+}
+
+func appendAuditEvent(events []*AuditEvent, rule string, outcome AuditOutcome, input string) []*AuditEvent {
+	return append(events, &AuditEvent{
+		Sequence: len(events) + 1,
+		Time:     time.Now().UTC(),
+		Rule:     rule,
+		Outcome:  outcome,
+		Input:    input,
+	})
+}
+
+func outcome(passed bool) AuditOutcome {
+	if passed {
+		return AuditOutcomePassed
+	}
+	return AuditOutcomeFailed
+}
+
+//makeAuditTrail the trail for a verification, the card is redacted unless includePII
+func makeAuditTrail(card *Card, results *CardVerificationResults, events []*AuditEvent, includePII bool) (*AuditTrail, error) {
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return nil, fmt.Errorf("error audit trail err=%s", err)
+	}
+
+	input := &AuditInput{
+		Issuer: card.Issuer,
+		Format: "digital",
+		Doses:  len(card.Doses),
+		Card:   card,
+	}
+	if card.PaperRecord != nil {
+		input.Doses = len(card.PaperRecord.Doses)
+	}
+	switch {
+	case results.CardStructure.IsSelfAttested:
+		input.Format = "self-attested"
+	case results.CardStructure.IsPaperCard:
+		input.Format = "paper"
+	}
+	if !includePII {
+		input.Card = RedactCard(card)
+	}
+
+	trail := &AuditTrail{
+		ID:              hex.EncodeToString(id),
+		RecordedAt:      time.Now().UTC(),
+		PolicyID:        results.PolicyID,
+		PolicyVersion:   results.PolicyVersion,
+		MetadataVersion: results.MetadataVersion,
+		LotsVersion:     results.LotsVersion,
+		CardHash:        results.CardHash,
+		State:           results.State,
+		AssuranceLevel:  results.AssuranceLevel,
+		Input:           input,
+		Events:          events,
+	}
+	if results.VerifiedAt != nil {
+		trail.VerifiedAt = *results.VerifiedAt
+	}
+
+	return trail, nil
+}
@@ -0,0 +1,197 @@
+package verification_test
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_AuditTrail(t *testing.T) {
+
+	sink := &testAuditSink{}
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            &verification.Policy{ID: "usa", Version: "1", Region: vaccinemd.RegionUSA},
+		SignatureVerifier: &testSignatureVerifier{result: &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}},
+		IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+		AuditSink:         sink,
+	})
+	require.NoError(t, err)
+
+	card := makeTestCard("https://unknown.example.com")
+	card.PatientGivenName = "Jane"
+	card.Doses[0].LotNumber = "025J20A"
+
+	results, err := verifier.Verify(context.Background(), card)
+	require.NoError(t, err)
+	require.Len(t, sink.trails, 1)
+
+	trail := sink.trails[0]
+	require.NotEmpty(t, trail.ID)
+	require.Equal(t, "usa", trail.PolicyID)
+	require.Equal(t, "1", trail.PolicyVersion)
+	require.Equal(t, results.MetadataVersion, trail.MetadataVersion)
+	require.Equal(t, results.CardHash, trail.CardHash)
+	require.Equal(t, verification.CardVerificationStateIssuerUnknown, trail.State)
+	require.Equal(t, "digital", trail.Input.Format)
+	require.Equal(t, 2, trail.Input.Doses)
+
+	rules := make([]string, 0)
+	for i, event := range trail.Events {
+		require.Equal(t, i+1, event.Sequence)
+		require.False(t, event.Time.IsZero())
+		if i > 0 {
+			require.False(t, event.Time.Before(trail.Events[i-1].Time), "events should be in the order made")
+		}
+		rules = append(rules, event.Rule)
+	}
+	require.Equal(t, []string{
+		verification.AuditRuleSignature,
+		verification.AuditRuleExpiry,
+		verification.AuditRuleNotBefore,
+		verification.AuditRuleIssuer,
+		verification.AuditRuleDoseWarnings,
+		verification.AuditRuleCounterfeitLot,
+		verification.AuditRuleVaccineKnown,
+		verification.AuditRuleVaccineTrusted,
+		verification.AuditRuleDosesRequired,
+		verification.AuditRuleDaysBetweenDoses,
+		verification.AuditRuleDaysSinceLastDose,
+		verification.AuditRuleState,
+	}, rules)
+	require.Equal(t, verification.AuditOutcomePassed, trail.Events[0].Outcome)
+	require.Equal(t, verification.AuditOutcomeNotChecked, trail.Events[1].Outcome, "card has no exp")
+	require.Equal(t, verification.AuditOutcomeFailed, trail.Events[3].Outcome)
+	require.Equal(t, verification.AuditOutcomePassed, trail.Events[9].Outcome)
+	require.Equal(t, "state=issuer_unknown", trail.Events[11].Input)
+
+	t.Run("should redact pii by default", func(t *testing.T) {
+		b, err := json.Marshal(trail)
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "Jane")
+		require.NotContains(t, string(b), "025J20A")
+		require.NotContains(t, string(b), "1970-01-01")
+	})
+
+	t.Run("should list the failed checks", func(t *testing.T) {
+		require.Equal(t, []string{verification.AuditRuleIssuer}, verification.FailedChecks(results))
+		require.Empty(t, verification.FailedChecks(&verification.CardVerificationResults{}))
+	})
+
+	t.Run("should not check the dose dates if there are too few doses", func(t *testing.T) {
+		card := makeTestCard(testIssuer)
+		card.Doses = card.Doses[:1]
+
+		results, err := verifier.Verify(context.Background(), card)
+		require.NoError(t, err)
+		require.Equal(t, []string{verification.AuditRuleDosesRequired}, verification.FailedChecks(results))
+
+		outcomes := make(map[string]verification.AuditOutcome)
+		for _, event := range sink.trails[len(sink.trails)-1].Events {
+			outcomes[event.Rule] = event.Outcome
+		}
+		require.Equal(t, verification.AuditOutcomeFailed, outcomes[verification.AuditRuleDosesRequired])
+		require.Equal(t, verification.AuditOutcomeNotChecked, outcomes[verification.AuditRuleDaysBetweenDoses])
+		require.Equal(t, verification.AuditOutcomeNotChecked, outcomes[verification.AuditRuleDaysSinceLastDose])
+	})
+
+	t.Run("should not fail a valid card with dose warnings", func(t *testing.T) {
+		card := makeTestCard(testIssuer)
+		card.Doses[0].Manufacturer = &vaccinemd.Coding{System: vaccinemd.MVXSystem, Code: "JSN"}
+
+		results, err := verifier.Verify(context.Background(), card)
+		require.NoError(t, err)
+		require.Equal(t, verification.CardVerificationStateValid, results.State)
+		require.NotEmpty(t, results.Immunization.Warnings)
+		require.Empty(t, verification.FailedChecks(results))
+
+		for _, event := range sink.trails[len(sink.trails)-1].Events {
+			require.NotEqual(t, verification.AuditOutcomeFailed, event.Outcome, event.Rule)
+			if event.Rule == verification.AuditRuleDoseWarnings {
+				require.Equal(t, verification.AuditOutcomeWarning, event.Outcome)
+				require.Equal(t, "manufacturer-mismatch", event.Input)
+			}
+		}
+	})
+
+	t.Run("should be valid from 14 days after the last dose", func(t *testing.T) {
+		require.NotNil(t, results.Immunization.ValidFrom)
+		require.Equal(t, time.Date(2021, 4, 21, 0, 0, 0, 0, time.UTC), *results.Immunization.ValidFrom)
+	})
+
+	t.Run("should fail verification if the sink fails", func(t *testing.T) {
+		verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+			Policy:    &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+			AuditSink: &testAuditSink{err: fmt.Errorf("disk full")},
+		})
+		require.NoError(t, err)
+
+		_, err = verifier.Verify(context.Background(), makeTestCard(testIssuer))
+		require.Error(t, err)
+	})
+}
+
+func Test_FileAuditSink(t *testing.T) {
+
+	dir := t.TempDir()
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+
+	old := filepath.Join(dir, "audit-2021-02-01.jsonl")
+	kept := filepath.Join(dir, "audit-2021-03-15.jsonl")
+	other := filepath.Join(dir, "notes.txt")
+	for _, path := range []string{old, kept, other} {
+		require.NoError(t, os.WriteFile(path, []byte("{}\n"), 0o600))
+	}
+
+	sink, err := verification.NewFileAuditSink(dir, 0, func() time.Time { return now })
+	require.NoError(t, err)
+
+	require.NoError(t, sink.WriteAuditTrail(context.Background(), &verification.AuditTrail{ID: "1"}))
+	require.NoError(t, sink.WriteAuditTrail(context.Background(), &verification.AuditTrail{ID: "2"}))
+	require.NoError(t, sink.Close())
+
+	_, err = os.Stat(old)
+	require.True(t, os.IsNotExist(err), "files older than 90 days should be deleted")
+	_, err = os.Stat(kept)
+	require.NoError(t, err)
+	_, err = os.Stat(other)
+	require.NoError(t, err)
+
+	f, err := os.Open(filepath.Join(dir, "audit-2021-06-01.jsonl"))
+	require.NoError(t, err)
+	defer func() { _ = f.Close() }()
+
+	ids := make([]string, 0)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var trail verification.AuditTrail
+		require.NoError(t, json.Unmarshal(scanner.Bytes(), &trail))
+		ids = append(ids, trail.ID)
+	}
+	require.Equal(t, []string{"1", "2"}, ids)
+}
+
+type testAuditSink struct {
+	mu     sync.Mutex
+	trails []*verification.AuditTrail
+	err    error
+}
+
+func (s *testAuditSink) WriteAuditTrail(_ context.Context, trail *verification.AuditTrail) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.err != nil {
+		return s.err
+	}
+	s.trails = append(s.trails, trail)
+	return nil
+}
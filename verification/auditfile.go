@@ -0,0 +1,147 @@
+package verification
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+//DefaultAuditRetention how long audit files are kept if not configured, regulators ask for 90 days
+const DefaultAuditRetention = 90 * 24 * time.Hour
+
+const (
+	auditFilePrefix     = "audit-"
+	auditFileSuffix     = ".jsonl"
+	auditFileDateLayout = "2006-01-02"
+)
+
+//FileAuditSink writes each audit trail as a line of JSON to a file per UTC day in a directory, for example
+//audit-2021-06-01.jsonl. Files older than the retention period are deleted when a new day's file is started.
+//Safe for concurrent use
+type FileAuditSink struct {
+	dir       string
+	retention time.Duration
+	now       func() time.Time
+
+	mu   sync.Mutex
+	day  string
+	file *os.File
+}
+
+//NewFileAuditSink create a sink writing to the directory, created if it does not exist. If retention is zero
+//DefaultAuditRetention, now returns the time used to pick the file and prune old ones, if nil time.Now
+func NewFileAuditSink(dir string, retention time.Duration, now func() time.Time) (*FileAuditSink, error) {
+
+	if err := os.MkdirAll(dir, 0o750); err != nil {
+		return nil, fmt.Errorf("error new file audit sink dir=%s err=%s", dir, err)
+	}
+
+	if retention <= 0 {
+		retention = DefaultAuditRetention
+	}
+	if now == nil {
+		now = time.Now
+	}
+
+	return &FileAuditSink{dir: dir, retention: retention, now: now}, nil
+}
+
+//WriteAuditTrail append the trail to the current day's file
+func (s *FileAuditSink) WriteAuditTrail(_ context.Context, trail *AuditTrail) error {
+
+	line, err := json.Marshal(trail)
+	if err != nil {
+		return fmt.Errorf("error write audit trail err=%s", err)
+	}
+	line = append(line, '\n')
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := s.now().UTC()
+	if day := now.Format(auditFileDateLayout); day != s.day {
+		if err := s.startDay(day); err != nil {
+			return err
+		}
+		if err := s.prune(now); err != nil {
+			return err
+		}
+	}
+
+	if _, err := s.file.Write(line); err != nil {
+		return fmt.Errorf("error write audit trail file=%s err=%s", s.file.Name(), err)
+	}
+
+	return nil
+}
+
+//Close the current file, the sink can still be written to after which opens it again
+func (s *FileAuditSink) Close() error {
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.file == nil {
+		return nil
+	}
+
+	err := s.file.Close()
+	s.file = nil
+	s.day = ""
+	return err
+}
+
+//startDay must hold the lock
+func (s *FileAuditSink) startDay(day string) error {
+
+	if s.file != nil {
+		_ = s.file.Close()
+		s.file = nil
+	}
+
+	path := filepath.Join(s.dir, auditFilePrefix+day+auditFileSuffix)
+	f, err := os.OpenFile(filepath.Clean(path), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0o600)
+	if err != nil {
+		return fmt.Errorf("error write audit trail file=%s err=%s", path, err)
+	}
+
+	s.file = f
+	s.day = day
+	return nil
+}
+
+//prune delete files for days that ended more than the retention period ago, must hold the lock
+func (s *FileAuditSink) prune(now time.Time) error {
+
+	entries, err := os.ReadDir(s.dir)
+	if err != nil {
+		return fmt.Errorf("error prune audit files dir=%s err=%s", s.dir, err)
+	}
+
+	cutoff := now.Add(-s.retention)
+	for _, entry := range entries {
+
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, auditFilePrefix) || !strings.HasSuffix(name, auditFileSuffix) {
+			continue
+		}
+
+		day, err := time.Parse(auditFileDateLayout, strings.TrimSuffix(strings.TrimPrefix(name, auditFilePrefix), auditFileSuffix))
+		if err != nil {
+			continue
+		}
+
+		if day.AddDate(0, 0, 1).Before(cutoff) {
+			if err := os.Remove(filepath.Join(s.dir, name)); err != nil {
+				return fmt.Errorf("error prune audit files file=%s err=%s", name, err)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,219 @@
+package verification
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/webshield-dev/dhc-common/pdm"
+)
+
+//
+// A door check only needs to know whether to let the holder in, not their vaccination history. Project reduces a
+// verification to what the disclosure level allows so integrations only send venue systems what they need, and the
+// Redact helpers remove PII before cards or results are logged.
+//
+
+//DisclosureLevel how much of a verification is disclosed
+type DisclosureLevel string
+
+const (
+	//DisclosureLevelColor only pass or fail and the state color
+	DisclosureLevelColor DisclosureLevel = "color"
+
+	//DisclosureLevelIdentity pass or fail, the state color and enough to check the holder, their first given
+	//name and birth year
+	DisclosureLevelIdentity DisclosureLevel = "identity"
+
+	//DisclosureLevelFull everything, the results, the patient's name and birth date and the doses
+	DisclosureLevelFull DisclosureLevel = "full"
+)
+
+//StateColor the color shown to the verifier for a state, see the README
+type StateColor string
+
+const (
+	//StateColorGreen the card is valid
+	StateColorGreen StateColor = "green"
+
+	//StateColorOrange the card could not be accepted but may be acceptable after a manual check
+	StateColorOrange StateColor = "orange"
+
+	//StateColorRed the card is corrupt
+	StateColorRed StateColor = "red"
+
+	//StateColorGrey the card has not been verified
+	StateColorGrey StateColor = "grey"
+)
+
+//ColorForState the color for the state
+func ColorForState(state CardVerificationState) StateColor {
+	switch state {
+	case CardVerificationStateValid:
+		return StateColorGreen
+	case CardVerificationStateCorrupt:
+		return StateColorRed
+	case CardVerificationStateUnknown, "":
+		return StateColorGrey
+	}
+	return StateColorOrange
+}
+
+//Disclosure a verification reduced to a disclosure level, fields not allowed by the level are empty
+type Disclosure struct {
+
+	//Level the disclosure level
+	Level DisclosureLevel `json:"level"`
+
+	//Pass true if the card is valid
+	Pass bool `json:"pass"`
+
+	//Color the state color
+	Color StateColor `json:"color"`
+
+	//GivenName the patient's first given name, identity and full levels
+	GivenName string `json:"given_name,omitempty"`
+
+	//BirthYear the year the patient was born, identity and full levels
+	BirthYear string `json:"birth_year,omitempty"`
+
+	//FamilyName the patient's family name, full level only
+	FamilyName string `json:"family_name,omitempty"`
+
+	//BirthDate the patient's birth date, full level only
+	BirthDate string `json:"birth_date,omitempty"`
+
+	//Doses the doses on the card, full level only
+	Doses []*pdm.Dose `json:"doses,omitempty"`
+
+	//Results the verification results, full level only
+	Results *CardVerificationResults `json:"results,omitempty"`
+}
+
+//Project reduce the card and its verification results to the disclosure level
+func Project(card *Card, results *CardVerificationResults, level DisclosureLevel) (*Disclosure, error) {
+
+	if card == nil || results == nil {
+		return nil, fmt.Errorf("error project card and results are required")
+	}
+
+	disclosure := &Disclosure{
+		Level: level,
+		Pass:  results.State == CardVerificationStateValid,
+		Color: ColorForState(results.State),
+	}
+
+	switch level {
+	case DisclosureLevelColor:
+		return disclosure, nil
+
+	case DisclosureLevelIdentity:
+		disclosure.GivenName = firstName(card.PatientGivenName)
+		disclosure.BirthYear = RedactBirthDate(card.PatientBirthDate)
+		return disclosure, nil
+
+	case DisclosureLevelFull:
+		disclosure.GivenName = firstName(card.PatientGivenName)
+		disclosure.BirthYear = RedactBirthDate(card.PatientBirthDate)
+		disclosure.FamilyName = card.PatientFamilyName
+		disclosure.BirthDate = card.PatientBirthDate
+		disclosure.Doses = card.Doses
+		disclosure.Results = results
+		return disclosure, nil
+	}
+
+	return nil, fmt.Errorf("error project unknown disclosure level got=%s", level)
+}
+
+func firstName(givenName string) string {
+	if names := strings.Fields(givenName); len(names) > 0 {
+		return names[0]
+	}
+	return ""
+}
+
+//
+// Redaction for logs
+//
+
+//redactedMark replaces redacted text
+const redactedMark = "***"
+
+//RedactName keeps the first letter of each name, for example "Jane Doe" is "J*** D***"
+func RedactName(name string) string {
+
+	names := strings.Fields(name)
+	for i, n := range names {
+		names[i] = string([]rune(n)[0]) + redactedMark
+	}
+
+	return strings.Join(names, " ")
+}
+
+//RedactBirthDate keeps the year of a FHIR date, a birth date that does not start with a year is fully redacted
+func RedactBirthDate(birthDate string) string {
+
+	if birthDate == "" {
+		return ""
+	}
+
+	if dt, err := pdm.ParseDateTime(birthDate); err == nil {
+		return dt.Time.Format("2006")
+	}
+
+	return redactedMark
+}
+
+//RedactCard a copy of the card safe to log, names and lot numbers are redacted, only the birth year is kept and the
+//raw card, paper record and patient conditions are removed. The vaccine codes, dates and statuses are kept as they
+//are needed to understand a decision, the schedule the conditions selected is in the audit events
+func RedactCard(card *Card) *Card {
+
+	if card == nil {
+		return nil
+	}
+
+	redacted := *card
+	redacted.Raw = nil
+	redacted.PaperRecord = nil
+	redacted.PatientConditions = nil
+	redacted.PatientGivenName = RedactName(card.PatientGivenName)
+	redacted.PatientFamilyName = RedactName(card.PatientFamilyName)
+	redacted.PatientBirthDate = RedactBirthDate(card.PatientBirthDate)
+
+	redacted.Doses = make([]*pdm.Dose, 0, len(card.Doses))
+	for _, dose := range card.Doses {
+		d := *dose
+		if d.LotNumber != "" {
+			d.LotNumber = redactedMark
+		}
+		d.Site = ""
+		redacted.Doses = append(redacted.Doses, &d)
+	}
+
+	return &redacted
+}
+
+//RedactResults a copy of the results safe to log, warning messages are removed as they can quote lot numbers
+//and dates, the warning codes and dose indexes are kept
+func RedactResults(results *CardVerificationResults) *CardVerificationResults {
+
+	if results == nil {
+		return nil
+	}
+
+	redacted := *results
+	if results.Immunization != nil {
+
+		immunization := *results.Immunization
+		immunization.Warnings = make([]*pdm.Warning, 0, len(results.Immunization.Warnings))
+		for _, warning := range results.Immunization.Warnings {
+			w := *warning
+			w.Message = ""
+			immunization.Warnings = append(immunization.Warnings, &w)
+		}
+
+		redacted.Immunization = &immunization
+	}
+
+	return &redacted
+}
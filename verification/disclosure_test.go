@@ -0,0 +1,124 @@
+package verification_test
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Project(t *testing.T) {
+
+	type testCase struct {
+		name              string
+		level             verification.DisclosureLevel
+		state             verification.CardVerificationState
+		expectedPass      bool
+		expectedColor     verification.StateColor
+		expectedGivenName string
+		expectedBirthYear string
+		expectedDoses     int
+		expectedErr       bool
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should only disclose color",
+			level:         verification.DisclosureLevelColor,
+			state:         verification.CardVerificationStateValid,
+			expectedPass:  true,
+			expectedColor: verification.StateColorGreen,
+		},
+		{
+			name:              "should disclose first name and birth year",
+			level:             verification.DisclosureLevelIdentity,
+			state:             verification.CardVerificationStateIssuerUnknown,
+			expectedColor:     verification.StateColorOrange,
+			expectedGivenName: "Jane",
+			expectedBirthYear: "1970",
+		},
+		{
+			name:              "should disclose everything",
+			level:             verification.DisclosureLevelFull,
+			state:             verification.CardVerificationStateCorrupt,
+			expectedColor:     verification.StateColorRed,
+			expectedGivenName: "Jane",
+			expectedBirthYear: "1970",
+			expectedDoses:     2,
+		},
+		{
+			name:        "should reject unknown level",
+			level:       "bogus",
+			expectedErr: true,
+		},
+	}
+
+	card := makeTestCard(testIssuer)
+	card.PatientGivenName = "Jane Mary"
+	card.PatientFamilyName = "Doe"
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			disclosure, err := verification.Project(card, &verification.CardVerificationResults{State: tc.state}, tc.level)
+			if tc.expectedErr {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+
+			require.Equal(t, tc.expectedPass, disclosure.Pass)
+			require.Equal(t, tc.expectedColor, disclosure.Color)
+			require.Equal(t, tc.expectedGivenName, disclosure.GivenName)
+			require.Equal(t, tc.expectedBirthYear, disclosure.BirthYear)
+			require.Equal(t, tc.expectedDoses, len(disclosure.Doses))
+
+			if tc.level != verification.DisclosureLevelFull {
+				b, err := json.Marshal(disclosure)
+				require.NoError(t, err)
+				require.NotContains(t, string(b), "Doe")
+				require.NotContains(t, string(b), "1970-01-01")
+			}
+		})
+	}
+}
+
+func Test_Redact(t *testing.T) {
+
+	require.Equal(t, "J*** M*** D***", verification.RedactName("Jane Mary Doe"))
+	require.Equal(t, "", verification.RedactName(""))
+	require.Equal(t, "1970", verification.RedactBirthDate("1970-01-01"))
+	require.Equal(t, "***", verification.RedactBirthDate("01/02/1970"))
+
+	card := makeTestCard(testIssuer)
+	card.Raw = []byte("shc:/56762909")
+	card.PatientGivenName = "Jane"
+	card.PatientFamilyName = "Doe"
+	card.Doses[0].LotNumber = "025J20A"
+	card.Doses[0].Site = "CVS"
+	card.PatientConditions = []vaccinemd.PatientCondition{vaccinemd.PatientConditionImmunocompromised}
+
+	redacted := verification.RedactCard(card)
+	require.Nil(t, redacted.Raw)
+	require.Equal(t, "J***", redacted.PatientGivenName)
+	require.Equal(t, "D***", redacted.PatientFamilyName)
+	require.Equal(t, "1970", redacted.PatientBirthDate)
+	require.Equal(t, "***", redacted.Doses[0].LotNumber)
+	require.Empty(t, redacted.Doses[0].Site)
+	require.Empty(t, redacted.PatientConditions, "health conditions should not be kept")
+	require.Equal(t, "2021-03-09", redacted.Doses[0].OccurrenceDateTime)
+	require.Equal(t, "025J20A", card.Doses[0].LotNumber, "original should not be changed")
+
+	results := &verification.CardVerificationResults{
+		Immunization: &verification.ImmunizationVerificationResults{
+			Warnings: []*pdm.Warning{{Code: pdm.WarningCodeCounterfeitLot, Message: "lot=025J20A"}},
+		},
+	}
+	redactedResults := verification.RedactResults(results)
+	require.Empty(t, redactedResults.Immunization.Warnings[0].Message)
+	require.Equal(t, pdm.WarningCodeCounterfeitLot, redactedResults.Immunization.Warnings[0].Code)
+	require.Equal(t, "lot=025J20A", results.Immunization.Warnings[0].Message, "original should not be changed")
+}
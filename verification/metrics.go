@@ -0,0 +1,248 @@
+package verification
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+//
+// A Verifier with Metrics reports every verification it completes and how long each step took, so operations can
+// alert on spikes in corrupt or issuer unknown cards. PrometheusMetrics keeps the metrics in process and writes
+// them in the Prometheus text format, it needs no server but can be mounted as an http.Handler.
+//
+
+//MetricStep a timed step of a verification
+type MetricStep string
+
+const (
+	//MetricStepSignature checking the signature, not including fetching the key or checking revocations
+	MetricStepSignature MetricStep = "signature"
+
+	//MetricStepKeyFetch finding the issuer's key, see SignatureResult KeyFetchDuration
+	MetricStepKeyFetch MetricStep = "key_fetch"
+
+	//MetricStepRevocation checking the card has not been revoked, only reported if the SignatureVerifier
+	//checks revocations, see SignatureResult RevocationChecked
+	MetricStepRevocation MetricStep = "revocation"
+
+	//MetricStepIssuer checking the issuer is trusted, see IssuerTrustStore
+	MetricStepIssuer MetricStep = "issuer"
+
+	//MetricStepImmunization checking the immunization criteria
+	MetricStepImmunization MetricStep = "immunization"
+
+	//MetricStepTotal the whole verification
+	MetricStepTotal MetricStep = "total"
+)
+
+//MetricReasonNone the reason reported for a card with no failed checks
+const MetricReasonNone = "none"
+
+//VerificationMetric the labels of a completed verification
+type VerificationMetric struct {
+
+	//State the rolled up state
+	State CardVerificationState
+
+	//Reason the rule of the failed check that set the State, see AuditRuleSignature etc, the State if no check
+	//of the state failed, for example a paper card, or MetricReasonNone if valid
+	Reason string
+
+	//PolicyID the policy applied
+	PolicyID string
+
+	//Region the policy's region
+	Region string
+
+	//Product the vaccine ID, empty if unknown or ambiguous
+	Product string
+}
+
+//Metrics receives verification metrics, must be safe for concurrent use and should not block
+type Metrics interface {
+
+	//VerificationCompleted called once for each verification that completes without error
+	VerificationCompleted(metric *VerificationMetric)
+
+	//StepCompleted called with the time a step took
+	StepCompleted(step MetricStep, duration time.Duration)
+}
+
+//stateAuditRules the checks whose failure sets each state
+var stateAuditRules = map[CardVerificationState][]string{
+	CardVerificationStateCorrupt: {AuditRuleSignature},
+	CardVerificationStateSafetyCriteriaNotMet: {AuditRuleCounterfeitLot, AuditRuleVaccineKnown, AuditRuleVaccineTrusted,
+		AuditRuleDosesRequired, AuditRuleDaysBetweenDoses, AuditRuleDaysSinceLastDose},
+	CardVerificationStateUnVerified:    {AuditRuleSignature},
+	CardVerificationStateIssuerUnknown: {AuditRuleIssuer},
+	CardVerificationStateNotYetValid:   {AuditRuleNotBefore},
+	CardVerificationStateExpired:       {AuditRuleExpiry},
+}
+
+//failureReason the rule of the first failed check of the state, rather than the first failed check, as the
+//state rules can rank a later check first
+func failureReason(state CardVerificationState, events []*AuditEvent) string {
+
+	if state == CardVerificationStateValid {
+		return MetricReasonNone
+	}
+
+	for _, event := range events {
+		if event.Outcome != AuditOutcomeFailed {
+			continue
+		}
+		for _, rule := range stateAuditRules[state] {
+			if event.Rule == rule {
+				return rule
+			}
+		}
+	}
+
+	return string(state)
+}
+
+//DefaultLatencyBuckets the histogram upper bounds in seconds, signature checks that fetch a key over the
+//network are expected to take up to a few seconds
+var DefaultLatencyBuckets = []float64{0.001, 0.005, 0.01, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+//PrometheusMetrics in process metrics written in the Prometheus text exposition format, safe for concurrent use
+type PrometheusMetrics struct {
+	buckets []float64
+
+	mu            sync.Mutex
+	verifications map[VerificationMetric]uint64
+	steps         map[MetricStep]*histogram
+}
+
+type histogram struct {
+	counts []uint64 //per bucket, not cumulative
+	count  uint64
+	sum    float64
+}
+
+//NewPrometheusMetrics create metrics with the latency bucket upper bounds in seconds, if empty DefaultLatencyBuckets
+func NewPrometheusMetrics(buckets ...float64) *PrometheusMetrics {
+
+	if len(buckets) == 0 {
+		buckets = DefaultLatencyBuckets
+	}
+	buckets = append([]float64{}, buckets...)
+	sort.Float64s(buckets)
+
+	return &PrometheusMetrics{
+		buckets:       buckets,
+		verifications: make(map[VerificationMetric]uint64),
+		steps:         make(map[MetricStep]*histogram),
+	}
+}
+
+func (pm *PrometheusMetrics) VerificationCompleted(metric *VerificationMetric) {
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+	pm.verifications[*metric]++
+}
+
+func (pm *PrometheusMetrics) StepCompleted(step MetricStep, duration time.Duration) {
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	h, ok := pm.steps[step]
+	if !ok {
+		h = &histogram{counts: make([]uint64, len(pm.buckets))}
+		pm.steps[step] = h
+	}
+
+	seconds := duration.Seconds()
+	for i, upper := range pm.buckets {
+		if seconds <= upper {
+			h.counts[i]++
+			break
+		}
+	}
+	h.count++
+	h.sum += seconds
+}
+
+//VerificationCount the number of verifications that completed with the state, for all reasons, policies and products
+func (pm *PrometheusMetrics) VerificationCount(state CardVerificationState) uint64 {
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	total := uint64(0)
+	for metric, count := range pm.verifications {
+		if metric.State == state {
+			total += count
+		}
+	}
+	return total
+}
+
+//WritePrometheus write the metrics in the Prometheus text exposition format, series are sorted so the
+//output is stable
+func (pm *PrometheusMetrics) WritePrometheus(w io.Writer) error {
+
+	pm.mu.Lock()
+	defer pm.mu.Unlock()
+
+	var b strings.Builder
+
+	b.WriteString("# HELP dhc_verifications_total Verifications completed by state, failure reason, policy, region and vaccine product.\n")
+	b.WriteString("# TYPE dhc_verifications_total counter\n")
+	lines := make([]string, 0, len(pm.verifications))
+	for metric, count := range pm.verifications {
+		lines = append(lines, fmt.Sprintf("dhc_verifications_total{state=%s,reason=%s,policy=%s,region=%s,product=%s} %d\n",
+			quoteLabel(string(metric.State)), quoteLabel(metric.Reason), quoteLabel(metric.PolicyID),
+			quoteLabel(metric.Region), quoteLabel(metric.Product), count))
+	}
+	sort.Strings(lines)
+	for _, line := range lines {
+		b.WriteString(line)
+	}
+
+	b.WriteString("# HELP dhc_verification_step_seconds Time taken by each verification step.\n")
+	b.WriteString("# TYPE dhc_verification_step_seconds histogram\n")
+	steps := make([]string, 0, len(pm.steps))
+	for step := range pm.steps {
+		steps = append(steps, string(step))
+	}
+	sort.Strings(steps)
+	for _, step := range steps {
+
+		h := pm.steps[MetricStep(step)]
+		label := quoteLabel(step)
+		cumulative := uint64(0)
+		for i, upper := range pm.buckets {
+			cumulative += h.counts[i]
+			fmt.Fprintf(&b, "dhc_verification_step_seconds_bucket{step=%s,le=\"%s\"} %d\n",
+				label, strconv.FormatFloat(upper, 'g', -1, 64), cumulative)
+		}
+		fmt.Fprintf(&b, "dhc_verification_step_seconds_bucket{step=%s,le=\"+Inf\"} %d\n", label, h.count)
+		fmt.Fprintf(&b, "dhc_verification_step_seconds_sum{step=%s} %s\n", label, strconv.FormatFloat(h.sum, 'g', -1, 64))
+		fmt.Fprintf(&b, "dhc_verification_step_seconds_count{step=%s} %d\n", label, h.count)
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+//ServeHTTP serve the metrics for a Prometheus scrape
+func (pm *PrometheusMetrics) ServeHTTP(w http.ResponseWriter, _ *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	_ = pm.WritePrometheus(w)
+}
+
+//quoteLabel quote a label value escaping backslash, double quote and new line
+func quoteLabel(value string) string {
+	value = strings.ReplaceAll(value, `\`, `\\`)
+	value = strings.ReplaceAll(value, `"`, `\"`)
+	value = strings.ReplaceAll(value, "\n", `\n`)
+	return `"` + value + `"`
+}
@@ -0,0 +1,93 @@
+package verification_test
+
+import (
+	"context"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Metrics(t *testing.T) {
+
+	metrics := verification.NewPrometheusMetrics()
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+		SignatureVerifier: &testSignatureVerifier{result: &verification.SignatureResult{
+			Checked: true, FetchedKey: true, Valid: true, RevocationChecked: true, RevocationDuration: 20 * time.Millisecond,
+		}},
+		IssuerTrustStore: verification.NewStaticIssuerTrustStore(testIssuer),
+		Metrics:          metrics,
+	})
+	require.NoError(t, err)
+
+	//the issuer check fails first but the state is safety criteria not met
+	oneDose := makeTestCard("https://unknown.example.com")
+	oneDose.Doses = oneDose.Doses[:1]
+	oneDose.Doses[0].Manufacturer = &vaccinemd.Coding{System: vaccinemd.MVXSystem, Code: "JSN"}
+
+	withWarnings := makeTestCard(testIssuer)
+	withWarnings.Doses[0].Manufacturer = &vaccinemd.Coding{System: vaccinemd.MVXSystem, Code: "JSN"}
+
+	for _, card := range []*verification.Card{makeTestCard(testIssuer), withWarnings,
+		makeTestCard("https://unknown.example.com"), oneDose} {
+		_, err := verifier.Verify(context.Background(), card)
+		require.NoError(t, err)
+	}
+
+	require.Equal(t, uint64(2), metrics.VerificationCount(verification.CardVerificationStateValid))
+	require.Equal(t, uint64(1), metrics.VerificationCount(verification.CardVerificationStateIssuerUnknown))
+	require.Equal(t, uint64(0), metrics.VerificationCount(verification.CardVerificationStateCorrupt))
+
+	var b strings.Builder
+	require.NoError(t, metrics.WritePrometheus(&b))
+	text := b.String()
+
+	require.Contains(t, text, "# TYPE dhc_verifications_total counter\n")
+	require.Contains(t, text,
+		`dhc_verifications_total{state="valid",reason="none",policy="usa",region="USA",product="http://hl7.org/fhir/sid/cvx#207"} 2`)
+	require.Contains(t, text,
+		`dhc_verifications_total{state="issuer_unknown",reason="issuer-trusted",policy="usa",region="USA",product="http://hl7.org/fhir/sid/cvx#207"} 1`)
+	require.Contains(t, text,
+		`dhc_verifications_total{state="safety_criteria_not_met",reason="doses-required",policy="usa",region="USA",product="http://hl7.org/fhir/sid/cvx#207"} 1`,
+		"the reason should be the check of the state")
+	require.Contains(t, text, "# TYPE dhc_verification_step_seconds histogram\n")
+	for _, step := range []string{"key_fetch", "revocation", "signature", "issuer", "immunization", "total"} {
+		require.Contains(t, text, `dhc_verification_step_seconds_bucket{step="`+step+`",le="+Inf"} 4`)
+		require.Contains(t, text, `dhc_verification_step_seconds_count{step="`+step+`"} 4`)
+	}
+	require.Contains(t, text, `dhc_verification_step_seconds_sum{step="revocation"} 0.08`)
+
+	t.Run("should serve over http", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		metrics.ServeHTTP(recorder, httptest.NewRequest("GET", "/metrics", nil))
+		require.Equal(t, 200, recorder.Code)
+		require.Contains(t, recorder.Header().Get("Content-Type"), "text/plain")
+		require.Equal(t, text, recorder.Body.String())
+	})
+}
+
+func Test_PrometheusHistogram(t *testing.T) {
+
+	metrics := verification.NewPrometheusMetrics(1, 0.1)
+	metrics.StepCompleted(verification.MetricStepSignature, 50*time.Millisecond)
+	metrics.StepCompleted(verification.MetricStepSignature, 500*time.Millisecond)
+	metrics.StepCompleted(verification.MetricStepSignature, 2*time.Second)
+	metrics.VerificationCompleted(&verification.VerificationMetric{
+		State: verification.CardVerificationStateCorrupt, Reason: "card-signature", PolicyID: `a"b`,
+	})
+
+	var b strings.Builder
+	require.NoError(t, metrics.WritePrometheus(&b))
+	text := b.String()
+
+	require.Contains(t, text, `dhc_verification_step_seconds_bucket{step="signature",le="0.1"} 1`)
+	require.Contains(t, text, `dhc_verification_step_seconds_bucket{step="signature",le="1"} 2`)
+	require.Contains(t, text, `dhc_verification_step_seconds_bucket{step="signature",le="+Inf"} 3`)
+	require.Contains(t, text, `dhc_verification_step_seconds_sum{step="signature"} 2.55`)
+	require.Contains(t, text, `policy="a\"b"`, "label values should be escaped")
+}
@@ -1,5 +1,12 @@
 package verification
 
+import (
+	"time"
+
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
 // CardVerificationState the card's verification state, see below
 type CardVerificationState string
 
@@ -21,6 +28,9 @@ const (
 	//CardVerificationStateExpired the card has expired
 	CardVerificationStateExpired CardVerificationState = "expired"
 
+	//CardVerificationStateNotYetValid the card's not before or issued at time is after the verification time
+	CardVerificationStateNotYetValid CardVerificationState = "not_yet_valid"
+
 	//CardVerificationStateIssuerUnknown card issuer not on a white list
 	CardVerificationStateIssuerUnknown CardVerificationState = "issuer_unknown"
 
@@ -37,12 +47,49 @@ type CardVerificationResults struct {
 	//State the rolled up state
 	State CardVerificationState `json:"state"`
 
+	//AssuranceLevel how much the card's origin can be relied on
+	AssuranceLevel AssuranceLevel `json:"assurance_level"`
+
+	//AssuranceScore 0 to 100, the sum of the AssuranceFactors, a finer grained measure than the level
+	AssuranceScore int `json:"assurance_score"`
+
+	//AssuranceFactors what contributed to the AssuranceScore
+	AssuranceFactors []*AssuranceFactor `json:"assurance_factors,omitempty"`
+
+	//MinimumAssuranceMet the policy's minimum assurance level and score were met, set by a Verifier. Does not
+	//change State, a policy can use it to decide for example whether to accept paper cards
+	MinimumAssuranceMet bool `json:"minimum_assurance_met"`
+
+	//IdentityMatched the verifier checked the holder's identity document matches the card
+	IdentityMatched bool `json:"identity_matched"`
+
 	//CardStructure the card structure verifications results
 	CardStructure *CardStructureVerificationResults `json:"card_structure,omitempty"`
 
 	Issuer *IssuerVerificationResults `json:"issuer,omitempty"`
 
 	Immunization *ImmunizationVerificationResults `json:"immunization,omitempty"`
+
+	//VerifiedAt the time the rules were applied at, set by a Verifier
+	VerifiedAt *time.Time `json:"verified_at,omitempty"`
+
+	//PolicyID the policy applied, set by a Verifier
+	PolicyID string `json:"policy_id,omitempty"`
+
+	//PolicyVersion the version of the policy applied, set by a Verifier
+	PolicyVersion string `json:"policy_version,omitempty"`
+
+	//MetadataVersion the version of the vaccine metadata used, see vaccinemd.Repo Version
+	MetadataVersion string `json:"metadata_version,omitempty"`
+
+	//LotsVersion the version of the lot registry used, empty if lots were not checked
+	LotsVersion string `json:"lots_version,omitempty"`
+
+	//CardHash identifies the card verified without disclosing its contents, set by a Verifier, see HashCard
+	CardHash string `json:"card_hash,omitempty"`
+
+	//checks the checks made in order, see FailedChecks
+	checks []*AuditEvent
 }
 
 //CardStructureVerificationResults the card structure verifications results
@@ -63,8 +110,17 @@ type CardStructureVerificationResults struct {
 	//Expired true if an exp date and has passed
 	Expired bool `json:"expired"`
 
+	//NotYetValid true if a nbf date and it has not been reached
+	NotYetValid bool `json:"not_yet_valid"`
+
+	//IssuedInFuture true if an iat date after the verification time, the issuer's clock is wrong or the card is forged
+	IssuedInFuture bool `json:"issued_in_future"`
+
 	//IsPaperCard the card is a paper card
 	IsPaperCard bool `json:"is_paper_card"`
+
+	//IsSelfAttested the record was entered by the holder, always a paper card
+	IsSelfAttested bool `json:"is_self_attested"`
 }
 
 //IssuerVerificationResults issuer verification results
@@ -84,18 +140,56 @@ type ImmunizationVerificationResults struct {
 	//so need to leave as unknown
 	VerificationPerformed bool `json:"verification_performed"`
 
+	//TargetDisease the disease the immunization criteria were checked for
+	TargetDisease *vaccinemd.Coding `json:"target_disease,omitempty"`
+
 	//AllChecksPassed all required checks passed
 	AllChecksPassed bool `json:"all_checks_passed"`
 
 	//UnKnownVaccineType the vaccine is on a regional whitelist
 	UnKnownVaccineType bool `json:"unknown_vaccine_type"`
 
+	//AmbiguousVaccineType the dose codings could be more than one vaccine, see VaccineCandidates
+	AmbiguousVaccineType bool `json:"ambiguous_vaccine_type"`
+
+	//VaccineID the ID of the vaccine the doses are of, empty if unknown or ambiguous
+	VaccineID string `json:"vaccine_id,omitempty"`
+
+	//VaccineCandidates the IDs of the vaccines an ambiguous dose could be
+	VaccineCandidates []string `json:"vaccine_candidates,omitempty"`
+
 	//TrustedVaccineType the vaccine is on a regional whitelist
 	TrustedVaccineType bool `json:"trusted_vaccine_type"`
 
+	//Schedule the name of the dosing schedule applied, chosen by the patient's age at each dose and
+	//conditions, see vaccinemd.Schedule
+	Schedule string `json:"schedule,omitempty"`
+
 	MetDosesRequiredCriteria bool `json:"met_doses_required_criteria"`
 
 	MetDaysBetweenDoesCriteria bool `json:"met_days_between_does_criteria"`
 
 	MetDaysSinceLastDoseCriteria bool `json:"met_days_since_last_dose_criteria"`
+
+	//ValidFrom when the days since last dose criteria is, or was, first met, nil if the doses required were not met
+	//or no dose has a date. A card can be valid before this under a policy version with a shorter wait
+	ValidFrom *time.Time `json:"valid_from,omitempty"`
+
+	//PartialOccurrenceDate a dose date was recorded with less than day precision, for example 2021-05,
+	//so the date criteria were checked using a conservative interpretation
+	PartialOccurrenceDate bool `json:"partial_occurrence_date"`
+
+	//UnparsableOccurrenceString a dose only had a free-text occurrence string that could not be
+	//converted to a date, so the dose was treated as having no date
+	UnparsableOccurrenceString bool `json:"unparsable_occurrence_string"`
+
+	//ExcludedDoses number of doses not counted as their status was entered-in-error or not-done
+	ExcludedDoses int `json:"excluded_doses"`
+
+	//CounterfeitLot a dose's lot has been reported as counterfeit so the criteria are not met, other lot
+	//issues are reported as Warnings
+	CounterfeitLot bool `json:"counterfeit_lot"`
+
+	//Warnings data quality issues found in the doses, they do not change the state, see pdm.ValidateDoses
+	Warnings []*pdm.Warning `json:"warnings,omitempty"`
 }
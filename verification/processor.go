@@ -4,11 +4,14 @@ import (
 	"fmt"
 	"github.com/webshield-dev/dhc-common/pdm"
 	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"sort"
+	"strings"
+	"sync"
 	"time"
 )
 
 //Processor can be created by a verifier to manage the verification state and calculate cards verification state
-//not designed to be thread safe. Create one per card verification
+//not designed to be thread safe. Create one per card verification, or use a Verifier which can be shared
 type Processor interface {
 
 	//GetVerificationResults returns the current card verification results based on current status
@@ -21,6 +24,9 @@ type Processor interface {
 	//SetIsPaperCard the card is a paper card so many checks cannot be made
 	SetIsPaperCard()
 
+	//SetSelfAttested the record was entered by the holder rather than read from a card, also sets paper card
+	SetSelfAttested()
+
 	//SetSignatureChecked do not check signature for some reason
 	SetSignatureChecked()
 
@@ -33,6 +39,10 @@ type Processor interface {
 	//SetExpired record expired
 	SetExpired()
 
+	//EvaluateTimeClaims check the card's exp, nbf and iat against the verification time allowing for clock skew,
+	//recording Expired, NotYetValid and IssuedInFuture. Claims that are nil are not checked. Call after SetExpired
+	EvaluateTimeClaims(claims *TimeClaims)
+
 	//CardStructureVerified check if all card structure verifications have passed
 	CardStructureVerified() bool
 
@@ -46,27 +56,95 @@ type Processor interface {
 	//SetIssuerTrusted issuer is on a trusted whitelist
 	SetIssuerTrusted()
 
+	//SetIdentityMatched the verifier checked the holder's identity document matches the card
+	SetIdentityMatched()
+
 	//IssuerVerified check is all the issuers verifications have passed
 	IssuerVerified() bool
 
+	//
+	// Patient
+	//
+
+	//SetPatientBirthDate the patient's FHIR birth date, used to check doses were not before birth and
+	//to pick the dosing schedule for the patient's age
+	SetPatientBirthDate(birthDate string)
+
+	//SetPatientConditions conditions the patient has that change the dosing schedule, for example
+	//immunocompromised
+	SetPatientConditions(conditions ...vaccinemd.PatientCondition)
+
 	//
 	// Immunization Criteria
 	//
 
+	//VerifyImmunization verify the covid immunization criteria are met, the same as
+	//VerifyImmunizationForDisease with vaccinemd.DiseaseCOVID19
 	VerifyImmunization(
 		region vaccinemd.Region,
 		Doses []*pdm.Dose, // the doses administered
 	) (bool, error)
 
+	//VerifyImmunizationForDisease verify the immunization criteria for the target disease are met,
+	//doses of vaccines for other diseases are ignored
+	VerifyImmunizationForDisease(
+		disease vaccinemd.Coding,
+		region vaccinemd.Region,
+		Doses []*pdm.Dose, // the doses administered
+	) (bool, error)
+
 	//ImmunizationCriteriaMet true if all the immunization criteria have been met, can be called
 	//after verifyImmunization
 	ImmunizationCriteriaMet() bool
+
+	//RecordCheck record a check made by the caller, for example the card signature, in the audit events.
+	//The processor records the checks it makes itself as it makes them
+	RecordCheck(rule string, outcome AuditOutcome, input string)
+
+	//AuditEvents the checks made and their outcomes in the order they are made, call after
+	//GetVerificationResults
+	AuditEvents() []*AuditEvent
+
+	//AddWarnings warnings found before verifying, for example converting a paper record, they are reported
+	//ahead of the warnings found verifying the doses
+	AddWarnings(warnings ...*pdm.Warning)
 }
 
+//ProcessorConfig optional configuration for a processor, nil fields take their defaults
+type ProcessorConfig struct {
+
+	//Repo vaccine metadata, if nil the default repo is used
+	Repo vaccinemd.Repo
+
+	//Now returns the verification time, if nil time.Now
+	Now func() time.Time
+
+	//Lots if set dose lot numbers are checked against the registry
+	Lots vaccinemd.LotRegistry
+
+	//ClockSkew how far the issuer's and verifier's clocks can differ when checking the card's time claims,
+	//if zero DefaultClockSkew
+	ClockSkew time.Duration
+
+	//StateRules the order the states are checked in, if nil DefaultStateRules, see ValidateStateRules
+	StateRules []CardVerificationState
+}
+
+//NewProcessor create a processor using the default vaccine metadata and the current time
 func NewProcessor() Processor {
+	p, _ := NewProcessorWithConfig(nil) //cannot fail without a config
+	return p
+}
 
-	return &v1Processor{
-		mdRepo: vaccinemd.MakeRepo(),
+//NewProcessorWithConfig create a processor with the passed in config, the config can be nil. An error if the
+//StateRules are not valid
+func NewProcessorWithConfig(config *ProcessorConfig) (Processor, error) {
+
+	p := &v1Processor{
+		mdRepo:     defaultRepo(),
+		now:        time.Now,
+		clockSkew:  DefaultClockSkew,
+		stateRules: DefaultStateRules(),
 		results: &CardVerificationResults{
 			State:         CardVerificationStateUnknown,
 			CardStructure: &CardStructureVerificationResults{},
@@ -75,60 +153,84 @@ func NewProcessor() Processor {
 		},
 	}
 
+	if config != nil {
+		if config.Repo != nil {
+			p.mdRepo = config.Repo
+		}
+		if config.Now != nil {
+			p.now = config.Now
+		}
+		if config.ClockSkew > 0 {
+			p.clockSkew = config.ClockSkew
+		}
+		p.lots = config.Lots
+		if config.StateRules != nil {
+			if err := ValidateStateRules(config.StateRules); err != nil {
+				return nil, fmt.Errorf("error new processor %s", err)
+			}
+			p.stateRules = config.StateRules
+		}
+	}
+
+	return p, nil
+}
+
+var (
+	defaultRepoOnce sync.Once
+	defaultMDRepo   vaccinemd.Repo
+)
+
+//defaultRepo the repo is read only so build once and share across processors
+func defaultRepo() vaccinemd.Repo {
+	defaultRepoOnce.Do(func() {
+		defaultMDRepo = vaccinemd.MakeRepo()
+	})
+	return defaultMDRepo
 }
 
 type v1Processor struct {
-	mdRepo  vaccinemd.Repo
-	results *CardVerificationResults
+	mdRepo            vaccinemd.Repo
+	lots              vaccinemd.LotRegistry
+	now               func() time.Time
+	clockSkew         time.Duration
+	stateRules        []CardVerificationState
+	results           *CardVerificationResults
+	patientBirthDate  string
+	patientConditions []vaccinemd.PatientCondition
+	warnings          []*pdm.Warning
+	events            []*AuditEvent
 }
 
 func (e *v1Processor) GetVerificationResults() *CardVerificationResults {
+	e.results.MetadataVersion = e.mdRepo.Version()
+	if e.lots != nil {
+		e.results.LotsVersion = e.lots.Version()
+	}
 	e.calcState()
+	e.calcAssurance()
+	e.results.checks = append([]*AuditEvent{}, e.events...)
 	return e.results
 }
 
+//calcState the state is the first rule whose check fails, see DefaultStateRules
 func (e *v1Processor) calcState() {
 
-	//the states are checked in this order so that is one check fails it makes no sense
+	//the states are checked in the rule order so that if one check fails it makes no sense
 	//to continue
 	e.results.State = CardVerificationStateUnknown
 
-	if e.CardCorrupted() {
-		e.results.State = CardVerificationStateCorrupt
-		return
-	}
+	for _, state := range e.stateRules {
 
-	if !e.results.Immunization.VerificationPerformed {
-		//if no verification of the immunization has been performed then makes no sense
-		//to continue to leave as unknown, cannot mark as criteria not met as we do not know
-		return
-	}
-
-	//if safety criteria not met then does not matter if anything else is good or bad
-	if !e.ImmunizationCriteriaMet() {
-		e.results.State = CardVerificationStateSafetyCriteriaNotMet
-		return
-	}
-
-	if e.results.CardStructure.IsPaperCard {
-		//all other checks require a digital card so stop here
-		e.results.State = CardVerificationStatePaperCard
-		return
-	}
-
-	if !e.CardStructureVerified() {
-		e.results.State = CardVerificationStateUnVerified
-		return
-	}
-
-	if !e.IssuerVerified() {
-		e.results.State = CardVerificationStateIssuerUnknown
-		return
-	}
+		if state == CardVerificationStateSafetyCriteriaNotMet && !e.results.Immunization.VerificationPerformed {
+			//if no verification of the immunization has been performed then makes no sense
+			//to continue to leave as unknown, cannot mark as criteria not met as we do not know
+			return
+		}
 
-	if e.results.CardStructure.Expired {
-		e.results.State = CardVerificationStateExpired
-		return
+		if stateChecks[state](e) {
+			e.results.State = state
+			return
+		}
 	}
 
 	//
@@ -181,7 +283,21 @@ func (e *v1Processor) SetExpired() {
 }
 
 func (e *v1Processor) SetIsPaperCard() {
+
+	if e.results.CardStructure.IsPaperCard {
+		return
+	}
 	e.results.CardStructure.IsPaperCard = true
+
+	//a paper card has no signature, time claims or issuer to check
+	for _, rule := range []string{AuditRuleSignature, AuditRuleExpiry, AuditRuleNotBefore, AuditRuleIssuer} {
+		e.RecordCheck(rule, AuditOutcomeNotChecked, "paper card")
+	}
+}
+
+func (e *v1Processor) SetSelfAttested() {
+	e.SetIsPaperCard()
+	e.results.CardStructure.IsSelfAttested = true
 }
 
 //
@@ -201,12 +317,29 @@ func (e *v1Processor) SetIssuerTrusted() {
 	e.results.Issuer.Trusted = true
 }
 
+func (e *v1Processor) SetIdentityMatched() {
+	e.results.IdentityMatched = true
+}
+
+//
+// Patient
+//
+
+func (e *v1Processor) SetPatientBirthDate(birthDate string) {
+	e.patientBirthDate = birthDate
+}
+
+func (e *v1Processor) SetPatientConditions(conditions ...vaccinemd.PatientCondition) {
+	e.patientConditions = conditions
+}
+
 //
 // Immunization State
 //
 
 func (e *v1Processor) ImmunizationCriteriaMet() bool {
 	if !e.results.Immunization.UnKnownVaccineType &&
+		!e.results.Immunization.CounterfeitLot &&
 		e.results.Immunization.TrustedVaccineType &&
 		e.results.Immunization.MetDosesRequiredCriteria &&
 		e.results.Immunization.MetDaysBetweenDoesCriteria &&
@@ -218,166 +351,348 @@ func (e *v1Processor) ImmunizationCriteriaMet() bool {
 	return false
 }
 
+func (e *v1Processor) AddWarnings(warnings ...*pdm.Warning) {
+	e.warnings = append(e.warnings, warnings...)
+}
+
 func (e *v1Processor) VerifyImmunization(
 	region vaccinemd.Region,
 	doses []*pdm.Dose, // the doses administered
 ) (bool, error) {
+	return e.VerifyImmunizationForDisease(vaccinemd.DiseaseCOVID19, region, doses)
+}
+
+func (e *v1Processor) VerifyImmunizationForDisease(
+	disease vaccinemd.Coding,
+	region vaccinemd.Region,
+	doses []*pdm.Dose, // the doses administered
+) (bool, error) {
 
 	//have been asked to verify
 	e.results.Immunization.VerificationPerformed = true
+	e.results.Immunization.TargetDisease = &disease
 
-	if len(doses) == 0 {
-		return false, nil
-	}
+	//use the metadata effective at the verification time
+	now := e.now()
+	mdRepo := e.mdRepo.AsOf(now)
 
 	//
-	// Find system and code for vaccine, expect all doses to be the same system, code
+	// only count doses that were administered, entered-in-error and not-done are ignored.
+	// Doses of known vaccines for other diseases are also ignored, unknown vaccines are kept so
+	// they are reported as unknown
 	//
-	system := ""
-	code := ""
-	for _, dose := range doses {
-		if system == "" {
-			system = dose.Coding.System
-		} else if system != dose.Coding.System {
-			return false, fmt.Errorf(
-				"error verify immunization expects all doses to be of same type got=%s expected=%s",
-				system, dose.Coding.System)
+	e.results.Immunization.ExcludedDoses = 0
+	administered := make([]*pdm.Dose, 0, len(doses))
+	resolutions := make([]*vaccinemd.Resolution, 0, len(doses))
+	otherDiseases := make(map[int]bool)
+	for i, dose := range doses {
+		if !dose.Administered() {
+			e.results.Immunization.ExcludedDoses++
+			continue
+		}
 
+		resolution := mdRepo.ResolveVaccine(dose.AllCodings())
+		if !mayTargetDisease(resolution, disease) {
+			otherDiseases[i] = true
+			continue
 		}
-		if code == "" {
-			code = dose.Coding.Code
-		} else if code != dose.Coding.Code {
-			return false, fmt.Errorf(
-				"error verify immunization expects all doses to be of same type got=%s expected=%s",
-				system, dose.Coding.Code)
 
+		administered = append(administered, dose)
+		resolutions = append(resolutions, resolution)
+	}
+
+	//validate all the doses so the warnings index the doses on the card, the excluded doses are skipped
+	e.results.Immunization.Warnings = append(append([]*pdm.Warning{}, e.warnings...),
+		pdm.ValidateDoses(doses, &pdm.ValidationContext{
+			Now:       now,
+			BirthDate: e.patientBirthDate,
+			Repo:      mdRepo,
+			Lots:      e.lots,
+			Excluded:  otherDiseases,
+		})...)
+	doses = administered
+
+	e.results.Immunization.CounterfeitLot = false
+	codes := make([]string, 0, len(e.results.Immunization.Warnings))
+	for _, warning := range e.results.Immunization.Warnings {
+		codes = append(codes, string(warning.Code))
+		if warning.Code == pdm.WarningCodeCounterfeitLot {
+			e.results.Immunization.CounterfeitLot = true
 		}
 	}
+	//warnings do not change the state so are not a failed check
+	warningsOutcome := AuditOutcomePassed
+	if len(codes) > 0 {
+		warningsOutcome = AuditOutcomeWarning
+	}
+	e.RecordCheck(AuditRuleDoseWarnings, warningsOutcome, strings.Join(codes, ","))
+	e.RecordCheck(AuditRuleCounterfeitLot, outcome(!e.results.Immunization.CounterfeitLot), "")
 
-	vMD := e.mdRepo.FindCovidVaccine(system, code)
-	if vMD == nil {
-		//do not treat as an error
-		e.results.Immunization.UnKnownVaccineType = true
+	if len(doses) == 0 {
+		e.recordSkippedChecks(immunizationAuditRules, "no doses")
 		return false, nil
 	}
-	e.results.Immunization.UnKnownVaccineType = false
 
-	//check if vaccine trusted for this region
-	e.results.Immunization.TrustedVaccineType = false
-	switch region {
-	case vaccinemd.RegionUSA:
-		{
-			if vMD.CVXStatus == vaccinemd.CVSStatusActive {
-				e.results.Immunization.TrustedVaccineType = true
+	//
+	// Find the vaccine, expect all doses to be the same vaccine even if coded differently
+	//
+	var vMD *vaccinemd.VaccineMetadata
+	for _, resolution := range resolutions {
+
+		if resolution.Ambiguous {
+			//do not treat as an error, cannot pick the criteria so cannot say they are met
+			e.results.Immunization.AmbiguousVaccineType = true
+			for _, candidate := range resolution.Candidates {
+				e.results.Immunization.VaccineCandidates = append(e.results.Immunization.VaccineCandidates, candidate.ID)
 			}
+			e.RecordCheck(AuditRuleVaccineKnown, AuditOutcomeFailed,
+				"candidates="+strings.Join(e.results.Immunization.VaccineCandidates, ","))
+			e.recordSkippedChecks(immunizationAuditRules, "vaccine not known")
+			return false, nil
+		}
+
+		if resolution.Vaccine == nil {
+			//do not treat as an error
+			e.results.Immunization.UnKnownVaccineType = true
+			e.RecordCheck(AuditRuleVaccineKnown, AuditOutcomeFailed, "")
+			e.recordSkippedChecks(immunizationAuditRules, "vaccine not known")
+			return false, nil
 		}
-	case vaccinemd.RegionEU:
-		{
-			return false, fmt.Errorf("error verify immunization need to implement check EU region")
 
+		if vMD == nil {
+			vMD = resolution.Vaccine
+		} else if vMD.ID != resolution.Vaccine.ID {
+			return false, fmt.Errorf(
+				"error verify immunization expects all doses to be of same type got=%s expected=%s",
+				resolution.Vaccine.ID, vMD.ID)
 		}
 	}
+	e.results.Immunization.UnKnownVaccineType = false
+	e.results.Immunization.VaccineID = vMD.ID
+	e.RecordCheck(AuditRuleVaccineKnown, AuditOutcomePassed, "vaccine="+vMD.ID)
+
+	//check if vaccine trusted for this region, approved by the region's jurisdictions at the verification time
+	trust, err := region.Trust()
+	if err != nil {
+		return false, fmt.Errorf("error verify immunization %s", err)
+	}
+	e.results.Immunization.TrustedVaccineType = trust.Trusts(vMD, now)
+	e.RecordCheck(AuditRuleVaccineTrusted, outcome(e.results.Immunization.TrustedVaccineType),
+		fmt.Sprintf("target_disease=%s#%s region=%s", disease.System, disease.Code, region))
+
+	schedule := e.selectSchedule(vMD, doses)
+	e.results.Immunization.Schedule = schedule.Name
 
 	//
 	// check if number of doses met
 	//
-	e.results.Immunization.MetDosesRequiredCriteria = true
-	if len(doses) < vMD.Doses {
-		e.results.Immunization.MetDosesRequiredCriteria = false
+	//an issuer that only records the latest dose, such as an EU certificate, records if it completes the series
+	seriesComplete := false
+	for _, dose := range doses {
+		if dose.CompletesSeries() {
+			seriesComplete = true
+		}
+	}
+	e.results.Immunization.MetDosesRequiredCriteria = len(doses) >= schedule.Doses || seriesComplete
+	e.RecordCheck(AuditRuleDosesRequired, outcome(e.results.Immunization.MetDosesRequiredCriteria),
+		fmt.Sprintf("schedule=%s doses=%d required=%d series_complete=%t", schedule.Name, len(doses), schedule.Doses,
+			seriesComplete))
+	if !e.results.Immunization.MetDosesRequiredCriteria {
+		e.recordSkippedChecks(immunizationAuditRules, "doses required not met")
 		return false, nil //no point in checking dates as not enough doses
 	}
 
 	//
-	// find last dose
+	// find last dose, when dates are partial compare the latest each could have been
 	//
-	var lastDose *pdm.Dose
+	var lastOccurrence *pdm.DateTime
+	occurrences := make([]*pdm.DateTime, 0, len(doses))
 	for _, dose := range doses {
 
-		if lastDose == nil {
-			lastDose = dose
-		} else {
-			lastDoseOccurrenceTime, err := getOccurrenceTime(lastDose)
-			if err != nil {
-				return false, err
-			}
-			currentDoseOccurrenceTime, err := getOccurrenceTime(dose)
-			if err != nil {
-				return false, err
-			}
+		occurrence, err := e.getOccurrence(dose)
+		if err != nil {
+			return false, err
+		}
 
-			if currentDoseOccurrenceTime != nil && currentDoseOccurrenceTime.After(*lastDoseOccurrenceTime) {
-				lastDose = dose
-			}
+		if occurrence == nil {
+			continue
 		}
-	}
 
-	if lastDose == nil {
-		return false, nil //for some reason could not find a last dose so lets just treat as false
-	}
+		if occurrence.IsPartial() {
+			e.results.Immunization.PartialOccurrenceDate = true
+		}
 
-	occurrenceTime, err := getOccurrenceTime(lastDose)
-	if err != nil {
-		return false, err
+		occurrences = append(occurrences, occurrence)
+		if lastOccurrence == nil || occurrence.Latest().After(lastOccurrence.Latest()) {
+			lastOccurrence = occurrence
+		}
 	}
 
-	if occurrenceTime == nil {
+	if lastOccurrence == nil {
+		e.recordSkippedChecks(immunizationAuditRules, "no dose dates")
 		return false, nil // could not find an occurrence date so no point in continuing
 	}
 
 	//
-	//check duration since the dose was taken
+	// Check duration between doses, when dates are partial the smallest gap each could have been must meet
+	// the minimum and the largest the maximum
+	//
+	e.results.Immunization.MetDaysBetweenDoesCriteria = metDaysBetweenDoses(occurrences, schedule)
+	e.RecordCheck(AuditRuleDaysBetweenDoses, outcome(e.results.Immunization.MetDaysBetweenDoesCriteria),
+		fmt.Sprintf("min=%d max=%d", schedule.DaysBetweenDoesCriteriaBegin, schedule.DaysBetweenDoesCriteriaEnd))
+
 	//
-	today := time.Now()
-	dateMustHaveOccuredBy := today.AddDate(0, 0, -(vMD.DaysSinceLastDoseCriteria))
+	//check duration since the dose was taken, use the latest the dose could have been taken
+	//
+	today := now
+	dateMustHaveOccuredBy := today.AddDate(0, 0, -(schedule.DaysSinceLastDoseCriteria))
+
+	validFrom := lastOccurrence.Latest().AddDate(0, 0, schedule.DaysSinceLastDoseCriteria).Add(time.Nanosecond)
+	e.results.Immunization.ValidFrom = &validFrom
 
 	e.results.Immunization.MetDaysSinceLastDoseCriteria = false
-	if dateMustHaveOccuredBy.After(*occurrenceTime) {
+	if dateMustHaveOccuredBy.After(lastOccurrence.Latest()) {
 		e.results.Immunization.MetDaysSinceLastDoseCriteria = true
 	}
+	e.RecordCheck(AuditRuleDaysSinceLastDose, outcome(e.results.Immunization.MetDaysSinceLastDoseCriteria),
+		fmt.Sprintf("days=%d", schedule.DaysSinceLastDoseCriteria))
 
-	//
-	// Check duration between doses
-	//
+	return e.ImmunizationCriteriaMet(), nil
 
-	//fixme hard code that dates are ok, so can test
-	e.results.Immunization.MetDaysBetweenDoesCriteria = true
+}
 
-	return e.ImmunizationCriteriaMet(), nil
+//metDaysBetweenDoses true if the gaps between the doses of the series are in the schedule's range, the series
+//is the first schedule Doses doses in date order so later booster doses are not checked. Doses without a date
+//are not checked, a range begin or end of 0 has no limit
+func metDaysBetweenDoses(occurrences []*pdm.DateTime, schedule *vaccinemd.Schedule) bool {
+
+	series := append([]*pdm.DateTime{}, occurrences...)
+	sort.SliceStable(series, func(i, j int) bool {
+		return series[i].Earliest().Before(series[j].Earliest())
+	})
+	if len(series) > schedule.Doses {
+		series = series[:schedule.Doses]
+	}
+
+	for i := 1; i < len(series); i++ {
+
+		if schedule.DaysBetweenDoesCriteriaBegin > 0 &&
+			pdm.MinDaysBetween(series[i-1], series[i]) < schedule.DaysBetweenDoesCriteriaBegin {
+			return false
+		}
+
+		if schedule.DaysBetweenDoesCriteriaEnd > 0 &&
+			pdm.MaxDaysBetween(series[i-1], series[i]) > schedule.DaysBetweenDoesCriteriaEnd {
+			return false
+		}
+	}
 
+	return true
 }
 
-func getOccurrenceTime(dose *pdm.Dose) (occurrenceTime *time.Time, err error) {
-	//
-	// http://build.fhir.org/ig/HL7/fhir-shc-vaccination-ig/StructureDefinition-shc-vaccination-dm-definitions.html#Immunization.occurrence[x]:occurrenceDateTime
-	//
-	if dose.OccurrenceDateTime != "" {
-		occurrenceTime, err = dateStringTime(dose.OccurrenceDateTime)
-		if err != nil {
-			return nil, err
+//selectSchedule the schedule for the patient's age at each dose, when the age at the doses or a partial
+//birth date gives more than one schedule the one needing the most doses is used. If the birth date or dose
+//dates are not known only schedules without an age band can apply
+func (e *v1Processor) selectSchedule(vMD *vaccinemd.VaccineMetadata, doses []*pdm.Dose) *vaccinemd.Schedule {
+
+	var birthDate *pdm.DateTime
+	if e.patientBirthDate != "" {
+		//an invalid birth date is reported as a warning by pdm.ValidateDoses
+		birthDate, _ = pdm.ParseDateTime(e.patientBirthDate)
+	}
+
+	var selected *vaccinemd.Schedule
+	for _, dose := range doses {
+
+		occurrence := pdm.DoseOccurrence(dose)
+		if birthDate == nil || occurrence == nil {
+			continue
 		}
-	} else if dose.OccurrenceString != "" {
-		occurrenceTime, err = dateStringTime(dose.OccurrenceDateTime)
-		if err != nil {
-			return nil, err
+
+		youngest := monthsBetween(birthDate.Latest(), occurrence.Earliest())
+		oldest := monthsBetween(birthDate.Earliest(), occurrence.Latest())
+		for _, age := range []int{youngest, oldest} {
+			selected = mostDemanding(selected, vMD.ScheduleFor(age, e.patientConditions))
 		}
 	}
 
-	return
+	if selected == nil {
+		return vMD.ScheduleFor(-1, e.patientConditions)
+	}
+
+	return selected
+}
+
+//mostDemanding the schedule needing the most doses, then the longest wait after the last dose
+func mostDemanding(a *vaccinemd.Schedule, b *vaccinemd.Schedule) *vaccinemd.Schedule {
+
+	if a == nil {
+		return b
+	}
+
+	if b.Doses > a.Doses || (b.Doses == a.Doses && b.DaysSinceLastDoseCriteria > a.DaysSinceLastDoseCriteria) {
+		return b
+	}
+
+	return a
 }
 
-func dateStringTime(date string) (*time.Time, error) {
+//monthsBetween whole months from from to to, 0 if to is before from
+func monthsBetween(from time.Time, to time.Time) int {
 
-	result, err := time.Parse("2006-01-02", date)
-	if err == nil {
-		return &result, nil
+	months := (to.Year()-from.Year())*12 + int(to.Month()-from.Month())
+	if to.Day() < from.Day() {
+		months--
 	}
 
-	result, err = time.Parse(time.RFC3339, date)
-	if err == nil {
-		return &result, nil
+	if months < 0 {
+		return 0
 	}
 
-	//not sure how to convert
-	return nil, fmt.Errorf("error verify immunization date format got=%s", date)
+	return months
+}
+
+//mayTargetDisease false only if the dose is known to be for other diseases
+func mayTargetDisease(resolution *vaccinemd.Resolution, disease vaccinemd.Coding) bool {
+
+	if resolution.Vaccine != nil {
+		return resolution.Vaccine.TargetsDisease(disease)
+	}
+
+	if resolution.Ambiguous {
+		for _, candidate := range resolution.Candidates {
+			if candidate.TargetsDisease(disease) {
+				return true
+			}
+		}
+		return false
+	}
+
+	return true
+}
+
+//getOccurrence returns when the dose occurred, or nil if no date. A free-text occurrence string that
+//cannot be parsed is not an error, it is flagged in the results and the dose treated as having no date
+func (e *v1Processor) getOccurrence(dose *pdm.Dose) (*pdm.DateTime, error) {
+	//
+	// http://build.fhir.org/ig/HL7/fhir-shc-vaccination-ig/StructureDefinition-shc-vaccination-dm-definitions.html#Immunization.occurrence[x]:occurrenceDateTime
+	//
+	if dose.OccurrenceDateTime != "" {
+		occurrence, err := pdm.ParseDateTime(dose.OccurrenceDateTime)
+		if err != nil {
+			return nil, fmt.Errorf("error verify immunization date format got=%s", dose.OccurrenceDateTime)
+		}
+		return occurrence, nil
+	}
+
+	if dose.OccurrenceString != "" {
+		occurrence, err := pdm.ParseOccurrenceString(dose.OccurrenceString)
+		if err != nil {
+			e.results.Immunization.UnparsableOccurrenceString = true
+			return nil, nil
+		}
+		return occurrence, nil
+	}
 
+	return nil, nil
 }
@@ -227,7 +227,7 @@ func Test_SafetyCriteriaNotMet(t *testing.T) {
 						Code:   "207", //moderna
 					},
 
-					OccurrenceDateTime: "2021-03-16",
+					OccurrenceDateTime: "2021-03-09",
 				},
 				{
 					Coding: vaccinemd.Coding{
@@ -275,7 +275,7 @@ func Test_SafetyCriteriaNotMet(t *testing.T) {
 						Code:   "207", //moderna
 					},
 
-					OccurrenceDateTime: "2021-03-16",
+					OccurrenceDateTime: "2021-03-09",
 				},
 			},
 			expectedMetImmunizationCriteria: true,
@@ -321,6 +321,70 @@ func Test_SafetyCriteriaNotMet(t *testing.T) {
 			},
 			expectedMetImmunizationCriteria: false,
 		},
+		{
+			name:          "all criteria met dateTime without a time zone",
+			expectedState: verification.CardVerificationStateValid,
+			region:        vaccinemd.RegionUSA,
+			doses: []*pdm.Dose{
+				{
+					Coding: vaccinemd.Coding{
+						System: vaccinemd.CVXSystem,
+						Code:   "212", //janseen
+					},
+
+					OccurrenceDateTime: "2021-03-16T10:00:00",
+				},
+			},
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:          "all criteria met free-text occurrence string",
+			expectedState: verification.CardVerificationStateValid,
+			region:        vaccinemd.RegionUSA,
+			doses: []*pdm.Dose{
+				{
+					Coding: vaccinemd.Coding{
+						System: vaccinemd.CVXSystem,
+						Code:   "212", //janseen
+					},
+
+					OccurrenceString: "March 16 2021",
+				},
+			},
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:          "criteria not met unparsable occurrence string",
+			expectedState: verification.CardVerificationStateSafetyCriteriaNotMet,
+			region:        vaccinemd.RegionUSA,
+			doses: []*pdm.Dose{
+				{
+					Coding: vaccinemd.Coding{
+						System: vaccinemd.CVXSystem,
+						Code:   "212", //janseen
+					},
+
+					OccurrenceString: "sometime in spring",
+				},
+			},
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:          "criteria NOT met partial date could be too soon",
+			expectedState: verification.CardVerificationStateSafetyCriteriaNotMet,
+			region:        vaccinemd.RegionUSA,
+			doses: []*pdm.Dose{
+				{
+					Coding: vaccinemd.Coding{
+						System: vaccinemd.CVXSystem,
+						Code:   "212", //janseen
+					},
+
+					OccurrenceDateTime: time.Now().Format("2006-01"),
+				},
+			},
+			expectedMetImmunizationCriteria: false,
+		},
 		{
 			name:          "criteria NOT met one dose ok but occurence data too soon",
 			expectedState: verification.CardVerificationStateSafetyCriteriaNotMet,
@@ -362,6 +426,571 @@ func Test_SafetyCriteriaNotMet(t *testing.T) {
 	}
 }
 
+func Test_OccurrenceResults(t *testing.T) {
+
+	type testCase struct {
+		name                               string
+		dose                               *pdm.Dose
+		expectedPartialOccurrenceDate      bool
+		expectedUnparsableOccurrenceString bool
+	}
+
+	testCases := []testCase{
+		{
+			name:                          "should flag a partial date",
+			dose:                          &pdm.Dose{OccurrenceDateTime: "2021-03"},
+			expectedPartialOccurrenceDate: true,
+		},
+		{
+			name:                               "should flag an unparsable occurrence string",
+			dose:                               &pdm.Dose{OccurrenceString: "after lunch"},
+			expectedUnparsableOccurrenceString: true,
+		},
+		{
+			name: "should not flag a full date",
+			dose: &pdm.Dose{OccurrenceDateTime: "2021-03-16"},
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			tc.dose.Coding = vaccinemd.Coding{
+				System: vaccinemd.CVXSystem,
+				Code:   "212", //janseen
+			}
+
+			processor := verification.NewProcessor()
+			_, err := processor.VerifyImmunization(vaccinemd.RegionUSA, []*pdm.Dose{tc.dose})
+			require.NoError(t, err)
+
+			results := processor.GetVerificationResults()
+			require.Equal(t, tc.expectedPartialOccurrenceDate, results.Immunization.PartialOccurrenceDate)
+			require.Equal(t, tc.expectedUnparsableOccurrenceString, results.Immunization.UnparsableOccurrenceString)
+		})
+	}
+}
+
+func Test_DaysBetweenDoses(t *testing.T) {
+
+	type testCase struct {
+		name                   string
+		doseDates              []string
+		expectedMetDaysBetween bool
+	}
+
+	//moderna doses must be 24 to 92 days apart
+	testCases := []testCase{
+		{
+			name:                   "should meet criteria 28 days apart",
+			doseDates:              []string{"2021-03-09", "2021-04-06"},
+			expectedMetDaysBetween: true,
+		},
+		{
+			name:                   "should meet criteria exactly 24 days apart",
+			doseDates:              []string{"2021-03-13", "2021-04-06"},
+			expectedMetDaysBetween: true,
+		},
+		{
+			name:                   "should not meet criteria 23 days apart",
+			doseDates:              []string{"2021-03-14", "2021-04-06"},
+			expectedMetDaysBetween: false,
+		},
+		{
+			name:                   "should meet criteria exactly 92 days apart",
+			doseDates:              []string{"2021-01-04", "2021-04-06"},
+			expectedMetDaysBetween: true,
+		},
+		{
+			name:                   "should not meet criteria 93 days apart",
+			doseDates:              []string{"2021-01-03", "2021-04-06"},
+			expectedMetDaysBetween: false,
+		},
+		{
+			name:                   "should not meet criteria if too close",
+			doseDates:              []string{"2021-03-16", "2021-04-06"},
+			expectedMetDaysBetween: false,
+		},
+		{
+			name:                   "should not meet criteria if too far apart",
+			doseDates:              []string{"2021-01-01", "2021-04-06"},
+			expectedMetDaysBetween: false,
+		},
+		{
+			name:                   "should use dates in time order",
+			doseDates:              []string{"2021-04-06", "2021-03-09"},
+			expectedMetDaysBetween: true,
+		},
+		{
+			name:                   "should use smallest gap of a partial date",
+			doseDates:              []string{"2021-03", "2021-04-06"},
+			expectedMetDaysBetween: false,
+		},
+		{
+			name:                   "should use largest gap of a partial date",
+			doseDates:              []string{"2021-01", "2021-04-06"},
+			expectedMetDaysBetween: false,
+		},
+		{
+			name:                   "should meet criteria if every possible gap is in range",
+			doseDates:              []string{"2021-02", "2021-04-06"},
+			expectedMetDaysBetween: true,
+		},
+		{
+			name:                   "should not check a booster after the series",
+			doseDates:              []string{"2021-03-09", "2021-04-06", "2021-10-20"},
+			expectedMetDaysBetween: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			doses := make([]*pdm.Dose, 0, len(tc.doseDates))
+			for _, date := range tc.doseDates {
+				doses = append(doses, &pdm.Dose{
+					Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"},
+					OccurrenceDateTime: date,
+				})
+			}
+
+			processor := verification.NewProcessor()
+			immVerifed, err := processor.VerifyImmunization(vaccinemd.RegionUSA, doses)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMetDaysBetween, immVerifed)
+			require.Equal(t, tc.expectedMetDaysBetween, processor.GetVerificationResults().Immunization.MetDaysBetweenDoesCriteria)
+		})
+	}
+}
+
+func Test_DoseStatus(t *testing.T) {
+
+	type testCase struct {
+		name                            string
+		statuses                        []pdm.Code
+		expectedMetImmunizationCriteria bool
+		expectedExcludedDoses           int
+	}
+
+	testCases := []testCase{
+		{
+			name:                            "completed doses should count",
+			statuses:                        []pdm.Code{pdm.CodeCompleted, pdm.CodeCompleted},
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:                            "entered-in-error dose should not count",
+			statuses:                        []pdm.Code{pdm.CodeCompleted, pdm.CodeEnteredInError},
+			expectedMetImmunizationCriteria: false,
+			expectedExcludedDoses:           1,
+		},
+		{
+			name:                            "not-done dose should not count",
+			statuses:                        []pdm.Code{pdm.CodeNotDone, pdm.CodeCompleted},
+			expectedMetImmunizationCriteria: false,
+			expectedExcludedDoses:           1,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			dates := []string{"2021-03-09", "2021-04-06"}
+			doses := make([]*pdm.Dose, 0)
+			for i, status := range tc.statuses {
+				doses = append(doses, &pdm.Dose{
+					Coding: vaccinemd.Coding{
+						System: vaccinemd.CVXSystem,
+						Code:   "207", //moderna
+					},
+					Status:             status,
+					OccurrenceDateTime: dates[i],
+				})
+			}
+
+			processor := verification.NewProcessor()
+			immVerifed, err := processor.VerifyImmunization(vaccinemd.RegionUSA, doses)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMetImmunizationCriteria, immVerifed)
+			require.Equal(t, tc.expectedExcludedDoses, processor.GetVerificationResults().Immunization.ExcludedDoses)
+		})
+	}
+}
+
+func Test_DoseWarnings(t *testing.T) {
+
+	processor := verification.NewProcessor()
+	processor.SetPatientBirthDate("2021-04-01")
+
+	doses := []*pdm.Dose{
+		{
+			Coding: vaccinemd.Coding{
+				System: vaccinemd.CVXSystem,
+				Code:   "212", //janseen
+			},
+			OccurrenceDateTime: "2021-03-16",
+		},
+	}
+
+	immVerifed, err := processor.VerifyImmunization(vaccinemd.RegionUSA, doses)
+	require.NoError(t, err)
+	require.True(t, immVerifed, "warnings should not change the criteria")
+
+	results := processor.GetVerificationResults()
+	require.Equal(t, 1, len(results.Immunization.Warnings))
+	require.Equal(t, pdm.WarningCodeDoseBeforeBirth, results.Immunization.Warnings[0].Code)
+
+	t.Run("should index warnings by the dose on the card", func(t *testing.T) {
+
+		moderna := vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}
+		doses := []*pdm.Dose{
+			{Coding: moderna, OccurrenceDateTime: "2021-03-09", LotNumber: "025J20A", Status: pdm.CodeEnteredInError},
+			{Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "37"}, OccurrenceDateTime: "2021-03-09"},
+			{Coding: moderna, OccurrenceDateTime: "2021-03-09", LotNumber: "025J20A"},
+			{Coding: moderna, OccurrenceDateTime: "2021-03-09", LotNumber: "025J20A"},
+		}
+
+		processor := verification.NewProcessor()
+		_, err := processor.VerifyImmunization(vaccinemd.RegionUSA, doses)
+		require.NoError(t, err)
+
+		warnings := processor.GetVerificationResults().Immunization.Warnings
+		require.Equal(t, 1, len(warnings), "excluded doses should not be validated")
+		require.Equal(t, pdm.WarningCodeDuplicateDose, warnings[0].Code)
+		require.Equal(t, 3, warnings[0].DoseIndex)
+		require.Equal(t, "dose has same date and lot number as dose 2", warnings[0].Message)
+	})
+}
+
+func Test_VerifyImmunizationForDisease(t *testing.T) {
+
+	type testCase struct {
+		name                            string
+		disease                         vaccinemd.Coding
+		doses                           []*pdm.Dose
+		expectedMetImmunizationCriteria bool
+	}
+
+	yellowFever := vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "37"}
+	moderna := vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "207"}
+
+	testCases := []testCase{
+		{
+			name:    "yellow fever criteria met",
+			disease: vaccinemd.DiseaseYellowFever,
+			doses: []*pdm.Dose{
+				{Coding: yellowFever, OccurrenceDateTime: "2021-03-16"},
+			},
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:    "yellow fever criteria not met by covid doses",
+			disease: vaccinemd.DiseaseYellowFever,
+			doses: []*pdm.Dose{
+				{Coding: moderna, OccurrenceDateTime: "2021-03-09"},
+				{Coding: moderna, OccurrenceDateTime: "2021-04-06"},
+			},
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:    "covid criteria met ignoring other vaccines on the card",
+			disease: vaccinemd.DiseaseCOVID19,
+			doses: []*pdm.Dose{
+				{Coding: moderna, OccurrenceDateTime: "2021-03-09"},
+				{Coding: yellowFever, OccurrenceDateTime: "2021-03-20"},
+				{Coding: moderna, OccurrenceDateTime: "2021-04-06"},
+			},
+			expectedMetImmunizationCriteria: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			processor := verification.NewProcessor()
+			immVerifed, err := processor.VerifyImmunizationForDisease(tc.disease, vaccinemd.RegionUSA, tc.doses)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMetImmunizationCriteria, immVerifed)
+			require.Equal(t, tc.disease, *processor.GetVerificationResults().Immunization.TargetDisease)
+		})
+	}
+}
+
+func Test_VaccineResolution(t *testing.T) {
+
+	type testCase struct {
+		name                            string
+		doses                           []*pdm.Dose
+		expectedMetImmunizationCriteria bool
+		expectedAmbiguous               bool
+	}
+
+	testCases := []testCase{
+		{
+			name: "should accept doses coded in different systems",
+			doses: []*pdm.Dose{
+				{
+					Coding:             vaccinemd.Coding{System: "urn:oid:2.16.840.1.113883.12.292", Code: "208"},
+					OccurrenceDateTime: "2021-03-16",
+				},
+				{
+					Coding:             vaccinemd.Coding{System: vaccinemd.NDCSystem, Code: "59267-1000-1"},
+					OccurrenceDateTime: "2021-04-06",
+				},
+			},
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name: "should use other codings when the first is a class code",
+			doses: []*pdm.Dose{
+				{
+					Coding:             vaccinemd.Coding{System: vaccinemd.SNOMEDSystem, Code: "1119349007"},
+					Codings:            []vaccinemd.Coding{{System: vaccinemd.CVXSystem, Code: "212"}},
+					OccurrenceDateTime: "2021-03-16",
+				},
+			},
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name: "should report an ambiguous class code",
+			doses: []*pdm.Dose{
+				{
+					Coding:             vaccinemd.Coding{System: vaccinemd.SNOMEDSystem, Code: "1119349007"},
+					OccurrenceDateTime: "2021-03-16",
+				},
+			},
+			expectedMetImmunizationCriteria: false,
+			expectedAmbiguous:               true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			processor := verification.NewProcessor()
+			immVerifed, err := processor.VerifyImmunization(vaccinemd.RegionUSA, tc.doses)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMetImmunizationCriteria, immVerifed)
+
+			results := processor.GetVerificationResults()
+			require.Equal(t, tc.expectedAmbiguous, results.Immunization.AmbiguousVaccineType)
+			require.False(t, results.Immunization.UnKnownVaccineType)
+			if tc.expectedAmbiguous {
+				require.Equal(t, 2, len(results.Immunization.VaccineCandidates))
+			}
+		})
+	}
+}
+
+func Test_Schedules(t *testing.T) {
+
+	type testCase struct {
+		name                            string
+		birthDate                       string
+		conditions                      []vaccinemd.PatientCondition
+		doseDates                       []string
+		expectedSchedule                string
+		expectedMetImmunizationCriteria bool
+	}
+
+	testCases := []testCase{
+		{
+			name:                            "should use default schedule for adult",
+			birthDate:                       "1970-01-01",
+			doseDates:                       []string{"2021-03-16", "2021-04-06"},
+			expectedSchedule:                vaccinemd.DefaultScheduleName,
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:                            "should use default schedule if no birth date",
+			doseDates:                       []string{"2021-03-16", "2021-04-06"},
+			expectedSchedule:                vaccinemd.DefaultScheduleName,
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:                            "should need 3 doses for a young child",
+			birthDate:                       "2019-01-01",
+			doseDates:                       []string{"2022-07-01", "2022-07-22"},
+			expectedSchedule:                "pediatric-6m-4y",
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:                            "should meet young child schedule",
+			birthDate:                       "2019-01-01",
+			doseDates:                       []string{"2022-07-01", "2022-07-22", "2022-09-16"},
+			expectedSchedule:                "pediatric-6m-4y",
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:                            "should not meet young child schedule if doses too close",
+			birthDate:                       "2019-01-01",
+			doseDates:                       []string{"2022-07-01", "2022-07-10", "2022-09-16"},
+			expectedSchedule:                "pediatric-6m-4y",
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:                            "should use age at each dose, child turned 5 during series",
+			birthDate:                       "2017-07-10",
+			doseDates:                       []string{"2022-07-01", "2022-07-22"},
+			expectedSchedule:                "pediatric-6m-4y",
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:                            "should use most doses when year of birth could be either band",
+			birthDate:                       "2017",
+			doseDates:                       []string{"2022-07-01", "2022-07-22"},
+			expectedSchedule:                "pediatric-6m-4y",
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:                            "should need additional dose if immunocompromised",
+			birthDate:                       "1970-01-01",
+			conditions:                      []vaccinemd.PatientCondition{vaccinemd.PatientConditionImmunocompromised},
+			doseDates:                       []string{"2021-03-16", "2021-04-06"},
+			expectedSchedule:                "immunocompromised",
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:                            "should meet immunocompromised schedule",
+			birthDate:                       "1970-01-01",
+			conditions:                      []vaccinemd.PatientCondition{vaccinemd.PatientConditionImmunocompromised},
+			doseDates:                       []string{"2021-03-16", "2021-04-06", "2021-05-06"},
+			expectedSchedule:                "immunocompromised",
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:                            "should not meet immunocompromised schedule if additional dose too soon",
+			birthDate:                       "1970-01-01",
+			conditions:                      []vaccinemd.PatientCondition{vaccinemd.PatientConditionImmunocompromised},
+			doseDates:                       []string{"2021-03-16", "2021-04-06", "2021-04-16"},
+			expectedSchedule:                "immunocompromised",
+			expectedMetImmunizationCriteria: false,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			doses := make([]*pdm.Dose, 0, len(tc.doseDates))
+			for _, date := range tc.doseDates {
+				doses = append(doses, &pdm.Dose{
+					Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "208"},
+					OccurrenceDateTime: date,
+				})
+			}
+
+			processor := verification.NewProcessor()
+			processor.SetPatientBirthDate(tc.birthDate)
+			processor.SetPatientConditions(tc.conditions...)
+
+			immVerifed, err := processor.VerifyImmunization(vaccinemd.RegionUSA, doses)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMetImmunizationCriteria, immVerifed)
+			require.Equal(t, tc.expectedSchedule, processor.GetVerificationResults().Immunization.Schedule)
+		})
+	}
+
+	t.Run("should use the days between doses of the schedule", func(t *testing.T) {
+
+		//children need 8 weeks between doses, adults the default 17 days
+		vaccineMD := make([]*vaccinemd.CovidVaccineMetadata, 0)
+		for _, vmd := range vaccinemd.MakeRepo().CovidVaccines() {
+			if vmd.ID == vaccinemd.CVXSystem+"#208" {
+				pediatric := *vmd.Schedules[0]
+				pediatric.DaysBetweenDoesCriteriaBegin = 56
+				copied := *vmd
+				copied.Schedules = []*vaccinemd.Schedule{&pediatric}
+				vmd = &copied
+			}
+			vaccineMD = append(vaccineMD, vmd)
+		}
+		repo, err := vaccinemd.MakeRepoFromMetadata(vaccineMD)
+		require.NoError(t, err)
+
+		doses := make([]*pdm.Dose, 0)
+		for _, date := range []string{"2022-07-01", "2022-07-22", "2022-09-16"} {
+			doses = append(doses, &pdm.Dose{
+				Coding:             vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: "208"},
+				OccurrenceDateTime: date,
+			})
+		}
+
+		for birthDate, expected := range map[string]bool{"2019-01-01": false, "1970-01-01": true} {
+			processor, err := verification.NewProcessorWithConfig(&verification.ProcessorConfig{Repo: repo})
+			require.NoError(t, err)
+			processor.SetPatientBirthDate(birthDate)
+			immVerifed, err := processor.VerifyImmunization(vaccinemd.RegionUSA, doses)
+			require.NoError(t, err)
+			require.Equal(t, expected, immVerifed, birthDate)
+			require.Equal(t, expected, processor.GetVerificationResults().Immunization.MetDaysBetweenDoesCriteria, birthDate)
+		}
+	})
+}
+
+func Test_RegionTrust(t *testing.T) {
+
+	type testCase struct {
+		name                            string
+		region                          vaccinemd.Region
+		cvx                             string
+		expectedMetImmunizationCriteria bool
+		expectedError                   bool
+	}
+
+	testCases := []testCase{
+		{
+			name:                            "should not trust astrazeneca in usa",
+			region:                          vaccinemd.RegionUSA,
+			cvx:                             "210",
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:                            "should trust astrazeneca in eu",
+			region:                          vaccinemd.RegionEU,
+			cvx:                             "210",
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:                            "should trust sinovac for who eul",
+			region:                          vaccinemd.RegionAnyOf(vaccinemd.JurisdictionEMA, vaccinemd.JurisdictionWHOEUL),
+			cvx:                             "511",
+			expectedMetImmunizationCriteria: true,
+		},
+		{
+			name:                            "should not trust sputnik for who eul",
+			region:                          vaccinemd.Region(vaccinemd.JurisdictionWHOEUL),
+			cvx:                             "505",
+			expectedMetImmunizationCriteria: false,
+		},
+		{
+			name:          "should error for unknown region",
+			region:        vaccinemd.Region("bogus"),
+			cvx:           "208",
+			expectedError: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			doses := []*pdm.Dose{
+				{Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: tc.cvx}, OccurrenceDateTime: "2021-09-01"},
+				{Coding: vaccinemd.Coding{System: vaccinemd.CVXSystem, Code: tc.cvx}, OccurrenceDateTime: "2021-10-01"},
+			}
+
+			processor := verification.NewProcessor()
+			immVerifed, err := processor.VerifyImmunization(tc.region, doses)
+			if tc.expectedError {
+				require.Error(t, err)
+				return
+			}
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedMetImmunizationCriteria, immVerifed)
+			require.Equal(t, tc.expectedMetImmunizationCriteria, processor.GetVerificationResults().Immunization.TrustedVaccineType)
+		})
+	}
+}
+
 func Test_CardStatePaper(t *testing.T) {
 
 	type testCase struct {
@@ -469,7 +1098,7 @@ func setImmunizationResultsOK(t *testing.T, ps verification.Processor) {
 				Code:   "207", //moderna
 			},
 
-			OccurrenceDateTime: "2021-03-16",
+			OccurrenceDateTime: "2021-03-09",
 		},
 		{
 			Coding: vaccinemd.Coding{
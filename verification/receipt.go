@@ -0,0 +1,155 @@
+package verification
+
+import (
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/internal/jws"
+)
+
+//
+// A receipt is a JWS signed by the verifier proving a card, identified by its hash, was verified with a given
+// outcome at a given time. Edge devices sign receipts with their own key so a backend that knows the device
+// keys can trust results it did not calculate, see VerifyReceipt.
+//
+
+//ReceiptType the JWS typ of a receipt
+const ReceiptType = "dhc-verification-receipt+jwt"
+
+//Receipt the claims in a verification receipt
+type Receipt struct {
+
+	//ID unique for each receipt, jti
+	ID string `json:"jti"`
+
+	//Issuer identifies the verifier that signed the receipt, iss
+	Issuer string `json:"iss,omitempty"`
+
+	//IssuedAt when the receipt was signed in seconds since the epoch, iat
+	IssuedAt int64 `json:"iat"`
+
+	//CardHash the hash of the card that was verified, see HashCard
+	CardHash string `json:"card_hash"`
+
+	//Results the verification results including the policy and metadata versions and the verification time,
+	//redacted as receipts are kept by backends, see RedactResults
+	Results *CardVerificationResults `json:"results"`
+}
+
+//ReceiptSigner signs receipts, safe for concurrent use
+type ReceiptSigner struct {
+	key    *ecdsa.PrivateKey
+	keyID  string
+	issuer string
+	now    func() time.Time
+}
+
+//NewReceiptSigner create a signer using a P-256 key, keyID identifies the key to VerifyReceipt and issuer the
+//verifier. Now returns the signing time, if nil time.Now
+func NewReceiptSigner(key *ecdsa.PrivateKey, keyID string, issuer string, now func() time.Time) (*ReceiptSigner, error) {
+
+	if key == nil {
+		return nil, fmt.Errorf("error new receipt signer a key is required")
+	}
+
+	if keyID == "" {
+		return nil, fmt.Errorf("error new receipt signer a key id is required")
+	}
+
+	if now == nil {
+		now = time.Now
+	}
+
+	return &ReceiptSigner{key: key, keyID: keyID, issuer: issuer, now: now}, nil
+}
+
+//Sign create a receipt for the results, the results must have the CardHash set which a Verifier does
+func (rs *ReceiptSigner) Sign(results *CardVerificationResults) (string, error) {
+
+	if results == nil || results.CardHash == "" {
+		return "", fmt.Errorf("error sign receipt results with a card hash are required")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("error sign receipt err=%s", err)
+	}
+
+	payload, err := json.Marshal(&Receipt{
+		ID:       hex.EncodeToString(id),
+		Issuer:   rs.issuer,
+		IssuedAt: rs.now().Unix(),
+		CardHash: results.CardHash,
+		Results:  RedactResults(results),
+	})
+	if err != nil {
+		return "", fmt.Errorf("error sign receipt err=%s", err)
+	}
+
+	receipt, err := jws.SignES256(jws.Header{KeyID: rs.keyID, Type: ReceiptType}, payload, rs.key)
+	if err != nil {
+		return "", fmt.Errorf("error sign receipt err=%s", err)
+	}
+
+	return receipt, nil
+}
+
+//VerifyReceipt check the receipt was signed by one of the keys, keyed by key id, and return its claims
+func VerifyReceipt(receipt string, keys map[string]*ecdsa.PublicKey) (*Receipt, error) {
+
+	token, err := jws.Parse(receipt)
+	if err != nil {
+		return nil, fmt.Errorf("error verify receipt err=%s", err)
+	}
+
+	if token.Header.Type != ReceiptType {
+		return nil, fmt.Errorf("error verify receipt not a receipt typ=%s", token.Header.Type)
+	}
+
+	key, ok := keys[token.Header.KeyID]
+	if !ok {
+		return nil, fmt.Errorf("error verify receipt unknown key id=%s", token.Header.KeyID)
+	}
+
+	if err := token.VerifyES256(key); err != nil {
+		return nil, fmt.Errorf("error verify receipt err=%s", err)
+	}
+
+	var claims Receipt
+	if err := json.Unmarshal(token.Payload, &claims); err != nil {
+		return nil, fmt.Errorf("error verify receipt claims err=%s", err)
+	}
+
+	if claims.Results == nil || claims.CardHash == "" || claims.CardHash != claims.Results.CardHash {
+		return nil, fmt.Errorf("error verify receipt incomplete claims")
+	}
+
+	return &claims, nil
+}
+
+//HashCard identifies a card without disclosing its contents, the base64url SHA-256 of the card as presented.
+//If there is no Raw card the paper record or doses are hashed instead
+func HashCard(card *Card) string {
+
+	content := card.Raw
+	if len(content) == 0 {
+		var err error
+		if card.PaperRecord != nil {
+			content, err = json.Marshal(card.PaperRecord)
+		} else {
+			content, err = json.Marshal(card.Doses)
+		}
+		if err != nil {
+			return ""
+		}
+	}
+
+	digest := sha256.Sum256(content)
+	return base64.RawURLEncoding.EncodeToString(digest[:])
+}
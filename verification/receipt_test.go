@@ -0,0 +1,104 @@
+package verification_test
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_Receipt(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	//the lot is of another vaccine so there is a warning quoting it
+	lots, err := vaccinemd.MakeLotRegistry([]*vaccinemd.Lot{{LotNumber: "025J20A", VaccineID: vaccinemd.CVXSystem + "#208"}})
+	require.NoError(t, err)
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            &verification.Policy{ID: "usa", Version: "1", Region: vaccinemd.RegionUSA},
+		SignatureVerifier: &testSignatureVerifier{result: &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}},
+		IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+		Now:               func() time.Time { return now },
+		Lots:              lots,
+	})
+	require.NoError(t, err)
+
+	card := makeTestCard(testIssuer)
+	card.Doses[0].LotNumber = "025J20A"
+	card.Raw = []byte("shc:/5676290952432060346029243740446031222959532654603460292540772804336028702864716745222809286133314564376531415906402203064504590856435503")
+
+	results, err := verifier.Verify(context.Background(), card)
+	require.NoError(t, err)
+	require.Equal(t, verification.HashCard(card), results.CardHash)
+
+	signer, err := verification.NewReceiptSigner(key, "edge-1", "https://edge.example.com", func() time.Time { return now })
+	require.NoError(t, err)
+
+	receipt, err := signer.Sign(results)
+	require.NoError(t, err)
+
+	t.Run("should verify receipt", func(t *testing.T) {
+		claims, err := verification.VerifyReceipt(receipt, map[string]*ecdsa.PublicKey{"edge-1": &key.PublicKey})
+		require.NoError(t, err)
+		require.Equal(t, "https://edge.example.com", claims.Issuer)
+		require.Equal(t, now.Unix(), claims.IssuedAt)
+		require.NotEmpty(t, claims.ID)
+		require.Equal(t, verification.HashCard(card), claims.CardHash)
+		require.Equal(t, verification.CardVerificationStateValid, claims.Results.State)
+		require.Equal(t, "usa", claims.Results.PolicyID)
+		require.Equal(t, "1", claims.Results.PolicyVersion)
+		require.Equal(t, results.MetadataVersion, claims.Results.MetadataVersion)
+		require.True(t, now.Equal(*claims.Results.VerifiedAt))
+	})
+
+	t.Run("should not contain the card", func(t *testing.T) {
+		require.NotContains(t, receipt, "shc:/")
+	})
+
+	t.Run("should not contain lot numbers", func(t *testing.T) {
+		claims, err := verification.VerifyReceipt(receipt, map[string]*ecdsa.PublicKey{"edge-1": &key.PublicKey})
+		require.NoError(t, err)
+		b, err := json.Marshal(claims)
+		require.NoError(t, err)
+		require.NotContains(t, string(b), "025J20A")
+		require.Equal(t, pdm.WarningCodeLotProductMismatch, claims.Results.Immunization.Warnings[0].Code)
+		require.Contains(t, results.Immunization.Warnings[0].Message, "025J20A", "results should not be changed")
+	})
+
+	t.Run("should reject unknown key", func(t *testing.T) {
+		_, err := verification.VerifyReceipt(receipt, map[string]*ecdsa.PublicKey{"edge-2": &key.PublicKey})
+		require.Error(t, err)
+	})
+
+	t.Run("should reject wrong key", func(t *testing.T) {
+		other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+		require.NoError(t, err)
+
+		_, err = verification.VerifyReceipt(receipt, map[string]*ecdsa.PublicKey{"edge-1": &other.PublicKey})
+		require.Error(t, err)
+	})
+
+	t.Run("should reject tampered receipt", func(t *testing.T) {
+		parts := strings.Split(receipt, ".")
+		_, err := verification.VerifyReceipt(parts[0]+"."+parts[1]+"A."+parts[2],
+			map[string]*ecdsa.PublicKey{"edge-1": &key.PublicKey})
+		require.Error(t, err)
+	})
+
+	t.Run("should require a card hash", func(t *testing.T) {
+		_, err := signer.Sign(&verification.CardVerificationResults{})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,100 @@
+package verification
+
+import (
+	"fmt"
+)
+
+//
+// The rolled up State is the first state rule, in order, whose check fails, or valid if none do. The default
+// order is corrupt, safety criteria not met, paper card, unverified, issuer unknown, not yet valid then expired.
+// A policy can reorder the rules, for example to rank issuer unknown above safety criteria not met, or leave out
+// expired so expired cards are treated as valid.
+//
+
+//stateCheck returns true if the rule's state applies, the processor is passed so checks see the current results
+type stateCheck func(e *v1Processor) bool
+
+var stateChecks = map[CardVerificationState]stateCheck{
+	CardVerificationStateCorrupt: func(e *v1Processor) bool {
+		return e.CardCorrupted()
+	},
+	CardVerificationStateSafetyCriteriaNotMet: func(e *v1Processor) bool {
+		return !e.ImmunizationCriteriaMet()
+	},
+	CardVerificationStatePaperCard: func(e *v1Processor) bool {
+		return e.results.CardStructure.IsPaperCard
+	},
+	CardVerificationStateUnVerified: func(e *v1Processor) bool {
+		return !e.CardStructureVerified()
+	},
+	CardVerificationStateIssuerUnknown: func(e *v1Processor) bool {
+		return !e.IssuerVerified()
+	},
+	CardVerificationStateNotYetValid: func(e *v1Processor) bool {
+		return e.results.CardStructure.NotYetValid || e.results.CardStructure.IssuedInFuture
+	},
+	CardVerificationStateExpired: func(e *v1Processor) bool {
+		return e.results.CardStructure.Expired
+	},
+}
+
+//requiredStateRules leaving these out would let a card that cannot be trusted be valid
+var requiredStateRules = []CardVerificationState{
+	CardVerificationStateCorrupt,
+	CardVerificationStateSafetyCriteriaNotMet,
+	CardVerificationStateUnVerified,
+}
+
+//DefaultStateRules the order the states are checked in if a policy does not set one
+func DefaultStateRules() []CardVerificationState {
+	return []CardVerificationState{
+		CardVerificationStateCorrupt,
+		CardVerificationStateSafetyCriteriaNotMet,
+		CardVerificationStatePaperCard,
+		CardVerificationStateUnVerified,
+		CardVerificationStateIssuerUnknown,
+		CardVerificationStateNotYetValid,
+		CardVerificationStateExpired,
+	}
+}
+
+//ValidateStateRules checks an ordering of the state rules makes sense. Each state can only be listed once, corrupt,
+//safety criteria not met and unverified are required. Corrupt must be first as nothing on a corrupt card can be
+//relied on, and paper card must come before unverified and issuer unknown as a paper card has neither a
+//signature nor an issuer so those rules would always hide it
+func ValidateStateRules(rules []CardVerificationState) error {
+
+	position := make(map[CardVerificationState]int)
+	for i, state := range rules {
+
+		if _, ok := stateChecks[state]; !ok {
+			return fmt.Errorf("error validate state rules state=%s is not a rule", state)
+		}
+
+		if _, ok := position[state]; ok {
+			return fmt.Errorf("error validate state rules state=%s listed more than once", state)
+		}
+		position[state] = i
+	}
+
+	for _, state := range requiredStateRules {
+		if _, ok := position[state]; !ok {
+			return fmt.Errorf("error validate state rules state=%s is required", state)
+		}
+	}
+
+	if position[CardVerificationStateCorrupt] != 0 {
+		return fmt.Errorf("error validate state rules state=%s must be first", CardVerificationStateCorrupt)
+	}
+
+	if paper, ok := position[CardVerificationStatePaperCard]; ok {
+		for _, state := range []CardVerificationState{CardVerificationStateUnVerified, CardVerificationStateIssuerUnknown} {
+			if other, ok := position[state]; ok && other < paper {
+				return fmt.Errorf("error validate state rules state=%s must come before state=%s",
+					CardVerificationStatePaperCard, state)
+			}
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,190 @@
+package verification_test
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_ValidateStateRules(t *testing.T) {
+
+	type testCase struct {
+		name        string
+		rules       []verification.CardVerificationState
+		expectedErr bool
+	}
+
+	testCases := []testCase{
+		{
+			name:  "should accept defaults",
+			rules: verification.DefaultStateRules(),
+		},
+		{
+			name: "should accept issuer unknown before safety criteria",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateIssuerUnknown,
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+				verification.CardVerificationStateUnVerified,
+			},
+		},
+		{
+			name: "should reject corrupt not first",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateUnVerified,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "should reject missing required rule",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateUnVerified,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "should reject duplicate rule",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+				verification.CardVerificationStateUnVerified,
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "should reject paper card after unverified",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+				verification.CardVerificationStateUnVerified,
+				verification.CardVerificationStatePaperCard,
+			},
+			expectedErr: true,
+		},
+		{
+			name: "should reject states that are not rules",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+				verification.CardVerificationStateUnVerified,
+				verification.CardVerificationStateValid,
+			},
+			expectedErr: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+			err := verification.ValidateStateRules(tc.rules)
+			if tc.expectedErr {
+				require.Error(t, err)
+			} else {
+				require.NoError(t, err)
+			}
+		})
+	}
+
+	t.Run("should reject policy with contradictory rules", func(t *testing.T) {
+		_, err := verification.NewVerifier(&verification.VerifierConfig{
+			Policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA,
+				StateRules: []verification.CardVerificationState{verification.CardVerificationStateExpired}},
+		})
+		require.Error(t, err)
+	})
+}
+
+func Test_ProcessorStateRules(t *testing.T) {
+
+	_, err := verification.NewProcessorWithConfig(&verification.ProcessorConfig{
+		StateRules: []verification.CardVerificationState{verification.CardVerificationStateExpired},
+	})
+	require.Error(t, err, "invalid rules should not be replaced by the defaults")
+
+	//rules written before not yet valid was a state
+	_, err = verification.NewProcessorWithConfig(&verification.ProcessorConfig{
+		StateRules: []verification.CardVerificationState{
+			verification.CardVerificationStateCorrupt,
+			verification.CardVerificationStateSafetyCriteriaNotMet,
+			verification.CardVerificationStateUnVerified,
+			verification.CardVerificationStateExpired,
+		},
+	})
+	require.NoError(t, err)
+}
+
+func Test_StateRules(t *testing.T) {
+
+	type testCase struct {
+		name          string
+		rules         []verification.CardVerificationState
+		card          *verification.Card
+		expectedState verification.CardVerificationState
+	}
+
+	goodSignature := &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}
+
+	unknownIssuerNotMet := makeTestCard("https://unknown.example.com")
+	unknownIssuerNotMet.Doses = unknownIssuerNotMet.Doses[:1]
+
+	expired := makeTestCard(testIssuer)
+	expired.Expired = true
+
+	testCases := []testCase{
+		{
+			name:          "should rank safety criteria first by default",
+			card:          unknownIssuerNotMet,
+			expectedState: verification.CardVerificationStateSafetyCriteriaNotMet,
+		},
+		{
+			name: "should rank issuer unknown above safety criteria",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateIssuerUnknown,
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+				verification.CardVerificationStateUnVerified,
+			},
+			card:          unknownIssuerNotMet,
+			expectedState: verification.CardVerificationStateIssuerUnknown,
+		},
+		{
+			name:          "should be expired by default",
+			card:          expired,
+			expectedState: verification.CardVerificationStateExpired,
+		},
+		{
+			name: "should treat expired as valid if left out",
+			rules: []verification.CardVerificationState{
+				verification.CardVerificationStateCorrupt,
+				verification.CardVerificationStateSafetyCriteriaNotMet,
+				verification.CardVerificationStatePaperCard,
+				verification.CardVerificationStateUnVerified,
+				verification.CardVerificationStateIssuerUnknown,
+			},
+			card:          expired,
+			expectedState: verification.CardVerificationStateValid,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+				Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA, StateRules: tc.rules},
+				SignatureVerifier: &testSignatureVerifier{result: goodSignature},
+				IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+			})
+			require.NoError(t, err)
+
+			results, err := verifier.Verify(context.Background(), tc.card)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedState, results.State)
+		})
+	}
+}
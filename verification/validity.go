@@ -0,0 +1,82 @@
+package verification
+
+import (
+	"fmt"
+	"time"
+)
+
+//
+// A SMART Health Card JWT carries nbf and sometimes exp and iat, an EU certificate CWT carries exp (4), nbf (5) and
+// iat (6). They are evaluated against the verification time allowing for the difference between the issuer's
+// and verifier's clocks, see ProcessorConfig ClockSkew.
+//
+
+//DefaultClockSkew how far the issuer's and verifier's clocks can differ if not configured
+const DefaultClockSkew = 5 * time.Minute
+
+//TimeClaims the validity times from the card's signed envelope, nil if the card does not have the claim
+type TimeClaims struct {
+
+	//ExpiresAt the card is not valid after this time, exp
+	ExpiresAt *time.Time `json:"exp,omitempty"`
+
+	//NotBefore the card is not valid before this time, nbf
+	NotBefore *time.Time `json:"nbf,omitempty"`
+
+	//IssuedAt when the card was issued, iat
+	IssuedAt *time.Time `json:"iat,omitempty"`
+}
+
+//TimeClaimsFromUnix make the claims from JWT or CWT NumericDate seconds, 0 means the claim is not present
+func TimeClaimsFromUnix(exp int64, nbf int64, iat int64) *TimeClaims {
+
+	fromUnix := func(seconds int64) *time.Time {
+		if seconds == 0 {
+			return nil
+		}
+		t := time.Unix(seconds, 0).UTC()
+		return &t
+	}
+
+	return &TimeClaims{
+		ExpiresAt: fromUnix(exp),
+		NotBefore: fromUnix(nbf),
+		IssuedAt:  fromUnix(iat),
+	}
+}
+
+func (e *v1Processor) EvaluateTimeClaims(claims *TimeClaims) {
+
+	if claims == nil {
+		claims = &TimeClaims{}
+	}
+
+	now := e.now()
+
+	if claims.ExpiresAt != nil && now.After(claims.ExpiresAt.Add(e.clockSkew)) {
+		e.results.CardStructure.Expired = true
+	}
+
+	if claims.NotBefore != nil && now.Before(claims.NotBefore.Add(-e.clockSkew)) {
+		e.results.CardStructure.NotYetValid = true
+	}
+
+	if claims.IssuedAt != nil && claims.IssuedAt.After(now.Add(e.clockSkew)) {
+		e.results.CardStructure.IssuedInFuture = true
+	}
+
+	structure := e.results.CardStructure
+
+	if claims.ExpiresAt == nil && !structure.Expired {
+		e.RecordCheck(AuditRuleExpiry, AuditOutcomeNotChecked, "no exp")
+	} else {
+		e.RecordCheck(AuditRuleExpiry, outcome(!structure.Expired), "")
+	}
+
+	if claims.NotBefore == nil && claims.IssuedAt == nil {
+		e.RecordCheck(AuditRuleNotBefore, AuditOutcomeNotChecked, "no nbf or iat")
+	} else {
+		e.RecordCheck(AuditRuleNotBefore, outcome(!structure.NotYetValid && !structure.IssuedInFuture),
+			fmt.Sprintf("not_yet_valid=%t issued_in_future=%t", structure.NotYetValid, structure.IssuedInFuture))
+	}
+}
@@ -0,0 +1,100 @@
+package verification_test
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+func Test_TimeClaims(t *testing.T) {
+
+	type testCase struct {
+		name                   string
+		claims                 *verification.TimeClaims
+		clockSkew              time.Duration
+		expectedState          verification.CardVerificationState
+		expectedExpired        bool
+		expectedNotYetValid    bool
+		expectedIssuedInFuture bool
+	}
+
+	now := time.Date(2021, 6, 1, 12, 0, 0, 0, time.UTC)
+	at := func(d time.Duration) int64 {
+		return now.Add(d).Unix()
+	}
+
+	testCases := []testCase{
+		{
+			name:          "should be valid without claims",
+			expectedState: verification.CardVerificationStateValid,
+		},
+		{
+			name:          "should be valid within period",
+			claims:        verification.TimeClaimsFromUnix(at(time.Hour), at(-time.Hour), at(-time.Hour)),
+			expectedState: verification.CardVerificationStateValid,
+		},
+		{
+			name:            "should be expired",
+			claims:          verification.TimeClaimsFromUnix(at(-time.Hour), 0, 0),
+			expectedState:   verification.CardVerificationStateExpired,
+			expectedExpired: true,
+		},
+		{
+			name:          "should allow for clock skew on exp",
+			claims:        verification.TimeClaimsFromUnix(at(-time.Minute), 0, 0),
+			expectedState: verification.CardVerificationStateValid,
+		},
+		{
+			name:                "should be not yet valid",
+			claims:              verification.TimeClaimsFromUnix(0, at(time.Hour), 0),
+			expectedState:       verification.CardVerificationStateNotYetValid,
+			expectedNotYetValid: true,
+		},
+		{
+			name:          "should allow for clock skew on nbf",
+			claims:        verification.TimeClaimsFromUnix(0, at(time.Minute), 0),
+			expectedState: verification.CardVerificationStateValid,
+		},
+		{
+			name:                "should use configured clock skew",
+			claims:              verification.TimeClaimsFromUnix(0, at(time.Minute), 0),
+			clockSkew:           time.Second,
+			expectedState:       verification.CardVerificationStateNotYetValid,
+			expectedNotYetValid: true,
+		},
+		{
+			name:                   "should be issued in future",
+			claims:                 verification.TimeClaimsFromUnix(0, 0, at(time.Hour)),
+			expectedState:          verification.CardVerificationStateNotYetValid,
+			expectedIssuedInFuture: true,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+				Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+				SignatureVerifier: &testSignatureVerifier{result: &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}},
+				IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+				Now:               func() time.Time { return now },
+				ClockSkew:         tc.clockSkew,
+			})
+			require.NoError(t, err)
+
+			card := makeTestCard(testIssuer)
+			card.TimeClaims = tc.claims
+
+			results, err := verifier.Verify(context.Background(), card)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedState, results.State)
+			require.Equal(t, tc.expectedExpired, results.CardStructure.Expired)
+			require.Equal(t, tc.expectedNotYetValid, results.CardStructure.NotYetValid)
+			require.Equal(t, tc.expectedIssuedInFuture, results.CardStructure.IssuedInFuture)
+		})
+	}
+}
@@ -0,0 +1,532 @@
+package verification
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+)
+
+//Verifier verifies cards against a policy. Unlike a Processor it is safe for concurrent use so create one
+//and share it, the per card state is kept in a Processor created for each call to Verify
+type Verifier interface {
+
+	//Verify run all the verifications on the card and return the results
+	Verify(ctx context.Context, card *Card) (*CardVerificationResults, error)
+
+	//VerifyAt verify the card using the policy and metadata effective at the passed in time, used by
+	//audits to reproduce a past decision or to try out upcoming rules
+	VerifyAt(ctx context.Context, card *Card, at time.Time) (*CardVerificationResults, error)
+
+	//Policy the policy version cards are verified against now, nil if none is effective
+	Policy() *Policy
+}
+
+//Card the data from a card needed to verify it, format neutral so can be filled in from a SHC or EU DGC
+type Card struct {
+
+	//IsPaperCard the card is a paper card so has no signature or issuer to check
+	IsPaperCard bool
+
+	//PaperRecord if set the card is a paper record, the doses are converted from it and Doses is ignored.
+	//PatientBirthDate is taken from the record if not set
+	PaperRecord *pdm.PaperRecord
+
+	//Issuer who issued the card, for a SHC the iss, checked against the IssuerTrustStore
+	Issuer string
+
+	//Raw the card as presented, passed to the SignatureVerifier
+	Raw []byte
+
+	//Expired true if the caller found the card has expired, leave false and set TimeClaims to have
+	//the verifier check
+	Expired bool
+
+	//TimeClaims the card's exp, nbf and iat, checked against the verification time, nil if not known
+	TimeClaims *TimeClaims
+
+	//PatientGivenName the patient's given names, only used to disclose to the verifier, see Project
+	PatientGivenName string
+
+	//PatientFamilyName the patient's family name, only used to disclose to the verifier, see Project
+	PatientFamilyName string
+
+	//PatientBirthDate the patient's FHIR birth date
+	PatientBirthDate string
+
+	//IdentityMatched the verifier checked the holder's identity document matches the card's name and birth date
+	IdentityMatched bool
+
+	//PatientConditions conditions the patient has that change the dosing schedule, usually attested
+	//at the point of verification as cards do not carry them
+	PatientConditions []vaccinemd.PatientCondition
+
+	//Doses the doses on the card
+	Doses []*pdm.Dose
+}
+
+//SignatureResult the outcome of checking a card signature
+type SignatureResult struct {
+
+	//Checked true if the signature was checked
+	Checked bool
+
+	//FetchedKey true if the issuer's key was found
+	FetchedKey bool
+
+	//Valid true if the signature is valid, false if the card has been revoked
+	Valid bool
+
+	//KeyFetchDuration the time taken to find the issuer's key, reported as MetricStepKeyFetch
+	KeyFetchDuration time.Duration
+
+	//RevocationChecked true if the card was checked against the issuer's revocations
+	RevocationChecked bool
+
+	//RevocationDuration the time taken to check the revocations, reported as MetricStepRevocation
+	RevocationDuration time.Duration
+}
+
+//SignatureVerifier checks a card's signature, must be safe for concurrent use.
+//A key that cannot be found is not an error, it is reported in the SignatureResult
+type SignatureVerifier interface {
+	VerifySignature(ctx context.Context, card *Card) (*SignatureResult, error)
+}
+
+//IssuerTrustStore knows which issuers are trusted, must be safe for concurrent use
+type IssuerTrustStore interface {
+	IsTrusted(ctx context.Context, issuer string) (bool, error)
+}
+
+//Policy the rules a card is verified against
+type Policy struct {
+
+	//ID identifies the policy
+	ID string `json:"id"`
+
+	//Version identifies this version of the policy
+	Version string `json:"version,omitempty"`
+
+	//Region the vaccines must be trusted in, a named region or jurisdiction expression, see vaccinemd.Region Trust
+	Region vaccinemd.Region `json:"region"`
+
+	//TargetDisease the disease the card must show immunization against, if nil vaccinemd.DiseaseCOVID19
+	TargetDisease *vaccinemd.Coding `json:"target_disease,omitempty"`
+
+	//MinimumAssurance the lowest assurance level the policy accepts, empty accepts any, see
+	//CardVerificationResults MinimumAssuranceMet
+	MinimumAssurance AssuranceLevel `json:"minimum_assurance,omitempty"`
+
+	//MinimumAssuranceScore the lowest assurance score the policy accepts, 0 to 100
+	MinimumAssuranceScore int `json:"minimum_assurance_score,omitempty"`
+
+	//StateRules the order the card states are checked in, if empty DefaultStateRules, see ValidateStateRules
+	StateRules []CardVerificationState `json:"state_rules,omitempty"`
+
+	//EffectiveFrom when this version starts to apply, inclusive, nil if always applied
+	EffectiveFrom *time.Time `json:"effective_from,omitempty"`
+
+	//EffectiveTo when this version stops applying, exclusive, nil if never stops
+	EffectiveTo *time.Time `json:"effective_to,omitempty"`
+}
+
+//EffectiveAt true if this version of the policy applies at the passed in time
+func (p *Policy) EffectiveAt(at time.Time) bool {
+
+	if p.EffectiveFrom != nil && at.Before(*p.EffectiveFrom) {
+		return false
+	}
+
+	if p.EffectiveTo != nil && !at.Before(*p.EffectiveTo) {
+		return false
+	}
+
+	return true
+}
+
+//VerifierConfig configuration for a verifier, only Policy is required
+type VerifierConfig struct {
+
+	//Policy the policy cards are verified against
+	Policy *Policy
+
+	//PolicyVersions other versions of Policy with the same ID, each card is verified against the
+	//version effective at the verification time. Effective periods cannot overlap
+	PolicyVersions []*Policy
+
+	//Repo vaccine metadata, if nil the default repo is used. Can be a vaccinemd.ReloadableRepo, each
+	//verification uses a snapshot of it
+	Repo vaccinemd.Repo
+
+	//SignatureVerifier if nil signatures are not checked so digital cards will be unverified
+	SignatureVerifier SignatureVerifier
+
+	//IssuerTrustStore if nil no issuers are trusted
+	IssuerTrustStore IssuerTrustStore
+
+	//Now returns the verification time, if nil time.Now
+	Now func() time.Time
+
+	//Lots if set dose lot numbers are checked against the registry
+	Lots vaccinemd.LotRegistry
+
+	//ClockSkew how far the issuer's and verifier's clocks can differ, if zero DefaultClockSkew
+	ClockSkew time.Duration
+
+	//AuditSink if set receives the audit trail of every verification, if it fails the verification fails
+	AuditSink AuditSink
+
+	//AuditIncludePII keep the patient's name, birth date and lot numbers in the audit trail, by default
+	//they are redacted
+	AuditIncludePII bool
+
+	//Metrics if set receives the outcome of every verification and the time each step took
+	Metrics Metrics
+}
+
+//NewVerifier create a verifier, the config is copied so can be changed after
+func NewVerifier(config *VerifierConfig) (Verifier, error) {
+
+	if config == nil || config.Policy == nil {
+		return nil, fmt.Errorf("error new verifier a policy is required")
+	}
+
+	policies := append([]*Policy{config.Policy}, config.PolicyVersions...)
+	if err := validatePolicyVersions(policies); err != nil {
+		return nil, err
+	}
+
+	v := &v1Verifier{
+		policies:          policies,
+		repo:              config.Repo,
+		signatureVerifier: config.SignatureVerifier,
+		issuerTrustStore:  config.IssuerTrustStore,
+		now:               config.Now,
+		lots:              config.Lots,
+		clockSkew:         config.ClockSkew,
+		auditSink:         config.AuditSink,
+		auditIncludePII:   config.AuditIncludePII,
+		metrics:           config.Metrics,
+	}
+
+	if v.repo == nil {
+		v.repo = defaultRepo()
+	}
+	if v.now == nil {
+		v.now = time.Now
+	}
+
+	return v, nil
+}
+
+//v1Verifier only holds read only state, all per card state is in the processor
+type v1Verifier struct {
+	policies          []*Policy
+	repo              vaccinemd.Repo
+	signatureVerifier SignatureVerifier
+	issuerTrustStore  IssuerTrustStore
+	now               func() time.Time
+	lots              vaccinemd.LotRegistry
+	clockSkew         time.Duration
+	auditSink         AuditSink
+	auditIncludePII   bool
+	metrics           Metrics
+}
+
+func (v *v1Verifier) Policy() *Policy {
+	return v.policyAt(v.now())
+}
+
+//policyAt the policy version effective at the passed in time, nil if none
+func (v *v1Verifier) policyAt(at time.Time) *Policy {
+	for _, policy := range v.policies {
+		if policy.EffectiveAt(at) {
+			return policy
+		}
+	}
+	return nil
+}
+
+func (v *v1Verifier) Verify(ctx context.Context, card *Card) (*CardVerificationResults, error) {
+	return v.VerifyAt(ctx, card, v.now())
+}
+
+func (v *v1Verifier) VerifyAt(ctx context.Context, card *Card, verificationTime time.Time) (*CardVerificationResults, error) {
+
+	started := time.Now()
+
+	if card == nil {
+		return nil, fmt.Errorf("error verify card is nil")
+	}
+
+	if err := ctx.Err(); err != nil {
+		return nil, err
+	}
+
+	policy := v.policyAt(verificationTime)
+	if policy == nil {
+		return nil, fmt.Errorf("error verify no policy effective at=%s", verificationTime.Format(time.RFC3339))
+	}
+
+	//fix the time and metadata so all checks see the same instant and version, even if the repo is reloaded
+	repo := v.repo.Snapshot()
+	processor, err := NewProcessorWithConfig(&ProcessorConfig{
+		Repo:       repo,
+		Now:        func() time.Time { return verificationTime },
+		Lots:       v.lots,
+		ClockSkew:  v.clockSkew,
+		StateRules: policy.StateRules,
+	})
+	if err != nil {
+		return nil, fmt.Errorf("error verify policy id=%s version=%s %s", policy.ID, policy.Version, err)
+	}
+
+	doses := card.Doses
+	birthDate := card.PatientBirthDate
+
+	if card.PaperRecord != nil {
+
+		processor.SetIsPaperCard()
+		if card.PaperRecord.SelfAttested {
+			processor.SetSelfAttested()
+		}
+
+		conversion := pdm.ConvertPaperRecord(card.PaperRecord, repo.AsOf(verificationTime))
+		processor.AddWarnings(conversion.Warnings...)
+		doses = conversion.Doses
+		if birthDate == "" {
+			birthDate = conversion.BirthDate
+		}
+
+	} else if card.IsPaperCard {
+		processor.SetIsPaperCard()
+	} else {
+
+		if err := v.verifySignature(ctx, card, processor); err != nil {
+			return nil, err
+		}
+
+		if card.Expired {
+			processor.SetExpired()
+		}
+		processor.EvaluateTimeClaims(card.TimeClaims)
+
+		stepStarted := time.Now()
+		if err := v.verifyIssuer(ctx, card, processor); err != nil {
+			return nil, err
+		}
+		v.stepCompleted(MetricStepIssuer, stepStarted)
+	}
+
+	if card.IdentityMatched {
+		processor.SetIdentityMatched()
+	}
+
+	processor.SetPatientBirthDate(birthDate)
+	processor.SetPatientConditions(card.PatientConditions...)
+
+	disease := vaccinemd.DiseaseCOVID19
+	if policy.TargetDisease != nil {
+		disease = *policy.TargetDisease
+	}
+
+	stepStarted := time.Now()
+	if _, err := processor.VerifyImmunizationForDisease(disease, policy.Region, doses); err != nil {
+		return nil, err
+	}
+	v.stepCompleted(MetricStepImmunization, stepStarted)
+
+	results := processor.GetVerificationResults()
+	results.VerifiedAt = &verificationTime
+	results.PolicyID = policy.ID
+	results.PolicyVersion = policy.Version
+	results.CardHash = HashCard(card)
+	results.MinimumAssuranceMet = policy.MeetsMinimumAssurance(results)
+
+	if v.auditSink != nil || v.metrics != nil {
+
+		events := processor.AuditEvents()
+
+		if v.auditSink != nil {
+			trail, err := makeAuditTrail(card, results, events, v.auditIncludePII)
+			if err != nil {
+				return nil, err
+			}
+			if err := v.auditSink.WriteAuditTrail(ctx, trail); err != nil {
+				return nil, fmt.Errorf("error verify audit err=%s", err)
+			}
+		}
+
+		if v.metrics != nil {
+			v.metrics.VerificationCompleted(&VerificationMetric{
+				State:    results.State,
+				Reason:   failureReason(results.State, events),
+				PolicyID: policy.ID,
+				Region:   string(policy.Region),
+				Product:  results.Immunization.VaccineID,
+			})
+			v.stepCompleted(MetricStepTotal, started)
+		}
+	}
+
+	return results, nil
+}
+
+//stepCompleted report the time since the step started if there are metrics
+func (v *v1Verifier) stepCompleted(step MetricStep, started time.Time) {
+	v.stepDuration(step, time.Since(started))
+}
+
+//stepDuration report the time a step took if there are metrics
+func (v *v1Verifier) stepDuration(step MetricStep, duration time.Duration) {
+	if v.metrics != nil {
+		v.metrics.StepCompleted(step, duration)
+	}
+}
+
+//validatePolicyVersions all versions must be of the same policy and at most one effective at any time
+func validatePolicyVersions(policies []*Policy) error {
+
+	for i, policy := range policies {
+
+		if policy == nil {
+			return fmt.Errorf("error new verifier policy version=%d is nil", i)
+		}
+
+		if policy.ID != policies[0].ID {
+			return fmt.Errorf("error new verifier policy versions have different ids got=%s expected=%s",
+				policy.ID, policies[0].ID)
+		}
+
+		if policy.EffectiveFrom != nil && policy.EffectiveTo != nil && !policy.EffectiveFrom.Before(*policy.EffectiveTo) {
+			return fmt.Errorf("error new verifier policy id=%s version=%s effective from is not before effective to",
+				policy.ID, policy.Version)
+		}
+
+		if _, err := policy.Region.Trust(); err != nil {
+			return fmt.Errorf("error new verifier policy id=%s version=%s %s", policy.ID, policy.Version, err)
+		}
+
+		if err := validateMinimumAssurance(policy); err != nil {
+			return err
+		}
+
+		if len(policy.StateRules) > 0 {
+			if err := ValidateStateRules(policy.StateRules); err != nil {
+				return fmt.Errorf("error new verifier policy id=%s version=%s %s", policy.ID, policy.Version, err)
+			}
+		}
+
+		for _, other := range policies[:i] {
+			if periodsOverlap(policy, other) {
+				return fmt.Errorf("error new verifier policy id=%s versions %s and %s have overlapping effective periods",
+					policy.ID, other.Version, policy.Version)
+			}
+		}
+	}
+
+	return nil
+}
+
+func periodsOverlap(a *Policy, b *Policy) bool {
+
+	//a ends before b starts
+	if a.EffectiveTo != nil && b.EffectiveFrom != nil && !a.EffectiveTo.After(*b.EffectiveFrom) {
+		return false
+	}
+
+	//b ends before a starts
+	if b.EffectiveTo != nil && a.EffectiveFrom != nil && !b.EffectiveTo.After(*a.EffectiveFrom) {
+		return false
+	}
+
+	return true
+}
+
+func (v *v1Verifier) verifySignature(ctx context.Context, card *Card, processor Processor) error {
+
+	if v.signatureVerifier == nil {
+		processor.RecordCheck(AuditRuleSignature, AuditOutcomeNotChecked, "no signature verifier")
+		return nil
+	}
+
+	started := time.Now()
+	sr, err := v.signatureVerifier.VerifySignature(ctx, card)
+	if err != nil {
+		return fmt.Errorf("error verify card signature err=%s", err)
+	}
+
+	//the signature step is the time not spent fetching the key or checking revocations
+	signature := time.Since(started) - sr.KeyFetchDuration - sr.RevocationDuration
+	if signature < 0 {
+		signature = 0
+	}
+	if sr.Checked {
+		v.stepDuration(MetricStepKeyFetch, sr.KeyFetchDuration)
+	}
+	if sr.RevocationChecked {
+		v.stepDuration(MetricStepRevocation, sr.RevocationDuration)
+	}
+	v.stepDuration(MetricStepSignature, signature)
+
+	if sr.Checked {
+		processor.SetSignatureChecked()
+	}
+	if sr.FetchedKey {
+		processor.SetFetchedKey()
+	}
+	if sr.Valid {
+		processor.SetSignatureValid()
+	}
+
+	if !sr.Checked {
+		processor.RecordCheck(AuditRuleSignature, AuditOutcomeNotChecked, "")
+	} else {
+		processor.RecordCheck(AuditRuleSignature, outcome(sr.FetchedKey && sr.Valid),
+			fmt.Sprintf("fetched_key=%t", sr.FetchedKey))
+	}
+
+	return nil
+}
+
+func (v *v1Verifier) verifyIssuer(ctx context.Context, card *Card, processor Processor) error {
+
+	if v.issuerTrustStore == nil || card.Issuer == "" {
+		processor.RecordCheck(AuditRuleIssuer, AuditOutcomeNotChecked, "")
+		return nil
+	}
+
+	trusted, err := v.issuerTrustStore.IsTrusted(ctx, card.Issuer)
+	if err != nil {
+		return fmt.Errorf("error verify card issuer=%s err=%s", card.Issuer, err)
+	}
+
+	if trusted {
+		processor.SetIssuerTrusted()
+	}
+	processor.RecordCheck(AuditRuleIssuer, outcome(trusted), "")
+
+	return nil
+}
+
+//NewStaticIssuerTrustStore a trust store for a fixed list of issuers
+func NewStaticIssuerTrustStore(issuers ...string) IssuerTrustStore {
+
+	trusted := make(map[string]bool)
+	for _, issuer := range issuers {
+		trusted[issuer] = true
+	}
+
+	return &staticIssuerTrustStore{trusted: trusted}
+}
+
+//staticIssuerTrustStore never modified once made so safe for concurrent use
+type staticIssuerTrustStore struct {
+	trusted map[string]bool
+}
+
+func (ts *staticIssuerTrustStore) IsTrusted(_ context.Context, issuer string) (bool, error) {
+	return ts.trusted[issuer], nil
+}
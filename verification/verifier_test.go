@@ -0,0 +1,344 @@
+package verification_test
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+	"github.com/webshield-dev/dhc-common/pdm"
+	"github.com/webshield-dev/dhc-common/vaccinemd"
+	"github.com/webshield-dev/dhc-common/verification"
+)
+
+const testIssuer = "https://issuer.example.com"
+
+func Test_Verifier(t *testing.T) {
+
+	type testCase struct {
+		name              string
+		card              *verification.Card
+		signature         *verification.SignatureResult
+		expectedState     verification.CardVerificationState
+		expectedAssurance verification.AssuranceLevel
+	}
+
+	goodSignature := &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}
+
+	testCases := []testCase{
+		{
+			name:              "should be valid",
+			card:              makeTestCard(testIssuer),
+			signature:         goodSignature,
+			expectedState:     verification.CardVerificationStateValid,
+			expectedAssurance: verification.AssuranceLevelTrustedIssuer,
+		},
+		{
+			name:              "should be corrupt if signature invalid",
+			card:              makeTestCard(testIssuer),
+			signature:         &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: false},
+			expectedState:     verification.CardVerificationStateCorrupt,
+			expectedAssurance: verification.AssuranceLevelUnknown,
+		},
+		{
+			name:              "should be unverified if key not found",
+			card:              makeTestCard(testIssuer),
+			signature:         &verification.SignatureResult{Checked: true},
+			expectedState:     verification.CardVerificationStateUnVerified,
+			expectedAssurance: verification.AssuranceLevelUnknown,
+		},
+		{
+			name:              "should be issuer unknown if not in trust store",
+			card:              makeTestCard("https://unknown.example.com"),
+			signature:         goodSignature,
+			expectedState:     verification.CardVerificationStateIssuerUnknown,
+			expectedAssurance: verification.AssuranceLevelDigitallySignedUnknownIssuer,
+		},
+		{
+			name: "should be paper card",
+			card: func() *verification.Card {
+				card := makeTestCard("")
+				card.IsPaperCard = true
+				return card
+			}(),
+			expectedState:     verification.CardVerificationStatePaperCard,
+			expectedAssurance: verification.AssuranceLevelPaper,
+		},
+		{
+			name: "should be safety criteria not met",
+			card: func() *verification.Card {
+				card := makeTestCard(testIssuer)
+				card.Doses = card.Doses[:1]
+				return card
+			}(),
+			signature:         goodSignature,
+			expectedState:     verification.CardVerificationStateSafetyCriteriaNotMet,
+			expectedAssurance: verification.AssuranceLevelTrustedIssuer,
+		},
+	}
+
+	for _, tc := range testCases {
+		t.Run(tc.name, func(t *testing.T) {
+
+			verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+				Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+				SignatureVerifier: &testSignatureVerifier{result: tc.signature},
+				IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+			})
+			require.NoError(t, err)
+
+			results, err := verifier.Verify(context.Background(), tc.card)
+			require.NoError(t, err)
+			require.Equal(t, tc.expectedState, results.State)
+			require.Equal(t, tc.expectedAssurance, results.AssuranceLevel)
+		})
+	}
+}
+
+func Test_VerifierErrors(t *testing.T) {
+
+	_, err := verification.NewVerifier(&verification.VerifierConfig{})
+	require.Error(t, err, "policy is required")
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+		SignatureVerifier: &testSignatureVerifier{err: fmt.Errorf("network down")},
+	})
+	require.NoError(t, err)
+
+	_, err = verifier.Verify(context.Background(), makeTestCard(testIssuer))
+	require.Error(t, err, "signature verifier error should be returned")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err = verifier.Verify(ctx, makeTestCard(testIssuer))
+	require.Error(t, err, "cancelled context should be returned")
+}
+
+func Test_VerifierConcurrent(t *testing.T) {
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+		SignatureVerifier: &testSignatureVerifier{result: &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}},
+		IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+		Now:               func() time.Time { return time.Date(2021, 10, 1, 0, 0, 0, 0, time.UTC) },
+	})
+	require.NoError(t, err)
+
+	var wg sync.WaitGroup
+	states := make([]verification.CardVerificationState, 50)
+	for i := range states {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results, err := verifier.Verify(context.Background(), makeTestCard(testIssuer))
+			if err == nil {
+				states[i] = results.State
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	for _, state := range states {
+		require.Equal(t, verification.CardVerificationStateValid, state)
+	}
+}
+
+func Test_VerifierAsOf(t *testing.T) {
+
+	change := time.Date(2021, 9, 1, 0, 0, 0, 0, time.UTC)
+
+	//moderna needed 28 days since last dose after the change
+	vaccineMD := make([]*vaccinemd.CovidVaccineMetadata, 0)
+	for _, vmd := range vaccinemd.MakeRepo().CovidVaccines() {
+		if vmd.ID != vaccinemd.CVXSystem+"#207" {
+			vaccineMD = append(vaccineMD, vmd)
+			continue
+		}
+		before, after := *vmd, *vmd
+		before.EffectiveTo = &change
+		after.EffectiveFrom = &change
+		after.DaysSinceLastDoseCriteria = 28
+		vaccineMD = append(vaccineMD, &before, &after)
+	}
+	repo, err := vaccinemd.MakeRepoFromMetadata(vaccineMD)
+	require.NoError(t, err)
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy: &verification.Policy{ID: "usa", Version: "1", Region: vaccinemd.RegionUSA, EffectiveTo: &change},
+		PolicyVersions: []*verification.Policy{
+			{ID: "usa", Version: "2", Region: vaccinemd.RegionUSA, EffectiveFrom: &change},
+		},
+		Repo:              repo,
+		SignatureVerifier: &testSignatureVerifier{result: &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}},
+		IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+	})
+	require.NoError(t, err)
+
+	//last dose 2021-08-15 so 15 days later meets 14, 20 days later does not meet 28
+	card := makeTestCard(testIssuer)
+	card.Doses[0].OccurrenceDateTime = "2021-07-18"
+	card.Doses[1].OccurrenceDateTime = "2021-08-15"
+	scanTime := time.Date(2021, 9, 4, 0, 0, 0, 0, time.UTC)
+
+	results, err := verifier.VerifyAt(context.Background(), card, scanTime.AddDate(0, 0, -5))
+	require.NoError(t, err)
+	require.Equal(t, verification.CardVerificationStateValid, results.State, "old rules should pass")
+	require.Equal(t, "1", results.PolicyVersion)
+	require.Equal(t, repo.Version(), results.MetadataVersion)
+
+	results, err = verifier.VerifyAt(context.Background(), card, scanTime)
+	require.NoError(t, err)
+	require.Equal(t, verification.CardVerificationStateSafetyCriteriaNotMet, results.State, "new rules should fail")
+	require.Equal(t, "usa", results.PolicyID)
+	require.Equal(t, "2", results.PolicyVersion)
+	require.True(t, scanTime.Equal(*results.VerifiedAt))
+
+	_, err = verification.NewVerifier(&verification.VerifierConfig{
+		Policy:         &verification.Policy{ID: "usa", Version: "1", Region: vaccinemd.RegionUSA},
+		PolicyVersions: []*verification.Policy{{ID: "usa", Version: "2", Region: vaccinemd.RegionUSA, EffectiveFrom: &change}},
+	})
+	require.Error(t, err, "overlapping policy versions should be rejected")
+}
+
+//-----------------
+//Helpers
+//------------------
+
+type testSignatureVerifier struct {
+	result *verification.SignatureResult
+	err    error
+}
+
+func (sv *testSignatureVerifier) VerifySignature(_ context.Context, _ *verification.Card) (*verification.SignatureResult, error) {
+	if sv.err != nil {
+		return nil, sv.err
+	}
+	if sv.result == nil {
+		return &verification.SignatureResult{}, nil
+	}
+	return sv.result, nil
+}
+
+func Test_VerifierLots(t *testing.T) {
+
+	lots, err := vaccinemd.MakeLotRegistry([]*vaccinemd.Lot{
+		{LotNumber: "025J20A", VaccineID: vaccinemd.CVXSystem + "#207", ExpiryDate: "2021-12-31"},
+		{LotNumber: "FAKE01", VaccineID: vaccinemd.CVXSystem + "#207", Counterfeit: true},
+	})
+	require.NoError(t, err)
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy:            &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+		SignatureVerifier: &testSignatureVerifier{result: &verification.SignatureResult{Checked: true, FetchedKey: true, Valid: true}},
+		IssuerTrustStore:  verification.NewStaticIssuerTrustStore(testIssuer),
+		Lots:              lots,
+	})
+	require.NoError(t, err)
+
+	t.Run("should be valid for a known lot", func(t *testing.T) {
+		card := makeTestCard(testIssuer)
+		card.Doses[0].LotNumber = "025J20A"
+
+		results, err := verifier.Verify(context.Background(), card)
+		require.NoError(t, err)
+		require.Equal(t, verification.CardVerificationStateValid, results.State)
+		require.Equal(t, lots.Version(), results.LotsVersion)
+		require.False(t, results.Immunization.CounterfeitLot)
+	})
+
+	t.Run("should not meet criteria for a counterfeit lot", func(t *testing.T) {
+		card := makeTestCard(testIssuer)
+		card.Doses[1].LotNumber = "FAKE01"
+
+		results, err := verifier.Verify(context.Background(), card)
+		require.NoError(t, err)
+		require.Equal(t, verification.CardVerificationStateSafetyCriteriaNotMet, results.State)
+		require.True(t, results.Immunization.CounterfeitLot)
+		require.Equal(t, pdm.WarningCodeCounterfeitLot, results.Immunization.Warnings[0].Code)
+		require.Equal(t, 1, results.Immunization.Warnings[0].DoseIndex)
+	})
+}
+
+func Test_VerifierPaperRecord(t *testing.T) {
+
+	verifier, err := verification.NewVerifier(&verification.VerifierConfig{
+		Policy: &verification.Policy{ID: "usa", Region: vaccinemd.RegionUSA},
+	})
+	require.NoError(t, err)
+
+	makeRecord := func() *pdm.PaperRecord {
+		return &pdm.PaperRecord{
+			BirthDate: "01/02/1970",
+			DateOrder: pdm.DateOrderMonthFirst,
+			Doses: []*pdm.PaperDose{
+				{ProductName: "Moderna", LotNumber: "025J20A", Date: "3/16/21"},
+				{ProductName: "Moderna", LotNumber: "030A21A", Date: "4/13/21"},
+			},
+		}
+	}
+
+	t.Run("should verify a paper record", func(t *testing.T) {
+		results, err := verifier.Verify(context.Background(), &verification.Card{PaperRecord: makeRecord()})
+		require.NoError(t, err)
+		require.Equal(t, verification.CardVerificationStatePaperCard, results.State)
+		require.Equal(t, verification.AssuranceLevelPaper, results.AssuranceLevel)
+		require.Empty(t, results.Immunization.Warnings)
+	})
+
+	t.Run("should be self attested", func(t *testing.T) {
+		record := makeRecord()
+		record.SelfAttested = true
+
+		results, err := verifier.Verify(context.Background(), &verification.Card{PaperRecord: record})
+		require.NoError(t, err)
+		require.Equal(t, verification.CardVerificationStatePaperCard, results.State)
+		require.Equal(t, verification.AssuranceLevelSelfAttested, results.AssuranceLevel)
+	})
+
+	t.Run("should report paper warnings", func(t *testing.T) {
+		record := makeRecord()
+		record.Doses[1].LotNumber = ""
+
+		results, err := verifier.Verify(context.Background(), &verification.Card{PaperRecord: record})
+		require.NoError(t, err)
+		require.Equal(t, pdm.WarningCodeMissingLot, results.Immunization.Warnings[0].Code)
+		require.Equal(t, 1, results.Immunization.Warnings[0].DoseIndex)
+	})
+
+	t.Run("should not meet criteria for unrecognized product", func(t *testing.T) {
+		record := makeRecord()
+		record.Doses[0].ProductName = "Acme"
+		record.Doses[1].ProductName = "Acme"
+
+		results, err := verifier.Verify(context.Background(), &verification.Card{PaperRecord: record})
+		require.NoError(t, err)
+		require.Equal(t, verification.CardVerificationStateSafetyCriteriaNotMet, results.State)
+		require.True(t, results.Immunization.UnKnownVaccineType)
+	})
+}
+
+func makeTestCard(issuer string) *verification.Card {
+	return &verification.Card{
+		Issuer:           issuer,
+		PatientBirthDate: "1970-01-01",
+		Doses: []*pdm.Dose{
+			{
+				Coding: vaccinemd.Coding{
+					System: vaccinemd.CVXSystem,
+					Code:   "207", //moderna
+				},
+				OccurrenceDateTime: "2021-03-09",
+			},
+			{
+				Coding: vaccinemd.Coding{
+					System: vaccinemd.CVXSystem,
+					Code:   "207", //moderna
+				},
+				OccurrenceDateTime: "2021-04-06",
+			},
+		},
+	}
+}